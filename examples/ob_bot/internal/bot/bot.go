@@ -219,9 +219,9 @@ func ordersCount(o []Order) int64 {
 
 // checkMoneyBalance - проверка доступного баланса денежных средств
 func (b *Bot) checkMoneyBalance(currency string, required float64) error {
-	operationsService := b.Client.NewOperationsServiceClient()
+	trading := b.Client.NewTrading()
 
-	resp, err := operationsService.GetPositions(b.Client.Config.AccountId)
+	resp, err := trading.GetPositions(context.Background(), b.Client.Config.AccountId)
 	if err != nil {
 		return err
 	}
@@ -235,10 +235,9 @@ func (b *Bot) checkMoneyBalance(currency string, required float64) error {
 	}
 
 	if diff := balance - required; diff < 0 {
-		if strings.HasPrefix(b.Client.Config.EndPoint, "sandbox") {
+		if sandbox, ok := trading.(*investgo.SandboxTrading); ok {
 			units, nano := math.Modf(diff)
-			sandbox := b.Client.NewSandboxServiceClient()
-			resp, err := sandbox.SandboxPayIn(&investgo.SandboxPayInRequest{
+			resp, err := sandbox.PayIn(context.Background(), &investgo.SandboxPayInRequest{
 				AccountId: b.Client.Config.AccountId,
 				Currency:  currency,
 				Unit:      int64(-units),
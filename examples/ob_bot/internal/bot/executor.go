@@ -92,9 +92,9 @@ type Executor struct {
 	wg     *sync.WaitGroup
 	cancel context.CancelFunc
 
-	client            *investgo.Client
-	ordersService     *investgo.OrdersServiceClient
-	operationsService *investgo.OperationsServiceClient
+	client *investgo.Client
+	// trading - торговые операции на контуре из конфигурации: боевом или в песочнице
+	trading investgo.Trading
 }
 
 // NewExecutor - Создание экземпляра исполнителя
@@ -103,15 +103,14 @@ func NewExecutor(ctx context.Context, c *investgo.Client, ids map[string]Instrum
 	wg := &sync.WaitGroup{}
 
 	e := &Executor{
-		instruments:       ids,
-		minProfit:         minProfit,
-		lastPrices:        NewLastPrices(),
-		positions:         NewPositions(),
-		wg:                wg,
-		cancel:            cancel,
-		client:            c,
-		ordersService:     c.NewOrdersServiceClient(),
-		operationsService: c.NewOperationsServiceClient(),
+		instruments: ids,
+		minProfit:   minProfit,
+		lastPrices:  NewLastPrices(),
+		positions:   NewPositions(),
+		wg:          wg,
+		cancel:      cancel,
+		client:      c,
+		trading:     c.NewTrading(),
 	}
 	// Сразу запускаем исполнителя из его же конструктора
 	e.start(ctxExecutor)
@@ -242,7 +241,7 @@ func (e *Executor) listenLastPrices(ctx context.Context) error {
 
 // updatePositionsUnary - Unary метод обновления позиций
 func (e *Executor) updatePositionsUnary() error {
-	resp, err := e.operationsService.GetPositions(e.client.Config.AccountId)
+	resp, err := e.trading.GetPositions(context.Background(), e.client.Config.AccountId)
 	if err != nil {
 		return err
 	}
@@ -301,7 +300,8 @@ func (e *Executor) Buy(id string) error {
 	if !e.possibleToBuy(id) {
 		return nil
 	}
-	resp, err := e.ordersService.Buy(&investgo.PostOrderRequestShort{
+	resp, err := e.trading.PostOrder(context.Background(), &investgo.PostOrderRequest{
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
 		InstrumentId: id,
 		Quantity:     currentInstrument.quantity,
 		Price:        nil,
@@ -334,7 +334,8 @@ func (e *Executor) Sell(id string) (float64, error) {
 		return 0, nil
 	}
 
-	resp, err := e.ordersService.Sell(&investgo.PostOrderRequestShort{
+	resp, err := e.trading.PostOrder(context.Background(), &investgo.PostOrderRequest{
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_SELL,
 		InstrumentId: id,
 		Quantity:     currentInstrument.quantity,
 		Price:        nil,
@@ -398,7 +399,7 @@ func (e *Executor) possibleToBuy(id string) bool {
 // SellOut - Метод выхода из всех ценно-бумажных позиций
 func (e *Executor) SellOut() (float64, error) {
 	// TODO for futures and options
-	resp, err := e.operationsService.GetPositions(e.client.Config.AccountId)
+	resp, err := e.trading.GetPositions(context.Background(), e.client.Config.AccountId)
 	if err != nil {
 		return 0, err
 	}
@@ -417,7 +418,8 @@ func (e *Executor) SellOut() (float64, error) {
 		}
 		balanceInLots := security.GetBalance() / lot
 		if balanceInLots < 0 {
-			resp, err := e.ordersService.Buy(&investgo.PostOrderRequestShort{
+			resp, err := e.trading.PostOrder(context.Background(), &investgo.PostOrderRequest{
+				Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
 				InstrumentId: security.GetInstrumentUid(),
 				Quantity:     -balanceInLots,
 				Price:        nil,
@@ -430,7 +432,8 @@ func (e *Executor) SellOut() (float64, error) {
 				return 0, err
 			}
 		} else {
-			resp, err := e.ordersService.Sell(&investgo.PostOrderRequestShort{
+			resp, err := e.trading.PostOrder(context.Background(), &investgo.PostOrderRequest{
+				Direction:    pb.OrderDirection_ORDER_DIRECTION_SELL,
 				InstrumentId: security.GetInstrumentUid(),
 				Quantity:     balanceInLots,
 				Price:        nil,
@@ -0,0 +1,151 @@
+package investgo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// Environment - Контур InvestAPI: боевой или песочница
+type Environment string
+
+const (
+	// EnvironmentSandbox - Песочница, при отсутствии счетов открывается новый счет
+	EnvironmentSandbox Environment = "sandbox"
+	// EnvironmentProduction - Боевой контур, счет выбирается из UsersService.GetAccounts и никогда не открывается
+	EnvironmentProduction Environment = "production"
+)
+
+const (
+	// SandboxEndPoint - Эндпоинт песочницы
+	SandboxEndPoint = "sandbox-invest-public-api.tinkoff.ru:443"
+	// ProductionEndPoint - Эндпоинт боевого контура
+	ProductionEndPoint = "invest-public-api.tinkoff.ru:443"
+)
+
+// ErrAccountNotFound - Ни один счет не подходит под политику выбора счета
+var ErrAccountNotFound = errors.New("account not found")
+
+// EndPoint - Эндпоинт контура
+func (e Environment) EndPoint() string {
+	if e == EnvironmentProduction {
+		return ProductionEndPoint
+	}
+	return SandboxEndPoint
+}
+
+// Valid - Является ли значение известным контуром, пустое значение допустимо
+func (e Environment) Valid() bool {
+	switch e {
+	case "", EnvironmentSandbox, EnvironmentProduction:
+		return true
+	default:
+		return false
+	}
+}
+
+// AccountSelector - Политика выбора счета, если в конфигурации не указан AccountId. Получает все счета пользователя
+// и возвращает выбранный или ошибку, оборачивающую ErrAccountNotFound
+type AccountSelector func(accounts []*pb.Account) (*pb.Account, error)
+
+// FirstOpenAccount - Первый открытый счет
+func FirstOpenAccount() AccountSelector {
+	return func(accounts []*pb.Account) (*pb.Account, error) {
+		for _, acc := range accounts {
+			if acc.GetStatus() == pb.AccountStatus_ACCOUNT_STATUS_OPEN {
+				return acc, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: no open accounts among %v", ErrAccountNotFound, len(accounts))
+	}
+}
+
+// AccountByType - Первый открытый счет заданного типа
+func AccountByType(accountType pb.AccountType) AccountSelector {
+	return func(accounts []*pb.Account) (*pb.Account, error) {
+		for _, acc := range accounts {
+			if acc.GetStatus() == pb.AccountStatus_ACCOUNT_STATUS_OPEN && acc.GetType() == accountType {
+				return acc, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: no open accounts with type %v", ErrAccountNotFound, accountType)
+	}
+}
+
+// AccountByName - Открытый счет с заданным названием
+func AccountByName(name string) AccountSelector {
+	return func(accounts []*pb.Account) (*pb.Account, error) {
+		for _, acc := range accounts {
+			if acc.GetStatus() == pb.AccountStatus_ACCOUNT_STATUS_OPEN && acc.GetName() == name {
+				return acc, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: no open accounts with name %q", ErrAccountNotFound, name)
+	}
+}
+
+// ParseAccountType - Тип счета из строки: полное имя ACCOUNT_TYPE_TINKOFF_IIS или без префикса, tinkoff_iis
+func ParseAccountType(s string) (pb.AccountType, error) {
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "ACCOUNT_TYPE_") {
+		name = "ACCOUNT_TYPE_" + name
+	}
+	v, ok := pb.AccountType_value[name]
+	if !ok || v == int32(pb.AccountType_ACCOUNT_TYPE_UNSPECIFIED) {
+		return pb.AccountType_ACCOUNT_TYPE_UNSPECIFIED, fmt.Errorf("unknown account type %q", s)
+	}
+	return pb.AccountType(v), nil
+}
+
+// accountSelector - политика выбора счета из конфигурации: по названию, по типу или первый открытый
+func accountSelector(conf Config) (AccountSelector, error) {
+	switch {
+	case conf.AccountName != "":
+		return AccountByName(conf.AccountName), nil
+	case conf.AccountType != "":
+		accountType, err := ParseAccountType(conf.AccountType)
+		if err != nil {
+			return nil, err
+		}
+		return AccountByType(accountType), nil
+	default:
+		return FirstOpenAccount(), nil
+	}
+}
+
+// discoverAccount - выбор счета для клиента без AccountId. В песочнице при отсутствии счетов открывается новый,
+// на боевом контуре счет только выбирается из существующих
+func (c *Client) discoverAccount(selector AccountSelector) (string, error) {
+	if c.Config.Environment == EnvironmentProduction {
+		resp, err := c.NewUsersServiceClient().GetAccounts()
+		if err != nil {
+			return "", err
+		}
+		acc, err := selector(resp.GetAccounts())
+		if err != nil {
+			return "", err
+		}
+		return acc.GetId(), nil
+	}
+
+	s := c.NewSandboxServiceClient()
+	accountsResp, err := s.GetSandboxAccounts()
+	if err != nil {
+		return "", err
+	}
+	accs := accountsResp.GetAccounts()
+	if len(accs) < 1 {
+		resp, err := s.OpenSandboxAccount()
+		if err != nil {
+			return "", err
+		}
+		return resp.GetAccountId(), nil
+	}
+	acc, err := selector(accs)
+	if err != nil {
+		return "", err
+	}
+	return acc.GetId(), nil
+}
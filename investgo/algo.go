@@ -0,0 +1,510 @@
+package investgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// ErrAlgoNotFilled - Алгоритм завершил срезы, но исполнено меньше Quantity лотов
+var ErrAlgoNotFilled = errors.New("algo order is not filled")
+
+const (
+	// DefaultAlgoCancelTimeout - Сколько алгоритм ждет конечного статуса дочерней заявки после отмены
+	DefaultAlgoCancelTimeout = 5 * time.Second
+	// DefaultVolumeProfileDays - За сколько торговых дней строится профиль объема для VWAP
+	DefaultVolumeProfileDays = 5
+)
+
+// CandleSource - Исторические свечи для профиля объема VWAP. Ее реализует MarketDataServiceClient
+type CandleSource interface {
+	GetCandlesWithContext(ctx context.Context, instrumentId string, interval pb.CandleInterval, from, to time.Time) (*GetCandlesResponse, error)
+}
+
+// AlgoRequest - Запрос на алгоритмическое исполнение крупной заявки
+type AlgoRequest struct {
+	// Id - Имя исполнения в приложении. Из него и номера дочерней заявки получаются ее order_request_id
+	// через OrderKey. Если пустой, создается через CreateUid
+	Id           string
+	AccountId    string
+	InstrumentId string
+	Direction    pb.OrderDirection
+	// Quantity - Общее количество лотов, срезы всегда содержат целое число лотов
+	Quantity int64
+	// LimitPrice - Цена лимитных дочерних заявок, округляется к min_price_increment в сторону, не худшую для
+	// заявки. Если nil - дочерние заявки рыночные, для Iceberg цена обязательна
+	LimitPrice *pb.Quotation
+}
+
+// AlgoProgress - Ход исполнения алгоритма
+type AlgoProgress struct {
+	Id            string
+	LotsRequested int64
+	LotsExecuted  int64
+	// LotsPending - Неисполненные лоты дочерних заявок, выставление которых не подтверждено (TrackedOrder.Pending).
+	// Пока OrderTracker их не найдет, они считаются исполненными и повторно не выставляются
+	LotsPending int64
+	// Orders - order_request_id дочерних заявок, их состояния доступны через OrderTracker
+	Orders []string
+	// Done - Алгоритм завершил работу
+	Done bool
+}
+
+// AlgoOption - Параметры AlgoExecutor
+type AlgoOption func(*AlgoExecutor)
+
+// WithAlgoCandles - Источник свечей для профиля объема VWAP
+func WithAlgoCandles(candles CandleSource) AlgoOption {
+	return func(e *AlgoExecutor) {
+		e.candles = candles
+	}
+}
+
+// WithAlgoProfileDays - За сколько торговых дней строится профиль объема для VWAP
+func WithAlgoProfileDays(days int) AlgoOption {
+	return func(e *AlgoExecutor) {
+		if days > 0 {
+			e.profileDays = days
+		}
+	}
+}
+
+// WithAlgoCancelTimeout - Сколько ждать конечного статуса дочерней заявки после отмены
+func WithAlgoCancelTimeout(d time.Duration) AlgoOption {
+	return func(e *AlgoExecutor) {
+		e.cancelTimeout = d
+	}
+}
+
+// WithAlgoCallback - Функция, которая вызывается после каждого среза и по завершении алгоритма
+func WithAlgoCallback(fn func(p AlgoProgress)) AlgoOption {
+	return func(e *AlgoExecutor) {
+		e.callbacks = append(e.callbacks, fn)
+	}
+}
+
+// WithAlgoLogger - Логгер для ошибок отмены дочерних заявок
+func WithAlgoLogger(l Logger) AlgoOption {
+	return func(e *AlgoExecutor) {
+		e.logger = l
+	}
+}
+
+// AlgoExecutor - Алгоритмическое исполнение крупных заявок: TWAP, VWAP и Iceberg. Дочерние заявки выставляются
+// через OrderTracker, поэтому их статусы приходят в те же WithOrderCallback и tracker.Subscribe, что и статусы
+// остальных заявок. Алгоритмы блокируют вызывающую горутину до завершения или отмены ctx, при отмене ctx
+// активная дочерняя заявка отменяется
+type AlgoExecutor struct {
+	tracker       *OrderTracker
+	instruments   InstrumentSource
+	candles       CandleSource
+	profileDays   int
+	cancelTimeout time.Duration
+	callbacks     []func(p AlgoProgress)
+	logger        Logger
+}
+
+// NewAlgoExecutor - Алгоритмическое исполнение с дочерними заявками через tracker и шагом цены из instruments.
+// Для работы нужен запущенный Run у tracker
+func NewAlgoExecutor(tracker *OrderTracker, instruments InstrumentSource, opts ...AlgoOption) *AlgoExecutor {
+	e := &AlgoExecutor{
+		tracker:       tracker,
+		instruments:   instruments,
+		profileDays:   DefaultVolumeProfileDays,
+		cancelTimeout: DefaultAlgoCancelTimeout,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// NewAlgoExecutor - Алгоритмическое исполнение со свечами для VWAP из MarketDataService
+func (c *Client) NewAlgoExecutor(tracker *OrderTracker, opts ...AlgoOption) *AlgoExecutor {
+	return NewAlgoExecutor(tracker, c.NewInstrumentsServiceClient(),
+		append([]AlgoOption{WithAlgoCandles(c.NewMarketDataServiceClient()), WithAlgoLogger(c.Logger)}, opts...)...)
+}
+
+// TWAP - Исполнение равными по количеству срезами через равные промежутки времени в течение duration.
+// Неисполненный остаток лимитной заявки отменяется и переносится в следующий срез. Если остаток не исполнен
+// и в последнем срезе, возвращается ход исполнения и ErrAlgoNotFilled
+func (e *AlgoExecutor) TWAP(ctx context.Context, req AlgoRequest, duration time.Duration, slices int) (AlgoProgress, error) {
+	if slices <= 0 {
+		return AlgoProgress{}, errors.New("twap slices must be positive")
+	}
+	weights := make([]float64, slices)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return e.schedule(ctx, req, weights, duration/time.Duration(slices))
+}
+
+// VWAP - Исполнение срезами через равные промежутки времени в течение duration, количество в срезе
+// пропорционально среднему объему торгов в это время дня по свечам за WithAlgoProfileDays торговых дней.
+// duration не больше суток. Остаток после последнего среза - как в TWAP
+func (e *AlgoExecutor) VWAP(ctx context.Context, req AlgoRequest, duration time.Duration, slices int) (AlgoProgress, error) {
+	if slices <= 0 {
+		return AlgoProgress{}, errors.New("vwap slices must be positive")
+	}
+	weights, err := e.VolumeProfile(ctx, req.InstrumentId, time.Now(), duration, slices)
+	if err != nil {
+		return AlgoProgress{}, err
+	}
+	return e.schedule(ctx, req, weights, duration/time.Duration(slices))
+}
+
+// VolumeProfile - Доли объема торгов для slices равных промежутков с начала start в течение duration
+// по минутным свечам за WithAlgoProfileDays прошлых торговых дней. Если свечей нет, доли равные
+func (e *AlgoExecutor) VolumeProfile(ctx context.Context, instrumentId string, start time.Time, duration time.Duration, slices int) ([]float64, error) {
+	if e.candles == nil {
+		return nil, errors.New("vwap needs candles, use WithAlgoCandles")
+	}
+	if duration <= 0 || duration > 24*time.Hour {
+		return nil, errors.New("vwap duration must be positive and at most 24h")
+	}
+	step := duration / time.Duration(slices)
+	weights := make([]float64, slices)
+	var total float64
+	// выходные и праздники пропускаются, поэтому дней назад просматривается больше, чем нужно торговых дней
+	for days, back := 0, 1; days < e.profileDays && back <= 2*e.profileDays+7; back++ {
+		from := start.AddDate(0, 0, -back)
+		resp, err := e.candles.GetCandlesWithContext(ctx, instrumentId, pb.CandleInterval_CANDLE_INTERVAL_1_MIN, from, from.Add(duration))
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.GetCandles()) == 0 {
+			continue
+		}
+		days++
+		for _, c := range resp.GetCandles() {
+			i := int(c.GetTime().AsTime().Sub(from) / step)
+			if i < 0 || i >= slices {
+				continue
+			}
+			weights[i] += float64(c.GetVolume())
+			total += float64(c.GetVolume())
+		}
+	}
+	if total == 0 {
+		for i := range weights {
+			weights[i] = 1 / float64(slices)
+		}
+		return weights, nil
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+	return weights, nil
+}
+
+// Iceberg - Исполнение лимитными заявками, из которых на бирже видно не больше visible лотов. Когда в заявке
+// остается половина видимого количества или меньше, она доливается до visible через ReplaceOrder, после
+// полного исполнения выставляется следующая заявка. Завершается после исполнения Quantity лотов
+func (e *AlgoExecutor) Iceberg(ctx context.Context, req AlgoRequest, visible int64) (AlgoProgress, error) {
+	if req.LimitPrice == nil {
+		return AlgoProgress{}, errors.New("iceberg needs limit price")
+	}
+	if visible <= 0 {
+		return AlgoProgress{}, errors.New("iceberg visible quantity must be positive")
+	}
+	r, err := e.start(ctx, req)
+	if err != nil {
+		return AlgoProgress{}, err
+	}
+	sub := e.tracker.Subscribe(0, OverflowDropOldest)
+	defer e.tracker.Unsubscribe(sub)
+	ticker := time.NewTicker(DefaultReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		committed := r.committed()
+		if committed >= req.Quantity {
+			return r.finish(), nil
+		}
+		left := req.Quantity - committed
+		o, ok := e.tracker.Order(r.current)
+		switch {
+		case ok && o.Pending():
+			// заявка без ответа PostOrder, ждем, пока OrderTracker ее найдет
+		case !ok || o.Filled():
+			if err := r.post(ctx, minLots(visible, left)); err != nil {
+				return r.stop(err)
+			}
+			e.report(r.progress(false))
+		case o.Done():
+			// заявка отменена или отклонена в обход алгоритма
+			return r.stop(fmt.Errorf("iceberg order %s: %w", o.OrderId, o.Err()))
+		case o.LotsRequested-o.LotsExecuted <= visible/2 && left > o.LotsRequested-o.LotsExecuted:
+			// исполнения между чтением состояния и ReplaceOrder могут превысить Quantity не больше чем на visible
+			if err := r.replace(ctx, minLots(visible, left)); err != nil && !errors.Is(err, ErrOrderNotFound) {
+				return r.stop(err)
+			}
+			e.report(r.progress(false))
+		}
+
+		select {
+		case <-ctx.Done():
+			return r.stop(ctx.Err())
+		case _, ok := <-sub.Updates():
+			if !ok {
+				return r.stop(errors.New("order tracker closed"))
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// schedule - исполнение срезами по весам weights через промежутки step
+func (e *AlgoExecutor) schedule(ctx context.Context, req AlgoRequest, weights []float64, step time.Duration) (AlgoProgress, error) {
+	r, err := e.start(ctx, req)
+	if err != nil {
+		return AlgoProgress{}, err
+	}
+	var total, cumulative float64
+	for _, w := range weights {
+		total += w
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for i, w := range weights {
+		select {
+		case <-ctx.Done():
+			return r.stop(ctx.Err())
+		case <-timer.C:
+		}
+		timer.Reset(step)
+		// неисполненная часть прошлого среза отменяется и переносится в этот
+		if err := r.complete(ctx); err != nil {
+			return r.stop(err)
+		}
+		cumulative += w
+		target := req.Quantity
+		if i < len(weights)-1 && total > 0 {
+			target = int64(math.Round(float64(req.Quantity) * cumulative / total))
+		}
+		if lots := target - r.committed(); lots > 0 {
+			if err := r.post(ctx, lots); err != nil {
+				return r.stop(err)
+			}
+		}
+		e.report(r.progress(false))
+	}
+	// последний срез получает тот же промежуток времени, что и остальные
+	if req.LimitPrice != nil {
+		select {
+		case <-ctx.Done():
+			return r.stop(ctx.Err())
+		case <-timer.C:
+		}
+	}
+	if err := r.complete(ctx); err != nil {
+		return r.stop(err)
+	}
+	if executed := r.executed(); executed < req.Quantity {
+		return r.finish(), fmt.Errorf("%w: %d of %d lots executed", ErrAlgoNotFilled, executed, req.Quantity)
+	}
+	return r.finish(), nil
+}
+
+// start - подготовка исполнения: проверка запроса и округление цены
+func (e *AlgoExecutor) start(ctx context.Context, req AlgoRequest) (*algoRun, error) {
+	switch {
+	case req.Quantity <= 0:
+		return nil, errors.New("algo quantity must be positive")
+	case req.Direction != pb.OrderDirection_ORDER_DIRECTION_BUY && req.Direction != pb.OrderDirection_ORDER_DIRECTION_SELL:
+		return nil, errors.New("algo direction must be buy or sell")
+	}
+	if req.Id == "" {
+		req.Id = CreateUid()
+	}
+	r := &algoRun{e: e, req: req}
+	if req.LimitPrice != nil {
+		inst, err := e.instruments.InstrumentWithContext(ctx, req.InstrumentId)
+		if err != nil {
+			return nil, err
+		}
+		mode := RoundDown
+		if req.Direction == pb.OrderDirection_ORDER_DIRECTION_SELL {
+			mode = RoundUp
+		}
+		if r.price, err = RoundQuotation(req.LimitPrice, inst.GetMinPriceIncrement(), mode); err != nil {
+			return nil, fmt.Errorf("instrument %s: %w", req.InstrumentId, err)
+		}
+	}
+	return r, nil
+}
+
+// report - вызов функций WithAlgoCallback
+func (e *AlgoExecutor) report(p AlgoProgress) {
+	for _, fn := range e.callbacks {
+		fn(p)
+	}
+}
+
+// algoRun - состояние одного исполнения алгоритма
+type algoRun struct {
+	e     *AlgoExecutor
+	req   AlgoRequest
+	price *pb.Quotation
+
+	mu      sync.Mutex
+	orders  []string
+	current string
+}
+
+// post - выставление следующей дочерней заявки на lots лотов. Если ответ PostOrder не получен и заявка могла быть
+// выставлена, ошибка не возвращается: заявка учитывается в committed, пока OrderTracker ее не найдет
+func (r *algoRun) post(ctx context.Context, lots int64) error {
+	orderType := pb.OrderType_ORDER_TYPE_MARKET
+	if r.price != nil {
+		orderType = pb.OrderType_ORDER_TYPE_LIMIT
+	}
+	o, err := r.e.tracker.PostOrder(ctx, &PostOrderRequest{
+		InstrumentId: r.req.InstrumentId,
+		Quantity:     lots,
+		Price:        r.price,
+		Direction:    r.req.Direction,
+		AccountId:    r.req.AccountId,
+		OrderType:    orderType,
+		OrderId:      r.nextKey(),
+	})
+	if err != nil {
+		// OrderTracker отслеживает заявку, только если она могла быть выставлена
+		if _, ok := r.e.tracker.Order(o.RequestId); !ok {
+			return err
+		}
+		if r.e.logger != nil {
+			logWarn(r.e.logger, "algo order outcome is unknown", FieldRequestId, o.RequestId, FieldInstrumentId, r.req.InstrumentId, FieldError, err)
+		}
+	}
+	r.mu.Lock()
+	r.current = o.RequestId
+	r.mu.Unlock()
+	return nil
+}
+
+// replace - замена текущей дочерней заявки на заявку на lots лотов
+func (r *algoRun) replace(ctx context.Context, lots int64) error {
+	// цена дочерних заявок в тех же единицах, что и в PostOrder
+	o, err := r.e.tracker.ReplaceOrder(ctx, r.current, r.nextKey(), lots, r.price, pb.PriceType_PRICE_TYPE_UNSPECIFIED)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.current = o.RequestId
+	r.mu.Unlock()
+	return nil
+}
+
+// nextKey - order_request_id следующей дочерней заявки
+func (r *algoRun) nextKey() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := OrderKey(fmt.Sprintf("algo/%s/%d", r.req.Id, len(r.orders)))
+	r.orders = append(r.orders, key)
+	return key
+}
+
+// complete - ожидание конечного статуса текущей дочерней заявки: рыночная заявка дожидается исполнения,
+// лимитная отменяется. Ожидающая заявка, которой нет среди активных, остается в committed
+func (r *algoRun) complete(ctx context.Context) error {
+	o, ok := r.e.tracker.Order(r.current)
+	if !ok || o.Done() {
+		return nil
+	}
+	if r.price == nil {
+		if o, err := r.e.tracker.AwaitDone(ctx, r.current, r.e.cancelTimeout); err == nil || o.Done() {
+			return nil
+		}
+	}
+	if err := r.e.tracker.CancelOrder(ctx, r.current); err != nil && !errors.Is(err, ErrOrderNotFound) {
+		return err
+	}
+	if o, _ := r.e.tracker.Order(r.current); o.Pending() {
+		return nil
+	}
+	if _, err := r.e.tracker.AwaitDone(ctx, r.current, r.e.cancelTimeout); err != nil {
+		return fmt.Errorf("algo order %s is not done after cancel: %w", r.current, err)
+	}
+	return nil
+}
+
+// stop - отмена текущей дочерней заявки при ошибке или отмене ctx
+func (r *algoRun) stop(err error) (AlgoProgress, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.e.cancelTimeout)
+	defer cancel()
+	if o, ok := r.e.tracker.Order(r.current); ok && !o.Done() {
+		if cancelErr := r.e.tracker.CancelOrder(ctx, r.current); cancelErr != nil && !errors.Is(cancelErr, ErrOrderNotFound) {
+			if r.e.logger != nil {
+				logError(r.e.logger, "cancel algo order", FieldOrderId, o.OrderId, FieldInstrumentId, r.req.InstrumentId, FieldError, cancelErr)
+			}
+		}
+	}
+	p := r.finish()
+	return p, err
+}
+
+// finish - итоговый ход исполнения
+func (r *algoRun) finish() AlgoProgress {
+	p := r.progress(true)
+	r.e.report(p)
+	return p
+}
+
+// lots - исполненные лоты всех дочерних заявок и неисполненные лоты ожидающих заявок
+func (r *algoRun) lots() (executed, pending int64) {
+	r.mu.Lock()
+	orders := append([]string(nil), r.orders...)
+	r.mu.Unlock()
+	for _, id := range orders {
+		o, ok := r.e.tracker.Order(id)
+		if !ok {
+			continue
+		}
+		executed += o.LotsExecuted
+		if o.Pending() {
+			pending += o.LotsRequested - o.LotsExecuted
+		}
+	}
+	return executed, pending
+}
+
+// executed - исполненные лоты всех дочерних заявок
+func (r *algoRun) executed() int64 {
+	executed, _ := r.lots()
+	return executed
+}
+
+// committed - исполненные лоты и лоты, которые еще могут исполниться по ожидающим заявкам. По ним считается
+// остаток, чтобы не выставить лишнего
+func (r *algoRun) committed() int64 {
+	executed, pending := r.lots()
+	return executed + pending
+}
+
+func (r *algoRun) progress(done bool) AlgoProgress {
+	executed, pending := r.lots()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return AlgoProgress{
+		Id:            r.req.Id,
+		LotsRequested: r.req.Quantity,
+		LotsExecuted:  executed,
+		LotsPending:   pending,
+		Orders:        append([]string(nil), r.orders...),
+		Done:          done,
+	}
+}
+
+// minLots - меньшее из количеств лотов
+func minLots(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,214 @@
+package investgo_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// candleStub - Свечи в начале промежутков step за каждый день, объем по номеру промежутка из volume
+type candleStub struct {
+	step   time.Duration
+	volume map[int]int64
+}
+
+func (c candleStub) GetCandlesWithContext(_ context.Context, _ string, _ pb.CandleInterval, from, _ time.Time) (*investgo.GetCandlesResponse, error) {
+	resp := &pb.GetCandlesResponse{}
+	for i, v := range c.volume {
+		resp.Candles = append(resp.Candles, &pb.HistoricCandle{
+			Time:   timestamppb.New(from.Add(time.Duration(i) * c.step)),
+			Volume: v,
+		})
+	}
+	return &investgo.GetCandlesResponse{GetCandlesResponse: resp}, nil
+}
+
+func algoRequest(srv *fake.Server, quantity int64, price *pb.Quotation) investgo.AlgoRequest {
+	return investgo.AlgoRequest{
+		Id:           "algo-1",
+		AccountId:    srv.AccountId(),
+		InstrumentId: testFigi,
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+		Quantity:     quantity,
+		LimitPrice:   price,
+	}
+}
+
+// childLots - Запрошенные лоты дочерних заявок в порядке выставления
+func childLots(tracker *investgo.OrderTracker, p investgo.AlgoProgress) []int64 {
+	lots := make([]int64, 0, len(p.Orders))
+	for _, id := range p.Orders {
+		o, _ := tracker.Order(id)
+		lots = append(lots, o.LotsRequested)
+	}
+	return lots
+}
+
+func equalLots(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTWAPMarketSlices(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	tracker := runTracker(t, client)
+	var reports int
+	e := client.NewAlgoExecutor(tracker, investgo.WithAlgoCallback(func(investgo.AlgoProgress) { reports++ }))
+
+	p, err := e.TWAP(context.Background(), algoRequest(srv, 5, nil), 40*time.Millisecond, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Done || p.LotsExecuted != 5 {
+		t.Fatalf("progress = %+v, want 5 lots executed", p)
+	}
+	// округление накопленной доли: 1.25, 2.5, 3.75, 5
+	if got := childLots(tracker, p); !equalLots(got, []int64{1, 2, 1, 1}) {
+		t.Fatalf("child orders = %v, want [1 2 1 1]", got)
+	}
+	if reports != 5 {
+		t.Fatalf("reports = %d, want 4 slices and the result", reports)
+	}
+	if pos := srv.PositionBalance(srv.AccountId(), testFigi); pos != 50 {
+		t.Fatalf("position = %d, want 50", pos)
+	}
+}
+
+func TestTWAPPendingChild(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	trading := &lostResponse{Trading: client.NewTrading()}
+	tracker := startStreamTracker(t, client, srv, investgo.NewOrderTracker(trading, investgo.WithPendingTimeout(time.Minute)))
+	eventually(t, "trades stream is not connected", func() bool {
+		o, err := tracker.PostOrder(context.Background(), marketBuy(srv, "", 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		o, _ = tracker.Order(o.RequestId)
+		return len(o.Trades) > 0
+	})
+	before := srv.PositionBalance(srv.AccountId(), testFigi)
+
+	// ответ на первую дочернюю заявку потерян, она исполнена и не выставляется повторно
+	trading.mu.Lock()
+	trading.lost, trading.place = 1, true
+	trading.mu.Unlock()
+	p, err := client.NewAlgoExecutor(tracker).TWAP(context.Background(), algoRequest(srv, 4, nil), 40*time.Millisecond, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.LotsExecuted != 4 || p.LotsPending != 0 {
+		t.Fatalf("progress = %+v, want 4 lots executed", p)
+	}
+	if got := childLots(tracker, p); !equalLots(got, []int64{2, 2}) {
+		t.Fatalf("child orders = %v, want [2 2]", got)
+	}
+	if pos := srv.PositionBalance(srv.AccountId(), testFigi) - before; pos != 40 {
+		t.Fatalf("position change = %d, want 40", pos)
+	}
+}
+
+func TestTWAPLimitNotFilled(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	tracker := runTracker(t, client)
+	e := client.NewAlgoExecutor(tracker)
+
+	// цена ниже рынка: срезы не исполняются, остаток переносится и отменяется после последнего среза
+	p, err := e.TWAP(context.Background(), algoRequest(srv, 2, fake.Quotation(240)), 40*time.Millisecond, 2)
+	if !errors.Is(err, investgo.ErrAlgoNotFilled) {
+		t.Fatalf("err = %v, want ErrAlgoNotFilled", err)
+	}
+	if !p.Done || p.LotsExecuted != 0 {
+		t.Fatalf("progress = %+v, want nothing executed", p)
+	}
+	if got := childLots(tracker, p); !equalLots(got, []int64{1, 2}) {
+		t.Fatalf("child orders = %v, want [1 2]", got)
+	}
+	for _, id := range p.Orders {
+		if o, _ := tracker.Order(id); o.Status != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED {
+			t.Fatalf("order %s status = %v, want cancelled", id, o.Status)
+		}
+	}
+}
+
+func TestVWAPSlicesByVolume(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	tracker := runTracker(t, client)
+	const duration = 40 * time.Millisecond
+	candles := candleStub{step: duration / 2, volume: map[int]int64{0: 300, 1: 100}}
+	e := client.NewAlgoExecutor(tracker, investgo.WithAlgoCandles(candles))
+
+	weights, err := e.VolumeProfile(context.Background(), testFigi, time.Now(), duration, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weights) != 2 || weights[0] != 0.75 || weights[1] != 0.25 {
+		t.Fatalf("weights = %v, want [0.75 0.25]", weights)
+	}
+
+	p, err := e.VWAP(context.Background(), algoRequest(srv, 4, nil), duration, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := childLots(tracker, p); p.LotsExecuted != 4 || !equalLots(got, []int64{3, 1}) {
+		t.Fatalf("progress = %+v, child orders = %v, want [3 1]", p, got)
+	}
+
+	if _, err := client.NewAlgoExecutor(tracker).VWAP(context.Background(), algoRequest(srv, 4, nil), 25*time.Hour, 2); err == nil {
+		t.Fatal("want error for duration over 24h")
+	}
+}
+
+// replaceRecorder - Trading, который запоминает запросы ReplaceOrder
+type replaceRecorder struct {
+	investgo.Trading
+	mu   sync.Mutex
+	reqs []investgo.ReplaceOrderRequest
+}
+
+func (r *replaceRecorder) ReplaceOrder(ctx context.Context, req *investgo.ReplaceOrderRequest) (*investgo.PostOrderResponse, error) {
+	r.mu.Lock()
+	r.reqs = append(r.reqs, *req)
+	r.mu.Unlock()
+	return r.Trading.ReplaceOrder(ctx, req)
+}
+
+func TestOrderTrackerReplacePriceType(t *testing.T) {
+	srv := newTestServer(t)
+	rec := &replaceRecorder{Trading: newTestClient(t, srv.Config()).NewTrading()}
+	tracker := investgo.NewOrderTracker(rec)
+	t.Cleanup(tracker.Close)
+
+	o := postLimit(t, tracker, srv, 240)
+	replaced, err := tracker.ReplaceOrder(context.Background(), o.RequestId, "", 1, fake.Quotation(241), pb.PriceType_PRICE_TYPE_POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.OrderId == o.OrderId || replaced.RequestId == "" {
+		t.Fatalf("replaced order = %+v, want a new order", replaced)
+	}
+	if len(rec.reqs) != 1 || rec.reqs[0].PriceType != pb.PriceType_PRICE_TYPE_POINT || rec.reqs[0].NewOrderId != replaced.RequestId {
+		t.Fatalf("ReplaceOrder requests = %+v, want one with the passed price type", rec.reqs)
+	}
+	if _, err := tracker.ReplaceOrder(context.Background(), "unknown", "", 1, nil, pb.PriceType_PRICE_TYPE_UNSPECIFIED); !errors.Is(err, investgo.ErrOrderNotTracked) {
+		t.Fatalf("err = %v, want ErrOrderNotTracked", err)
+	}
+}
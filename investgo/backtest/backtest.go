@@ -0,0 +1,343 @@
+// Package backtest - Воспроизведение истории для стратегий, которые торгуют через investgo.Trading.
+// Исторические свечи, стаканы и сделки передаются стратегии в виде сообщений MarketDataStream по времени
+// событий, часы стратегии показывают время воспроизводимого события, а заявки исполняет paper.Broker
+// с комиссией, проскальзыванием, лотностью и шагом цены инструментов.
+//
+// Стратегия не знает, где она запущена: на истории ее запускает Run, на рынке - RunLive с торговлей из
+// Client.NewTrading и каналом подписчика MarketDataStream:
+//
+//	res, err := backtest.Run(ctx, backtest.Config{
+//		Instruments:  []*pb.Instrument{inst},
+//		InitialMoney: decimal.NewFromInt(100000),
+//		Commission:   decimal.RequireFromString("0.0005"),
+//	}, strategy, backtest.Candles(inst, pb.CandleInterval_CANDLE_INTERVAL_1_MIN, candles))
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/paper"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// DefaultCurrency - Валюта счета и кривой доходности по умолчанию
+const DefaultCurrency = "rub"
+
+// Strategy - Торговая стратегия. Торгует через Env.Trading и получает биржевую информацию в виде сообщений
+// MarketDataStream, поэтому запускается и на истории через Run, и на рынке через RunLive без изменений
+type Strategy interface {
+	// Start - Вызывается один раз до первого сообщения
+	Start(ctx context.Context, env Env) error
+	// OnMarketData - Новое сообщение биржевой информации
+	OnMarketData(ctx context.Context, resp *pb.MarketDataResponse) error
+}
+
+// TradesHandler - Стратегия, которая получает события об исполнении своих заявок, как из TradesStream
+type TradesHandler interface {
+	OnOrderTrades(ctx context.Context, trades *pb.OrderTrades) error
+}
+
+// Env - Окружение стратегии
+type Env struct {
+	// Trading - Торговые операции по счету
+	Trading investgo.Trading
+	// AccountId - Идентификатор счета для запросов Trading
+	AccountId string
+	// Now - Текущее время. На истории - время воспроизводимого события, стратегия не должна вызывать time.Now
+	Now func() time.Time
+}
+
+// Config - Параметры воспроизведения истории
+type Config struct {
+	// Instruments - Инструменты, по которым торгует стратегия. Лотность, шаг цены и валюта берутся из них
+	Instruments []*pb.Instrument
+	// AccountId - Идентификатор счета, по умолчанию paper.DefaultAccountId
+	AccountId string
+	// Currency - Валюта начальных денег и кривой доходности, по умолчанию DefaultCurrency
+	Currency string
+	// InitialMoney - Деньги на счете в начале
+	InitialMoney decimal.Decimal
+	// Commission - Комиссия в долях от суммы сделки, например 0.0005 для 0.05%
+	Commission decimal.Decimal
+	// Slippage - Проскальзывание рыночных заявок в долях от цены
+	Slippage decimal.Decimal
+}
+
+// Trade - Сделка из журнала сделок
+type Trade struct {
+	Time          time.Time
+	OrderId       string
+	TradeId       string
+	Figi          string
+	InstrumentUid string
+	Direction     pb.OrderDirection
+	Price         decimal.Decimal
+	// Quantity - Количество в штуках
+	Quantity   int64
+	Commission decimal.Decimal
+}
+
+// EquityPoint - Стоимость портфеля после обработки всех событий на момент Time
+type EquityPoint struct {
+	Time   time.Time
+	Equity decimal.Decimal
+}
+
+// Result - Результат воспроизведения истории
+type Result struct {
+	// InitialMoney - Деньги на счете в начале
+	InitialMoney decimal.Decimal
+	// Equity - Кривая доходности в валюте Config.Currency, одна точка на каждый момент времени с событиями
+	Equity []EquityPoint
+	// Trades - Журнал сделок
+	Trades []Trade
+	// Portfolio - Портфель в конце воспроизведения
+	Portfolio *pb.PortfolioResponse
+}
+
+// Profit - Изменение стоимости портфеля от начальных денег до конца воспроизведения
+func (r *Result) Profit() decimal.Decimal {
+	if len(r.Equity) == 0 {
+		return decimal.Zero
+	}
+	return r.Equity[len(r.Equity)-1].Equity.Sub(r.InitialMoney)
+}
+
+// MaxDrawdown - Максимальная просадка стоимости портфеля от предыдущего максимума в долях
+func (r *Result) MaxDrawdown() decimal.Decimal {
+	peak, maxDrawdown := r.InitialMoney, decimal.Zero
+	for _, p := range r.Equity {
+		if p.Equity.GreaterThan(peak) {
+			peak = p.Equity
+		}
+		if !peak.IsPositive() {
+			continue
+		}
+		if dd := peak.Sub(p.Equity).Div(peak); dd.GreaterThan(maxDrawdown) {
+			maxDrawdown = dd
+		}
+	}
+	return maxDrawdown
+}
+
+// Run - Воспроизведение событий источников sources для стратегии. События из разных источников объединяются
+// по времени, при равном времени раньше идут события источника, переданного раньше. Для каждого события
+// сначала исполняются активные заявки, затем событие получает стратегия, затем - события об исполнении заявок.
+// При ошибке стратегии или источника воспроизведение останавливается и возвращается результат на этот момент
+func Run(ctx context.Context, conf Config, strategy Strategy, sources ...Source) (*Result, error) {
+	currency := strings.ToLower(conf.Currency)
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	portfolioCurrency, ok := pb.PortfolioRequest_CurrencyRequest_value[strings.ToUpper(currency)]
+	if !ok {
+		return nil, fmt.Errorf("backtest: unsupported currency %q", conf.Currency)
+	}
+	accountId := conf.AccountId
+	if accountId == "" {
+		accountId = paper.DefaultAccountId
+	}
+
+	r := &runner{
+		strategy: strategy,
+		result:   &Result{InitialMoney: conf.InitialMoney},
+	}
+	r.broker = paper.NewBroker(
+		paper.WithAccountId(accountId),
+		paper.WithCommission(conf.Commission),
+		paper.WithSlippage(conf.Slippage),
+		paper.WithClock(r.clock.Now),
+		paper.WithTradesHandler(func(t *pb.OrderTrades) {
+			r.record(t, conf.Commission)
+		}),
+	)
+	for _, inst := range conf.Instruments {
+		r.broker.AddInstrument(inst)
+	}
+	r.broker.PayIn(currency, conf.InitialMoney)
+
+	equity := func(t time.Time) error {
+		resp, err := r.broker.GetPortfolio(ctx, accountId, pb.PortfolioRequest_CurrencyRequest(portfolioCurrency))
+		if err != nil {
+			return err
+		}
+		r.result.Portfolio = resp.PortfolioResponse
+		r.result.Equity = append(r.result.Equity, EquityPoint{
+			Time:   t,
+			Equity: resp.GetTotalAmountPortfolio().ToDecimal(),
+		})
+		return nil
+	}
+
+	src := merge(sources)
+	started := false
+	var current time.Time
+	for {
+		if err := ctx.Err(); err != nil {
+			return r.result, err
+		}
+		e, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return r.result, err
+		}
+		if !started {
+			started = true
+			r.clock.set(e.Time)
+			err = strategy.Start(ctx, Env{
+				Trading:   r.broker,
+				AccountId: accountId,
+				Now:       r.clock.Now,
+			})
+			if err != nil {
+				return r.result, err
+			}
+			if err := r.deliver(ctx); err != nil {
+				return r.result, err
+			}
+		} else if e.Time.After(current) {
+			if err := equity(current); err != nil {
+				return r.result, err
+			}
+		}
+		current = e.Time
+		r.clock.set(e.Time)
+
+		r.broker.OnMarketData(e.Data)
+		if err := r.deliver(ctx); err != nil {
+			return r.result, err
+		}
+		if err := strategy.OnMarketData(ctx, e.Data); err != nil {
+			return r.result, err
+		}
+		if err := r.deliver(ctx); err != nil {
+			return r.result, err
+		}
+	}
+	if started {
+		if err := equity(current); err != nil {
+			return r.result, err
+		}
+	}
+	return r.result, nil
+}
+
+// runner - состояние одного воспроизведения
+type runner struct {
+	strategy Strategy
+	broker   *paper.Broker
+	clock    clock
+	result   *Result
+
+	// mu - защищает pending и журнал сделок, если стратегия выставляет заявки из своих горутин
+	mu      sync.Mutex
+	pending []*pb.OrderTrades
+}
+
+// record - запись исполнения в журнал сделок, вызывается брокером синхронно
+func (r *runner) record(t *pb.OrderTrades, commission decimal.Decimal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, tr := range t.GetTrades() {
+		price := tr.GetPrice().ToDecimal()
+		r.result.Trades = append(r.result.Trades, Trade{
+			Time:          tr.GetDateTime().AsTime(),
+			OrderId:       t.GetOrderId(),
+			TradeId:       tr.GetTradeId(),
+			Figi:          t.GetFigi(),
+			InstrumentUid: t.GetInstrumentUid(),
+			Direction:     t.GetDirection(),
+			Price:         price,
+			Quantity:      tr.GetQuantity(),
+			Commission:    price.Mul(decimal.NewFromInt(tr.GetQuantity())).Mul(commission),
+		})
+	}
+	r.pending = append(r.pending, t)
+}
+
+// deliver - передача стратегии событий об исполнении, в том числе вызванных ее ответами на эти события
+func (r *runner) deliver(ctx context.Context) error {
+	handler, ok := r.strategy.(TradesHandler)
+	for {
+		r.mu.Lock()
+		if len(r.pending) == 0 {
+			r.mu.Unlock()
+			return nil
+		}
+		t := r.pending[0]
+		r.pending = r.pending[1:]
+		r.mu.Unlock()
+		if !ok {
+			continue
+		}
+		if err := handler.OnOrderTrades(ctx, t); err != nil {
+			return err
+		}
+	}
+}
+
+// RunLive - Запуск стратегии на рынке: сообщения из канала подписчика MarketDataStream updates и события
+// TradesStream trades передаются стратегии до отмены ctx, закрытия updates или ошибки стратегии.
+// trades может быть nil, если стратегии не нужны события об исполнении. Если env.Now не задан, используется time.Now
+func RunLive(ctx context.Context, env Env, strategy Strategy, updates <-chan *pb.MarketDataResponse, trades <-chan *pb.OrderTrades) error {
+	if env.Now == nil {
+		env.Now = time.Now
+	}
+	if err := strategy.Start(ctx, env); err != nil {
+		return err
+	}
+	handler, _ := strategy.(TradesHandler)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case resp, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := strategy.OnMarketData(ctx, resp); err != nil {
+				return err
+			}
+		case t, ok := <-trades:
+			if !ok {
+				trades = nil
+				continue
+			}
+			if handler == nil {
+				continue
+			}
+			if err := handler.OnOrderTrades(ctx, t); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// clock - часы воспроизведения, показывают время текущего события
+type clock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// Now - Время воспроизводимого события
+func (c *clock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now
+}
+
+func (c *clock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
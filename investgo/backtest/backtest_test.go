@@ -0,0 +1,208 @@
+package backtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/backtest"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	start = time.Date(2023, 10, 2, 7, 0, 0, 0, time.UTC)
+	inst  = &pb.Instrument{
+		Figi:              "BBG004730N88",
+		Uid:               "e6123145-9665-43e0-8413-cd61b8aa9b13",
+		Lot:               10,
+		Currency:          "rub",
+		MinPriceIncrement: &pb.Quotation{Nano: 10000000},
+		InstrumentKind:    pb.InstrumentType_INSTRUMENT_TYPE_SHARE,
+	}
+)
+
+func candle(minute int, closePrice int64) *pb.HistoricCandle {
+	price := &pb.Quotation{Units: closePrice}
+	return &pb.HistoricCandle{
+		Open:   price,
+		High:   price,
+		Low:    price,
+		Close:  price,
+		Volume: 1000,
+		Time:   timestamppb.New(start.Add(time.Duration(minute) * time.Minute)),
+	}
+}
+
+// scriptStrategy - Стратегия, которая покупает 1 лот на первой свече и продает на третьей
+type scriptStrategy struct {
+	env     backtest.Env
+	startAt time.Time
+	seen    []time.Time
+	fills   []string
+	failAt  int
+}
+
+func (s *scriptStrategy) Start(_ context.Context, env backtest.Env) error {
+	s.env = env
+	s.startAt = env.Now()
+	return nil
+}
+
+func (s *scriptStrategy) OnMarketData(ctx context.Context, _ *pb.MarketDataResponse) error {
+	s.seen = append(s.seen, s.env.Now())
+	if len(s.seen) == s.failAt {
+		return errors.New("strategy failed")
+	}
+	direction := pb.OrderDirection_ORDER_DIRECTION_UNSPECIFIED
+	switch len(s.seen) {
+	case 1:
+		direction = pb.OrderDirection_ORDER_DIRECTION_BUY
+	case 3:
+		direction = pb.OrderDirection_ORDER_DIRECTION_SELL
+	default:
+		return nil
+	}
+	_, err := s.env.Trading.PostOrder(ctx, &investgo.PostOrderRequest{
+		InstrumentId: inst.GetFigi(),
+		Quantity:     1,
+		Direction:    direction,
+		AccountId:    s.env.AccountId,
+		OrderType:    pb.OrderType_ORDER_TYPE_MARKET,
+	})
+	return err
+}
+
+func (s *scriptStrategy) OnOrderTrades(_ context.Context, t *pb.OrderTrades) error {
+	s.fills = append(s.fills, t.GetOrderId())
+	return nil
+}
+
+func run(t *testing.T, strategy backtest.Strategy) (*backtest.Result, error) {
+	t.Helper()
+	return backtest.Run(context.Background(), backtest.Config{
+		Instruments:  []*pb.Instrument{inst},
+		InitialMoney: decimal.NewFromInt(100000),
+		Commission:   decimal.RequireFromString("0.001"),
+	}, strategy, backtest.Candles(inst, pb.CandleInterval_CANDLE_INTERVAL_1_MIN, []*pb.HistoricCandle{
+		candle(0, 250), candle(1, 260), candle(2, 255),
+	}))
+}
+
+func TestRun(t *testing.T) {
+	s := &scriptStrategy{}
+	res, err := run(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// свеча известна в момент окончания интервала
+	if !s.startAt.Equal(start.Add(time.Minute)) {
+		t.Fatalf("clock at start = %v, want %v", s.startAt, start.Add(time.Minute))
+	}
+	for i, got := range s.seen {
+		if want := start.Add(time.Duration(i+1) * time.Minute); !got.Equal(want) {
+			t.Fatalf("clock at event %d = %v, want %v", i, got, want)
+		}
+	}
+
+	if len(res.Trades) != 2 || len(s.fills) != 2 {
+		t.Fatalf("trades = %d, fills = %d, want 2", len(res.Trades), len(s.fills))
+	}
+	buy := res.Trades[0]
+	if buy.Direction != pb.OrderDirection_ORDER_DIRECTION_BUY || !buy.Price.Equal(decimal.NewFromInt(250)) ||
+		buy.Quantity != 10 || !buy.Commission.Equal(decimal.RequireFromString("2.5")) || !buy.Time.Equal(s.seen[0]) {
+		t.Fatalf("buy trade = %+v", buy)
+	}
+	if sell := res.Trades[1]; !sell.Price.Equal(decimal.NewFromInt(255)) || !sell.Time.Equal(s.seen[2]) {
+		t.Fatalf("sell trade = %+v", sell)
+	}
+
+	want := []string{"99997.5", "100097.5", "100044.95"}
+	if len(res.Equity) != len(want) {
+		t.Fatalf("equity points = %d, want %d", len(res.Equity), len(want))
+	}
+	for i, p := range res.Equity {
+		if !p.Equity.Equal(decimal.RequireFromString(want[i])) || !p.Time.Equal(s.seen[i]) {
+			t.Fatalf("equity %d = %v at %v, want %v at %v", i, p.Equity, p.Time, want[i], s.seen[i])
+		}
+	}
+	if got := res.Profit(); !got.Equal(decimal.RequireFromString("44.95")) {
+		t.Fatalf("profit = %v, want 44.95", got)
+	}
+	wantDrawdown := decimal.RequireFromString("52.55").Div(decimal.RequireFromString("100097.5"))
+	if got := res.MaxDrawdown(); !got.Equal(wantDrawdown) {
+		t.Fatalf("max drawdown = %v, want %v", got, wantDrawdown)
+	}
+}
+
+func TestRunStrategyError(t *testing.T) {
+	s := &scriptStrategy{failAt: 2}
+	res, err := run(t, s)
+	if err == nil || err.Error() != "strategy failed" {
+		t.Fatalf("err = %v, want strategy error", err)
+	}
+	if len(s.seen) != 2 || len(res.Trades) != 1 || len(res.Equity) != 1 {
+		t.Fatalf("events = %d, trades = %d, equity points = %d, want 2, 1, 1", len(s.seen), len(res.Trades), len(res.Equity))
+	}
+}
+
+func TestRunUnsupportedCurrency(t *testing.T) {
+	_, err := backtest.Run(context.Background(), backtest.Config{Currency: "xyz"}, &scriptStrategy{})
+	if err == nil {
+		t.Fatal("want error for unknown currency")
+	}
+}
+
+// recorder - Стратегия, которая запоминает цены сделок из стрима
+type recorder struct {
+	prices []int64
+}
+
+func (r *recorder) Start(context.Context, backtest.Env) error { return nil }
+
+func (r *recorder) OnMarketData(_ context.Context, resp *pb.MarketDataResponse) error {
+	r.prices = append(r.prices, resp.GetTrade().GetPrice().GetUnits())
+	return nil
+}
+
+func TestMergeOrder(t *testing.T) {
+	trade := func(second int, price int64) *pb.Trade {
+		return &pb.Trade{Figi: inst.GetFigi(), Price: &pb.Quotation{Units: price}, Quantity: 1,
+			Time: timestamppb.New(start.Add(time.Duration(second) * time.Second))}
+	}
+	r := &recorder{}
+	_, err := backtest.Run(context.Background(), backtest.Config{Instruments: []*pb.Instrument{inst}}, r,
+		backtest.Trades([]*pb.Trade{trade(2, 3), trade(0, 1)}),
+		backtest.Trades([]*pb.Trade{trade(0, 2), trade(3, 4)}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// при равном времени раньше идет источник, переданный раньше
+	want := []int64{1, 2, 3, 4}
+	if len(r.prices) != len(want) {
+		t.Fatalf("prices = %v, want %v", r.prices, want)
+	}
+	for i := range want {
+		if r.prices[i] != want[i] {
+			t.Fatalf("prices = %v, want %v", r.prices, want)
+		}
+	}
+}
+
+func TestRunLive(t *testing.T) {
+	updates := make(chan *pb.MarketDataResponse, 1)
+	updates <- &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_Trade{Trade: &pb.Trade{Price: &pb.Quotation{Units: 7}}}}
+	close(updates)
+	r := &recorder{}
+	if err := backtest.RunLive(context.Background(), backtest.Env{}, r, updates, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.prices) != 1 || r.prices[0] != 7 {
+		t.Fatalf("prices = %v, want [7]", r.prices)
+	}
+}
@@ -0,0 +1,208 @@
+package backtest
+
+import (
+	"container/heap"
+	"io"
+	"sort"
+	"time"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// Event - Историческое сообщение MarketDataStream и время, когда оно стало известно
+type Event struct {
+	Time time.Time
+	Data *pb.MarketDataResponse
+}
+
+// Source - Источник исторических данных. События отдаются по возрастанию времени, после последнего события
+// Next возвращает io.EOF
+type Source interface {
+	Next() (Event, error)
+}
+
+// sliceSource - источник из готового списка событий
+type sliceSource struct {
+	events []Event
+	pos    int
+}
+
+func (s *sliceSource) Next() (Event, error) {
+	if s.pos >= len(s.events) {
+		return Event{}, io.EOF
+	}
+	e := s.events[s.pos]
+	s.pos++
+	return e, nil
+}
+
+// Events - Источник из списка событий, события сортируются по времени
+func Events(events []Event) Source {
+	sorted := append([]Event(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+	return &sliceSource{events: sorted}
+}
+
+// Candles - Источник свечей инструмента inst из MarketDataServiceClient.GetCandles. Свеча становится известна
+// в момент окончания интервала, поэтому время события - время начала свечи плюс interval
+func Candles(inst *pb.Instrument, interval pb.CandleInterval, candles []*pb.HistoricCandle) Source {
+	events := make([]Event, 0, len(candles))
+	for _, c := range candles {
+		start := c.GetTime().AsTime()
+		events = append(events, Event{
+			Time: candleEnd(start, interval),
+			Data: &pb.MarketDataResponse{
+				Payload: &pb.MarketDataResponse_Candle{Candle: &pb.Candle{
+					Figi:          inst.GetFigi(),
+					Interval:      subscriptionInterval(interval),
+					Open:          c.GetOpen(),
+					High:          c.GetHigh(),
+					Low:           c.GetLow(),
+					Close:         c.GetClose(),
+					Volume:        c.GetVolume(),
+					Time:          c.GetTime(),
+					InstrumentUid: inst.GetUid(),
+				}},
+			},
+		})
+	}
+	return Events(events)
+}
+
+// OrderBooks - Источник стаканов, время события - время стакана
+func OrderBooks(books []*pb.OrderBook) Source {
+	events := make([]Event, 0, len(books))
+	for _, ob := range books {
+		events = append(events, Event{
+			Time: ob.GetTime().AsTime(),
+			Data: &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_Orderbook{Orderbook: ob}},
+		})
+	}
+	return Events(events)
+}
+
+// Trades - Источник обезличенных сделок, время события - время сделки
+func Trades(trades []*pb.Trade) Source {
+	events := make([]Event, 0, len(trades))
+	for _, t := range trades {
+		events = append(events, Event{
+			Time: t.GetTime().AsTime(),
+			Data: &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_Trade{Trade: t}},
+		})
+	}
+	return Events(events)
+}
+
+// candleEnd - время окончания свечи
+func candleEnd(start time.Time, interval pb.CandleInterval) time.Time {
+	switch interval {
+	case pb.CandleInterval_CANDLE_INTERVAL_1_MIN:
+		return start.Add(time.Minute)
+	case pb.CandleInterval_CANDLE_INTERVAL_2_MIN:
+		return start.Add(2 * time.Minute)
+	case pb.CandleInterval_CANDLE_INTERVAL_3_MIN:
+		return start.Add(3 * time.Minute)
+	case pb.CandleInterval_CANDLE_INTERVAL_5_MIN:
+		return start.Add(5 * time.Minute)
+	case pb.CandleInterval_CANDLE_INTERVAL_10_MIN:
+		return start.Add(10 * time.Minute)
+	case pb.CandleInterval_CANDLE_INTERVAL_15_MIN:
+		return start.Add(15 * time.Minute)
+	case pb.CandleInterval_CANDLE_INTERVAL_30_MIN:
+		return start.Add(30 * time.Minute)
+	case pb.CandleInterval_CANDLE_INTERVAL_HOUR:
+		return start.Add(time.Hour)
+	case pb.CandleInterval_CANDLE_INTERVAL_2_HOUR:
+		return start.Add(2 * time.Hour)
+	case pb.CandleInterval_CANDLE_INTERVAL_4_HOUR:
+		return start.Add(4 * time.Hour)
+	case pb.CandleInterval_CANDLE_INTERVAL_DAY:
+		return start.AddDate(0, 0, 1)
+	case pb.CandleInterval_CANDLE_INTERVAL_WEEK:
+		return start.AddDate(0, 0, 7)
+	case pb.CandleInterval_CANDLE_INTERVAL_MONTH:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start
+	}
+}
+
+// subscriptionInterval - интервал свечи в стриме, для интервалов без подписки - UNSPECIFIED
+func subscriptionInterval(interval pb.CandleInterval) pb.SubscriptionInterval {
+	switch interval {
+	case pb.CandleInterval_CANDLE_INTERVAL_1_MIN:
+		return pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE
+	case pb.CandleInterval_CANDLE_INTERVAL_5_MIN:
+		return pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_FIVE_MINUTES
+	default:
+		return pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_UNSPECIFIED
+	}
+}
+
+// merged - слияние нескольких источников по времени, при равном времени раньше идет событие источника
+// с меньшим номером
+type merged struct {
+	sources []Source
+	heads   eventHeap
+	started bool
+}
+
+type head struct {
+	event  Event
+	source int
+}
+
+type eventHeap []head
+
+func (h eventHeap) Len() int { return len(h) }
+func (h eventHeap) Less(i, j int) bool {
+	if h[i].event.Time.Equal(h[j].event.Time) {
+		return h[i].source < h[j].source
+	}
+	return h[i].event.Time.Before(h[j].event.Time)
+}
+func (h eventHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *eventHeap) Push(x any)   { *h = append(*h, x.(head)) }
+func (h *eventHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func merge(sources []Source) *merged {
+	return &merged{sources: sources}
+}
+
+func (m *merged) pull(i int) error {
+	e, err := m.sources[i].Next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	heap.Push(&m.heads, head{event: e, source: i})
+	return nil
+}
+
+func (m *merged) Next() (Event, error) {
+	if !m.started {
+		m.started = true
+		for i := range m.sources {
+			if err := m.pull(i); err != nil {
+				return Event{}, err
+			}
+		}
+	}
+	if m.heads.Len() == 0 {
+		return Event{}, io.EOF
+	}
+	h := heap.Pop(&m.heads).(head)
+	if err := m.pull(h.source); err != nil {
+		return Event{}, err
+	}
+	return h.event, nil
+}
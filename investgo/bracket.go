@@ -0,0 +1,795 @@
+package investgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// ErrBracketNotFound - Брекет с таким идентификатором не найден
+var ErrBracketNotFound = errors.New("bracket not found")
+
+// DefaultBracketInterval - Период проверки стоп-заявок брекетов через GetStopOrders
+const DefaultBracketInterval = 2 * time.Second
+
+// BracketState - Состояние брекета
+type BracketState int
+
+const (
+	// BracketPending - Входная заявка выставлена и еще не исполнена
+	BracketPending BracketState = iota
+	// BracketActive - Входная заявка исполнена полностью или частично, стоп-заявки выставлены
+	BracketActive
+	// BracketTakeProfit - Сработал тейк-профит, стоп-лосс отменен
+	BracketTakeProfit
+	// BracketStopLoss - Сработал стоп-лосс, тейк-профит отменен
+	BracketStopLoss
+	// BracketCancelled - Брекет отменен через Cancel, или входная заявка отменена или отклонена без исполнения
+	BracketCancelled
+	// BracketClosed - Стоп-заявки брекета отменены в обход BracketManager или истекли, или одна из них пропала
+	// без сделки в OrderTracker. Оставшиеся стоп-заявки при этом не отменяются
+	BracketClosed
+)
+
+// Done - Брекет завершен и больше не меняется
+func (s BracketState) Done() bool {
+	return s >= BracketTakeProfit
+}
+
+func (s BracketState) String() string {
+	switch s {
+	case BracketPending:
+		return "pending"
+	case BracketActive:
+		return "active"
+	case BracketTakeProfit:
+		return "take_profit"
+	case BracketStopLoss:
+		return "stop_loss"
+	case BracketCancelled:
+		return "cancelled"
+	case BracketClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("BracketState(%d)", int(s))
+	}
+}
+
+// BracketRequest - Запрос на брекет: входная заявка и стоп-заявки тейк-профит и стоп-лосс, которые
+// выставляются на исполненное количество лотов
+type BracketRequest struct {
+	// Id - Имя брекета в приложении. Из него получается order_request_id входной заявки через OrderKey,
+	// по нему брекет восстанавливается после перезапуска через Restore. Если пустой, создается через CreateUid
+	Id           string
+	AccountId    string
+	InstrumentId string
+	// Direction - Направление входной заявки, стоп-заявки выставляются в обратном направлении
+	Direction pb.OrderDirection
+	// Quantity - Количество лотов
+	Quantity int64
+	// OrderType - Тип входной заявки, для лимитной заявки нужна Price
+	OrderType pb.OrderType
+	Price     *pb.Quotation
+	// TakeProfit - Цена активации тейк-профита, nil - без тейк-профита
+	TakeProfit *pb.Quotation
+	// StopLoss - Цена активации стоп-лосса, nil - без стоп-лосса
+	StopLoss *pb.Quotation
+}
+
+// BracketLeg - Стоп-заявка брекета
+type BracketLeg struct {
+	StopOrderId string
+	Lots        int64
+}
+
+// Bracket - Состояние брекета
+type Bracket struct {
+	Request BracketRequest
+	State   BracketState
+	// Entry - Входная заявка. Пока ее выставление не подтверждено, Entry.Pending() == true
+	Entry TrackedOrder
+	// TakeProfit - Стоп-заявки тейк-профита, по одной на каждое увеличение исполненного объема входной заявки
+	TakeProfit []BracketLeg
+	// StopLoss - Стоп-заявки стоп-лосса, по одной на каждое увеличение исполненного объема входной заявки
+	StopLoss []BracketLeg
+	// ProtectedLots - Количество лотов, защищенных стоп-заявками каждого из запрошенных типов
+	ProtectedLots int64
+	// ProtectedAt - Время выставления первых стоп-заявок, сделки по ним ищутся с этого момента
+	ProtectedAt time.Time
+	UpdatedAt   time.Time
+}
+
+// BracketOption - Параметры BracketManager
+type BracketOption func(*BracketManager)
+
+// WithBracketInterval - Период проверки стоп-заявок и входных заявок брекетов
+func WithBracketInterval(d time.Duration) BracketOption {
+	return func(m *BracketManager) {
+		m.interval = d
+	}
+}
+
+// WithBracketCallback - Функция, которая вызывается при каждом изменении брекета
+func WithBracketCallback(fn func(b Bracket)) BracketOption {
+	return func(m *BracketManager) {
+		m.callbacks = append(m.callbacks, fn)
+	}
+}
+
+// WithBracketLogger - Логгер для ошибок выставления и отмены стоп-заявок
+func WithBracketLogger(l Logger) BracketOption {
+	return func(m *BracketManager) {
+		m.logger = l
+	}
+}
+
+// BracketManager - Брекет-заявки: входная заявка выставляется через OrderTracker, после ее исполнения по событиям
+// TradesStream на исполненные лоты выставляются тейк-профит и стоп-лосс. Когда одна стоп-заявка срабатывает,
+// другая отменяется (one-cancels-other). Пропавшая стоп-заявка находится по списку GetStopOrders с периодом
+// WithBracketInterval и считается сработавшей, только если в OrderTracker.UntrackedTrades есть сделка по ней,
+// поэтому OrderTracker должен получать события TradesStream
+type BracketManager struct {
+	tracker   *OrderTracker
+	stops     StopOrderTrading
+	logger    Logger
+	interval  time.Duration
+	callbacks []func(b Bracket)
+	sub       *OrderSubscription
+
+	// exec - изменения брекетов выполняются по одному: реакция на исполнение, сверка, отмена
+	exec sync.Mutex
+	mu   sync.Mutex
+	// brackets - брекеты по Id
+	brackets map[string]*Bracket
+	// byRequest - брекеты по order_request_id входной заявки
+	byRequest map[string]*Bracket
+	// changed - изменения для функций WithBracketCallback, вызываются после освобождения exec
+	changed []Bracket
+	// missing - время, когда у брекета пропала стоп-заявка без сделки, по Id брекета. Доступ под exec
+	missing map[string]time.Time
+}
+
+// NewBracketManager - Брекет-заявки с входными заявками через tracker и стоп-заявками через stops.
+// Для работы нужен запущенный Run у tracker и у BracketManager
+func NewBracketManager(tracker *OrderTracker, stops StopOrderTrading, opts ...BracketOption) *BracketManager {
+	m := &BracketManager{
+		tracker:   tracker,
+		stops:     stops,
+		interval:  DefaultBracketInterval,
+		brackets:  make(map[string]*Bracket),
+		byRequest: make(map[string]*Bracket),
+		missing:   make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	// пропущенные изменения входных заявок подхватит периодическая сверка
+	m.sub = tracker.Subscribe(DefaultSubscriberBuffer, OverflowDropOldest)
+	return m
+}
+
+// NewBracketManager - Брекет-заявки со стоп-заявками через StopOrdersService. В песочнице стоп-заявок нет,
+// возвращается ErrStopOrdersUnsupported
+func (c *Client) NewBracketManager(tracker *OrderTracker, opts ...BracketOption) (*BracketManager, error) {
+	stops := c.NewStopOrderTrading()
+	if stops == nil {
+		return nil, ErrStopOrdersUnsupported
+	}
+	return NewBracketManager(tracker, stops, append([]BracketOption{WithBracketLogger(c.Logger)}, opts...)...), nil
+}
+
+// Submit - Выставление брекета. Если входная заявка сразу исполнена, стоп-заявки выставляются до возврата из Submit.
+// Если исход выставления входной заявки неизвестен, брекет возвращается вместе с ошибкой и остается в BracketPending,
+// пока OrderTracker не найдет заявку по order_request_id или не отклонит ее
+func (m *BracketManager) Submit(ctx context.Context, req BracketRequest) (Bracket, error) {
+	if err := validateBracket(req); err != nil {
+		return Bracket{}, err
+	}
+	if req.Id == "" {
+		req.Id = CreateUid()
+	}
+	key := bracketKey(req.Id)
+
+	m.exec.Lock()
+	defer m.flush()
+	defer m.exec.Unlock()
+
+	m.mu.Lock()
+	if _, ok := m.brackets[req.Id]; ok {
+		m.mu.Unlock()
+		return Bracket{}, fmt.Errorf("bracket %s already exists", req.Id)
+	}
+	b := &Bracket{Request: req, State: BracketPending}
+	m.brackets[req.Id] = b
+	m.byRequest[key] = b
+	m.mu.Unlock()
+
+	o, err := m.tracker.PostOrder(ctx, &PostOrderRequest{
+		InstrumentId: req.InstrumentId,
+		Quantity:     req.Quantity,
+		Price:        req.Price,
+		Direction:    req.Direction,
+		AccountId:    req.AccountId,
+		OrderType:    req.OrderType,
+		OrderId:      key,
+	})
+	if err != nil {
+		// OrderTracker отслеживает заявку, только если она могла быть выставлена, дальше ее проверит Reconcile
+		if _, ok := m.tracker.Order(key); ok {
+			m.mu.Lock()
+			b.Entry = o
+			m.mu.Unlock()
+			m.notify(b)
+			return m.snapshot(b), err
+		}
+		m.mu.Lock()
+		delete(m.brackets, req.Id)
+		delete(m.byRequest, key)
+		m.mu.Unlock()
+		return Bracket{}, err
+	}
+	m.onEntry(ctx, b, o)
+	return m.snapshot(b), nil
+}
+
+// Cancel - Отмена брекета: отменяется входная заявка, если она не исполнена, и стоп-заявки.
+// Уже открытая позиция не закрывается. Если выставление входной заявки еще не подтверждено и среди
+// активных заявок ее нет, возвращается ErrOrderPending: заявка может появиться позже, отмену нужно повторить
+func (m *BracketManager) Cancel(ctx context.Context, id string) error {
+	m.exec.Lock()
+	defer m.flush()
+	defer m.exec.Unlock()
+
+	m.mu.Lock()
+	b, ok := m.brackets[id]
+	m.mu.Unlock()
+	if !ok {
+		return ErrBracketNotFound
+	}
+	if b.State.Done() {
+		return nil
+	}
+	var errs []error
+	if !b.Entry.Done() && b.Entry.RequestId != "" {
+		err := m.tracker.CancelOrder(ctx, b.Entry.RequestId)
+		if o, _ := m.tracker.Order(b.Entry.RequestId); errors.Is(err, ErrOrderNotFound) && o.Pending() {
+			err = fmt.Errorf("bracket %s entry: %w", id, ErrOrderPending)
+		}
+		if err != nil && !errors.Is(err, ErrOrderNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, m.cancelLegs(ctx, b, true, true)...)
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	m.setState(b, BracketCancelled)
+	return nil
+}
+
+// Bracket - Состояние брекета по Id
+func (m *BracketManager) Bracket(id string) (Bracket, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.brackets[id]
+	if !ok {
+		return Bracket{}, false
+	}
+	return *b, true
+}
+
+// Brackets - Состояния всех брекетов
+func (m *BracketManager) Brackets() []Bracket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	brackets := make([]Bracket, 0, len(m.brackets))
+	for _, b := range m.brackets {
+		brackets = append(brackets, *b)
+	}
+	return brackets
+}
+
+// Restore - Восстановление брекетов после перезапуска приложения по активным заявкам из GetOrders и стоп-заявкам
+// из GetStopOrders. reqs - запросы, с которыми брекеты выставлялись через Submit, с теми же Id. Входная заявка
+// находится по order_request_id, стоп-заявки - по инструменту, направлению, типу и цене активации. Брекет,
+// от которого на сервере не осталось ни входной заявки, ни стоп-заявок, считается завершенным
+func (m *BracketManager) Restore(ctx context.Context, reqs ...BracketRequest) ([]Bracket, error) {
+	m.exec.Lock()
+	defer m.flush()
+	defer m.exec.Unlock()
+
+	orders := make(map[string][]*pb.OrderState)
+	stops := make(map[string][]*pb.StopOrder)
+	for _, req := range reqs {
+		if _, ok := orders[req.AccountId]; ok {
+			continue
+		}
+		ordersResp, err := m.tracker.trading.GetOrders(ctx, req.AccountId)
+		if err != nil {
+			return nil, err
+		}
+		stopsResp, err := m.stops.GetStopOrders(ctx, req.AccountId)
+		if err != nil {
+			return nil, err
+		}
+		orders[req.AccountId] = ordersResp.GetOrders()
+		stops[req.AccountId] = stopsResp.GetStopOrders()
+	}
+
+	claimed := make(map[string]struct{})
+	restored := make([]Bracket, 0, len(reqs))
+	for _, req := range reqs {
+		if err := validateBracket(req); err != nil {
+			return restored, err
+		}
+		if req.Id == "" {
+			return restored, errors.New("bracket id is required to restore")
+		}
+		key := bracketKey(req.Id)
+		b := &Bracket{Request: req, State: BracketPending}
+
+		var entry *pb.OrderState
+		for _, o := range orders[req.AccountId] {
+			if o.GetOrderRequestId() == key {
+				entry = o
+				break
+			}
+		}
+		// стоп-заявки вместе защищают не больше исполненного объема входной заявки
+		lots := req.Quantity
+		if entry != nil {
+			lots = entry.GetLotsExecuted()
+		}
+		b.TakeProfit = findLegs(stops[req.AccountId], claimed, req, pb.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT, req.TakeProfit, lots)
+		b.StopLoss = findLegs(stops[req.AccountId], claimed, req, pb.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS, req.StopLoss, lots)
+		b.ProtectedLots = protectedLots(b)
+		tp, sl := len(b.TakeProfit) > 0, len(b.StopLoss) > 0
+		if tp || sl {
+			b.State = BracketActive
+		}
+
+		m.mu.Lock()
+		m.brackets[req.Id] = b
+		m.byRequest[key] = b
+		m.mu.Unlock()
+
+		switch {
+		case entry != nil:
+			o, err := m.tracker.Track(ctx, req.AccountId, entry.GetOrderId())
+			if err != nil {
+				return restored, err
+			}
+			m.onEntry(ctx, b, o)
+		case !tp && !sl:
+			m.setState(b, BracketClosed)
+		default:
+			b.Entry = TrackedOrder{
+				RequestId:    key,
+				AccountId:    req.AccountId,
+				InstrumentId: req.InstrumentId,
+				Direction:    req.Direction,
+				Status:       pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL,
+			}
+			m.notify(b)
+		}
+		// одна из запрошенных стоп-заявок пропала до перезапуска. Сделки по ней в OrderTracker нет, сработала она
+		// или отменена, не узнать, поэтому вторая стоп-заявка остается
+		if b.State == BracketActive && (req.TakeProfit != nil && !tp || req.StopLoss != nil && !sl) {
+			m.error("bracket stop order is gone before restore, the other one is left", FieldInstrumentId, req.InstrumentId)
+			m.fired(ctx, b, BracketClosed)
+		}
+		restored = append(restored, m.snapshot(b))
+	}
+	return restored, nil
+}
+
+// Run - Выставление стоп-заявок по исполнению входных заявок и проверка сработавших стоп-заявок до отмены ctx
+func (m *BracketManager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	updates := m.sub.Updates()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case o, ok := <-updates:
+			if !ok {
+				updates = nil
+				continue
+			}
+			m.onOrder(ctx, o)
+		case <-ticker.C:
+			_ = m.Reconcile(ctx)
+		}
+	}
+}
+
+// Reconcile - Проверка входных заявок по состоянию в OrderTracker и стоп-заявок через GetStopOrders
+func (m *BracketManager) Reconcile(ctx context.Context) error {
+	m.exec.Lock()
+	defer m.flush()
+	defer m.exec.Unlock()
+
+	m.mu.Lock()
+	active := make([]*Bracket, 0, len(m.brackets))
+	for _, b := range m.brackets {
+		if !b.State.Done() {
+			active = append(active, b)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, b := range active {
+		if o, ok := m.tracker.Order(bracketKey(b.Request.Id)); ok {
+			m.onEntry(ctx, b, o)
+		}
+	}
+
+	var errs []error
+	alive := make(map[string]map[string]struct{})
+	for _, b := range active {
+		if b.State != BracketActive {
+			continue
+		}
+		ids, ok := alive[b.Request.AccountId]
+		if !ok {
+			resp, err := m.stops.GetStopOrders(ctx, b.Request.AccountId)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ids = make(map[string]struct{}, len(resp.GetStopOrders()))
+			for _, so := range resp.GetStopOrders() {
+				ids[so.GetStopOrderId()] = struct{}{}
+			}
+			alive[b.Request.AccountId] = ids
+		}
+		if _, err := m.checkLegs(ctx, b, ids); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close - Отписка от изменений заявок OrderTracker
+func (m *BracketManager) Close() {
+	m.tracker.Unsubscribe(m.sub)
+}
+
+// onOrder - изменение заявки из OrderTracker
+func (m *BracketManager) onOrder(ctx context.Context, o TrackedOrder) {
+	m.exec.Lock()
+	defer m.flush()
+	defer m.exec.Unlock()
+
+	m.mu.Lock()
+	b, ok := m.byRequest[o.RequestId]
+	m.mu.Unlock()
+	if ok {
+		m.onEntry(ctx, b, o)
+	}
+}
+
+// onEntry - изменение входной заявки, вызывается под exec
+func (m *BracketManager) onEntry(ctx context.Context, b *Bracket, o TrackedOrder) {
+	if b.State.Done() {
+		return
+	}
+	m.mu.Lock()
+	changed := b.Entry.Status != o.Status || b.Entry.LotsExecuted != o.LotsExecuted
+	b.Entry = o
+	m.mu.Unlock()
+
+	switch {
+	case o.LotsExecuted > b.ProtectedLots:
+		if err := m.protect(ctx, b, o.LotsExecuted); err != nil {
+			m.error("attach bracket stop orders", FieldOrderId, o.OrderId, FieldInstrumentId, b.Request.InstrumentId, FieldError, err)
+		}
+	case o.Done() && o.LotsExecuted == 0:
+		m.setState(b, BracketCancelled)
+	case changed:
+		m.notify(b)
+	}
+}
+
+// protect - выставление стоп-заявок на лоты сверх уже защищенных, чтобы стоп-заявки каждого типа вместе защищали
+// lots лотов, вызывается под exec. Прежние стоп-заявки остаются: позиция не остается без защиты, и стоп-заявки
+// одного типа не закрывают больше исполненного. Если стоп-заявка не выставилась, следующая сверка выставит
+// только недостающие
+func (m *BracketManager) protect(ctx context.Context, b *Bracket, lots int64) error {
+	if len(b.TakeProfit) > 0 || len(b.StopLoss) > 0 {
+		// перед добавлением убеждаемся, что стоп-заявки не сработали
+		resp, err := m.stops.GetStopOrders(ctx, b.Request.AccountId)
+		if err != nil {
+			return err
+		}
+		ids := make(map[string]struct{}, len(resp.GetStopOrders()))
+		for _, so := range resp.GetStopOrders() {
+			ids[so.GetStopOrderId()] = struct{}{}
+		}
+		if done, err := m.checkLegs(ctx, b, ids); done || err != nil {
+			return err
+		}
+	}
+
+	req := b.Request
+	var errs []error
+	add := func(legs *[]BracketLeg, stopType pb.StopOrderType, stopPrice *pb.Quotation) {
+		quantity := lots - legLots(*legs)
+		if stopPrice == nil || quantity <= 0 {
+			return
+		}
+		resp, err := m.stops.PostStopOrder(ctx, &PostStopOrderRequest{
+			InstrumentId:   req.InstrumentId,
+			Quantity:       quantity,
+			StopPrice:      stopPrice,
+			Direction:      exitDirection(req.Direction),
+			AccountId:      req.AccountId,
+			ExpirationType: pb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_CANCEL,
+			StopOrderType:  stopType,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", stopName(stopType), err))
+			return
+		}
+		m.mu.Lock()
+		// новый срез, чтобы не менять копии брекета, отданные наружу
+		*legs = append((*legs)[:len(*legs):len(*legs)], BracketLeg{StopOrderId: resp.GetStopOrderId(), Lots: quantity})
+		m.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	if b.ProtectedAt.IsZero() {
+		b.ProtectedAt = time.Now()
+	}
+	m.mu.Unlock()
+	add(&b.TakeProfit, pb.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT, req.TakeProfit)
+	add(&b.StopLoss, pb.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS, req.StopLoss)
+	m.mu.Lock()
+	b.ProtectedLots = protectedLots(b)
+	m.mu.Unlock()
+	if len(b.TakeProfit) > 0 || len(b.StopLoss) > 0 {
+		m.setState(b, BracketActive)
+	}
+	return errors.Join(errs...)
+}
+
+// checkLegs - проверка стоп-заявок брекета по активным стоп-заявкам alive. Если стоп-заявка пропала и по ней есть
+// сделка в OrderTracker, стоп-заявки другого типа отменяются. Если сделки нет, возвращается ошибка, а когда сделка
+// не находится дольше WithBracketInterval, брекет завершается как BracketClosed без отмены второй стоп-заявки.
+// Возвращает true, если брекет завершился, вызывается под exec
+func (m *BracketManager) checkLegs(ctx context.Context, b *Bracket, alive map[string]struct{}) (bool, error) {
+	gone := func(legs []BracketLeg) string {
+		for _, l := range legs {
+			if _, ok := alive[l.StopOrderId]; !ok {
+				return l.StopOrderId
+			}
+		}
+		return ""
+	}
+	var id string
+	var state BracketState
+	switch tpGone, slGone := gone(b.TakeProfit), gone(b.StopLoss); {
+	case tpGone != "" && slGone != "":
+		m.fired(ctx, b, BracketClosed)
+		return true, nil
+	case tpGone != "":
+		id, state = tpGone, BracketTakeProfit
+	case slGone != "":
+		id, state = slGone, BracketStopLoss
+	default:
+		return false, nil
+	}
+	direction := pb.OrderDirection_ORDER_DIRECTION_SELL
+	if b.Request.Direction == pb.OrderDirection_ORDER_DIRECTION_SELL {
+		direction = pb.OrderDirection_ORDER_DIRECTION_BUY
+	}
+	if len(m.tracker.UntrackedTrades(b.Request.AccountId, b.Request.InstrumentId, direction, b.ProtectedAt)) > 0 {
+		m.fired(ctx, b, state)
+		return true, nil
+	}
+	// событие TradesStream может прийти позже, чем стоп-заявка пропадет из GetStopOrders
+	since, ok := m.missing[b.Request.Id]
+	if !ok {
+		since = time.Now()
+		m.missing[b.Request.Id] = since
+	}
+	if time.Since(since) < m.interval {
+		return false, fmt.Errorf("bracket %s: stop order %s is gone without a trade", b.Request.Id, id)
+	}
+	m.error("bracket stop order is gone without a trade, the other one is left", FieldOrderId, id,
+		FieldInstrumentId, b.Request.InstrumentId)
+	m.fired(ctx, b, BracketClosed)
+	return true, nil
+}
+
+// fired - завершение брекета после срабатывания стоп-заявки: отмена стоп-заявок другого типа и остатка входной
+// заявки, чтобы ее исполнение не открыло позицию без защиты. Стоп-заявки сработавшего типа с той же ценой
+// активации не отменяются, они срабатывают вместе с ней. Вызывается под exec
+func (m *BracketManager) fired(ctx context.Context, b *Bracket, state BracketState) {
+	var errs []error
+	switch state {
+	case BracketTakeProfit:
+		errs = m.cancelLegs(ctx, b, false, true)
+	case BracketStopLoss:
+		errs = m.cancelLegs(ctx, b, true, false)
+	}
+	if !b.Entry.Done() && b.Entry.RequestId != "" {
+		if err := m.tracker.CancelOrder(ctx, b.Entry.RequestId); err != nil && !errors.Is(err, ErrOrderNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	for _, err := range errs {
+		m.error("cancel bracket orders", FieldOrderId, b.Entry.OrderId, FieldInstrumentId, b.Request.InstrumentId, FieldError, err)
+	}
+	m.setState(b, state)
+}
+
+// cancelLegs - отмена стоп-заявок брекета, уже исполненные или отмененные стоп-заявки не считаются ошибкой.
+// В брекете остаются стоп-заявки, которые отменить не удалось
+func (m *BracketManager) cancelLegs(ctx context.Context, b *Bracket, takeProfit, stopLoss bool) []error {
+	var errs []error
+	cancel := func(legs *[]BracketLeg) {
+		var left []BracketLeg
+		for _, l := range *legs {
+			if err := m.cancelStop(ctx, b.Request.AccountId, l.StopOrderId); err != nil {
+				errs = append(errs, err)
+				left = append(left, l)
+			}
+		}
+		m.mu.Lock()
+		*legs = left
+		m.mu.Unlock()
+	}
+	if takeProfit {
+		cancel(&b.TakeProfit)
+	}
+	if stopLoss {
+		cancel(&b.StopLoss)
+	}
+	return errs
+}
+
+// cancelStop - отмена стоп-заявки, пустой id, уже исполненная или отмененная стоп-заявка не считаются ошибкой
+func (m *BracketManager) cancelStop(ctx context.Context, accountId, id string) error {
+	if id == "" {
+		return nil
+	}
+	_, err := m.stops.CancelStopOrder(ctx, accountId, id)
+	if err != nil && !errors.Is(err, ErrOrderNotFound) {
+		return err
+	}
+	return nil
+}
+
+// setState - смена состояния брекета и уведомление, вызывается под exec
+func (m *BracketManager) setState(b *Bracket, state BracketState) {
+	if state.Done() {
+		delete(m.missing, b.Request.Id)
+	}
+	m.mu.Lock()
+	b.State = state
+	m.mu.Unlock()
+	m.notify(b)
+}
+
+// notify - постановка изменения брекета в очередь функций WithBracketCallback, вызывается под exec
+func (m *BracketManager) notify(b *Bracket) {
+	m.mu.Lock()
+	b.UpdatedAt = time.Now()
+	m.changed = append(m.changed, *b)
+	m.mu.Unlock()
+}
+
+// flush - вызов функций WithBracketCallback после освобождения exec, чтобы из них можно было вызывать BracketManager
+func (m *BracketManager) flush() {
+	m.mu.Lock()
+	changed := m.changed
+	m.changed = nil
+	m.mu.Unlock()
+	for _, b := range changed {
+		for _, fn := range m.callbacks {
+			fn(b)
+		}
+	}
+}
+
+func (m *BracketManager) snapshot(b *Bracket) Bracket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *b
+}
+
+// error - ошибка в логгер, если он задан
+func (m *BracketManager) error(msg string, keysAndValues ...any) {
+	if m.logger != nil {
+		logError(m.logger, msg, keysAndValues...)
+	}
+}
+
+// bracketKey - order_request_id входной заявки брекета
+func bracketKey(id string) string {
+	return OrderKey("bracket/" + id)
+}
+
+// findLegs - стоп-заявки брекета среди активных стоп-заявок счета, вместе не больше lots лотов
+func findLegs(stops []*pb.StopOrder, claimed map[string]struct{}, req BracketRequest, stopType pb.StopOrderType, stopPrice *pb.Quotation, lots int64) []BracketLeg {
+	if stopPrice == nil {
+		return nil
+	}
+	direction := exitDirection(req.Direction)
+	var legs []BracketLeg
+	for _, so := range stops {
+		if _, ok := claimed[so.GetStopOrderId()]; ok {
+			continue
+		}
+		if so.GetFigi() != req.InstrumentId && so.GetInstrumentUid() != req.InstrumentId {
+			continue
+		}
+		if so.GetOrderType() != stopType || so.GetDirection() != direction {
+			continue
+		}
+		if !so.GetStopPrice().ToDecimal().Equal(stopPrice.ToDecimal()) {
+			continue
+		}
+		if legLots(legs)+so.GetLotsRequested() > lots {
+			continue
+		}
+		claimed[so.GetStopOrderId()] = struct{}{}
+		legs = append(legs, BracketLeg{StopOrderId: so.GetStopOrderId(), Lots: so.GetLotsRequested()})
+	}
+	return legs
+}
+
+// legLots - количество лотов в стоп-заявках legs
+func legLots(legs []BracketLeg) int64 {
+	var lots int64
+	for _, l := range legs {
+		lots += l.Lots
+	}
+	return lots
+}
+
+// protectedLots - количество лотов, защищенных стоп-заявками каждого из запрошенных типов брекета
+func protectedLots(b *Bracket) int64 {
+	tp, sl := legLots(b.TakeProfit), legLots(b.StopLoss)
+	switch {
+	case b.Request.TakeProfit == nil:
+		return sl
+	case b.Request.StopLoss == nil || tp < sl:
+		return tp
+	}
+	return sl
+}
+
+// stopName - название типа стоп-заявки брекета для ошибок
+func stopName(stopType pb.StopOrderType) string {
+	if stopType == pb.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT {
+		return "take profit"
+	}
+	return "stop loss"
+}
+
+// validateBracket - проверка запроса на брекет
+func validateBracket(req BracketRequest) error {
+	switch {
+	case req.Quantity <= 0:
+		return errors.New("bracket quantity must be positive")
+	case req.Direction != pb.OrderDirection_ORDER_DIRECTION_BUY && req.Direction != pb.OrderDirection_ORDER_DIRECTION_SELL:
+		return errors.New("bracket direction must be buy or sell")
+	case req.TakeProfit == nil && req.StopLoss == nil:
+		return errors.New("bracket needs take profit or stop loss")
+	}
+	if req.TakeProfit != nil && req.StopLoss != nil {
+		tp, sl := req.TakeProfit.ToDecimal(), req.StopLoss.ToDecimal()
+		if req.Direction == pb.OrderDirection_ORDER_DIRECTION_BUY && !tp.GreaterThan(sl) ||
+			req.Direction == pb.OrderDirection_ORDER_DIRECTION_SELL && !tp.LessThan(sl) {
+			return errors.New("bracket take profit must be on the profit side of stop loss")
+		}
+	}
+	return nil
+}
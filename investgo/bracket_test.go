@@ -0,0 +1,275 @@
+package investgo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const postStopOrderMethod = "StopOrdersService/PostStopOrder"
+
+// runStreamTracker - Трекер заявок клиента с событиями TradesStream по счету сервера, работает до конца теста
+func runStreamTracker(t *testing.T, client *investgo.Client, srv *fake.Server) *investgo.OrderTracker {
+	t.Helper()
+	return startStreamTracker(t, client, srv, client.NewOrderTracker(investgo.WithReconcileInterval(20*time.Millisecond)))
+}
+
+// startStreamTracker - Run трекера с событиями TradesStream по счету сервера до конца теста
+func startStreamTracker(t *testing.T, client *investgo.Client, srv *fake.Server, tracker *investgo.OrderTracker) *investgo.OrderTracker {
+	t.Helper()
+	ts, err := client.NewOrdersStreamClient().TradesStream([]string{srv.AccountId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	listened, ran := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(listened)
+		_ = ts.Listen()
+	}()
+	go func() {
+		defer close(ran)
+		_ = tracker.Run(ctx, ts.Trades())
+	}()
+	// трекер читает события, пока стрим не закроет канал, иначе Listen может заблокироваться на отправке
+	t.Cleanup(func() {
+		ts.Stop()
+		<-listened
+		cancel()
+		<-ran
+		tracker.Close()
+	})
+	return tracker
+}
+
+func newBracketManager(t *testing.T, srv *fake.Server, opts ...investgo.BracketOption) (*investgo.BracketManager, investgo.StopOrderTrading) {
+	t.Helper()
+	client := newTestClient(t, srv.Config())
+	m, err := client.NewBracketManager(runStreamTracker(t, client, srv), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	return m, client.NewStopOrderTrading()
+}
+
+func bracketRequest(srv *fake.Server, quantity int64) investgo.BracketRequest {
+	return investgo.BracketRequest{
+		Id:           "bracket-1",
+		AccountId:    srv.AccountId(),
+		InstrumentId: testFigi,
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+		Quantity:     quantity,
+		OrderType:    pb.OrderType_ORDER_TYPE_LIMIT,
+		Price:        fake.Quotation(245),
+		TakeProfit:   fake.Quotation(260),
+		StopLoss:     fake.Quotation(240),
+	}
+}
+
+// activeStops - Идентификаторы и лоты активных стоп-заявок счета
+func activeStops(t *testing.T, stops investgo.StopOrderTrading, srv *fake.Server) map[string]int64 {
+	t.Helper()
+	resp, err := stops.GetStopOrders(context.Background(), srv.AccountId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make(map[string]int64)
+	for _, so := range resp.GetStopOrders() {
+		res[so.GetStopOrderId()] = so.GetLotsRequested()
+	}
+	return res
+}
+
+// awaitBracket - Сверка брекетов, пока брекет не окажется в состоянии state с защищенными lots лотами
+func awaitBracket(t *testing.T, m *investgo.BracketManager, state investgo.BracketState, lots int64) investgo.Bracket {
+	t.Helper()
+	var b investgo.Bracket
+	eventually(t, "bracket did not reach the expected state", func() bool {
+		_ = m.Reconcile(context.Background())
+		b, _ = m.Bracket("bracket-1")
+		return b.State == state && b.ProtectedLots == lots
+	})
+	return b
+}
+
+func TestBracketTakeProfitCancelsStopLoss(t *testing.T) {
+	srv := newTestServer(t)
+	m, stops := newBracketManager(t, srv, investgo.WithBracketInterval(time.Hour))
+
+	b, err := m.Submit(context.Background(), bracketRequest(srv, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.FillOrder(srv.AccountId(), b.Entry.OrderId, 1); err != nil {
+		t.Fatal(err)
+	}
+	b = awaitBracket(t, m, investgo.BracketActive, 1)
+	if got := activeStops(t, stops, srv); len(got) != 2 || got[b.TakeProfit[0].StopOrderId] != 1 || got[b.StopLoss[0].StopOrderId] != 1 {
+		t.Fatalf("stop orders = %v, want take profit and stop loss for 1 lot", got)
+	}
+
+	// тейк-профит срабатывает, его рыночная заявка приходит в TradesStream
+	if err := srv.SetLastPrice(testFigi, fake.Quotation(261)); err != nil {
+		t.Fatal(err)
+	}
+	b = awaitBracket(t, m, investgo.BracketTakeProfit, 1)
+	if len(b.StopLoss) != 0 {
+		t.Fatalf("stop loss = %v, want cancelled", b.StopLoss)
+	}
+	if got := activeStops(t, stops, srv); len(got) != 0 {
+		t.Fatalf("stop orders = %v, want stop loss cancelled", got)
+	}
+}
+
+func TestBracketStopGoneWithoutTrade(t *testing.T) {
+	srv := newTestServer(t)
+	m, stops := newBracketManager(t, srv, investgo.WithBracketInterval(50*time.Millisecond))
+
+	b, err := m.Submit(context.Background(), bracketRequest(srv, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.FillOrder(srv.AccountId(), b.Entry.OrderId, 1); err != nil {
+		t.Fatal(err)
+	}
+	b = awaitBracket(t, m, investgo.BracketActive, 1)
+
+	// тейк-профит отменен в обход BracketManager: сделки нет, стоп-лосс остается
+	if _, err := stops.CancelStopOrder(context.Background(), srv.AccountId(), b.TakeProfit[0].StopOrderId); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reconcile(context.Background()); err == nil {
+		t.Fatal("want error for the stop order gone without a trade")
+	}
+	if got, _ := m.Bracket("bracket-1"); got.State != investgo.BracketActive {
+		t.Fatalf("state = %v, want active until the trade is awaited", got.State)
+	}
+	awaitBracket(t, m, investgo.BracketClosed, 1)
+	if got, sl := activeStops(t, stops, srv), b.StopLoss[0].StopOrderId; len(got) != 1 || got[sl] != 1 {
+		t.Fatalf("stop orders = %v, want stop loss %s left", got, sl)
+	}
+}
+
+func TestBracketResizeAddsLegs(t *testing.T) {
+	srv := newTestServer(t)
+	m, stops := newBracketManager(t, srv, investgo.WithBracketInterval(time.Hour))
+
+	first, err := m.Submit(context.Background(), bracketRequest(srv, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.FillOrder(srv.AccountId(), first.Entry.OrderId, 1); err != nil {
+		t.Fatal(err)
+	}
+	first = awaitBracket(t, m, investgo.BracketActive, 1)
+
+	// тейк-профит на второй лот не выставился: стоп-лосс на него добавлен, защищен по-прежнему 1 лот
+	srv.InjectError(postStopOrderMethod, status.Error(codes.InvalidArgument, "invalid"))
+	if err := srv.FillOrder(srv.AccountId(), first.Entry.OrderId, 1); err != nil {
+		t.Fatal(err)
+	}
+	var b investgo.Bracket
+	eventually(t, "entry fill was not tracked", func() bool {
+		_ = m.Reconcile(context.Background())
+		b, _ = m.Bracket("bracket-1")
+		return b.Entry.LotsExecuted == 2 && len(b.StopLoss) == 2
+	})
+	if b.ProtectedLots != 1 || len(b.TakeProfit) != 1 {
+		t.Fatalf("bracket = %+v, want 1 lot protected by take profit", b)
+	}
+
+	// следующая сверка добавляет только тейк-профит, прежние стоп-заявки остаются
+	second := awaitBracket(t, m, investgo.BracketActive, 2)
+	if len(second.TakeProfit) != 2 || second.TakeProfit[0] != first.TakeProfit[0] || second.StopLoss[0] != first.StopLoss[0] {
+		t.Fatalf("bracket = %+v, want stop orders added to %+v", second, first)
+	}
+	got := activeStops(t, stops, srv)
+	if len(got) != 4 {
+		t.Fatalf("stop orders = %v, want 2 take profits and 2 stop losses", got)
+	}
+	for _, l := range append(second.TakeProfit, second.StopLoss...) {
+		if got[l.StopOrderId] != 1 || l.Lots != 1 {
+			t.Fatalf("stop order %+v = %d lots, want 1 lot each", l, got[l.StopOrderId])
+		}
+	}
+
+	// стоп-лосс срабатывает по обоим лотам, тейк-профиты отменяются
+	if err := srv.SetLastPrice(testFigi, fake.Quotation(239)); err != nil {
+		t.Fatal(err)
+	}
+	awaitBracket(t, m, investgo.BracketStopLoss, 2)
+	if got := activeStops(t, stops, srv); len(got) != 0 {
+		t.Fatalf("stop orders = %v, want none", got)
+	}
+	if pos := srv.PositionBalance(srv.AccountId(), testFigi); pos != 0 {
+		t.Fatalf("position = %d, want closed", pos)
+	}
+}
+
+func TestBracketPendingEntry(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	trading := &lostResponse{Trading: client.NewTrading()}
+	tracker := startStreamTracker(t, client, srv, investgo.NewOrderTracker(trading,
+		investgo.WithReconcileInterval(20*time.Millisecond), investgo.WithPendingTimeout(200*time.Millisecond)))
+	m := investgo.NewBracketManager(tracker, client.NewStopOrderTrading(), investgo.WithBracketInterval(time.Hour))
+	t.Cleanup(m.Close)
+
+	// ответ потерян, входная заявка выставлена: брекет ждет ее и защищает после исполнения
+	trading.mu.Lock()
+	trading.lost, trading.place = 1, true
+	trading.mu.Unlock()
+	b, err := m.Submit(context.Background(), bracketRequest(srv, 1))
+	if status.Code(err) != codes.Unavailable || b.State != investgo.BracketPending || b.Entry.RequestId == "" {
+		t.Fatalf("bracket = %+v, err = %v, want pending bracket with Unavailable", b, err)
+	}
+	eventually(t, "entry was not found", func() bool {
+		_ = m.Reconcile(context.Background())
+		b, _ = m.Bracket("bracket-1")
+		return b.Entry.OrderId != ""
+	})
+	if err := srv.FillOrder(srv.AccountId(), b.Entry.OrderId, 1); err != nil {
+		t.Fatal(err)
+	}
+	awaitBracket(t, m, investgo.BracketActive, 1)
+
+	// ответ потерян, заявки нет: отмена ждет, пока выставление не прояснится, затем брекет отменен
+	trading.mu.Lock()
+	trading.lost, trading.place = 1, false
+	trading.mu.Unlock()
+	req := bracketRequest(srv, 1)
+	req.Id = "bracket-2"
+	if _, err := m.Submit(context.Background(), req); status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+	if err := m.Cancel(context.Background(), req.Id); !errors.Is(err, investgo.ErrOrderPending) {
+		t.Fatalf("err = %v, want ErrOrderPending", err)
+	}
+	eventually(t, "bracket was not cancelled", func() bool {
+		_ = m.Reconcile(context.Background())
+		b, _ := m.Bracket(req.Id)
+		return b.State == investgo.BracketCancelled
+	})
+	if err := m.Cancel(context.Background(), req.Id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBracketManagerSandbox(t *testing.T) {
+	srv := newTestServer(t)
+	conf := srv.Config()
+	conf.Environment = investgo.EnvironmentSandbox
+	client := newTestClient(t, conf)
+	_, err := client.NewBracketManager(client.NewOrderTracker())
+	if !errors.Is(err, investgo.ErrStopOrdersUnsupported) {
+		t.Fatalf("err = %v, want ErrStopOrdersUnsupported", err)
+	}
+}
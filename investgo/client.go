@@ -12,6 +12,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/credentials/oauth"
 	"google.golang.org/grpc/metadata"
 )
@@ -27,55 +28,88 @@ type Client struct {
 	conn   *grpc.ClientConn
 	Config Config
 	Logger Logger
-	ctx    context.Context
+	// Risk - Предторговые проверки заявок по Config.RiskLimits и аварийный выключатель
+	Risk *RiskManager
+	ctx  context.Context
 }
 
-// NewClient - создание клиента для API Тинькофф инвестиций
-func NewClient(ctx context.Context, conf Config, l Logger) (*Client, error) {
+// NewClient - создание клиента для API Тинькофф инвестиций, opts - дополнительные интерсепторы и опции соединения.
+// Конфигурация проверяется через Validate, поэтому неизвестный контур или эндпоинт другого контура дают ошибку
+// ErrInvalidConfig и для конфигурации из LoadConfig
+func NewClient(ctx context.Context, conf Config, l Logger, opts ...Option) (*Client, error) {
 	setDefaultConfig(&conf)
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	var options clientOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 
 	var authKey ctxKey = "authorization"
 	ctx = context.WithValue(ctx, authKey, fmt.Sprintf("Bearer %s", conf.Token))
-	ctx = metadata.AppendToOutgoingContext(ctx, "x-app-name", conf.AppName)
-
-	opts := []retry.CallOption{
-		retry.WithCodes(codes.Unavailable, codes.Internal),
-		retry.WithBackoff(retry.BackoffLinear(WAIT_BETWEEN)),
-		retry.WithMax(conf.MaxRetries),
-	}
 
 	// при исчерпывании лимита запросов в минуту, нужно ждать дольше
 	exhaustedOpts := []retry.CallOption{
 		retry.WithCodes(codes.ResourceExhausted),
 		retry.WithMax(conf.MaxRetries),
-		retry.WithOnRetryCallback(func(ctx context.Context, attempt uint, err error) {
-			l.Infof("Resource Exhausted, sleep for %vs...", attempt)
+		// ретраер RE передает в колбэк не номер попытки, а время ожидания в секундах
+		retry.WithOnRetryCallback(func(ctx context.Context, wait uint, err error) {
+			logWarn(l, "resource exhausted, retry", FieldWait, time.Duration(wait)*time.Second, FieldError, err)
 		}),
 	}
 
-	streamInterceptors := []grpc.StreamClientInterceptor{
-		retry.StreamClientInterceptor(opts...),
+	idempotencyStore := options.idempotencyStore
+	if idempotencyStore == nil {
+		idempotencyStore = NewMemoryIdempotencyStore()
 	}
 
-	var unaryInterceptors []grpc.UnaryClientInterceptor
-	if conf.DisableResourceExhaustedRetry {
-		unaryInterceptors = []grpc.UnaryClientInterceptor{
-			retry.UnaryClientInterceptor(opts...),
-		}
-	} else {
-		unaryInterceptors = []grpc.UnaryClientInterceptor{
-			retry.UnaryClientInterceptor(opts...),
-			retry.UnaryClientInterceptorRE(exhaustedOpts...),
-		}
+	// источники данных для проверок задаются после создания соединения
+	risk := NewRiskManager(conf.RiskLimits, nil, nil, nil, nil)
+	risk.logger = l
+
+	// порядок интерсепторов: x-app-name, пользовательские интерсепторы вызова, логирование, перевод ошибок в Error,
+	// ключи идемпотентности заявок, предторговые проверки, ретраеры, лимитер, пользовательские интерсепторы попытки. x-app-name добавляется
+	// интерсептором, так как методы ...WithContext принимают произвольный контекст
+	unaryInterceptors := []grpc.UnaryClientInterceptor{
+		appNameUnaryInterceptor(conf.AppName),
+	}
+	unaryInterceptors = append(unaryInterceptors, options.unaryInterceptors...)
+	unaryInterceptors = append(unaryInterceptors,
+		loggingUnaryInterceptor(l),
+		errorsUnaryInterceptor(),
+		idempotencyUnaryInterceptor(idempotencyStore, l),
+		riskUnaryInterceptor(risk),
+		// коды, количество попыток и ожидание ретраера задаются политикой метода из RetryPolicies
+		retryPolicyUnaryInterceptor(conf),
+		retry.UnaryClientInterceptor(),
+	)
+	if !conf.DisableResourceExhaustedRetry {
+		unaryInterceptors = append(unaryInterceptors, retry.UnaryClientInterceptorRE(exhaustedOpts...))
 	}
+	// лимитер стоит после ретраеров, чтобы каждая попытка запроса расходовала токен
+	var limiter *rateLimiter
+	if conf.EnableRateLimiter {
+		limiter = newRateLimiter()
+		unaryInterceptors = append(unaryInterceptors, limiter.unaryInterceptor())
+	}
+	unaryInterceptors = append(unaryInterceptors, options.unaryAttemptInterceptors...)
 
-	conn, err := grpc.Dial(conf.EndPoint,
-		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})),
-		grpc.WithPerRPCCredentials(oauth.TokenSource{
-			TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: conf.Token}),
-		}),
+	streamInterceptors := []grpc.StreamClientInterceptor{
+		appNameStreamInterceptor(conf.AppName),
+	}
+	streamInterceptors = append(streamInterceptors, options.streamInterceptors...)
+	streamInterceptors = append(streamInterceptors, retryPolicyStreamInterceptor(conf), retry.StreamClientInterceptor())
+	streamInterceptors = append(streamInterceptors, options.streamAttemptInterceptors...)
+
+	dialOptions := append(credentialsOptions(conf),
 		grpc.WithChainUnaryInterceptor(unaryInterceptors...),
 		grpc.WithChainStreamInterceptor(streamInterceptors...))
+	// пользовательские опции идут последними и могут переопределить опции по умолчанию
+	dialOptions = append(dialOptions, options.dialOptions...)
+
+	conn, err := grpc.Dial(conf.EndPoint, dialOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -84,41 +118,100 @@ func NewClient(ctx context.Context, conf Config, l Logger) (*Client, error) {
 		conn:   conn,
 		Config: conf,
 		Logger: l,
+		Risk:   risk,
 		ctx:    ctx,
 	}
+	risk.trading = client.NewTrading()
+	risk.instruments = client.NewInstrumentsServiceClient()
+	risk.marketData = client.NewMarketDataServiceClient()
+	risk.margin = client.NewUsersServiceClient()
 
-	if conf.AccountId == "" {
-		s := client.NewSandboxServiceClient()
-		accountsResp, err := s.GetSandboxAccounts()
+	if limiter != nil {
+		tariff, err := client.NewUsersServiceClient().GetUserTariff()
 		if err != nil {
 			return nil, err
 		}
-		accs := accountsResp.GetAccounts()
-		if len(accs) < 1 {
-			resp, err := s.OpenSandboxAccount()
+		limiter.load(tariff.GetUserTariffResponse)
+	}
+
+	if conf.AccountId == "" {
+		selector := options.accountSelector
+		if selector == nil {
+			selector, err = accountSelector(conf)
 			if err != nil {
 				return nil, err
 			}
-			client.Config.AccountId = resp.GetAccountId()
-		} else {
-			for _, acc := range accs {
-				if acc.GetStatus() == pb.AccountStatus_ACCOUNT_STATUS_OPEN {
-					client.Config.AccountId = acc.GetId()
-					break
-				}
-			}
+		}
+		client.Config.AccountId, err = client.discoverAccount(selector)
+		if err != nil {
+			return nil, err
 		}
 	}
 
 	return client, nil
 }
 
+// withAppName - добавляет в исходящие метаданные x-app-name, если его там еще нет
+func withAppName(ctx context.Context, appName string) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get("x-app-name")) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "x-app-name", appName)
+}
+
+func appNameUnaryInterceptor(appName string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withAppName(ctx, appName), method, req, reply, cc, opts...)
+	}
+}
+
+func appNameStreamInterceptor(appName string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withAppName(ctx, appName), desc, cc, method, opts...)
+	}
+}
+
+// credentialsOptions - опции аутентификации соединения, для Insecure токен передается без TLS
+func credentialsOptions(conf Config) []grpc.DialOption {
+	if conf.Insecure {
+		return []grpc.DialOption{
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithPerRPCCredentials(insecureToken(conf.Token)),
+		}
+	}
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})),
+		grpc.WithPerRPCCredentials(oauth.TokenSource{
+			TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: conf.Token}),
+		}),
+	}
+}
+
+// insecureToken - токен авторизации, который можно передавать по соединению без TLS
+type insecureToken string
+
+func (t insecureToken) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	return map[string]string{"authorization": fmt.Sprintf("Bearer %s", string(t))}, nil
+}
+
+func (t insecureToken) RequireTransportSecurity() bool {
+	return false
+}
+
 func setDefaultConfig(conf *Config) {
 	if conf.AppName == "" {
 		conf.AppName = "invest-api-go-sdk"
 	}
+	// контур определяется по эндпоинту, если не задан явно, а эндпоинт по контуру
+	if conf.Environment == "" {
+		if conf.EndPoint == ProductionEndPoint {
+			conf.Environment = EnvironmentProduction
+		} else {
+			conf.Environment = EnvironmentSandbox
+		}
+	}
 	if conf.EndPoint == "" {
-		conf.EndPoint = "sandbox-invest-public-api.tinkoff.ru:443"
+		conf.EndPoint = conf.Environment.EndPoint()
 	}
 	if conf.DisableAllRetry {
 		conf.MaxRetries = 0
@@ -127,12 +220,6 @@ func setDefaultConfig(conf *Config) {
 	}
 }
 
-type Logger interface {
-	Infof(template string, args ...any)
-	Errorf(template string, args ...any)
-	Fatalf(template string, args ...any)
-}
-
 // NewMarketDataStreamClient - создание клиента для сервиса стримов маркетадаты
 func (c *Client) NewMarketDataStreamClient() *MarketDataStreamClient {
 	pbClient := pb.NewMarketDataStreamServiceClient(c.conn)
@@ -269,6 +356,6 @@ func (c *Client) NewOperationsStreamClient() *OperationsStreamClient {
 
 // Stop - корректное завершение работы клиента
 func (c *Client) Stop() error {
-	c.Logger.Infof("stop client")
+	logInfo(c.Logger, "stop client")
 	return c.conn.Close()
 }
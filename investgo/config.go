@@ -1,9 +1,16 @@
 package investgo
 
 import (
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -12,22 +19,46 @@ type Config struct {
 	// EndPoint - Для работы с реальным контуром и контуром песочницы нужны разные эндпоинты.
 	// По умолчанию = sandbox-invest-public-api.tinkoff.ru:443
 	//https://tinkoff.github.io/investAPI/url_difference/
-	EndPoint string `yaml:"EndPoint"`
+	EndPoint string `yaml:"EndPoint" json:"EndPoint" toml:"EndPoint"`
 	// Token - Ваш токен для Tinkoff InvestAPI
-	Token string `yaml:"APIToken"`
+	Token string `yaml:"APIToken" json:"APIToken" toml:"APIToken"`
+	// TokenFile - Путь к файлу с токеном, например смонтированному секрету. При загрузке через NewConfig токен
+	// читается из файла в том слое, где задан TokenFile, и заменяет Token этого и предыдущих слоев, а Token
+	// из следующих слоев имеет приоритет над ним
+	TokenFile string `yaml:"APITokenFile" json:"APITokenFile" toml:"APITokenFile"`
 	// AppName - Название вашего приложения, по умолчанию = tinkoff-api-go-sdk
-	AppName string `yaml:"AppName"`
-	// AccountId - Если уже есть аккаунт для апи можно указать напрямую,
-	// по умолчанию откроется новый счет в песочнице
-	AccountId string `yaml:"AccountId"`
+	AppName string `yaml:"AppName" json:"AppName" toml:"AppName"`
+	// Environment - Контур: sandbox или production. Если не задан, определяется по EndPoint,
+	// а если не задан и EndPoint - песочница. EndPoint по умолчанию выбирается по контуру
+	Environment Environment `yaml:"Environment" json:"Environment" toml:"Environment"`
+	// AccountId - Если уже есть аккаунт для апи можно указать напрямую. Иначе счет выбирается по AccountName,
+	// AccountType или первый открытый, а в песочнице без счетов откроется новый счет
+	AccountId string `yaml:"AccountId" json:"AccountId" toml:"AccountId"`
+	// AccountName - Выбор счета по названию, если не указан AccountId
+	AccountName string `yaml:"AccountName" json:"AccountName" toml:"AccountName"`
+	// AccountType - Выбор первого открытого счета заданного типа, если не указан AccountId: tinkoff, tinkoff_iis, invest_box
+	AccountType string `yaml:"AccountType" json:"AccountType" toml:"AccountType"`
 	// DisableResourceExhaustedRetry - Если true, то сдк не пытается ретраить, после получения ошибки об исчерпывании
 	// лимита запросов, если false, то сдк ждет нужное время и пытается выполнить запрос снова. По умолчанию = false
-	DisableResourceExhaustedRetry bool `yaml:"DisableResourceExhaustedRetry"`
+	DisableResourceExhaustedRetry bool `yaml:"DisableResourceExhaustedRetry" json:"DisableResourceExhaustedRetry" toml:"DisableResourceExhaustedRetry"`
 	// DisableAllRetry - Отключение всех ретраев
-	DisableAllRetry bool `yaml:"DisableAllRetry"`
-	// MaxRetries - Максимальное количество попыток переподключения, по умолчанию = 3
+	DisableAllRetry bool `yaml:"DisableAllRetry" json:"DisableAllRetry" toml:"DisableAllRetry"`
+	// MaxRetries - Максимальное количество попыток переподключения, по умолчанию = 3. Используется для политик
+	// ретраев из RetryPolicies, в которых не задан MaxAttempts
 	// (если указать значение 0 это не отключит ретраи, для отключения нужно прописать DisableAllRetry = true)
-	MaxRetries uint `yaml:"MaxRetries"`
+	MaxRetries uint `yaml:"MaxRetries" json:"MaxRetries" toml:"MaxRetries"`
+	// EnableRateLimiter - Если true, то сдк загружает лимиты тарифа через GetUserTariff и придерживает unary-запросы,
+	// которые превысили бы лимит, вместо получения ошибки ResourceExhausted. По умолчанию = false
+	EnableRateLimiter bool `yaml:"EnableRateLimiter" json:"EnableRateLimiter" toml:"EnableRateLimiter"`
+	// RetryPolicies - Политики ретраев по полному имени метода grpc, например
+	// "/tinkoff.public.invest.api.contract.v1.OrdersService/PostOrder", или по категории: read, order, stream.
+	// Политика метода имеет приоритет над политикой категории, незаданные политики берутся из DefaultRetryPolicies
+	RetryPolicies map[string]RetryPolicy `yaml:"RetryPolicies" json:"RetryPolicies" toml:"RetryPolicies"`
+	// RiskLimits - Лимиты предторговых проверок заявок, нулевые значения выключают проверку. Аварийный
+	// выключатель Client.Risk работает и без лимитов
+	RiskLimits RiskLimits `yaml:"RiskLimits" json:"RiskLimits" toml:"RiskLimits"`
+	// Insecure - Подключение без TLS, нужно только для локальных серверов, например investgo/fake. По умолчанию = false
+	Insecure bool `yaml:"Insecure" json:"Insecure" toml:"Insecure"`
 }
 
 // LoadConfig - загрузка конфигурации для сдк из .yaml файла
@@ -39,7 +70,245 @@ func LoadConfig(filename string) (Config, error) {
 	}
 	err = yaml.Unmarshal(input, &c)
 	if err != nil {
-		log.Println(err)
+		return Config{}, fmt.Errorf("parse config %v: %w", filename, err)
 	}
 	return c, nil
 }
+
+// EnvPrefix - Префикс переменных окружения конфигурации по умолчанию
+const EnvPrefix = "INVEST_"
+
+// MaxRetriesLimit - Максимально допустимое значение MaxRetries
+const MaxRetriesLimit = 10
+
+// ErrInvalidConfig - Ошибка валидации конфигурации, конкретные ошибки оборачивают ее
+var ErrInvalidConfig = errors.New("invalid config")
+
+// ConfigOption - Источник конфигурации для NewConfig
+type ConfigOption func(*configOptions)
+
+type configOptions struct {
+	files     []string
+	envPrefix string
+	noEnv     bool
+	overrides []func(*Config)
+}
+
+// WithConfigFile - Файл конфигурации, формат определяется по расширению: .yaml, .yml, .json или .toml.
+// Если файлов несколько, они применяются по порядку
+func WithConfigFile(filename string) ConfigOption {
+	return func(o *configOptions) {
+		o.files = append(o.files, filename)
+	}
+}
+
+// WithEnvPrefix - Префикс переменных окружения, по умолчанию EnvPrefix
+func WithEnvPrefix(prefix string) ConfigOption {
+	return func(o *configOptions) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithoutEnv - Не читать переменные окружения
+func WithoutEnv() ConfigOption {
+	return func(o *configOptions) {
+		o.noEnv = true
+	}
+}
+
+// WithOverride - Явное изменение конфигурации, применяется после файлов и переменных окружения
+func WithOverride(override func(c *Config)) ConfigOption {
+	return func(o *configOptions) {
+		o.overrides = append(o.overrides, override)
+	}
+}
+
+// NewConfig - Загрузка конфигурации по слоям: значения по умолчанию, файлы, переменные окружения INVEST_*,
+// явные изменения. Если слой задает TokenFile, токен читается из файла сразу после слоя. Затем контур и эндпоинт
+// выбираются по умолчанию, и конфигурация проверяется через Validate.
+//
+// Переменные окружения: INVEST_ENVIRONMENT, INVEST_ENDPOINT, INVEST_TOKEN, INVEST_TOKEN_FILE, INVEST_APP_NAME,
+// INVEST_ACCOUNT_ID, INVEST_ACCOUNT_NAME, INVEST_ACCOUNT_TYPE, INVEST_DISABLE_RESOURCE_EXHAUSTED_RETRY,
+// INVEST_DISABLE_ALL_RETRY, INVEST_MAX_RETRIES, INVEST_ENABLE_RATE_LIMITER, INVEST_INSECURE
+func NewConfig(opts ...ConfigOption) (Config, error) {
+	o := configOptions{envPrefix: EnvPrefix}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := DefaultConfig()
+	for _, filename := range o.files {
+		filename := filename
+		if err := c.layer(func(c *Config) error { return c.loadFile(filename) }); err != nil {
+			return Config{}, err
+		}
+	}
+	if !o.noEnv {
+		if err := c.layer(func(c *Config) error { return c.loadEnv(o.envPrefix) }); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, override := range o.overrides {
+		override := override
+		if err := c.layer(func(c *Config) error { override(c); return nil }); err != nil {
+			return Config{}, err
+		}
+	}
+	setDefaultConfig(&c)
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// DefaultConfig - Конфигурация со значениями по умолчанию, без токена. Контур и эндпоинт не заполняются,
+// чтобы их можно было задать следующими слоями, NewConfig и NewClient выбирают их по умолчанию сами
+func DefaultConfig() Config {
+	return Config{
+		AppName:    "invest-api-go-sdk",
+		MaxRetries: 3,
+	}
+}
+
+// Validate - Проверка конфигурации: непустой токен, известный контур и тип счета, эндпоинт в формате host:port,
+// совпадение контура с эндпоинтом песочницы или боевого контура, MaxRetries не больше MaxRetriesLimit, ключи и значения RetryPolicies, неотрицательные RiskLimits.
+// Все найденные ошибки объединяются, каждая оборачивает ErrInvalidConfig
+func (c Config) Validate() error {
+	var errs []error
+	if c.Token == "" {
+		errs = append(errs, fmt.Errorf("%w: token is empty", ErrInvalidConfig))
+	}
+	if !c.Environment.Valid() {
+		errs = append(errs, fmt.Errorf("%w: unknown environment %q", ErrInvalidConfig, c.Environment))
+	}
+	if c.AccountType != "" {
+		if _, err := ParseAccountType(c.AccountType); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %v", ErrInvalidConfig, err))
+		}
+	}
+	if err := validateEndPoint(c.EndPoint); err != nil {
+		errs = append(errs, fmt.Errorf("%w: endpoint %q: %v", ErrInvalidConfig, c.EndPoint, err))
+	}
+	if env := endPointEnvironment(c.EndPoint); env != "" && c.Environment != "" && env != c.Environment {
+		errs = append(errs, fmt.Errorf("%w: endpoint %q belongs to %v, but environment is %v", ErrInvalidConfig, c.EndPoint, env, c.Environment))
+	}
+	if c.MaxRetries > MaxRetriesLimit {
+		errs = append(errs, fmt.Errorf("%w: MaxRetries = %v, must be at most %v", ErrInvalidConfig, c.MaxRetries, MaxRetriesLimit))
+	}
+	errs = append(errs, c.validateRetryPolicies()...)
+	errs = append(errs, c.RiskLimits.validate()...)
+	return errors.Join(errs...)
+}
+
+// endPointEnvironment - Контур, которому принадлежит эндпоинт, или пустая строка для остальных эндпоинтов,
+// например локальных серверов и прокси
+func endPointEnvironment(endPoint string) Environment {
+	switch endPoint {
+	case SandboxEndPoint:
+		return EnvironmentSandbox
+	case ProductionEndPoint:
+		return EnvironmentProduction
+	}
+	return ""
+}
+
+func validateEndPoint(endPoint string) error {
+	host, port, err := net.SplitHostPort(endPoint)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return errors.New("missing host")
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// layer - применение слоя конфигурации. Если слой изменил TokenFile, токен читается из файла сразу,
+// чтобы Token из следующих слоев имел приоритет
+func (c *Config) layer(apply func(c *Config) error) error {
+	tokenFile := c.TokenFile
+	if err := apply(c); err != nil {
+		return err
+	}
+	if c.TokenFile == "" || c.TokenFile == tokenFile {
+		return nil
+	}
+	token, err := os.ReadFile(c.TokenFile)
+	if err != nil {
+		return fmt.Errorf("read token file: %w", err)
+	}
+	c.Token = strings.TrimSpace(string(token))
+	return nil
+}
+
+// loadFile - загрузка значений из файла поверх текущих, формат по расширению
+func (c *Config) loadFile(filename string) error {
+	input, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(input, c)
+	case ".json":
+		err = json.Unmarshal(input, c)
+	case ".toml":
+		err = toml.Unmarshal(input, c)
+	default:
+		return fmt.Errorf("config %v: unknown format %q", filename, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config %v: %w", filename, err)
+	}
+	return nil
+}
+
+// loadEnv - загрузка значений из переменных окружения с префиксом prefix, незаданные переменные не меняют значения
+func (c *Config) loadEnv(prefix string) error {
+	strs := map[string]*string{
+		"ENDPOINT":     &c.EndPoint,
+		"TOKEN":        &c.Token,
+		"TOKEN_FILE":   &c.TokenFile,
+		"APP_NAME":     &c.AppName,
+		"ACCOUNT_ID":   &c.AccountId,
+		"ACCOUNT_NAME": &c.AccountName,
+		"ACCOUNT_TYPE": &c.AccountType,
+	}
+	if v, ok := os.LookupEnv(prefix + "ENVIRONMENT"); ok {
+		c.Environment = Environment(v)
+	}
+	for name, field := range strs {
+		if v, ok := os.LookupEnv(prefix + name); ok {
+			*field = v
+		}
+	}
+
+	bools := map[string]*bool{
+		"DISABLE_RESOURCE_EXHAUSTED_RETRY": &c.DisableResourceExhaustedRetry,
+		"DISABLE_ALL_RETRY":                &c.DisableAllRetry,
+		"ENABLE_RATE_LIMITER":              &c.EnableRateLimiter,
+		"INSECURE":                         &c.Insecure,
+	}
+	for name, field := range bools {
+		if v, ok := os.LookupEnv(prefix + name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%w: %v%v: %v", ErrInvalidConfig, prefix, name, err)
+			}
+			*field = b
+		}
+	}
+
+	if v, ok := os.LookupEnv(prefix + "MAX_RETRIES"); ok {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%w: %vMAX_RETRIES: %v", ErrInvalidConfig, prefix, err)
+		}
+		c.MaxRetries = uint(n)
+	}
+	return nil
+}
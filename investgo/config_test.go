@@ -0,0 +1,143 @@
+package investgo_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+)
+
+func TestValidateEnvironmentEndPoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment investgo.Environment
+		endPoint    string
+		wantErr     bool
+	}{
+		{"sandbox", investgo.EnvironmentSandbox, investgo.SandboxEndPoint, false},
+		{"production", investgo.EnvironmentProduction, investgo.ProductionEndPoint, false},
+		{"production with sandbox endpoint", investgo.EnvironmentProduction, investgo.SandboxEndPoint, true},
+		{"sandbox with production endpoint", investgo.EnvironmentSandbox, investgo.ProductionEndPoint, true},
+		{"custom endpoint", investgo.EnvironmentProduction, "localhost:8080", false},
+		{"environment from endpoint", "", investgo.ProductionEndPoint, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := investgo.DefaultConfig()
+			conf.Token = "token"
+			conf.Environment = tt.environment
+			conf.EndPoint = tt.endPoint
+			err := conf.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, investgo.ErrInvalidConfig) {
+				t.Fatalf("err = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestNewConfigRejectsMismatch(t *testing.T) {
+	_, err := investgo.NewConfig(investgo.WithoutEnv(), investgo.WithOverride(func(c *investgo.Config) {
+		c.Token = "token"
+		c.Environment = investgo.EnvironmentProduction
+		c.EndPoint = investgo.SandboxEndPoint
+	}))
+	if !errors.Is(err, investgo.ErrInvalidConfig) {
+		t.Fatalf("err = %v, want ErrInvalidConfig", err)
+	}
+
+	conf, err := investgo.NewConfig(investgo.WithoutEnv(), investgo.WithOverride(func(c *investgo.Config) {
+		c.Token = "token"
+		c.Environment = investgo.EnvironmentProduction
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.EndPoint != investgo.ProductionEndPoint {
+		t.Fatalf("endpoint = %v, want %v", conf.EndPoint, investgo.ProductionEndPoint)
+	}
+}
+
+func TestNewClientValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment investgo.Environment
+		endPoint    string
+	}{
+		{"production with sandbox endpoint", investgo.EnvironmentProduction, investgo.SandboxEndPoint},
+		{"sandbox with production endpoint", investgo.EnvironmentSandbox, investgo.ProductionEndPoint},
+		{"unknown environment", "prod", investgo.ProductionEndPoint},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := investgo.NewClient(context.Background(), investgo.Config{
+				Token:       "token",
+				Environment: tt.environment,
+				EndPoint:    tt.endPoint,
+			}, testLogger{})
+			if !errors.Is(err, investgo.ErrInvalidConfig) {
+				t.Fatalf("err = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestNewConfigTokenPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("APIToken: yaml-token\nAPITokenFile: "+tokenFile+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	const prefix = "TEST_INVEST_"
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		override func(c *investgo.Config)
+		want     string
+	}{
+		{name: "token file from yaml", want: "file-token"},
+		{name: "env token over yaml token file", env: map[string]string{"TOKEN": "env-token"}, want: "env-token"},
+		{name: "override over env", env: map[string]string{"TOKEN": "env-token"},
+			override: func(c *investgo.Config) { c.Token = "override-token" }, want: "override-token"},
+		{name: "env token file over env token", env: map[string]string{"TOKEN": "env-token", "TOKEN_FILE": tokenFile + "-env"},
+			want: "env-file-token"},
+	}
+	if err := os.WriteFile(tokenFile+"-env", []byte("env-file-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(prefix+name, value)
+			}
+			opts := []investgo.ConfigOption{investgo.WithConfigFile(configFile), investgo.WithEnvPrefix(prefix)}
+			if tt.override != nil {
+				opts = append(opts, investgo.WithOverride(tt.override))
+			}
+			conf, err := investgo.NewConfig(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conf.Token != tt.want {
+				t.Fatalf("token = %q, want %q", conf.Token, tt.want)
+			}
+		})
+	}
+
+	_, err := investgo.NewConfig(investgo.WithoutEnv(), investgo.WithOverride(func(c *investgo.Config) {
+		c.TokenFile = filepath.Join(dir, "missing")
+	}))
+	if err == nil {
+		t.Fatal("want error for missing token file")
+	}
+}
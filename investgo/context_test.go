@@ -0,0 +1,56 @@
+package investgo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const getLastPricesMethod = "MarketDataService/GetLastPrices"
+
+func TestWithContextCancellation(t *testing.T) {
+	srv := newTestServer(t)
+	conf := srv.Config()
+	conf.RetryPolicies = map[string]investgo.RetryPolicy{string(investgo.RetryCategoryRead): {
+		Codes:          []string{"Unavailable"},
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second,
+	}}
+	md := newTestClient(t, conf).NewMarketDataServiceClient()
+
+	// отмененный контекст: запрос не отправляется
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := md.GetLastPricesWithContext(ctx, []string{testFigi}); status.Code(err) != codes.Canceled {
+		t.Fatalf("err = %v, want Canceled", err)
+	}
+	if calls := srv.Calls(getLastPricesMethod); calls != 0 {
+		t.Fatalf("GetLastPrices calls = %d, want 0", calls)
+	}
+
+	// дедлайн прерывает ожидание между попытками ретраера
+	srv.InjectError(getLastPricesMethod, status.Error(codes.Unavailable, "unavailable"))
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := md.GetLastPricesWithContext(ctx, []string{testFigi})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("call took %v, want to stop at the deadline instead of the backoff", elapsed)
+	}
+	if calls := srv.Calls(getLastPricesMethod); calls != 1 {
+		t.Fatalf("GetLastPrices calls = %d, want 1 before the deadline", calls)
+	}
+
+	// контекст клиента не отменен, следующий запрос проходит
+	if _, err := md.GetLastPricesWithContext(context.Background(), []string{testFigi}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,7 +1,6 @@
 package investgo
 
 import (
-	"math"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -18,19 +17,31 @@ func TimeToTimestamp(t time.Time) *timestamppb.Timestamp {
 
 // FloatToQuotation - Перевод float в Quotation, step - шаг цены для инструмента (min_price_increment)
 func FloatToQuotation(number float64, step *pb.Quotation) *pb.Quotation {
-	// делим дробь на дробь и округляем до ближайшего целого
-	k := math.Round(number / step.ToFloat())
-	// целое умножаем на дробный шаг и получаем готовое дробное значение
-	roundedNumber := step.ToFloat() * k
-	// разделяем дробную и целую части
-	decNumber := decimal.NewFromFloat(roundedNumber)
-
-	intPart := decNumber.IntPart()
-	fracPart := decNumber.Sub(decimal.NewFromInt(intPart))
+	q := DecimalToQuotation(decimal.NewFromFloat(number))
+	rounded, err := RoundQuotation(q, step, RoundHalfUp)
+	if err != nil {
+		return q
+	}
+	return rounded
+}
 
-	nano := fracPart.Mul(decimal.NewFromInt(BILLION)).IntPart()
+// DecimalToQuotation - Перевод decimal.Decimal в Quotation, знаки после 9-го округляются
+func DecimalToQuotation(d decimal.Decimal) *pb.Quotation {
+	d = d.Round(9)
+	units := d.IntPart()
+	nano := d.Sub(decimal.NewFromInt(units)).Shift(9).IntPart()
 	return &pb.Quotation{
-		Units: intPart,
+		Units: units,
 		Nano:  int32(nano),
 	}
 }
+
+// DecimalToMoneyValue - Перевод decimal.Decimal в MoneyValue в валюте currency, знаки после 9-го округляются
+func DecimalToMoneyValue(d decimal.Decimal, currency string) *pb.MoneyValue {
+	q := DecimalToQuotation(d)
+	return &pb.MoneyValue{
+		Currency: currency,
+		Units:    q.GetUnits(),
+		Nano:     q.GetNano(),
+	}
+}
@@ -0,0 +1,168 @@
+package investgo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// ErrCurrencyMismatch - Ошибка арифметики над денежными суммами в разных валютах
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// ErrOverflow - Результат не помещается в Quotation или MoneyValue: целая часть выходит за пределы int64
+var ErrOverflow = errors.New("quotation overflow")
+
+// ErrInvalidIncrement - Ошибка округления к шагу цены, если шаг не положительный
+var ErrInvalidIncrement = errors.New("price increment must be positive")
+
+// RoundingMode - Способ округления цены к шагу min_price_increment
+type RoundingMode int
+
+const (
+	// RoundHalfUp - К ближайшему значению, половина шага округляется от нуля
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven - К ближайшему значению, половина шага округляется к четному числу шагов
+	RoundHalfEven
+	// RoundDown - Вниз, к меньшему значению
+	RoundDown
+	// RoundUp - Вверх, к большему значению
+	RoundUp
+)
+
+// AddQuotation - Сумма котировок a + b
+func AddQuotation(a, b *pb.Quotation) *pb.Quotation {
+	return DecimalToQuotation(a.ToDecimal().Add(b.ToDecimal()))
+}
+
+// SubQuotation - Разность котировок a - b
+func SubQuotation(a, b *pb.Quotation) *pb.Quotation {
+	return DecimalToQuotation(a.ToDecimal().Sub(b.ToDecimal()))
+}
+
+// MulQuotation - Умножение котировки на целое число, например цены на количество лотов.
+// Если результат не помещается в Quotation, возвращается ErrOverflow
+func MulQuotation(q *pb.Quotation, n int64) (*pb.Quotation, error) {
+	d := q.ToDecimal().Mul(decimal.NewFromInt(n))
+	if err := checkRange(d); err != nil {
+		return nil, err
+	}
+	return DecimalToQuotation(d), nil
+}
+
+// CompareQuotation - Сравнение котировок, возвращает -1 если a < b, 0 если a == b, +1 если a > b
+func CompareQuotation(a, b *pb.Quotation) int {
+	return a.ToDecimal().Cmp(b.ToDecimal())
+}
+
+// RoundQuotation - Округление котировки к шагу цены step (min_price_increment) способом mode
+func RoundQuotation(q, step *pb.Quotation, mode RoundingMode) (*pb.Quotation, error) {
+	inc := step.ToDecimal()
+	if inc.Sign() <= 0 {
+		return nil, ErrInvalidIncrement
+	}
+	d := q.ToDecimal()
+	// целое число шагов с отбрасыванием дробной части и остаток того же знака, что и d
+	steps, rem := d.QuoRem(inc, 0)
+	if !rem.IsZero() {
+		half := rem.Abs().Mul(decimal.NewFromInt(2)).Cmp(inc)
+		away := false
+		switch mode {
+		case RoundHalfUp:
+			away = half >= 0
+		case RoundHalfEven:
+			away = half > 0 || (half == 0 && steps.Mod(decimal.NewFromInt(2)).Abs().Equal(decimal.NewFromInt(1)))
+		case RoundDown:
+			away = rem.Sign() < 0
+		case RoundUp:
+			away = rem.Sign() > 0
+		default:
+			return nil, fmt.Errorf("unknown rounding mode %v", mode)
+		}
+		if away {
+			steps = steps.Add(decimal.NewFromInt(int64(rem.Sign())))
+		}
+	}
+	return DecimalToQuotation(steps.Mul(inc)), nil
+}
+
+// AddMoney - Сумма a + b, суммы должны быть в одной валюте, при переполнении возвращается ErrOverflow
+func AddMoney(a, b *pb.MoneyValue) (*pb.MoneyValue, error) {
+	currency, err := commonCurrency(a, b)
+	if err != nil {
+		return nil, err
+	}
+	d := a.ToDecimal().Add(b.ToDecimal())
+	if err := checkRange(d); err != nil {
+		return nil, err
+	}
+	return DecimalToMoneyValue(d, currency), nil
+}
+
+// SubMoney - Разность a - b, суммы должны быть в одной валюте, при переполнении возвращается ErrOverflow
+func SubMoney(a, b *pb.MoneyValue) (*pb.MoneyValue, error) {
+	currency, err := commonCurrency(a, b)
+	if err != nil {
+		return nil, err
+	}
+	d := a.ToDecimal().Sub(b.ToDecimal())
+	if err := checkRange(d); err != nil {
+		return nil, err
+	}
+	return DecimalToMoneyValue(d, currency), nil
+}
+
+// MulMoney - Умножение денежной суммы на целое число, валюта сохраняется.
+// Если результат не помещается в MoneyValue, возвращается ErrOverflow
+func MulMoney(m *pb.MoneyValue, n int64) (*pb.MoneyValue, error) {
+	d := m.ToDecimal().Mul(decimal.NewFromInt(n))
+	if err := checkRange(d); err != nil {
+		return nil, err
+	}
+	return DecimalToMoneyValue(d, m.GetCurrency()), nil
+}
+
+// CompareMoney - Сравнение денежных сумм в одной валюте, возвращает -1 если a < b, 0 если a == b, +1 если a > b
+func CompareMoney(a, b *pb.MoneyValue) (int, error) {
+	if _, err := commonCurrency(a, b); err != nil {
+		return 0, err
+	}
+	return a.ToDecimal().Cmp(b.ToDecimal()), nil
+}
+
+// MoneyValueFromQuotation - Денежная сумма в валюте currency из котировки, например цена инструмента
+func MoneyValueFromQuotation(q *pb.Quotation, currency string) *pb.MoneyValue {
+	return DecimalToMoneyValue(q.ToDecimal(), currency)
+}
+
+var (
+	maxUnits = decimal.NewFromInt(math.MaxInt64)
+	minUnits = decimal.NewFromInt(math.MinInt64)
+)
+
+// checkRange - проверка, что целая часть d помещается в units котировки
+func checkRange(d decimal.Decimal) error {
+	units := d.Round(9).Truncate(0)
+	if units.GreaterThan(maxUnits) || units.LessThan(minUnits) {
+		return fmt.Errorf("%w: %s", ErrOverflow, d.String())
+	}
+	return nil
+}
+
+// commonCurrency - валюта результата операции над a и b, nil считается нулем в любой валюте.
+// Регистр не учитывается, так как InvestAPI возвращает валюты и в верхнем, и в нижнем регистре
+func commonCurrency(a, b *pb.MoneyValue) (string, error) {
+	switch {
+	case a == nil:
+		return b.GetCurrency(), nil
+	case b == nil:
+		return a.GetCurrency(), nil
+	case !strings.EqualFold(a.GetCurrency(), b.GetCurrency()):
+		return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, a.GetCurrency(), b.GetCurrency())
+	default:
+		return a.GetCurrency(), nil
+	}
+}
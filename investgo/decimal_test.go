@@ -0,0 +1,57 @@
+package investgo_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+func TestMulQuotation(t *testing.T) {
+	got, err := investgo.MulQuotation(&pb.Quotation{Units: 250, Nano: 500000000}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetUnits() != 751 || got.GetNano() != 500000000 {
+		t.Fatalf("got %v, want 751.5", got)
+	}
+
+	_, err = investgo.MulQuotation(&pb.Quotation{Units: math.MaxInt64 / 2}, 3)
+	if !errors.Is(err, investgo.ErrOverflow) {
+		t.Fatalf("err = %v, want ErrOverflow", err)
+	}
+	_, err = investgo.MulQuotation(&pb.Quotation{Units: math.MaxInt64 / 2}, -3)
+	if !errors.Is(err, investgo.ErrOverflow) {
+		t.Fatalf("err = %v, want ErrOverflow", err)
+	}
+}
+
+func TestMulMoney(t *testing.T) {
+	got, err := investgo.MulMoney(&pb.MoneyValue{Currency: "rub", Units: 10, Nano: 10000000}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetCurrency() != "rub" || got.GetUnits() != 1001 || got.GetNano() != 0 {
+		t.Fatalf("got %v, want 1001 rub", got)
+	}
+
+	_, err = investgo.MulMoney(&pb.MoneyValue{Currency: "rub", Units: 1 << 40}, 1<<30)
+	if !errors.Is(err, investgo.ErrOverflow) {
+		t.Fatalf("err = %v, want ErrOverflow", err)
+	}
+}
+
+func TestAddMoneyOverflow(t *testing.T) {
+	top := &pb.MoneyValue{Currency: "rub", Units: math.MaxInt64}
+	if _, err := investgo.AddMoney(top, &pb.MoneyValue{Currency: "rub", Nano: 500000000}); err != nil {
+		t.Fatalf("fractional part must fit: %v", err)
+	}
+	if _, err := investgo.AddMoney(top, &pb.MoneyValue{Currency: "rub", Units: 1}); !errors.Is(err, investgo.ErrOverflow) {
+		t.Fatalf("err = %v, want ErrOverflow", err)
+	}
+	if _, err := investgo.AddMoney(top, &pb.MoneyValue{Currency: "usd", Units: 1}); !errors.Is(err, investgo.ErrCurrencyMismatch) {
+		t.Fatalf("err = %v, want ErrCurrencyMismatch", err)
+	}
+}
@@ -7,6 +7,16 @@ Package investgo предоставляет инструменты для раб
 есть свой конфиг, который привязывает его к определенному счету и токену. Если есть потребность использовать разные счета и токены, нужно
 создавать разных клиентов. investgo.Client предоставляет функции-конcтрукторы для всех сервисов Tinkoff InvestAPI.
 
+# Context
+
+Методы сервисов по умолчанию используют контекст, переданный в investgo.NewClient(). У каждого метода есть вариант
+с суффиксом WithContext, который принимает context.Context первым аргументом: его отмена или дедлайн прерывают
+запрос, в том числе ожидание между ретраями.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := client.NewOrdersServiceClient().GetOrdersWithContext(ctx, client.Config.AccountId)
+
 Подробнее смотрите в директории examples.
 */
 package investgo
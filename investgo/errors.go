@@ -0,0 +1,179 @@
+package investgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Категории ошибок InvestAPI для проверки через errors.Is(err, investgo.ErrInsufficientFunds)
+var (
+	// ErrInsufficientFunds - Недостаточно денег или активов для сделки
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrNotTradable - Инструмент недоступен для торгов
+	ErrNotTradable = errors.New("instrument is not tradable")
+	// ErrRateLimited - Превышен лимит запросов
+	ErrRateLimited = errors.New("rate limited")
+	// ErrInvalidToken - Токен недействителен или не передан
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrOrderNotFound - Заявка или стоп-заявка не найдена
+	ErrOrderNotFound = errors.New("order not found")
+)
+
+// errorCategories - коды ошибок InvestAPI по категориям, https://tinkoff.github.io/investAPI/errors/
+var errorCategories = map[error][]string{
+	ErrInsufficientFunds: {"30034", "30042"},
+	ErrNotTradable:       {"30079"},
+	ErrRateLimited:       {"80002"},
+	ErrInvalidToken:      {"40003"},
+	ErrOrderNotFound:     {"50005", "50006"},
+}
+
+// RateLimit - Информация о лимите запросов из заголовков ответа
+type RateLimit struct {
+	// Limit - Лимит запросов в минуту, -1 если заголовка нет
+	Limit int
+	// Remaining - Остаток запросов, -1 если заголовка нет
+	Remaining int
+	// Reset - Время до сброса лимита
+	Reset time.Duration
+}
+
+// Error - Ошибка InvestAPI. Методы сервисов возвращают ее вместо ошибки grpc, при этом
+// status.Code(err) и status.FromError(err) продолжают работать
+type Error struct {
+	// GRPCCode - Код grpc
+	GRPCCode codes.Code
+	// Code - Код ошибки InvestAPI, например "30079"
+	Code string
+	// Message - Описание ошибки из заголовка message
+	Message string
+	// TrackingId - Идентификатор запроса x-tracking-id для обращения в поддержку
+	TrackingId string
+	// RateLimit - Лимиты запросов на момент ошибки
+	RateLimit RateLimit
+
+	status *status.Status
+}
+
+// Error - Текст ошибки с кодами grpc и InvestAPI
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("investgo: code = %v", e.GRPCCode)
+	if e.Code != "" {
+		msg += fmt.Sprintf(", api code = %v", e.Code)
+	}
+	if e.Message != "" {
+		msg += fmt.Sprintf(", message = %v", e.Message)
+	}
+	if e.TrackingId != "" {
+		msg += fmt.Sprintf(", tracking id = %v", e.TrackingId)
+	}
+	return msg
+}
+
+// GRPCStatus - Исходный статус grpc
+func (e *Error) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// Is - Проверка категории ошибки, например errors.Is(err, investgo.ErrRateLimited)
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		if e.GRPCCode == codes.ResourceExhausted {
+			return true
+		}
+	case ErrInvalidToken:
+		if e.GRPCCode == codes.Unauthenticated {
+			return true
+		}
+	}
+	for _, code := range errorCategories[target] {
+		if code == e.Code {
+			return true
+		}
+	}
+	return false
+}
+
+// newError - ошибка InvestAPI из ошибки grpc и метаданных ответа, остальные ошибки возвращаются без изменений
+func newError(err error, md metadata.MD) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	// InvestAPI передает код ошибки в описании статуса, а текст ошибки в заголовке message
+	code, message := s.Message(), MessageFromHeader(md)
+	if _, err := strconv.Atoi(code); err != nil {
+		if message == "" {
+			message = code
+		}
+		code = ""
+	}
+	return &Error{
+		GRPCCode:   s.Code(),
+		Code:       code,
+		Message:    message,
+		TrackingId: firstValue(md, "x-tracking-id"),
+		RateLimit: RateLimit{
+			Limit:     intValue(md, "x-ratelimit-limit"),
+			Remaining: RemainingLimitFromHeader(md),
+			Reset:     resetFromHeader(md),
+		},
+		status: s,
+	}
+}
+
+// NewError - Ошибка InvestAPI с кодом grpc code и кодом InvestAPI apiCode. Нужна реализациям Trading без сервера,
+// например paper-брокеру, чтобы их ошибки проверялись через errors.Is так же, как ошибки InvestAPI
+func NewError(code codes.Code, apiCode, message string) *Error {
+	return &Error{
+		GRPCCode:  code,
+		Code:      apiCode,
+		Message:   message,
+		RateLimit: RateLimit{Limit: -1, Remaining: -1},
+		status:    status.New(code, apiCode),
+	}
+}
+
+func firstValue(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func intValue(md metadata.MD, key string) int {
+	v, err := strconv.Atoi(firstValue(md, key))
+	if err != nil {
+		return -1
+	}
+	return v
+}
+
+// errorsUnaryInterceptor - перевод ошибок grpc в Error, стоит перед ретраерами, чтобы они работали с исходными ошибками
+func errorsUnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var header, trailer metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header), grpc.Trailer(&trailer))...)
+		if err != nil {
+			return newError(err, metadata.Join(header, trailer))
+		}
+		return nil
+	}
+}
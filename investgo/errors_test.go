@@ -0,0 +1,116 @@
+package investgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewErrorParsing(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		md      metadata.MD
+		want    Error
+		message string
+	}{
+		{
+			name: "api code in status message",
+			err:  status.Error(codes.InvalidArgument, "30042"),
+			md: metadata.Pairs("message", "Not enough assets", "x-tracking-id", "tracking-1",
+				"x-ratelimit-limit", "100", "x-ratelimit-remaining", "99", "x-ratelimit-reset", "30"),
+			want: Error{GRPCCode: codes.InvalidArgument, Code: "30042", Message: "Not enough assets", TrackingId: "tracking-1",
+				RateLimit: RateLimit{Limit: 100, Remaining: 99, Reset: 30e9}},
+		},
+		{
+			name: "text status message",
+			err:  status.Error(codes.Unavailable, "connection refused"),
+			want: Error{GRPCCode: codes.Unavailable, Message: "connection refused", RateLimit: RateLimit{Limit: -1, Remaining: -1}},
+		},
+		{
+			name: "text status message with header",
+			err:  status.Error(codes.Internal, "internal"),
+			md:   metadata.Pairs("message", "from header"),
+			want: Error{GRPCCode: codes.Internal, Message: "from header", RateLimit: RateLimit{Limit: -1, Remaining: -1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e *Error
+			if !errors.As(newError(tt.err, tt.md), &e) {
+				t.Fatalf("err = %v, want *Error", e)
+			}
+			if e.GRPCCode != tt.want.GRPCCode || e.Code != tt.want.Code || e.Message != tt.want.Message ||
+				e.TrackingId != tt.want.TrackingId || e.RateLimit != tt.want.RateLimit {
+				t.Fatalf("error = %+v, want %+v", *e, tt.want)
+			}
+			if status.Code(e) != tt.want.GRPCCode {
+				t.Fatalf("status code = %v, want %v", status.Code(e), tt.want.GRPCCode)
+			}
+		})
+	}
+
+	// ошибки не grpc и уже переведенные ошибки не меняются
+	plain := errors.New("plain")
+	if newError(plain, nil) != plain || newError(nil, nil) != nil {
+		t.Fatal("non grpc errors must be returned unchanged")
+	}
+	e := NewError(codes.NotFound, "50005", "not found")
+	if newError(e, metadata.Pairs("x-tracking-id", "other")) != error(e) {
+		t.Fatal("*Error must be returned unchanged")
+	}
+}
+
+func TestErrorCategories(t *testing.T) {
+	categories := []error{ErrInsufficientFunds, ErrNotTradable, ErrRateLimited, ErrInvalidToken, ErrOrderNotFound}
+	tests := []struct {
+		code    codes.Code
+		apiCode string
+		want    error
+	}{
+		{codes.InvalidArgument, "30034", ErrInsufficientFunds},
+		{codes.InvalidArgument, "30042", ErrInsufficientFunds},
+		{codes.InvalidArgument, "30079", ErrNotTradable},
+		{codes.ResourceExhausted, "80002", ErrRateLimited},
+		{codes.ResourceExhausted, "", ErrRateLimited},
+		{codes.Unauthenticated, "40003", ErrInvalidToken},
+		{codes.Unauthenticated, "", ErrInvalidToken},
+		{codes.NotFound, "50005", ErrOrderNotFound},
+		{codes.NotFound, "50006", ErrOrderNotFound},
+		{codes.InvalidArgument, "30001", nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %v", tt.code, tt.apiCode), func(t *testing.T) {
+			err := newError(status.Error(tt.code, tt.apiCode), nil)
+			// категория проверяется и через обертки
+			wrapped := fmt.Errorf("post order: %w", err)
+			for _, category := range categories {
+				if got := errors.Is(wrapped, category); got != (category == tt.want) {
+					t.Fatalf("errors.Is(%v, %v) = %v", err, category, got)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorsUnaryInterceptorTrailer(t *testing.T) {
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		for _, opt := range opts {
+			if tr, ok := opt.(grpc.TrailerCallOption); ok {
+				*tr.TrailerAddr = metadata.Pairs("x-tracking-id", "tracking-2", "message", "Order not found")
+			}
+		}
+		return status.Error(codes.NotFound, "50005")
+	}
+	err := errorsUnaryInterceptor()(context.Background(), "/svc/Method", nil, nil, nil, invoker)
+	var e *Error
+	if !errors.As(err, &e) || e.TrackingId != "tracking-2" || e.Message != "Order not found" || !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("err = %v, want order not found with tracking id from trailer", err)
+	}
+}
@@ -0,0 +1,46 @@
+package fake
+
+import (
+	"github.com/shopspring/decimal"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// Quotation - Создание котировки из целого числа
+func Quotation(units int64) *pb.Quotation {
+	return &pb.Quotation{Units: units}
+}
+
+// QuotationFromFloat - Создание котировки из числа с плавающей точкой
+func QuotationFromFloat(number float64) *pb.Quotation {
+	return toQuotation(decimal.NewFromFloat(number))
+}
+
+// Money - Создание денежной суммы в валюте currency
+func Money(units int64, currency string) *pb.MoneyValue {
+	return &pb.MoneyValue{Units: units, Currency: currency}
+}
+
+func toDecimal(q *pb.Quotation) decimal.Decimal {
+	if q == nil {
+		return decimal.Zero
+	}
+	return decimal.New(q.GetUnits(), 0).Add(decimal.New(int64(q.GetNano()), -9))
+}
+
+func moneyToDecimal(m *pb.MoneyValue) decimal.Decimal {
+	if m == nil {
+		return decimal.Zero
+	}
+	return decimal.New(m.GetUnits(), 0).Add(decimal.New(int64(m.GetNano()), -9))
+}
+
+func toQuotation(d decimal.Decimal) *pb.Quotation {
+	units := d.IntPart()
+	nano := d.Sub(decimal.NewFromInt(units)).Shift(9).IntPart()
+	return &pb.Quotation{Units: units, Nano: int32(nano)}
+}
+
+func toMoney(d decimal.Decimal, currency string) *pb.MoneyValue {
+	q := toQuotation(d)
+	return &pb.MoneyValue{Currency: currency, Units: q.GetUnits(), Nano: q.GetNano()}
+}
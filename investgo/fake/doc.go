@@ -0,0 +1,27 @@
+/*
+Package fake предоставляет локальный gRPC сервер, реализующий все сервисы Tinkoff InvestAPI из директории proto.
+
+# Server
+
+Сервер хранит состояние в памяти: счета, инструменты, заявки, стоп-заявки, позиции, операции и рыночные данные.
+Состояние заполняется и изменяется методами сервера (AddShare, PayIn, SetLastPrice, PushCandle и т.д.),
+поэтому на нем можно тестировать стратегии без подключения к Tinkoff InvestAPI:
+
+	srv := fake.NewServer()
+	if err := srv.Start(); err != nil {
+		return err
+	}
+	defer srv.Stop()
+
+	srv.AddShare(&pb.Share{Figi: "BBG004730N88", Ticker: "SBER", ClassCode: "TQBR", Lot: 10, Currency: "rub"})
+	srv.PayIn(srv.AccountId(), fake.Money(100000, "rub"))
+	srv.SetLastPrice("BBG004730N88", fake.Quotation(250))
+
+	client, err := investgo.NewClient(ctx, srv.Config(), logger)
+
+Рыночные заявки исполняются сразу по лучшей цене стакана или по последней цене, лимитные - при пересечении цены.
+Исполнения отправляются в TradesStream, обновления портфеля - в PortfolioStream и PositionsStream.
+Для проверки обработки ошибок можно использовать InjectError, для проверки переподключений - BreakStreams.
+Методы отчетов (GetBrokerReport, GetDividendsForeignIssuer), активов и брендов возвращают Unimplemented.
+*/
+package fake
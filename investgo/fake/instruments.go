@@ -0,0 +1,436 @@
+package fake
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type instrumentsService struct {
+	pb.UnimplementedInstrumentsServiceServer
+	s *Server
+}
+
+func (is *instrumentsService) TradingSchedules(_ context.Context, req *pb.TradingSchedulesRequest) (*pb.TradingSchedulesResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from := s.now()
+	if req.GetFrom() != nil {
+		from = req.GetFrom().AsTime()
+	}
+	to := from
+	if req.GetTo() != nil {
+		to = req.GetTo().AsTime()
+	}
+	if to.Before(from) || to.Sub(from) > 14*24*time.Hour {
+		return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Invalid request period")
+	}
+	schedules := s.schedules
+	if len(schedules) == 0 {
+		schedules = s.defaultSchedules(from, to)
+	}
+	resp := &pb.TradingSchedulesResponse{}
+	for _, sch := range schedules {
+		if req.GetExchange() != "" && !strings.EqualFold(sch.GetExchange(), req.GetExchange()) {
+			continue
+		}
+		resp.Exchanges = append(resp.Exchanges, sch)
+	}
+	return resp, nil
+}
+
+// defaultSchedules - Расписание по умолчанию для всех бирж инструментов: будние дни торговые с 07:00 до 15:40 UTC
+func (s *Server) defaultSchedules(from, to time.Time) []*pb.TradingSchedule {
+	exchanges := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, inst := range s.instruments.list {
+		ex := inst.base.GetExchange()
+		if _, ok := seen[ex]; ok || ex == "" {
+			continue
+		}
+		seen[ex] = struct{}{}
+		exchanges = append(exchanges, ex)
+	}
+	if len(exchanges) == 0 {
+		exchanges = append(exchanges, "MOEX")
+	}
+
+	schedules := make([]*pb.TradingSchedule, 0, len(exchanges))
+	for _, ex := range exchanges {
+		sch := &pb.TradingSchedule{Exchange: ex}
+		day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+		for ; !day.After(to); day = day.AddDate(0, 0, 1) {
+			td := &pb.TradingDay{Date: timestamppb.New(day)}
+			if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+				td.IsTradingDay = true
+				td.StartTime = timestamppb.New(day.Add(7 * time.Hour))
+				td.EndTime = timestamppb.New(day.Add(15*time.Hour + 40*time.Minute))
+			}
+			sch.Days = append(sch.Days, td)
+		}
+		schedules = append(schedules, sch)
+	}
+	return schedules
+}
+
+// instrumentBy - Поиск инструмента по запросу InstrumentRequest, вызывается под s.mu
+func (s *Server) instrumentBy(req *pb.InstrumentRequest) (*instrument, error) {
+	inst := s.instruments.findBy(req.GetIdType(), req.GetClassCode(), req.GetId())
+	if inst == nil {
+		return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	return inst, nil
+}
+
+// listed - Инструменты, подходящие под статус запроса, вызывается под s.mu
+func (s *Server) listed(status pb.InstrumentStatus) []*instrument {
+	res := make([]*instrument, 0, len(s.instruments.list))
+	for _, inst := range s.instruments.list {
+		if status == pb.InstrumentStatus_INSTRUMENT_STATUS_ALL || inst.base.GetApiTradeAvailableFlag() {
+			res = append(res, inst)
+		}
+	}
+	return res
+}
+
+func (is *instrumentsService) BondBy(_ context.Context, req *pb.InstrumentRequest) (*pb.BondResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.instrumentBy(req)
+	if err != nil {
+		return nil, err
+	}
+	if inst.bond == nil {
+		return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	return &pb.BondResponse{Instrument: inst.bond}, nil
+}
+
+func (is *instrumentsService) Bonds(_ context.Context, req *pb.InstrumentsRequest) (*pb.BondsResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.BondsResponse{}
+	for _, inst := range s.listed(req.GetInstrumentStatus()) {
+		if inst.bond != nil {
+			resp.Instruments = append(resp.Instruments, inst.bond)
+		}
+	}
+	return resp, nil
+}
+
+func (is *instrumentsService) GetBondCoupons(_ context.Context, req *pb.GetBondCouponsRequest) (*pb.GetBondCouponsResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.findInstrument(req.GetFigi(), ""); err != nil {
+		return nil, err
+	}
+	return &pb.GetBondCouponsResponse{}, nil
+}
+
+func (is *instrumentsService) CurrencyBy(_ context.Context, req *pb.InstrumentRequest) (*pb.CurrencyResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.instrumentBy(req)
+	if err != nil {
+		return nil, err
+	}
+	if inst.currency == nil {
+		return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	return &pb.CurrencyResponse{Instrument: inst.currency}, nil
+}
+
+func (is *instrumentsService) Currencies(_ context.Context, req *pb.InstrumentsRequest) (*pb.CurrenciesResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.CurrenciesResponse{}
+	for _, inst := range s.listed(req.GetInstrumentStatus()) {
+		if inst.currency != nil {
+			resp.Instruments = append(resp.Instruments, inst.currency)
+		}
+	}
+	return resp, nil
+}
+
+func (is *instrumentsService) EtfBy(_ context.Context, req *pb.InstrumentRequest) (*pb.EtfResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.instrumentBy(req)
+	if err != nil {
+		return nil, err
+	}
+	if inst.etf == nil {
+		return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	return &pb.EtfResponse{Instrument: inst.etf}, nil
+}
+
+func (is *instrumentsService) Etfs(_ context.Context, req *pb.InstrumentsRequest) (*pb.EtfsResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.EtfsResponse{}
+	for _, inst := range s.listed(req.GetInstrumentStatus()) {
+		if inst.etf != nil {
+			resp.Instruments = append(resp.Instruments, inst.etf)
+		}
+	}
+	return resp, nil
+}
+
+func (is *instrumentsService) FutureBy(_ context.Context, req *pb.InstrumentRequest) (*pb.FutureResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.instrumentBy(req)
+	if err != nil {
+		return nil, err
+	}
+	if inst.future == nil {
+		return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	return &pb.FutureResponse{Instrument: inst.future}, nil
+}
+
+func (is *instrumentsService) Futures(_ context.Context, req *pb.InstrumentsRequest) (*pb.FuturesResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.FuturesResponse{}
+	for _, inst := range s.listed(req.GetInstrumentStatus()) {
+		if inst.future != nil {
+			resp.Instruments = append(resp.Instruments, inst.future)
+		}
+	}
+	return resp, nil
+}
+
+func (is *instrumentsService) OptionBy(_ context.Context, req *pb.InstrumentRequest) (*pb.OptionResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.instrumentBy(req)
+	if err != nil {
+		return nil, err
+	}
+	if inst.option == nil {
+		return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	return &pb.OptionResponse{Instrument: inst.option}, nil
+}
+
+func (is *instrumentsService) Options(_ context.Context, req *pb.InstrumentsRequest) (*pb.OptionsResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.OptionsResponse{}
+	for _, inst := range s.listed(req.GetInstrumentStatus()) {
+		if inst.option != nil {
+			resp.Instruments = append(resp.Instruments, inst.option)
+		}
+	}
+	return resp, nil
+}
+
+func (is *instrumentsService) OptionsBy(_ context.Context, req *pb.FilterOptionsRequest) (*pb.OptionsResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.OptionsResponse{}
+	for _, inst := range s.instruments.list {
+		if inst.option == nil {
+			continue
+		}
+		if req.GetBasicAssetPositionUid() != "" && inst.option.GetBasicAssetPositionUid() != req.GetBasicAssetPositionUid() {
+			continue
+		}
+		resp.Instruments = append(resp.Instruments, inst.option)
+	}
+	return resp, nil
+}
+
+func (is *instrumentsService) ShareBy(_ context.Context, req *pb.InstrumentRequest) (*pb.ShareResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.instrumentBy(req)
+	if err != nil {
+		return nil, err
+	}
+	if inst.share == nil {
+		return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	return &pb.ShareResponse{Instrument: inst.share}, nil
+}
+
+func (is *instrumentsService) Shares(_ context.Context, req *pb.InstrumentsRequest) (*pb.SharesResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.SharesResponse{}
+	for _, inst := range s.listed(req.GetInstrumentStatus()) {
+		if inst.share != nil {
+			resp.Instruments = append(resp.Instruments, inst.share)
+		}
+	}
+	return resp, nil
+}
+
+func (is *instrumentsService) GetAccruedInterests(_ context.Context, req *pb.GetAccruedInterestsRequest) (*pb.GetAccruedInterestsResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.findInstrument(req.GetFigi(), ""); err != nil {
+		return nil, err
+	}
+	return &pb.GetAccruedInterestsResponse{}, nil
+}
+
+func (is *instrumentsService) GetFuturesMargin(_ context.Context, req *pb.GetFuturesMarginRequest) (*pb.GetFuturesMarginResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.findInstrument(req.GetFigi(), "")
+	if err != nil {
+		return nil, err
+	}
+	if inst.future == nil {
+		return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	currency := inst.base.GetCurrency()
+	return &pb.GetFuturesMarginResponse{
+		InitialMarginOnBuy:      toMoney(decimal.Zero, currency),
+		InitialMarginOnSell:     toMoney(decimal.Zero, currency),
+		MinPriceIncrement:       inst.base.GetMinPriceIncrement(),
+		MinPriceIncrementAmount: inst.base.GetMinPriceIncrement(),
+	}, nil
+}
+
+func (is *instrumentsService) GetInstrumentBy(_ context.Context, req *pb.InstrumentRequest) (*pb.InstrumentResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.instrumentBy(req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.InstrumentResponse{Instrument: inst.base}, nil
+}
+
+func (is *instrumentsService) GetDividends(_ context.Context, req *pb.GetDividendsRequest) (*pb.GetDividendsResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.findInstrument(req.GetFigi(), ""); err != nil {
+		return nil, err
+	}
+	return &pb.GetDividendsResponse{}, nil
+}
+
+func (is *instrumentsService) GetFavorites(_ context.Context, _ *pb.GetFavoritesRequest) (*pb.GetFavoritesResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &pb.GetFavoritesResponse{FavoriteInstruments: s.favoriteInstruments()}, nil
+}
+
+func (is *instrumentsService) EditFavorites(_ context.Context, req *pb.EditFavoritesRequest) (*pb.EditFavoritesResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, fi := range req.GetInstruments() {
+		inst := s.instruments.find(fi.GetFigi())
+		if inst == nil {
+			return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+		}
+		switch req.GetActionType() {
+		case pb.EditFavoritesActionType_EDIT_FAVORITES_ACTION_TYPE_ADD:
+			s.favorites[inst.uid()] = struct{}{}
+		case pb.EditFavoritesActionType_EDIT_FAVORITES_ACTION_TYPE_DEL:
+			delete(s.favorites, inst.uid())
+		default:
+			return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Action type is not specified")
+		}
+	}
+	return &pb.EditFavoritesResponse{FavoriteInstruments: s.favoriteInstruments()}, nil
+}
+
+// favoriteInstruments - вызывается под s.mu
+func (s *Server) favoriteInstruments() []*pb.FavoriteInstrument {
+	res := make([]*pb.FavoriteInstrument, 0, len(s.favorites))
+	for _, inst := range s.instruments.list {
+		if _, ok := s.favorites[inst.uid()]; !ok {
+			continue
+		}
+		b := inst.base
+		res = append(res, &pb.FavoriteInstrument{
+			Figi:                  b.GetFigi(),
+			Ticker:                b.GetTicker(),
+			ClassCode:             b.GetClassCode(),
+			Isin:                  b.GetIsin(),
+			InstrumentType:        b.GetInstrumentType(),
+			OtcFlag:               b.GetOtcFlag(),
+			ApiTradeAvailableFlag: b.GetApiTradeAvailableFlag(),
+			InstrumentKind:        b.GetInstrumentKind(),
+		})
+	}
+	return res
+}
+
+func (is *instrumentsService) FindInstrument(_ context.Context, req *pb.FindInstrumentRequest) (*pb.FindInstrumentResponse, error) {
+	s := is.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query := strings.ToLower(req.GetQuery())
+	resp := &pb.FindInstrumentResponse{}
+	for _, inst := range s.instruments.list {
+		b := inst.base
+		if req.GetInstrumentKind() != pb.InstrumentType_INSTRUMENT_TYPE_UNSPECIFIED && b.GetInstrumentKind() != req.GetInstrumentKind() {
+			continue
+		}
+		if req.GetApiTradeAvailableFlag() && !b.GetApiTradeAvailableFlag() {
+			continue
+		}
+		matched := false
+		for _, field := range []string{b.GetFigi(), b.GetTicker(), b.GetIsin(), b.GetUid(), b.GetName()} {
+			if field != "" && strings.Contains(strings.ToLower(field), query) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		resp.Instruments = append(resp.Instruments, &pb.InstrumentShort{
+			Isin:                  b.GetIsin(),
+			Figi:                  b.GetFigi(),
+			Ticker:                b.GetTicker(),
+			ClassCode:             b.GetClassCode(),
+			InstrumentType:        b.GetInstrumentType(),
+			Name:                  b.GetName(),
+			Uid:                   b.GetUid(),
+			PositionUid:           b.GetPositionUid(),
+			InstrumentKind:        b.GetInstrumentKind(),
+			ApiTradeAvailableFlag: b.GetApiTradeAvailableFlag(),
+			ForIisFlag:            b.GetForIisFlag(),
+			First_1MinCandleDate:  b.GetFirst_1MinCandleDate(),
+			First_1DayCandleDate:  b.GetFirst_1DayCandleDate(),
+			ForQualInvestorFlag:   b.GetForQualInvestorFlag(),
+			WeekendFlag:           b.GetWeekendFlag(),
+			BlockedTcaFlag:        b.GetBlockedTcaFlag(),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,396 @@
+package fake
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type marketDataService struct {
+	pb.UnimplementedMarketDataServiceServer
+	s *Server
+}
+
+func (md *marketDataService) GetCandles(_ context.Context, req *pb.GetCandlesRequest) (*pb.GetCandlesResponse, error) {
+	s := md.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.findInstrument(req.GetInstrumentId(), req.GetFigi())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetFrom() == nil || req.GetTo() == nil || !req.GetFrom().AsTime().Before(req.GetTo().AsTime()) {
+		return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Invalid request period")
+	}
+	from, to := req.GetFrom().AsTime(), req.GetTo().AsTime()
+	resp := &pb.GetCandlesResponse{}
+	for _, c := range s.candles[inst.uid()][req.GetInterval()] {
+		t := c.GetTime().AsTime()
+		if !t.Before(from) && t.Before(to) {
+			resp.Candles = append(resp.Candles, c)
+		}
+	}
+	return resp, nil
+}
+
+func (md *marketDataService) GetLastPrices(_ context.Context, req *pb.GetLastPricesRequest) (*pb.GetLastPricesResponse, error) {
+	s := md.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.GetLastPricesResponse{}
+	for _, id := range append(append([]string(nil), req.GetFigi()...), req.GetInstrumentId()...) {
+		inst := s.instruments.find(id)
+		if inst == nil {
+			return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+		}
+		if lp, ok := s.lastPrices[inst.uid()]; ok {
+			resp.LastPrices = append(resp.LastPrices, lp)
+		}
+	}
+	return resp, nil
+}
+
+func (md *marketDataService) GetOrderBook(_ context.Context, req *pb.GetOrderBookRequest) (*pb.GetOrderBookResponse, error) {
+	s := md.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.findInstrument(req.GetInstrumentId(), req.GetFigi())
+	if err != nil {
+		return nil, err
+	}
+	if !validDepth(req.GetDepth()) {
+		return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Invalid order book depth")
+	}
+	resp := &pb.GetOrderBookResponse{
+		Figi:          inst.base.GetFigi(),
+		Depth:         req.GetDepth(),
+		InstrumentUid: inst.uid(),
+	}
+	if ob, ok := s.orderBooks[inst.uid()]; ok {
+		book := withDepth(ob, req.GetDepth())
+		resp.Bids = book.GetBids()
+		resp.Asks = book.GetAsks()
+		resp.LimitUp = book.GetLimitUp()
+		resp.LimitDown = book.GetLimitDown()
+		resp.OrderbookTs = book.GetTime()
+	}
+	if lp, ok := s.lastPrices[inst.uid()]; ok {
+		resp.LastPrice = lp.GetPrice()
+		resp.LastPriceTs = lp.GetTime()
+	}
+	if cp, ok := s.closePrices[inst.uid()]; ok {
+		resp.ClosePrice = cp
+	}
+	return resp, nil
+}
+
+func (md *marketDataService) GetTradingStatus(_ context.Context, req *pb.GetTradingStatusRequest) (*pb.GetTradingStatusResponse, error) {
+	s := md.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.findInstrument(req.GetInstrumentId(), req.GetFigi())
+	if err != nil {
+		return nil, err
+	}
+	return s.tradingStatus(inst), nil
+}
+
+func (md *marketDataService) GetTradingStatuses(_ context.Context, req *pb.GetTradingStatusesRequest) (*pb.GetTradingStatusesResponse, error) {
+	s := md.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.GetTradingStatusesResponse{}
+	for _, id := range req.GetInstrumentId() {
+		inst := s.instruments.find(id)
+		if inst == nil {
+			return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+		}
+		resp.TradingStatuses = append(resp.TradingStatuses, s.tradingStatus(inst))
+	}
+	return resp, nil
+}
+
+func (md *marketDataService) GetLastTrades(_ context.Context, req *pb.GetLastTradesRequest) (*pb.GetLastTradesResponse, error) {
+	s := md.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.findInstrument(req.GetInstrumentId(), req.GetFigi())
+	if err != nil {
+		return nil, err
+	}
+	to := s.now()
+	if req.GetTo() != nil {
+		to = req.GetTo().AsTime()
+	}
+	from := to.Add(-time.Hour)
+	if req.GetFrom() != nil {
+		from = req.GetFrom().AsTime()
+	}
+	resp := &pb.GetLastTradesResponse{}
+	for _, t := range s.trades[inst.uid()] {
+		tt := t.GetTime().AsTime()
+		if !tt.Before(from) && !tt.After(to) {
+			resp.Trades = append(resp.Trades, t)
+		}
+	}
+	return resp, nil
+}
+
+func (md *marketDataService) GetClosePrices(_ context.Context, req *pb.GetClosePricesRequest) (*pb.GetClosePricesResponse, error) {
+	s := md.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.GetClosePricesResponse{}
+	for _, r := range req.GetInstruments() {
+		inst := s.instruments.find(r.GetInstrumentId())
+		if inst == nil {
+			return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+		}
+		if cp, ok := s.closePrices[inst.uid()]; ok {
+			resp.ClosePrices = append(resp.ClosePrices, &pb.InstrumentClosePriceResponse{
+				Figi:          inst.base.GetFigi(),
+				InstrumentUid: inst.uid(),
+				Price:         cp,
+				Time:          timestamppb.New(s.now()),
+			})
+		}
+	}
+	return resp, nil
+}
+
+// findInstrument - Поиск инструмента по instrument_id или устаревшему figi, вызывается под s.mu
+func (s *Server) findInstrument(instrumentId, figi string) (*instrument, error) {
+	if instrumentId == "" {
+		instrumentId = figi
+	}
+	inst := s.instruments.find(instrumentId)
+	if inst == nil {
+		return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	return inst, nil
+}
+
+// tradingStatus - Торговый статус инструмента, вызывается под s.mu
+func (s *Server) tradingStatus(inst *instrument) *pb.GetTradingStatusResponse {
+	status := inst.base.GetTradingStatus()
+	if ts, ok := s.tradingStatuses[inst.uid()]; ok {
+		status = ts.GetTradingStatus()
+	}
+	normal := status == pb.SecurityTradingStatus_SECURITY_TRADING_STATUS_NORMAL_TRADING
+	return &pb.GetTradingStatusResponse{
+		Figi:                     inst.base.GetFigi(),
+		TradingStatus:            status,
+		LimitOrderAvailableFlag:  normal,
+		MarketOrderAvailableFlag: normal,
+		ApiTradeAvailableFlag:    inst.base.GetApiTradeAvailableFlag(),
+		InstrumentUid:            inst.uid(),
+	}
+}
+
+func validDepth(depth int32) bool {
+	switch depth {
+	case 1, 10, 20, 30, 40, 50:
+		return true
+	}
+	return false
+}
+
+// withDepth - Копия стакана, обрезанная до глубины depth
+func withDepth(ob *pb.OrderBook, depth int32) *pb.OrderBook {
+	cut := func(orders []*pb.Order) []*pb.Order {
+		if len(orders) > int(depth) {
+			return orders[:depth]
+		}
+		return orders
+	}
+	return &pb.OrderBook{
+		Figi:          ob.GetFigi(),
+		Depth:         depth,
+		IsConsistent:  ob.GetIsConsistent(),
+		Bids:          cut(ob.GetBids()),
+		Asks:          cut(ob.GetAsks()),
+		Time:          ob.GetTime(),
+		LimitUp:       ob.GetLimitUp(),
+		LimitDown:     ob.GetLimitDown(),
+		InstrumentUid: ob.GetInstrumentUid(),
+	}
+}
+
+// SetLastPrice - Установка цены последней сделки. Цена отправляется подписчикам на последние цены,
+// по ней исполняются лимитные заявки и срабатывают стоп-заявки
+func (s *Server) SetLastPrice(instrumentId string, price *pb.Quotation) error {
+	return s.update(func(ev *events) error {
+		inst, err := s.findInstrument(instrumentId, "")
+		if err != nil {
+			return err
+		}
+		s.setLastPrice(inst, price, ev)
+		s.matchOrders(inst, ev)
+		return nil
+	})
+}
+
+// setLastPrice - вызывается под s.mu
+func (s *Server) setLastPrice(inst *instrument, price *pb.Quotation, ev *events) {
+	lp := &pb.LastPrice{
+		Figi:          inst.base.GetFigi(),
+		Price:         price,
+		Time:          timestamppb.New(s.now()),
+		InstrumentUid: inst.uid(),
+	}
+	s.lastPrices[inst.uid()] = lp
+	ev.md = append(ev.md, &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_LastPrice{LastPrice: lp}})
+}
+
+// SetOrderBook - Установка стакана, bids по убыванию цены, asks по возрастанию. Стакан отправляется подписчикам
+// с нужной им глубиной, по лучшим ценам исполняются рыночные и лимитные заявки
+func (s *Server) SetOrderBook(instrumentId string, bids, asks []*pb.Order) error {
+	return s.update(func(ev *events) error {
+		inst, err := s.findInstrument(instrumentId, "")
+		if err != nil {
+			return err
+		}
+		depth := len(bids)
+		if len(asks) > depth {
+			depth = len(asks)
+		}
+		ob := &pb.OrderBook{
+			Figi:          inst.base.GetFigi(),
+			Depth:         int32(depth),
+			IsConsistent:  true,
+			Bids:          bids,
+			Asks:          asks,
+			Time:          timestamppb.New(s.now()),
+			InstrumentUid: inst.uid(),
+		}
+		if old, ok := s.orderBooks[inst.uid()]; ok {
+			ob.LimitUp = old.GetLimitUp()
+			ob.LimitDown = old.GetLimitDown()
+		}
+		s.orderBooks[inst.uid()] = ob
+		ev.md = append(ev.md, &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_Orderbook{Orderbook: ob}})
+		s.matchOrders(inst, ev)
+		return nil
+	})
+}
+
+// SetPriceLimits - Установка верхней и нижней границ цены инструмента
+func (s *Server) SetPriceLimits(instrumentId string, limitDown, limitUp *pb.Quotation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.findInstrument(instrumentId, "")
+	if err != nil {
+		return err
+	}
+	ob, ok := s.orderBooks[inst.uid()]
+	if !ok {
+		ob = &pb.OrderBook{Figi: inst.base.GetFigi(), InstrumentUid: inst.uid(), Time: timestamppb.New(s.now())}
+		s.orderBooks[inst.uid()] = ob
+	}
+	ob.LimitDown = limitDown
+	ob.LimitUp = limitUp
+	return nil
+}
+
+// SetTradingStatus - Установка торгового статуса инструмента, заявки принимаются только в статусе NORMAL_TRADING
+func (s *Server) SetTradingStatus(instrumentId string, status pb.SecurityTradingStatus) error {
+	return s.update(func(ev *events) error {
+		inst, err := s.findInstrument(instrumentId, "")
+		if err != nil {
+			return err
+		}
+		normal := status == pb.SecurityTradingStatus_SECURITY_TRADING_STATUS_NORMAL_TRADING
+		ts := &pb.TradingStatus{
+			Figi:                     inst.base.GetFigi(),
+			TradingStatus:            status,
+			Time:                     timestamppb.New(s.now()),
+			LimitOrderAvailableFlag:  normal,
+			MarketOrderAvailableFlag: normal,
+			InstrumentUid:            inst.uid(),
+		}
+		s.tradingStatuses[inst.uid()] = ts
+		ev.md = append(ev.md, &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_TradingStatus{TradingStatus: ts}})
+		return nil
+	})
+}
+
+// PushTrade - Обезличенная сделка по инструменту, также обновляет цену последней сделки
+func (s *Server) PushTrade(instrumentId string, direction pb.TradeDirection, price *pb.Quotation, quantity int64) error {
+	return s.update(func(ev *events) error {
+		inst, err := s.findInstrument(instrumentId, "")
+		if err != nil {
+			return err
+		}
+		t := &pb.Trade{
+			Figi:          inst.base.GetFigi(),
+			Direction:     direction,
+			Price:         price,
+			Quantity:      quantity,
+			Time:          timestamppb.New(s.now()),
+			InstrumentUid: inst.uid(),
+		}
+		s.trades[inst.uid()] = append(s.trades[inst.uid()], t)
+		ev.md = append(ev.md, &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_Trade{Trade: t}})
+		s.setLastPrice(inst, price, ev)
+		s.matchOrders(inst, ev)
+		return nil
+	})
+}
+
+// PushCandle - Отправка свечи подписчикам на свечи с интервалом candle.Interval. Если время свечи не задано,
+// используется текущее время сервера
+func (s *Server) PushCandle(instrumentId string, candle *pb.Candle) error {
+	return s.update(func(ev *events) error {
+		inst, err := s.findInstrument(instrumentId, "")
+		if err != nil {
+			return err
+		}
+		candle.Figi = inst.base.GetFigi()
+		candle.InstrumentUid = inst.uid()
+		if candle.Time == nil {
+			candle.Time = timestamppb.New(s.now())
+		}
+		if candle.LastTradeTs == nil {
+			candle.LastTradeTs = candle.Time
+		}
+		ev.md = append(ev.md, &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_Candle{Candle: candle}})
+		return nil
+	})
+}
+
+// AddHistoricCandles - Добавление исторических свечей, которые возвращает GetCandles
+func (s *Server) AddHistoricCandles(instrumentId string, interval pb.CandleInterval, candles ...*pb.HistoricCandle) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.findInstrument(instrumentId, "")
+	if err != nil {
+		return err
+	}
+	byInterval, ok := s.candles[inst.uid()]
+	if !ok {
+		byInterval = make(map[pb.CandleInterval][]*pb.HistoricCandle)
+		s.candles[inst.uid()] = byInterval
+	}
+	list := append(byInterval[interval], candles...)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].GetTime().AsTime().Before(list[j].GetTime().AsTime())
+	})
+	byInterval[interval] = list
+	return nil
+}
+
+// SetClosePrice - Установка цены закрытия торговой сессии
+func (s *Server) SetClosePrice(instrumentId string, price *pb.Quotation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, err := s.findInstrument(instrumentId, "")
+	if err != nil {
+		return err
+	}
+	s.closePrices[inst.uid()] = price
+	return nil
+}
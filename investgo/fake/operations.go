@@ -0,0 +1,387 @@
+package fake
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type operationsService struct {
+	pb.UnimplementedOperationsServiceServer
+	s *Server
+}
+
+func (os *operationsService) GetOperations(_ context.Context, req *pb.OperationsRequest) (*pb.OperationsResponse, error) {
+	return os.s.operations(req, false)
+}
+
+func (os *operationsService) GetPortfolio(_ context.Context, req *pb.PortfolioRequest) (*pb.PortfolioResponse, error) {
+	return os.s.getPortfolio(req, false)
+}
+
+func (os *operationsService) GetPositions(_ context.Context, req *pb.PositionsRequest) (*pb.PositionsResponse, error) {
+	return os.s.positions(req.GetAccountId(), false)
+}
+
+func (os *operationsService) GetWithdrawLimits(_ context.Context, req *pb.WithdrawLimitsRequest) (*pb.WithdrawLimitsResponse, error) {
+	return os.s.withdrawLimits(req.GetAccountId(), false)
+}
+
+func (os *operationsService) GetOperationsByCursor(_ context.Context, req *pb.GetOperationsByCursorRequest) (*pb.GetOperationsByCursorResponse, error) {
+	return os.s.operationsByCursor(req, false)
+}
+
+// ownAccount - Поиск счета нужного вида (брокерский или песочница), вызывается под s.mu
+func (s *Server) ownAccount(accountId string, sandbox bool) (*account, error) {
+	acc, err := s.account(accountId)
+	if err != nil {
+		return nil, err
+	}
+	if acc.sandbox != sandbox {
+		return nil, Error(codes.NotFound, codeAccountNotFound, "Account not found")
+	}
+	return acc, nil
+}
+
+func (s *Server) operations(req *pb.OperationsRequest, sandbox bool) (*pb.OperationsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, err := s.ownAccount(req.GetAccountId(), sandbox)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.OperationsResponse{}
+	for _, op := range acc.operations {
+		date := op.GetDate().AsTime()
+		switch {
+		case req.GetFrom() != nil && date.Before(req.GetFrom().AsTime()):
+		case req.GetTo() != nil && date.After(req.GetTo().AsTime()):
+		case req.GetState() != pb.OperationState_OPERATION_STATE_UNSPECIFIED && op.GetState() != req.GetState():
+		case req.GetFigi() != "" && op.GetFigi() != req.GetFigi():
+		default:
+			resp.Operations = append(resp.Operations, op)
+		}
+	}
+	return resp, nil
+}
+
+// operationsByCursor - Операции с пагинацией, курсором служит номер операции на счете
+func (s *Server) operationsByCursor(req *pb.GetOperationsByCursorRequest, sandbox bool) (*pb.GetOperationsByCursorResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, err := s.ownAccount(req.GetAccountId(), sandbox)
+	if err != nil {
+		return nil, err
+	}
+	start := 0
+	if req.GetCursor() != "" {
+		start, err = strconv.Atoi(req.GetCursor())
+		if err != nil || start < 0 {
+			return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Invalid cursor")
+		}
+	}
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	var inst *instrument
+	if req.GetInstrumentId() != "" {
+		if inst = s.instruments.find(req.GetInstrumentId()); inst == nil {
+			return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+		}
+	}
+	types := make(map[pb.OperationType]struct{}, len(req.GetOperationTypes()))
+	for _, t := range req.GetOperationTypes() {
+		types[t] = struct{}{}
+	}
+
+	resp := &pb.GetOperationsByCursorResponse{}
+	for i := start; i < len(acc.operations); i++ {
+		op := acc.operations[i]
+		if _, ok := types[op.GetOperationType()]; len(types) > 0 && !ok {
+			continue
+		}
+		date := op.GetDate().AsTime()
+		switch {
+		case inst != nil && op.GetInstrumentUid() != inst.uid():
+		case req.GetFrom() != nil && date.Before(req.GetFrom().AsTime()):
+		case req.GetTo() != nil && date.After(req.GetTo().AsTime()):
+		case req.GetState() != pb.OperationState_OPERATION_STATE_UNSPECIFIED && op.GetState() != req.GetState():
+		case req.GetWithoutCommissions() && op.GetOperationType() == pb.OperationType_OPERATION_TYPE_BROKER_FEE:
+		default:
+			if len(resp.Items) == limit {
+				resp.HasNext = true
+				resp.NextCursor = strconv.Itoa(i)
+				return resp, nil
+			}
+			resp.Items = append(resp.Items, s.operationItem(acc, op, i, req.GetWithoutTrades()))
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) operationItem(acc *account, op *pb.Operation, index int, withoutTrades bool) *pb.OperationItem {
+	item := &pb.OperationItem{
+		Cursor:            strconv.Itoa(index),
+		BrokerAccountId:   acc.acc.GetId(),
+		Id:                op.GetId(),
+		ParentOperationId: op.GetParentOperationId(),
+		Name:              op.GetType(),
+		Date:              op.GetDate(),
+		Type:              op.GetOperationType(),
+		Description:       op.GetType(),
+		State:             op.GetState(),
+		InstrumentUid:     op.GetInstrumentUid(),
+		Figi:              op.GetFigi(),
+		InstrumentType:    op.GetInstrumentType(),
+		PositionUid:       op.GetPositionUid(),
+		Payment:           op.GetPayment(),
+		Price:             op.GetPrice(),
+		Quantity:          op.GetQuantity(),
+		QuantityDone:      op.GetQuantity() - op.GetQuantityRest(),
+		QuantityRest:      op.GetQuantityRest(),
+	}
+	if inst := s.instruments.find(op.GetInstrumentUid()); inst != nil {
+		item.InstrumentKind = inst.base.GetInstrumentKind()
+	}
+	if !withoutTrades && len(op.GetTrades()) > 0 {
+		item.TradesInfo = &pb.OperationItemTrades{}
+		for _, t := range op.GetTrades() {
+			item.TradesInfo.Trades = append(item.TradesInfo.Trades, &pb.OperationItemTrade{
+				Num:      t.GetTradeId(),
+				Date:     t.GetDateTime(),
+				Quantity: t.GetQuantity(),
+				Price:    t.GetPrice(),
+			})
+		}
+	}
+	return item
+}
+
+func (s *Server) getPortfolio(req *pb.PortfolioRequest, sandbox bool) (*pb.PortfolioResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, err := s.ownAccount(req.GetAccountId(), sandbox)
+	if err != nil {
+		return nil, err
+	}
+	currency := strings.ToLower(req.GetCurrency().String())
+	if _, ok := s.rate(currency); !ok {
+		return nil, Error(codes.InvalidArgument, codeInvalidArgument, "No exchange rate for portfolio currency")
+	}
+	return s.portfolioIn(acc, currency), nil
+}
+
+// portfolio - Портфель по счету в валюте по умолчанию, вызывается под s.mu
+func (s *Server) portfolio(acc *account) *pb.PortfolioResponse {
+	return s.portfolioIn(acc, DefaultCurrency)
+}
+
+// rate - Курс валюты currency к валюте по умолчанию по цене последней сделки валютного инструмента
+func (s *Server) rate(currency string) (decimal.Decimal, bool) {
+	if currency == DefaultCurrency {
+		return decimal.NewFromInt(1), true
+	}
+	for _, inst := range s.instruments.list {
+		if inst.currency == nil || !strings.EqualFold(inst.currency.GetIsoCurrencyName(), currency) {
+			continue
+		}
+		if lp, ok := s.lastPrices[inst.uid()]; ok {
+			return toDecimal(lp.GetPrice()), true
+		}
+	}
+	return decimal.Zero, false
+}
+
+// convert - Перевод суммы из валюты from в валюту to, вызывается под s.mu
+func (s *Server) convert(amount decimal.Decimal, from, to string) decimal.Decimal {
+	if from == to {
+		return amount
+	}
+	fromRate, ok := s.rate(from)
+	if !ok {
+		return decimal.Zero
+	}
+	toRate, ok := s.rate(to)
+	if !ok || toRate.IsZero() {
+		return decimal.Zero
+	}
+	return amount.Mul(fromRate).Div(toRate)
+}
+
+// currentPrice - Текущая цена инструмента: цена последней сделки или средняя цена позиции
+func (s *Server) currentPrice(p *position) decimal.Decimal {
+	if lp, ok := s.lastPrices[p.inst.uid()]; ok {
+		return toDecimal(lp.GetPrice())
+	}
+	return p.avgPrice
+}
+
+// portfolioIn - Портфель по счету в валюте currency, вызывается под s.mu
+func (s *Server) portfolioIn(acc *account, currency string) *pb.PortfolioResponse {
+	totals := make(map[pb.InstrumentType]decimal.Decimal)
+	cost, yield := decimal.Zero, decimal.Zero
+	resp := &pb.PortfolioResponse{AccountId: acc.acc.GetId()}
+
+	for _, p := range sortedPositions(acc) {
+		if p.balance == 0 {
+			continue
+		}
+		instCurrency := strings.ToLower(p.inst.base.GetCurrency())
+		balance := decimal.NewFromInt(p.balance)
+		price := s.currentPrice(p)
+		positionYield := price.Sub(p.avgPrice).Mul(balance)
+		kind := p.inst.base.GetInstrumentKind()
+		totals[kind] = totals[kind].Add(s.convert(price.Mul(balance), instCurrency, currency))
+		cost = cost.Add(s.convert(p.avgPrice.Mul(balance).Abs(), instCurrency, currency))
+		yield = yield.Add(s.convert(positionYield, instCurrency, currency))
+		resp.Positions = append(resp.Positions, &pb.PortfolioPosition{
+			Figi:                     p.inst.base.GetFigi(),
+			InstrumentType:           p.inst.base.GetInstrumentType(),
+			Quantity:                 toQuotation(balance),
+			AveragePositionPrice:     toMoney(p.avgPrice, instCurrency),
+			ExpectedYield:            toQuotation(positionYield),
+			CurrentPrice:             toMoney(price, instCurrency),
+			AveragePositionPriceFifo: toMoney(p.avgPrice, instCurrency),
+			QuantityLots:             toQuotation(balance.Div(decimal.NewFromInt(p.inst.lot()))),
+			BlockedLots:              toQuotation(decimal.Zero),
+			PositionUid:              p.inst.base.GetPositionUid(),
+			InstrumentUid:            p.inst.uid(),
+			VarMargin:                toMoney(decimal.Zero, instCurrency),
+			ExpectedYieldFifo:        toQuotation(positionYield),
+		})
+	}
+
+	money := decimal.Zero
+	for cur, amount := range acc.money {
+		money = money.Add(s.convert(amount, cur, currency))
+	}
+	totals[pb.InstrumentType_INSTRUMENT_TYPE_CURRENCY] = totals[pb.InstrumentType_INSTRUMENT_TYPE_CURRENCY].Add(money)
+
+	total := decimal.Zero
+	for _, v := range totals {
+		total = total.Add(v)
+	}
+	resp.TotalAmountShares = toMoney(totals[pb.InstrumentType_INSTRUMENT_TYPE_SHARE], currency)
+	resp.TotalAmountBonds = toMoney(totals[pb.InstrumentType_INSTRUMENT_TYPE_BOND], currency)
+	resp.TotalAmountEtf = toMoney(totals[pb.InstrumentType_INSTRUMENT_TYPE_ETF], currency)
+	resp.TotalAmountCurrencies = toMoney(totals[pb.InstrumentType_INSTRUMENT_TYPE_CURRENCY], currency)
+	resp.TotalAmountFutures = toMoney(totals[pb.InstrumentType_INSTRUMENT_TYPE_FUTURES], currency)
+	resp.TotalAmountOptions = toMoney(totals[pb.InstrumentType_INSTRUMENT_TYPE_OPTION], currency)
+	resp.TotalAmountSp = toMoney(totals[pb.InstrumentType_INSTRUMENT_TYPE_SP], currency)
+	resp.TotalAmountPortfolio = toMoney(total, currency)
+	resp.ExpectedYield = toQuotation(decimal.Zero)
+	if cost.IsPositive() {
+		resp.ExpectedYield = toQuotation(yield.Div(cost).Mul(decimal.NewFromInt(100)).Round(2))
+	}
+	return resp
+}
+
+func sortedPositions(acc *account) []*position {
+	positions := make([]*position, 0, len(acc.positions))
+	for _, p := range acc.positions {
+		positions = append(positions, p)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].inst.uid() < positions[j].inst.uid()
+	})
+	return positions
+}
+
+func sortedMoney(acc *account) []*pb.MoneyValue {
+	currencies := make([]string, 0, len(acc.money))
+	for cur := range acc.money {
+		currencies = append(currencies, cur)
+	}
+	sort.Strings(currencies)
+	money := make([]*pb.MoneyValue, 0, len(currencies))
+	for _, cur := range currencies {
+		money = append(money, toMoney(acc.money[cur], cur))
+	}
+	return money
+}
+
+func (s *Server) positions(accountId string, sandbox bool) (*pb.PositionsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, err := s.ownAccount(accountId, sandbox)
+	if err != nil {
+		return nil, err
+	}
+	data := s.positionData(acc)
+	resp := &pb.PositionsResponse{
+		Securities: data.GetSecurities(),
+		Futures:    data.GetFutures(),
+		Options:    data.GetOptions(),
+	}
+	for _, m := range data.GetMoney() {
+		resp.Money = append(resp.Money, m.GetAvailableValue())
+		resp.Blocked = append(resp.Blocked, m.GetBlockedValue())
+	}
+	return resp, nil
+}
+
+// positionData - Позиции по счету в формате PositionsStream, вызывается под s.mu
+func (s *Server) positionData(acc *account) *pb.PositionData {
+	data := &pb.PositionData{
+		AccountId: acc.acc.GetId(),
+		Date:      timestamppb.New(s.now()),
+	}
+	for _, m := range sortedMoney(acc) {
+		data.Money = append(data.Money, &pb.PositionsMoney{
+			AvailableValue: m,
+			BlockedValue:   toMoney(decimal.Zero, m.GetCurrency()),
+		})
+	}
+	for _, p := range sortedPositions(acc) {
+		b := p.inst.base
+		switch b.GetInstrumentKind() {
+		case pb.InstrumentType_INSTRUMENT_TYPE_FUTURES:
+			data.Futures = append(data.Futures, &pb.PositionsFutures{
+				Figi:          b.GetFigi(),
+				Balance:       p.balance,
+				PositionUid:   b.GetPositionUid(),
+				InstrumentUid: b.GetUid(),
+			})
+		case pb.InstrumentType_INSTRUMENT_TYPE_OPTION:
+			data.Options = append(data.Options, &pb.PositionsOptions{
+				PositionUid:   b.GetPositionUid(),
+				InstrumentUid: b.GetUid(),
+				Balance:       p.balance,
+			})
+		default:
+			data.Securities = append(data.Securities, &pb.PositionsSecurities{
+				Figi:           b.GetFigi(),
+				Balance:        p.balance,
+				PositionUid:    b.GetPositionUid(),
+				InstrumentUid:  b.GetUid(),
+				InstrumentType: b.GetInstrumentType(),
+			})
+		}
+	}
+	return data
+}
+
+func (s *Server) withdrawLimits(accountId string, sandbox bool) (*pb.WithdrawLimitsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, err := s.ownAccount(accountId, sandbox)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.WithdrawLimitsResponse{Money: sortedMoney(acc)}
+	for _, m := range resp.Money {
+		resp.Blocked = append(resp.Blocked, toMoney(decimal.Zero, m.GetCurrency()))
+		resp.BlockedGuarantee = append(resp.BlockedGuarantee, toMoney(decimal.Zero, m.GetCurrency()))
+	}
+	return resp, nil
+}
@@ -0,0 +1,561 @@
+package fake
+
+import (
+	"context"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// order - Биржевая заявка, state отдается клиенту копией, потому что меняется при исполнении и отмене
+type order struct {
+	accountId string
+	inst      *instrument
+	limit     decimal.Decimal
+	executed  decimal.Decimal
+	fee       decimal.Decimal
+	state     *pb.OrderState
+}
+
+func (o *order) active() bool {
+	status := o.state.GetExecutionReportStatus()
+	return status == pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW ||
+		status == pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL
+}
+
+func (o *order) lotsLeft() int64 {
+	return o.state.GetLotsRequested() - o.state.GetLotsExecuted()
+}
+
+func (o *order) response() *pb.PostOrderResponse {
+	st := o.state
+	return &pb.PostOrderResponse{
+		OrderId:               st.GetOrderId(),
+		ExecutionReportStatus: st.GetExecutionReportStatus(),
+		LotsRequested:         st.GetLotsRequested(),
+		LotsExecuted:          st.GetLotsExecuted(),
+		InitialOrderPrice:     st.GetInitialOrderPrice(),
+		ExecutedOrderPrice:    st.GetExecutedOrderPrice(),
+		TotalOrderAmount:      st.GetTotalOrderAmount(),
+		InitialCommission:     st.GetInitialCommission(),
+		ExecutedCommission:    st.GetExecutedCommission(),
+		Figi:                  st.GetFigi(),
+		Direction:             st.GetDirection(),
+		InitialSecurityPrice:  st.GetInitialSecurityPrice(),
+		OrderType:             st.GetOrderType(),
+		InstrumentUid:         st.GetInstrumentUid(),
+	}
+}
+
+// events - Изменения состояния, которые нужно отправить в стримы после снятия блокировки
+type events struct {
+	accounts map[string]struct{}
+	trades   []*pb.OrderTrades
+	md       []*pb.MarketDataResponse
+}
+
+func (ev *events) changed(accountId string) {
+	if ev.accounts == nil {
+		ev.accounts = make(map[string]struct{})
+	}
+	ev.accounts[accountId] = struct{}{}
+}
+
+// update - Изменение состояния под s.mu и отправка всех накопленных событий в стримы
+func (s *Server) update(fn func(ev *events) error) error {
+	ev := &events{}
+	s.mu.Lock()
+	err := fn(ev)
+	portfolios := make([]*pb.PortfolioResponse, 0, len(ev.accounts))
+	positions := make([]*pb.PositionData, 0, len(ev.accounts))
+	for id := range ev.accounts {
+		acc, ok := s.accounts[id]
+		if !ok {
+			continue
+		}
+		portfolios = append(portfolios, s.portfolio(acc))
+		positions = append(positions, s.positionData(acc))
+	}
+	s.mu.Unlock()
+
+	for _, resp := range ev.md {
+		s.streams.publishMarketData(resp)
+	}
+	for _, trades := range ev.trades {
+		s.streams.publishTrades(trades)
+	}
+	for _, p := range portfolios {
+		s.streams.publishPortfolio(p)
+	}
+	for _, p := range positions {
+		s.streams.publishPositions(p)
+	}
+	return err
+}
+
+type ordersService struct {
+	pb.UnimplementedOrdersServiceServer
+	s *Server
+}
+
+func (os *ordersService) PostOrder(_ context.Context, req *pb.PostOrderRequest) (*pb.PostOrderResponse, error) {
+	return os.s.postOrder(req, false)
+}
+
+func (os *ordersService) CancelOrder(_ context.Context, req *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
+	return os.s.cancelOrder(req, false)
+}
+
+func (os *ordersService) GetOrderState(_ context.Context, req *pb.GetOrderStateRequest) (*pb.OrderState, error) {
+	return os.s.orderState(req, false)
+}
+
+func (os *ordersService) GetOrders(_ context.Context, req *pb.GetOrdersRequest) (*pb.GetOrdersResponse, error) {
+	return os.s.activeOrders(req.GetAccountId(), false)
+}
+
+func (os *ordersService) ReplaceOrder(_ context.Context, req *pb.ReplaceOrderRequest) (*pb.PostOrderResponse, error) {
+	return os.s.replaceOrder(req, false)
+}
+
+// tradingAccount - Поиск счета, с которого можно торговать, вызывается под s.mu
+func (s *Server) tradingAccount(accountId string, sandbox bool) (*account, error) {
+	acc, err := s.ownAccount(accountId, sandbox)
+	if err != nil {
+		return nil, err
+	}
+	if acc.acc.GetAccessLevel() != pb.AccessLevel_ACCOUNT_ACCESS_LEVEL_FULL_ACCESS {
+		return nil, Error(codes.PermissionDenied, codeInsufficientRights, "Insufficient privileges")
+	}
+	return acc, nil
+}
+
+func (s *Server) postOrder(req *pb.PostOrderRequest, sandbox bool) (*pb.PostOrderResponse, error) {
+	var resp *pb.PostOrderResponse
+	err := s.update(func(ev *events) error {
+		acc, err := s.tradingAccount(req.GetAccountId(), sandbox)
+		if err != nil {
+			return err
+		}
+		id := req.GetInstrumentId()
+		if id == "" {
+			id = req.GetFigi()
+		}
+		o, err := s.placeOrder(acc, id, req.GetOrderId(), req.GetDirection(), req.GetOrderType(),
+			req.GetQuantity(), req.GetPrice(), ev)
+		if err != nil {
+			return err
+		}
+		resp = o.response()
+		return nil
+	})
+	return resp, err
+}
+
+// placeOrder - Выставление заявки и ее исполнение, если цена позволяет, вызывается под s.mu
+func (s *Server) placeOrder(acc *account, instrumentId, requestId string, direction pb.OrderDirection,
+	orderType pb.OrderType, quantity int64, price *pb.Quotation, ev *events) (*order, error) {
+	if requestId != "" {
+		if o, ok := acc.requests[requestId]; ok {
+			return o, nil
+		}
+	}
+	inst := s.instruments.find(instrumentId)
+	if inst == nil {
+		return nil, Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	if quantity <= 0 {
+		return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Quantity must be positive")
+	}
+	if direction == pb.OrderDirection_ORDER_DIRECTION_UNSPECIFIED {
+		return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Direction is not specified")
+	}
+	if !s.tradable(inst) {
+		return nil, Error(codes.FailedPrecondition, codeNotAvailableTrading, "Instrument is not available for trading")
+	}
+
+	market, hasMarket := s.marketPrice(inst, direction)
+	var limit decimal.Decimal
+	switch orderType {
+	case pb.OrderType_ORDER_TYPE_LIMIT:
+		limit = toDecimal(price)
+		if !limit.IsPositive() {
+			return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Price must be positive")
+		}
+		step := toDecimal(inst.base.GetMinPriceIncrement())
+		if step.IsPositive() && !limit.Mod(step).IsZero() {
+			return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Price is not a multiple of min price increment")
+		}
+	case pb.OrderType_ORDER_TYPE_MARKET, pb.OrderType_ORDER_TYPE_BESTPRICE:
+		if !hasMarket {
+			return nil, Error(codes.FailedPrecondition, codeNotAvailableTrading, "No market price for instrument")
+		}
+		limit = market
+	default:
+		return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Order type is not specified")
+	}
+
+	pieces := decimal.NewFromInt(quantity * inst.lot())
+	amount := limit.Mul(pieces)
+	fee := amount.Mul(s.commission)
+	currency := strings.ToLower(inst.base.GetCurrency())
+	if direction == pb.OrderDirection_ORDER_DIRECTION_BUY {
+		if acc.money[currency].LessThan(amount.Add(fee)) {
+			return nil, Error(codes.InvalidArgument, codeInsufficientBalance, "Not enough balance")
+		}
+	} else if !inst.base.GetShortEnabledFlag() {
+		var balance int64
+		if p, ok := acc.positions[inst.uid()]; ok {
+			balance = p.balance
+		}
+		if balance < quantity*inst.lot() {
+			return nil, Error(codes.InvalidArgument, codeNotEnoughAssets, "Not enough assets for a margin trade")
+		}
+	}
+
+	o := &order{
+		accountId: acc.acc.GetId(),
+		inst:      inst,
+		limit:     limit,
+		state: &pb.OrderState{
+			OrderId:               s.nextId(),
+			ExecutionReportStatus: pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW,
+			LotsRequested:         quantity,
+			InitialOrderPrice:     toMoney(amount, currency),
+			ExecutedOrderPrice:    toMoney(decimal.Zero, currency),
+			TotalOrderAmount:      toMoney(decimal.Zero, currency),
+			InitialCommission:     toMoney(fee, currency),
+			ExecutedCommission:    toMoney(decimal.Zero, currency),
+			ServiceCommission:     toMoney(decimal.Zero, currency),
+			Figi:                  inst.base.GetFigi(),
+			Direction:             direction,
+			InitialSecurityPrice:  toMoney(limit, currency),
+			Currency:              currency,
+			OrderType:             orderType,
+			OrderDate:             timestamppb.New(s.now()),
+			InstrumentUid:         inst.uid(),
+			OrderRequestId:        requestId,
+		},
+	}
+	acc.orders[o.state.GetOrderId()] = o
+	acc.orderIds = append(acc.orderIds, o.state.GetOrderId())
+	if requestId != "" {
+		acc.requests[requestId] = o
+	}
+
+	if orderType != pb.OrderType_ORDER_TYPE_LIMIT {
+		s.fill(acc, o, quantity, market, ev)
+	} else if hasMarket && crosses(direction, market, limit) {
+		s.fill(acc, o, quantity, market, ev)
+	}
+	return o, nil
+}
+
+// crosses - Можно ли исполнить заявку по цене price при лимитной цене limit
+func crosses(direction pb.OrderDirection, price, limit decimal.Decimal) bool {
+	if direction == pb.OrderDirection_ORDER_DIRECTION_BUY {
+		return price.LessThanOrEqual(limit)
+	}
+	return price.GreaterThanOrEqual(limit)
+}
+
+// tradable - Торгуется ли инструмент в данный момент, вызывается под s.mu
+func (s *Server) tradable(inst *instrument) bool {
+	status := inst.base.GetTradingStatus()
+	if ts, ok := s.tradingStatuses[inst.uid()]; ok {
+		status = ts.GetTradingStatus()
+	}
+	return status == pb.SecurityTradingStatus_SECURITY_TRADING_STATUS_NORMAL_TRADING
+}
+
+// marketPrice - Цена исполнения рыночной заявки: лучшая цена стакана или цена последней сделки
+func (s *Server) marketPrice(inst *instrument, direction pb.OrderDirection) (decimal.Decimal, bool) {
+	if ob, ok := s.orderBooks[inst.uid()]; ok {
+		side := ob.GetAsks()
+		if direction == pb.OrderDirection_ORDER_DIRECTION_SELL {
+			side = ob.GetBids()
+		}
+		if len(side) > 0 {
+			return toDecimal(side[0].GetPrice()), true
+		}
+	}
+	if lp, ok := s.lastPrices[inst.uid()]; ok {
+		return toDecimal(lp.GetPrice()), true
+	}
+	return decimal.Zero, false
+}
+
+// fill - Исполнение lots лотов заявки по цене price, вызывается под s.mu
+func (s *Server) fill(acc *account, o *order, lots int64, price decimal.Decimal, ev *events) {
+	inst := o.inst
+	currency := o.state.GetCurrency()
+	quantity := lots * inst.lot()
+	amount := price.Mul(decimal.NewFromInt(quantity))
+	fee := amount.Mul(s.commission)
+	now := s.now()
+	tradeId := s.nextId()
+
+	signed := quantity
+	payment := amount.Neg()
+	opType := pb.OperationType_OPERATION_TYPE_BUY
+	opName := "Покупка ценных бумаг"
+	if o.state.GetDirection() == pb.OrderDirection_ORDER_DIRECTION_SELL {
+		signed = -quantity
+		payment = amount
+		opType = pb.OperationType_OPERATION_TYPE_SELL
+		opName = "Продажа ценных бумаг"
+	}
+	acc.money[currency] = acc.money[currency].Add(payment).Sub(fee)
+
+	p, ok := acc.positions[inst.uid()]
+	if !ok {
+		p = &position{inst: inst}
+		acc.positions[inst.uid()] = p
+	}
+	p.apply(signed, price)
+
+	o.executed = o.executed.Add(amount)
+	o.fee = o.fee.Add(fee)
+	st := o.state
+	st.LotsExecuted += lots
+	st.ExecutedOrderPrice = toMoney(o.executed, currency)
+	st.ExecutedCommission = toMoney(o.fee, currency)
+	if st.GetDirection() == pb.OrderDirection_ORDER_DIRECTION_BUY {
+		st.TotalOrderAmount = toMoney(o.executed.Add(o.fee), currency)
+	} else {
+		st.TotalOrderAmount = toMoney(o.executed.Sub(o.fee), currency)
+	}
+	st.AveragePositionPrice = toMoney(o.executed.Div(decimal.NewFromInt(st.GetLotsExecuted()*inst.lot())), currency)
+	st.Stages = append(st.Stages, &pb.OrderStage{
+		Price:    toMoney(price, currency),
+		Quantity: lots,
+		TradeId:  tradeId,
+	})
+	if o.lotsLeft() > 0 {
+		st.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL
+	} else {
+		st.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL
+	}
+
+	opId := s.nextId()
+	acc.operations = append(acc.operations, &pb.Operation{
+		Id:             opId,
+		Currency:       currency,
+		Payment:        toMoney(payment, currency),
+		Price:          toMoney(price, currency),
+		State:          pb.OperationState_OPERATION_STATE_EXECUTED,
+		Quantity:       quantity,
+		Figi:           inst.base.GetFigi(),
+		InstrumentType: inst.base.GetInstrumentType(),
+		Date:           timestamppb.New(now),
+		Type:           opName,
+		OperationType:  opType,
+		Trades: []*pb.OperationTrade{{
+			TradeId:  tradeId,
+			DateTime: timestamppb.New(now),
+			Quantity: quantity,
+			Price:    toMoney(price, currency),
+		}},
+		PositionUid:   inst.base.GetPositionUid(),
+		InstrumentUid: inst.uid(),
+	})
+	if fee.IsPositive() {
+		acc.operations = append(acc.operations, &pb.Operation{
+			Id:                s.nextId(),
+			ParentOperationId: opId,
+			Currency:          currency,
+			Payment:           toMoney(fee.Neg(), currency),
+			State:             pb.OperationState_OPERATION_STATE_EXECUTED,
+			Figi:              inst.base.GetFigi(),
+			InstrumentType:    inst.base.GetInstrumentType(),
+			Date:              timestamppb.New(now),
+			Type:              "Удержание комиссии за операцию",
+			OperationType:     pb.OperationType_OPERATION_TYPE_BROKER_FEE,
+			PositionUid:       inst.base.GetPositionUid(),
+			InstrumentUid:     inst.uid(),
+		})
+	}
+
+	ev.trades = append(ev.trades, &pb.OrderTrades{
+		OrderId:   st.GetOrderId(),
+		CreatedAt: timestamppb.New(now),
+		Direction: st.GetDirection(),
+		Figi:      inst.base.GetFigi(),
+		Trades: []*pb.OrderTrade{{
+			DateTime: timestamppb.New(now),
+			Price:    toQuotation(price),
+			Quantity: quantity,
+			TradeId:  tradeId,
+		}},
+		AccountId:     acc.acc.GetId(),
+		InstrumentUid: inst.uid(),
+	})
+	ev.changed(acc.acc.GetId())
+}
+
+// apply - Изменение позиции на signed штук по цене price с пересчетом средней цены
+func (p *position) apply(signed int64, price decimal.Decimal) {
+	balance := p.balance + signed
+	switch {
+	case balance == 0:
+		p.avgPrice = decimal.Zero
+	case p.balance == 0 || (p.balance > 0) == (signed > 0):
+		total := p.avgPrice.Mul(decimal.NewFromInt(abs(p.balance))).Add(price.Mul(decimal.NewFromInt(abs(signed))))
+		p.avgPrice = total.Div(decimal.NewFromInt(abs(balance)))
+	case (p.balance > 0) != (balance > 0):
+		p.avgPrice = price
+	}
+	p.balance = balance
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// matchOrders - Исполнение активных лимитных заявок по инструменту после изменения цены, вызывается под s.mu
+func (s *Server) matchOrders(inst *instrument, ev *events) {
+	for _, id := range s.accountsIds {
+		acc := s.accounts[id]
+		for _, orderId := range acc.orderIds {
+			o := acc.orders[orderId]
+			if o.inst != inst || !o.active() {
+				continue
+			}
+			market, ok := s.marketPrice(inst, o.state.GetDirection())
+			if !ok || !crosses(o.state.GetDirection(), market, o.limit) {
+				continue
+			}
+			s.fill(acc, o, o.lotsLeft(), o.limit, ev)
+		}
+	}
+	s.matchStopOrders(inst, ev)
+}
+
+// FillOrder - Исполнение lots лотов активной заявки по ее цене независимо от рыночных данных
+func (s *Server) FillOrder(accountId, orderId string, lots int64) error {
+	return s.update(func(ev *events) error {
+		acc, err := s.account(accountId)
+		if err != nil {
+			return err
+		}
+		o, ok := acc.orders[orderId]
+		if !ok || !o.active() {
+			return Error(codes.NotFound, codeOrderNotFound, "Order not found")
+		}
+		if lots <= 0 || lots > o.lotsLeft() {
+			lots = o.lotsLeft()
+		}
+		s.fill(acc, o, lots, o.limit, ev)
+		return nil
+	})
+}
+
+// RejectOrder - Перевод активной заявки в статус REJECTED
+func (s *Server) RejectOrder(accountId, orderId string) error {
+	return s.update(func(ev *events) error {
+		acc, err := s.account(accountId)
+		if err != nil {
+			return err
+		}
+		o, ok := acc.orders[orderId]
+		if !ok || !o.active() {
+			return Error(codes.NotFound, codeOrderNotFound, "Order not found")
+		}
+		o.state.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED
+		ev.changed(accountId)
+		return nil
+	})
+}
+
+func (s *Server) cancelOrder(req *pb.CancelOrderRequest, sandbox bool) (*pb.CancelOrderResponse, error) {
+	var resp *pb.CancelOrderResponse
+	err := s.update(func(ev *events) error {
+		acc, err := s.tradingAccount(req.GetAccountId(), sandbox)
+		if err != nil {
+			return err
+		}
+		o, ok := acc.orders[req.GetOrderId()]
+		if !ok || !o.active() {
+			return Error(codes.NotFound, codeOrderNotFound, "Order not found")
+		}
+		o.state.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED
+		ev.changed(acc.acc.GetId())
+		resp = &pb.CancelOrderResponse{Time: timestamppb.New(s.now())}
+		return nil
+	})
+	return resp, err
+}
+
+func (s *Server) orderState(req *pb.GetOrderStateRequest, sandbox bool) (*pb.OrderState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, err := s.ownAccount(req.GetAccountId(), sandbox)
+	if err != nil {
+		return nil, err
+	}
+	o, ok := acc.orders[req.GetOrderId()]
+	if !ok {
+		return nil, Error(codes.NotFound, codeOrderNotFound, "Order not found")
+	}
+	return proto.Clone(o.state).(*pb.OrderState), nil
+}
+
+func (s *Server) activeOrders(accountId string, sandbox bool) (*pb.GetOrdersResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, err := s.ownAccount(accountId, sandbox)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.GetOrdersResponse{}
+	for _, id := range acc.orderIds {
+		if o := acc.orders[id]; o.active() {
+			resp.Orders = append(resp.Orders, proto.Clone(o.state).(*pb.OrderState))
+		}
+	}
+	return resp, nil
+}
+
+// replaceOrder - Отмена заявки и выставление новой с остатком лотов, как на бирже
+func (s *Server) replaceOrder(req *pb.ReplaceOrderRequest, sandbox bool) (*pb.PostOrderResponse, error) {
+	var resp *pb.PostOrderResponse
+	err := s.update(func(ev *events) error {
+		acc, err := s.tradingAccount(req.GetAccountId(), sandbox)
+		if err != nil {
+			return err
+		}
+		old, ok := acc.orders[req.GetOrderId()]
+		if !ok || !old.active() {
+			return Error(codes.NotFound, codeOrderNotFound, "Order not found")
+		}
+		price := req.GetPrice()
+		if price == nil {
+			price = toQuotation(old.limit)
+		}
+		quantity := req.GetQuantity()
+		if quantity <= 0 {
+			quantity = old.lotsLeft()
+		}
+		old.state.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED
+		o, err := s.placeOrder(acc, old.inst.uid(), req.GetIdempotencyKey(), old.state.GetDirection(),
+			pb.OrderType_ORDER_TYPE_LIMIT, quantity, price, ev)
+		if err != nil {
+			old.state.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW
+			if old.state.GetLotsExecuted() > 0 {
+				old.state.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL
+			}
+			return err
+		}
+		ev.changed(acc.acc.GetId())
+		resp = o.response()
+		return nil
+	})
+	return resp, err
+}
@@ -0,0 +1,93 @@
+package fake
+
+import (
+	"context"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+)
+
+// sandboxService - Песочница работает на том же движке заявок, что и брокерские счета, но только со счетами песочницы
+type sandboxService struct {
+	pb.UnimplementedSandboxServiceServer
+	s *Server
+}
+
+func (ss *sandboxService) OpenSandboxAccount(_ context.Context, _ *pb.OpenSandboxAccountRequest) (*pb.OpenSandboxAccountResponse, error) {
+	return &pb.OpenSandboxAccountResponse{AccountId: ss.s.OpenSandboxAccount()}, nil
+}
+
+func (ss *sandboxService) GetSandboxAccounts(_ context.Context, _ *pb.GetAccountsRequest) (*pb.GetAccountsResponse, error) {
+	return ss.s.accountsList(true), nil
+}
+
+func (ss *sandboxService) CloseSandboxAccount(_ context.Context, req *pb.CloseSandboxAccountRequest) (*pb.CloseSandboxAccountResponse, error) {
+	s := ss.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.ownAccount(req.GetAccountId(), true); err != nil {
+		return nil, err
+	}
+	if err := s.closeAccount(req.GetAccountId()); err != nil {
+		return nil, err
+	}
+	return &pb.CloseSandboxAccountResponse{}, nil
+}
+
+func (ss *sandboxService) PostSandboxOrder(_ context.Context, req *pb.PostOrderRequest) (*pb.PostOrderResponse, error) {
+	return ss.s.postOrder(req, true)
+}
+
+func (ss *sandboxService) ReplaceSandboxOrder(_ context.Context, req *pb.ReplaceOrderRequest) (*pb.PostOrderResponse, error) {
+	return ss.s.replaceOrder(req, true)
+}
+
+func (ss *sandboxService) GetSandboxOrders(_ context.Context, req *pb.GetOrdersRequest) (*pb.GetOrdersResponse, error) {
+	return ss.s.activeOrders(req.GetAccountId(), true)
+}
+
+func (ss *sandboxService) CancelSandboxOrder(_ context.Context, req *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
+	return ss.s.cancelOrder(req, true)
+}
+
+func (ss *sandboxService) GetSandboxOrderState(_ context.Context, req *pb.GetOrderStateRequest) (*pb.OrderState, error) {
+	return ss.s.orderState(req, true)
+}
+
+func (ss *sandboxService) GetSandboxPositions(_ context.Context, req *pb.PositionsRequest) (*pb.PositionsResponse, error) {
+	return ss.s.positions(req.GetAccountId(), true)
+}
+
+func (ss *sandboxService) GetSandboxOperations(_ context.Context, req *pb.OperationsRequest) (*pb.OperationsResponse, error) {
+	return ss.s.operations(req, true)
+}
+
+func (ss *sandboxService) GetSandboxOperationsByCursor(_ context.Context, req *pb.GetOperationsByCursorRequest) (*pb.GetOperationsByCursorResponse, error) {
+	return ss.s.operationsByCursor(req, true)
+}
+
+func (ss *sandboxService) GetSandboxPortfolio(_ context.Context, req *pb.PortfolioRequest) (*pb.PortfolioResponse, error) {
+	return ss.s.getPortfolio(req, true)
+}
+
+func (ss *sandboxService) SandboxPayIn(_ context.Context, req *pb.SandboxPayInRequest) (*pb.SandboxPayInResponse, error) {
+	s := ss.s
+	s.mu.Lock()
+	_, err := s.ownAccount(req.GetAccountId(), true)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if req.GetAmount() == nil || moneyToDecimal(req.GetAmount()).IsNegative() {
+		return nil, Error(codes.InvalidArgument, codeInvalidArgument, "Amount must be positive")
+	}
+	balance, err := s.payIn(req.GetAccountId(), req.GetAmount())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SandboxPayInResponse{Balance: balance}, nil
+}
+
+func (ss *sandboxService) GetSandboxWithdrawLimits(_ context.Context, req *pb.WithdrawLimitsRequest) (*pb.WithdrawLimitsResponse, error) {
+	return ss.s.withdrawLimits(req.GetAccountId(), true)
+}
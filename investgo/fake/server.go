@@ -0,0 +1,414 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultToken - Токен, который сервер принимает по умолчанию
+	DefaultToken = "fake-token"
+	// DefaultCurrency - Валюта счета по умолчанию
+	DefaultCurrency = "rub"
+)
+
+// Server - Локальный сервер InvestAPI с состоянием в памяти
+type Server struct {
+	mu sync.Mutex
+
+	grpcServer *grpc.Server
+	listener   net.Listener
+
+	token            string
+	clock            func() time.Time
+	defaultAccountId string
+	enforceLimits    bool
+	commission       decimal.Decimal
+	seq              int64
+
+	accounts    map[string]*account
+	accountsIds []string
+	instruments *instrumentsIndex
+	favorites   map[string]struct{}
+	schedules   []*pb.TradingSchedule
+
+	lastPrices      map[string]*pb.LastPrice
+	closePrices     map[string]*pb.Quotation
+	orderBooks      map[string]*pb.OrderBook
+	tradingStatuses map[string]*pb.TradingStatus
+	candles         map[string]map[pb.CandleInterval][]*pb.HistoricCandle
+	trades          map[string][]*pb.Trade
+
+	tariff *pb.GetUserTariffResponse
+	info   *pb.GetInfoResponse
+	margin map[string]*pb.GetMarginAttributesResponse
+
+	injected map[string][]error
+	calls    map[string]int
+	limits   map[string]*limitWindow
+
+	streams *streamsHub
+}
+
+// NewServer - Создание сервера с одним открытым счетом без денег и без инструментов
+func NewServer() *Server {
+	s := &Server{
+		token:           DefaultToken,
+		clock:           time.Now,
+		accounts:        make(map[string]*account),
+		instruments:     newInstrumentsIndex(),
+		favorites:       make(map[string]struct{}),
+		lastPrices:      make(map[string]*pb.LastPrice),
+		closePrices:     make(map[string]*pb.Quotation),
+		orderBooks:      make(map[string]*pb.OrderBook),
+		tradingStatuses: make(map[string]*pb.TradingStatus),
+		candles:         make(map[string]map[pb.CandleInterval][]*pb.HistoricCandle),
+		trades:          make(map[string][]*pb.Trade),
+		tariff:          defaultTariff(),
+		info:            &pb.GetInfoResponse{Tariff: "investor"},
+		margin:          make(map[string]*pb.GetMarginAttributesResponse),
+		injected:        make(map[string][]error),
+		calls:           make(map[string]int),
+		limits:          make(map[string]*limitWindow),
+		streams:         newStreamsHub(),
+	}
+	s.defaultAccountId = s.OpenAccount("fake", pb.AccountType_ACCOUNT_TYPE_TINKOFF)
+	return s
+}
+
+// Start - Запуск сервера на свободном локальном порту
+func (s *Server) Start() error {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.listener = lis
+	s.grpcServer = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor))
+
+	pb.RegisterInstrumentsServiceServer(s.grpcServer, &instrumentsService{s: s})
+	pb.RegisterMarketDataServiceServer(s.grpcServer, &marketDataService{s: s})
+	pb.RegisterMarketDataStreamServiceServer(s.grpcServer, &marketDataStreamService{s: s})
+	pb.RegisterOperationsServiceServer(s.grpcServer, &operationsService{s: s})
+	pb.RegisterOperationsStreamServiceServer(s.grpcServer, &operationsStreamService{s: s})
+	pb.RegisterOrdersServiceServer(s.grpcServer, &ordersService{s: s})
+	pb.RegisterOrdersStreamServiceServer(s.grpcServer, &ordersStreamService{s: s})
+	pb.RegisterStopOrdersServiceServer(s.grpcServer, &stopOrdersService{s: s})
+	pb.RegisterSandboxServiceServer(s.grpcServer, &sandboxService{s: s})
+	pb.RegisterUsersServiceServer(s.grpcServer, &usersService{s: s})
+
+	go func() {
+		_ = s.grpcServer.Serve(lis)
+	}()
+	return nil
+}
+
+// Stop - Остановка сервера, все открытые стримы завершаются
+func (s *Server) Stop() {
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+	}
+}
+
+// Addr - Адрес, на котором слушает сервер
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Config - Конфигурация investgo для подключения к серверу
+func (s *Server) Config() investgo.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return investgo.Config{
+		EndPoint:  s.Addr(),
+		Token:     s.token,
+		AppName:   "invest-api-go-sdk-fake",
+		AccountId: s.defaultAccountId,
+		Insecure:  true,
+		// счет по умолчанию открыт как боевой, песочница доступна через OpenSandboxAccount
+		Environment: investgo.EnvironmentProduction,
+	}
+}
+
+// AccountId - Идентификатор счета, открытого при создании сервера
+func (s *Server) AccountId() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.defaultAccountId
+}
+
+// SetToken - Установка токена, с которым сервер принимает запросы. Пустой токен отключает проверку
+func (s *Server) SetToken(token string) {
+	s.mu.Lock()
+	s.token = token
+	s.mu.Unlock()
+}
+
+// SetClock - Установка источника текущего времени сервера, по умолчанию time.Now
+func (s *Server) SetClock(clock func() time.Time) {
+	s.mu.Lock()
+	s.clock = clock
+	s.mu.Unlock()
+}
+
+// SetCommission - Установка комиссии за сделку в долях от ее объема, например 0.0005
+func (s *Server) SetCommission(rate float64) {
+	s.mu.Lock()
+	s.commission = decimal.NewFromFloat(rate)
+	s.mu.Unlock()
+}
+
+// EnforceRateLimits - Включение проверки лимитов запросов из тарифа, при превышении сервер
+// возвращает ResourceExhausted, как и реальный InvestAPI
+func (s *Server) EnforceRateLimits(enable bool) {
+	s.mu.Lock()
+	s.enforceLimits = enable
+	s.mu.Unlock()
+}
+
+// InjectError - Следующий вызов метода method вернет ошибку err. Метод указывается полным именем,
+// например "/tinkoff.public.invest.api.contract.v1.OrdersService/PostOrder" или коротко "OrdersService/PostOrder".
+// Ошибки накапливаются в очередь и возвращаются по одной на каждый вызов
+func (s *Server) InjectError(method string, err error) {
+	s.mu.Lock()
+	key := shortMethod(method)
+	s.injected[key] = append(s.injected[key], err)
+	s.mu.Unlock()
+}
+
+// Calls - Количество вызовов метода, имя метода указывается так же, как в InjectError
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[shortMethod(method)]
+}
+
+// Error - Ошибка в формате InvestAPI: код ошибки API передается в описании статуса,
+// а текст ошибки в заголовке message
+func Error(code codes.Code, apiCode string, message string) error {
+	return &apiError{code: code, apiCode: apiCode, message: message}
+}
+
+// Коды ошибок InvestAPI, которые возвращает сервер
+const (
+	codeInvalidArgument     = "30001"
+	codeInsufficientBalance = "30034"
+	codeNotEnoughAssets     = "30042"
+	codeNotAvailableTrading = "30079"
+	codeInsufficientRights  = "40002"
+	codeInvalidToken        = "40003"
+	codeInstrumentNotFound  = "50002"
+	codeAccountNotFound     = "50004"
+	codeOrderNotFound       = "50005"
+	codeStopOrderNotFound   = "50006"
+	codeInternal            = "70001"
+	codeRequestsLimit       = "80002"
+)
+
+type apiError struct {
+	code    codes.Code
+	apiCode string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.apiCode, e.message)
+}
+
+func (s *Server) now() time.Time {
+	return s.clock()
+}
+
+// nextId - Последовательный числовой идентификатор, вызывается под s.mu
+func (s *Server) nextId() string {
+	s.seq++
+	return strconv.FormatInt(s.seq, 10)
+}
+
+func shortMethod(method string) string {
+	method = strings.TrimPrefix(method, "/")
+	return strings.TrimPrefix(method, "tinkoff.public.invest.api.contract.v1.")
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	header := metadata.Pairs("x-tracking-id", uuid.NewString())
+	if err := s.before(ctx, info.FullMethod, header); err != nil {
+		return nil, s.finish(ctx, header, err)
+	}
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, s.finish(ctx, header, err)
+	}
+	_ = grpc.SetHeader(ctx, header)
+	return resp, nil
+}
+
+func (s *Server) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	header := metadata.Pairs("x-tracking-id", uuid.NewString())
+	if err := s.before(ss.Context(), info.FullMethod, header); err != nil {
+		return s.finishStream(ss, header, err)
+	}
+	if err := ss.SetHeader(header); err != nil {
+		return err
+	}
+	if err := handler(srv, ss); err != nil {
+		return s.finishStream(ss, header, err)
+	}
+	return nil
+}
+
+// before - Проверка токена, лимитов и внедренных ошибок перед вызовом обработчика
+func (s *Server) before(ctx context.Context, fullMethod string, header metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	method := shortMethod(fullMethod)
+	s.calls[method]++
+
+	if s.token != "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		auth := md.Get("authorization")
+		if len(auth) < 1 || auth[0] != fmt.Sprintf("Bearer %s", s.token) {
+			return Error(codes.Unauthenticated, codeInvalidToken, "Authentication token is missing or invalid")
+		}
+	}
+
+	if err := s.checkLimit(method, header); err != nil {
+		return err
+	}
+
+	if errs := s.injected[method]; len(errs) > 0 {
+		s.injected[method] = errs[1:]
+		return errs[0]
+	}
+	return nil
+}
+
+func (s *Server) finish(ctx context.Context, header metadata.MD, err error) error {
+	trailer, err := s.toStatus(header, err)
+	_ = grpc.SetTrailer(ctx, trailer)
+	return err
+}
+
+func (s *Server) finishStream(ss grpc.ServerStream, header metadata.MD, err error) error {
+	trailer, err := s.toStatus(header, err)
+	ss.SetTrailer(trailer)
+	return err
+}
+
+// toStatus - Перевод ошибки обработчика в статус gRPC и заголовки ответа
+func (s *Server) toStatus(header metadata.MD, err error) (metadata.MD, error) {
+	trailer := header.Copy()
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		trailer.Set("message", apiErr.message)
+		return trailer, status.Error(apiErr.code, apiErr.apiCode)
+	}
+	return trailer, err
+}
+
+// limitWindow - Счетчик запросов группы методов за текущую минуту
+type limitWindow struct {
+	limit int
+	start time.Time
+	count int
+}
+
+// checkLimit - Учет запроса в лимитах тарифа, вызывается под s.mu
+func (s *Server) checkLimit(method string, header metadata.MD) error {
+	group, limit := s.limitGroup(method)
+	if limit <= 0 {
+		return nil
+	}
+	now := s.now()
+	w, ok := s.limits[group]
+	if !ok || now.Sub(w.start) >= time.Minute {
+		w = &limitWindow{limit: limit, start: now.Truncate(time.Minute)}
+		s.limits[group] = w
+	}
+	w.count++
+	reset := int(w.start.Add(time.Minute).Sub(now).Seconds())
+	remaining := w.limit - w.count
+	if remaining < 0 {
+		remaining = 0
+	}
+	header.Set("x-ratelimit-limit", strconv.Itoa(w.limit))
+	header.Set("x-ratelimit-remaining", strconv.Itoa(remaining))
+	header.Set("x-ratelimit-reset", strconv.Itoa(reset))
+	if s.enforceLimits && w.count > w.limit {
+		return Error(codes.ResourceExhausted, codeRequestsLimit, "Request limit exceeded")
+	}
+	return nil
+}
+
+// limitGroup - Поиск лимита метода в тарифе, группой считается набор методов одного UnaryLimit
+func (s *Server) limitGroup(method string) (string, int) {
+	for i, l := range s.tariff.GetUnaryLimits() {
+		for _, m := range l.GetMethods() {
+			if shortMethod(m) == method {
+				return strconv.Itoa(i), int(l.GetLimitPerMinute())
+			}
+		}
+	}
+	return "", 0
+}
+
+func defaultTariff() *pb.GetUserTariffResponse {
+	prefix := "tinkoff.public.invest.api.contract.v1."
+	methods := func(service string, names ...string) []string {
+		res := make([]string, 0, len(names))
+		for _, n := range names {
+			res = append(res, prefix+service+"/"+n)
+		}
+		return res
+	}
+	return &pb.GetUserTariffResponse{
+		UnaryLimits: []*pb.UnaryLimit{
+			{LimitPerMinute: 200, Methods: methods("InstrumentsService", "TradingSchedules", "BondBy", "Bonds",
+				"GetBondCoupons", "CurrencyBy", "Currencies", "EtfBy", "Etfs", "FutureBy", "Futures", "OptionBy",
+				"Options", "OptionsBy", "ShareBy", "Shares", "GetAccruedInterests", "GetFuturesMargin",
+				"GetInstrumentBy", "GetDividends", "GetAssetBy", "GetAssets", "GetFavorites", "EditFavorites",
+				"GetCountries", "FindInstrument", "GetBrands", "GetBrandBy")},
+			{LimitPerMinute: 600, Methods: methods("MarketDataService", "GetCandles", "GetLastPrices",
+				"GetOrderBook", "GetTradingStatus", "GetTradingStatuses", "GetLastTrades", "GetClosePrices")},
+			{LimitPerMinute: 200, Methods: methods("OperationsService", "GetOperations", "GetPortfolio",
+				"GetPositions", "GetWithdrawLimits", "GetBrokerReport", "GetDividendsForeignIssuer",
+				"GetOperationsByCursor")},
+			{LimitPerMinute: 100, Methods: methods("OrdersService", "PostOrder", "CancelOrder", "ReplaceOrder")},
+			{LimitPerMinute: 200, Methods: methods("OrdersService", "GetOrderState", "GetOrders")},
+			{LimitPerMinute: 50, Methods: methods("StopOrdersService", "PostStopOrder", "GetStopOrders",
+				"CancelStopOrder")},
+			{LimitPerMinute: 100, Methods: methods("UsersService", "GetAccounts", "GetMarginAttributes",
+				"GetUserTariff", "GetInfo")},
+			{LimitPerMinute: 200, Methods: methods("SandboxService", "OpenSandboxAccount", "GetSandboxAccounts",
+				"CloseSandboxAccount", "PostSandboxOrder", "ReplaceSandboxOrder", "GetSandboxOrders",
+				"CancelSandboxOrder", "GetSandboxOrderState", "GetSandboxPositions", "GetSandboxOperations",
+				"GetSandboxOperationsByCursor", "GetSandboxPortfolio", "SandboxPayIn", "GetSandboxWithdrawLimits")},
+		},
+		StreamLimits: []*pb.StreamLimit{
+			{Limit: 16, Streams: []string{prefix + "MarketDataStreamService/MarketDataStream",
+				prefix + "MarketDataStreamService/MarketDataServerSideStream"}},
+			{Limit: 8, Streams: []string{prefix + "OrdersStreamService/TradesStream"}},
+			{Limit: 8, Streams: []string{prefix + "OperationsStreamService/PortfolioStream",
+				prefix + "OperationsStreamService/PositionsStream"}},
+		},
+	}
+}
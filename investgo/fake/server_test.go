@@ -0,0 +1,263 @@
+package fake_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testFigi   = "BBG004730N88"
+	testTicker = "SBER"
+)
+
+type testLogger struct{}
+
+func (testLogger) Infof(string, ...any)  {}
+func (testLogger) Errorf(string, ...any) {}
+func (testLogger) Fatalf(string, ...any) {}
+
+// newTestServer - Сервер с одной акцией по цене 250 и 100000 рублей на счете и клиент к нему
+func newTestServer(t *testing.T) (*fake.Server, *investgo.Client) {
+	t.Helper()
+	srv := fake.NewServer()
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+	srv.AddShare(&pb.Share{Figi: testFigi, Ticker: testTicker, ClassCode: "TQBR", Lot: 10, Currency: "rub", ApiTradeAvailableFlag: true})
+	if err := srv.PayIn(srv.AccountId(), fake.Money(100000, "rub")); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.SetLastPrice(testFigi, fake.Quotation(250)); err != nil {
+		t.Fatal(err)
+	}
+	client, err := investgo.NewClient(context.Background(), srv.Config(), testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Stop()
+	})
+	return srv, client
+}
+
+func TestPostMarketOrder(t *testing.T) {
+	srv, client := newTestServer(t)
+	orders := client.NewOrdersServiceClient()
+
+	resp, err := orders.Buy(&investgo.PostOrderRequestShort{
+		InstrumentId: testFigi,
+		Quantity:     2,
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_MARKET,
+		OrderId:      investgo.CreateUid(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL {
+		t.Fatalf("status = %v, want FILL", resp.GetExecutionReportStatus())
+	}
+	if got := resp.GetLotsExecuted(); got != 2 {
+		t.Fatalf("lots executed = %d, want 2", got)
+	}
+	if len(resp.Header.Get("x-tracking-id")) == 0 {
+		t.Fatal("x-tracking-id header is missing")
+	}
+	if got := srv.PositionBalance(srv.AccountId(), testFigi); got != 20 {
+		t.Fatalf("position = %d, want 20", got)
+	}
+	if got := srv.Balance(srv.AccountId(), "rub").ToFloat(); got != 95000 {
+		t.Fatalf("balance = %v, want 95000", got)
+	}
+}
+
+func TestLimitOrderLifecycle(t *testing.T) {
+	srv, client := newTestServer(t)
+	orders := client.NewOrdersServiceClient()
+
+	resp, err := orders.Buy(&investgo.PostOrderRequestShort{
+		InstrumentId: testFigi,
+		Quantity:     1,
+		Price:        fake.Quotation(240),
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_LIMIT,
+		OrderId:      investgo.CreateUid(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW {
+		t.Fatalf("status = %v, want NEW", resp.GetExecutionReportStatus())
+	}
+	active, err := orders.GetOrders(srv.AccountId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active.GetOrders()) != 1 || active.GetOrders()[0].GetOrderId() != resp.GetOrderId() {
+		t.Fatalf("active orders = %v, want %s", active.GetOrders(), resp.GetOrderId())
+	}
+
+	// цена опустилась до лимитной, заявка исполняется
+	if err := srv.SetLastPrice(testFigi, fake.Quotation(240)); err != nil {
+		t.Fatal(err)
+	}
+	state, err := orders.GetOrderState(srv.AccountId(), resp.GetOrderId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL {
+		t.Fatalf("status = %v, want FILL", state.GetExecutionReportStatus())
+	}
+
+	resp, err = orders.Sell(&investgo.PostOrderRequestShort{
+		InstrumentId: testFigi,
+		Quantity:     1,
+		Price:        fake.Quotation(300),
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_LIMIT,
+		OrderId:      investgo.CreateUid(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.CancelOrder(srv.AccountId(), resp.GetOrderId()); err != nil {
+		t.Fatal(err)
+	}
+	state, err = orders.GetOrderState(srv.AccountId(), resp.GetOrderId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED {
+		t.Fatalf("status = %v, want CANCELLED", state.GetExecutionReportStatus())
+	}
+}
+
+func TestOperations(t *testing.T) {
+	srv, client := newTestServer(t)
+	srv.SetCommission(0.001)
+	_, err := client.NewOrdersServiceClient().Buy(&investgo.PostOrderRequestShort{
+		InstrumentId: testFigi,
+		Quantity:     1,
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_MARKET,
+		OrderId:      investgo.CreateUid(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	operations := client.NewOperationsServiceClient()
+
+	ops, err := operations.GetOperations(&investgo.GetOperationsRequest{
+		AccountId: srv.AccountId(),
+		From:      time.Now().Add(-time.Hour),
+		To:        time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := make(map[pb.OperationType]int)
+	for _, op := range ops.GetOperations() {
+		types[op.GetOperationType()]++
+	}
+	for _, want := range []pb.OperationType{
+		pb.OperationType_OPERATION_TYPE_INPUT,
+		pb.OperationType_OPERATION_TYPE_BUY,
+		pb.OperationType_OPERATION_TYPE_BROKER_FEE,
+	} {
+		if types[want] != 1 {
+			t.Errorf("operations of type %v = %d, want 1", want, types[want])
+		}
+	}
+
+	positions, err := operations.GetPositions(srv.AccountId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions.GetSecurities()) != 1 || positions.GetSecurities()[0].GetBalance() != 10 {
+		t.Fatalf("securities = %v, want 10 shares", positions.GetSecurities())
+	}
+
+	portfolio, err := operations.GetPortfolio(srv.AccountId(), pb.PortfolioRequest_RUB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := portfolio.GetTotalAmountShares().ToFloat(); got != 2500 {
+		t.Fatalf("shares amount = %v, want 2500", got)
+	}
+	// 100000 - 2500 за акции - 2.5 комиссии, акции по текущей цене 2500
+	if got := portfolio.GetTotalAmountPortfolio().ToFloat(); got != 99997.5 {
+		t.Fatalf("portfolio amount = %v, want 99997.5", got)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	srv, client := newTestServer(t)
+	orders := client.NewOrdersServiceClient()
+
+	_, err := orders.Buy(&investgo.PostOrderRequestShort{
+		InstrumentId: testFigi,
+		Quantity:     1000,
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_MARKET,
+		OrderId:      investgo.CreateUid(),
+	})
+	var apiErr *investgo.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *investgo.Error", err)
+	}
+	if apiErr.GRPCCode != codes.InvalidArgument || apiErr.Code != "30034" || apiErr.Message == "" || apiErr.TrackingId == "" {
+		t.Fatalf("err = %+v, want InvalidArgument 30034 with message and tracking id", apiErr)
+	}
+	if !errors.Is(err, investgo.ErrInsufficientFunds) {
+		t.Fatalf("err = %v, want ErrInsufficientFunds", err)
+	}
+
+	_, err = orders.GetOrderState(srv.AccountId(), "unknown")
+	if status.Code(err) != codes.NotFound || !errors.Is(err, investgo.ErrOrderNotFound) {
+		t.Fatalf("err = %v, want NotFound ErrOrderNotFound", err)
+	}
+
+	_, err = client.NewInstrumentsServiceClient().ShareByFigi("unknown")
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.NotFound || apiErr.Code != "50002" {
+		t.Fatalf("err = %v, want NotFound 50002", err)
+	}
+
+	srv.InjectError("UsersService/GetInfo", fake.Error(codes.PermissionDenied, "40002", "Insufficient privileges"))
+	_, err = client.NewUsersServiceClient().GetInfo()
+	if !errors.As(err, &apiErr) || apiErr.GRPCCode != codes.PermissionDenied || apiErr.Message != "Insufficient privileges" {
+		t.Fatalf("err = %v, want injected PermissionDenied", err)
+	}
+
+	conf := srv.Config()
+	conf.Token = "invalid"
+	unauthorized, err := investgo.NewClient(context.Background(), conf, testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unauthorized.Stop()
+	_, err = unauthorized.NewUsersServiceClient().GetInfo()
+	if status.Code(err) != codes.Unauthenticated || !errors.Is(err, investgo.ErrInvalidToken) {
+		t.Fatalf("err = %v, want Unauthenticated ErrInvalidToken", err)
+	}
+}
+
+func TestRetryOnUnavailable(t *testing.T) {
+	srv, client := newTestServer(t)
+	srv.InjectError("OrdersService/GetOrders", status.Error(codes.Unavailable, "unavailable"))
+
+	if _, err := client.NewOrdersServiceClient().GetOrders(srv.AccountId()); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Calls("OrdersService/GetOrders"); got != 2 {
+		t.Fatalf("calls = %d, want 2", got)
+	}
+}
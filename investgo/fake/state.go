@@ -0,0 +1,477 @@
+package fake
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// account - Счет со всеми его позициями, заявками и операциями
+type account struct {
+	acc     *pb.Account
+	sandbox bool
+
+	money      map[string]decimal.Decimal
+	positions  map[string]*position
+	orders     map[string]*order
+	orderIds   []string
+	requests   map[string]*order
+	stopOrders map[string]*stopOrder
+	stopIds    []string
+	operations []*pb.Operation
+}
+
+// position - Позиция по инструменту, balance в штуках
+type position struct {
+	inst     *instrument
+	balance  int64
+	avgPrice decimal.Decimal
+}
+
+func newAccount(acc *pb.Account, sandbox bool) *account {
+	return &account{
+		acc:        acc,
+		sandbox:    sandbox,
+		money:      make(map[string]decimal.Decimal),
+		positions:  make(map[string]*position),
+		orders:     make(map[string]*order),
+		requests:   make(map[string]*order),
+		stopOrders: make(map[string]*stopOrder),
+	}
+}
+
+// instrument - Инструмент любого типа, base заполняется всегда, остальные поля - в зависимости от типа
+type instrument struct {
+	base     *pb.Instrument
+	share    *pb.Share
+	bond     *pb.Bond
+	etf      *pb.Etf
+	future   *pb.Future
+	currency *pb.Currency
+	option   *pb.Option
+}
+
+func (i *instrument) uid() string {
+	return i.base.GetUid()
+}
+
+func (i *instrument) lot() int64 {
+	return int64(i.base.GetLot())
+}
+
+type instrumentsIndex struct {
+	list []*instrument
+	byId map[string]*instrument
+}
+
+func newInstrumentsIndex() *instrumentsIndex {
+	return &instrumentsIndex{byId: make(map[string]*instrument)}
+}
+
+func (idx *instrumentsIndex) add(inst *instrument) {
+	b := inst.base
+	if old, ok := idx.byId[b.GetUid()]; ok {
+		for i, in := range idx.list {
+			if in == old {
+				idx.list = append(idx.list[:i], idx.list[i+1:]...)
+				break
+			}
+		}
+	}
+	idx.list = append(idx.list, inst)
+	for _, key := range []string{b.GetFigi(), b.GetUid(), b.GetPositionUid(), tickerKey(b.GetTicker(), b.GetClassCode())} {
+		if key != "" && key != "_" {
+			idx.byId[key] = inst
+		}
+	}
+}
+
+// find - Поиск инструмента по figi, uid, position_uid или ticker_classCode
+func (idx *instrumentsIndex) find(id string) *instrument {
+	if id == "" {
+		return nil
+	}
+	return idx.byId[id]
+}
+
+func (idx *instrumentsIndex) findBy(idType pb.InstrumentIdType, classCode, id string) *instrument {
+	var inst *instrument
+	switch idType {
+	case pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER:
+		inst = idx.find(tickerKey(id, classCode))
+	default:
+		inst = idx.find(id)
+	}
+	if inst == nil {
+		return nil
+	}
+	b := inst.base
+	switch idType {
+	case pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI:
+		if b.GetFigi() != id {
+			return nil
+		}
+	case pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID:
+		if b.GetUid() != id {
+			return nil
+		}
+	case pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID:
+		if b.GetPositionUid() != id {
+			return nil
+		}
+	}
+	return inst
+}
+
+func tickerKey(ticker, classCode string) string {
+	return fmt.Sprintf("%s_%s", strings.ToUpper(ticker), strings.ToUpper(classCode))
+}
+
+// commonInstrument - Общие поля всех типов инструментов
+type commonInstrument interface {
+	GetFigi() string
+	GetTicker() string
+	GetClassCode() string
+	GetLot() int32
+	GetCurrency() string
+	GetName() string
+	GetExchange() string
+	GetCountryOfRisk() string
+	GetShortEnabledFlag() bool
+	GetTradingStatus() pb.SecurityTradingStatus
+	GetOtcFlag() bool
+	GetBuyAvailableFlag() bool
+	GetSellAvailableFlag() bool
+	GetMinPriceIncrement() *pb.Quotation
+	GetApiTradeAvailableFlag() bool
+	GetUid() string
+	GetPositionUid() string
+	GetForIisFlag() bool
+	GetForQualInvestorFlag() bool
+	GetWeekendFlag() bool
+	GetBlockedTcaFlag() bool
+	GetFirst_1MinCandleDate() *timestamppb.Timestamp
+	GetFirst_1DayCandleDate() *timestamppb.Timestamp
+	GetKlong() *pb.Quotation
+	GetKshort() *pb.Quotation
+	GetDlong() *pb.Quotation
+	GetDshort() *pb.Quotation
+	GetDlongMin() *pb.Quotation
+	GetDshortMin() *pb.Quotation
+}
+
+func baseInstrument(c commonInstrument, isin string, kind pb.InstrumentType, typeName string) *pb.Instrument {
+	return &pb.Instrument{
+		Figi:                  c.GetFigi(),
+		Ticker:                c.GetTicker(),
+		ClassCode:             c.GetClassCode(),
+		Isin:                  isin,
+		Lot:                   c.GetLot(),
+		Currency:              c.GetCurrency(),
+		Klong:                 c.GetKlong(),
+		Kshort:                c.GetKshort(),
+		Dlong:                 c.GetDlong(),
+		Dshort:                c.GetDshort(),
+		DlongMin:              c.GetDlongMin(),
+		DshortMin:             c.GetDshortMin(),
+		ShortEnabledFlag:      c.GetShortEnabledFlag(),
+		Name:                  c.GetName(),
+		Exchange:              c.GetExchange(),
+		CountryOfRisk:         c.GetCountryOfRisk(),
+		InstrumentType:        typeName,
+		TradingStatus:         c.GetTradingStatus(),
+		OtcFlag:               c.GetOtcFlag(),
+		BuyAvailableFlag:      c.GetBuyAvailableFlag(),
+		SellAvailableFlag:     c.GetSellAvailableFlag(),
+		MinPriceIncrement:     c.GetMinPriceIncrement(),
+		ApiTradeAvailableFlag: c.GetApiTradeAvailableFlag(),
+		Uid:                   c.GetUid(),
+		PositionUid:           c.GetPositionUid(),
+		ForIisFlag:            c.GetForIisFlag(),
+		ForQualInvestorFlag:   c.GetForQualInvestorFlag(),
+		WeekendFlag:           c.GetWeekendFlag(),
+		BlockedTcaFlag:        c.GetBlockedTcaFlag(),
+		InstrumentKind:        kind,
+		First_1MinCandleDate:  c.GetFirst_1MinCandleDate(),
+		First_1DayCandleDate:  c.GetFirst_1DayCandleDate(),
+	}
+}
+
+// fillDefaults - Заполнение отсутствующих идентификаторов, лотности и шага цены
+func fillDefaults(uid, positionUid *string, lot *int32, step **pb.Quotation, status *pb.SecurityTradingStatus) {
+	if *uid == "" {
+		*uid = uuid.NewString()
+	}
+	if *positionUid == "" {
+		*positionUid = uuid.NewString()
+	}
+	if *lot == 0 {
+		*lot = 1
+	}
+	if *step == nil {
+		*step = &pb.Quotation{Nano: 10000000}
+	}
+	if *status == pb.SecurityTradingStatus_SECURITY_TRADING_STATUS_UNSPECIFIED {
+		*status = pb.SecurityTradingStatus_SECURITY_TRADING_STATUS_NORMAL_TRADING
+	}
+}
+
+// AddShare - Добавление акции. Пустые uid и position_uid генерируются, лотность по умолчанию 1, шаг цены 0.01
+func (s *Server) AddShare(share *pb.Share) {
+	fillDefaults(&share.Uid, &share.PositionUid, &share.Lot, &share.MinPriceIncrement, &share.TradingStatus)
+	s.addInstrument(&instrument{
+		base:  baseInstrument(share, share.GetIsin(), pb.InstrumentType_INSTRUMENT_TYPE_SHARE, "share"),
+		share: share,
+	})
+}
+
+// AddBond - Добавление облигации, значения по умолчанию как в AddShare
+func (s *Server) AddBond(bond *pb.Bond) {
+	fillDefaults(&bond.Uid, &bond.PositionUid, &bond.Lot, &bond.MinPriceIncrement, &bond.TradingStatus)
+	s.addInstrument(&instrument{
+		base: baseInstrument(bond, bond.GetIsin(), pb.InstrumentType_INSTRUMENT_TYPE_BOND, "bond"),
+		bond: bond,
+	})
+}
+
+// AddEtf - Добавление инвестиционного фонда, значения по умолчанию как в AddShare
+func (s *Server) AddEtf(etf *pb.Etf) {
+	fillDefaults(&etf.Uid, &etf.PositionUid, &etf.Lot, &etf.MinPriceIncrement, &etf.TradingStatus)
+	s.addInstrument(&instrument{
+		base: baseInstrument(etf, etf.GetIsin(), pb.InstrumentType_INSTRUMENT_TYPE_ETF, "etf"),
+		etf:  etf,
+	})
+}
+
+// AddFuture - Добавление фьючерса, значения по умолчанию как в AddShare
+func (s *Server) AddFuture(future *pb.Future) {
+	fillDefaults(&future.Uid, &future.PositionUid, &future.Lot, &future.MinPriceIncrement, &future.TradingStatus)
+	s.addInstrument(&instrument{
+		base:   baseInstrument(future, "", pb.InstrumentType_INSTRUMENT_TYPE_FUTURES, "futures"),
+		future: future,
+	})
+}
+
+// AddCurrency - Добавление валюты, значения по умолчанию как в AddShare
+func (s *Server) AddCurrency(currency *pb.Currency) {
+	fillDefaults(&currency.Uid, &currency.PositionUid, &currency.Lot, &currency.MinPriceIncrement, &currency.TradingStatus)
+	s.addInstrument(&instrument{
+		base:     baseInstrument(currency, currency.GetIsin(), pb.InstrumentType_INSTRUMENT_TYPE_CURRENCY, "currency"),
+		currency: currency,
+	})
+}
+
+// AddOption - Добавление опциона, значения по умолчанию как в AddShare
+func (s *Server) AddOption(option *pb.Option) {
+	fillDefaults(&option.Uid, &option.PositionUid, &option.Lot, &option.MinPriceIncrement, &option.TradingStatus)
+	s.addInstrument(&instrument{
+		base:   baseInstrument(optionInstrument{option}, "", pb.InstrumentType_INSTRUMENT_TYPE_OPTION, "option"),
+		option: option,
+	})
+}
+
+// optionInstrument - У опциона нет figi, остальные общие поля совпадают с другими инструментами
+type optionInstrument struct {
+	*pb.Option
+}
+
+func (optionInstrument) GetFigi() string {
+	return ""
+}
+
+// AddInstrument - Добавление инструмента без данных, специфичных для его типа
+func (s *Server) AddInstrument(inst *pb.Instrument) {
+	fillDefaults(&inst.Uid, &inst.PositionUid, &inst.Lot, &inst.MinPriceIncrement, &inst.TradingStatus)
+	s.addInstrument(&instrument{base: inst})
+}
+
+func (s *Server) addInstrument(inst *instrument) {
+	s.mu.Lock()
+	s.instruments.add(inst)
+	s.mu.Unlock()
+}
+
+// OpenAccount - Открытие брокерского счета, возвращает его идентификатор
+func (s *Server) OpenAccount(name string, accType pb.AccountType) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.openAccount(name, accType, false)
+}
+
+// OpenSandboxAccount - Открытие счета песочницы, возвращает его идентификатор
+func (s *Server) OpenSandboxAccount() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.openAccount("sandbox", pb.AccountType_ACCOUNT_TYPE_TINKOFF, true)
+}
+
+// openAccount - вызывается под s.mu
+func (s *Server) openAccount(name string, accType pb.AccountType, sandbox bool) string {
+	s.nextId()
+	id := fmt.Sprintf("20%08d", s.seq)
+	s.accounts[id] = newAccount(&pb.Account{
+		Id:          id,
+		Type:        accType,
+		Name:        name,
+		Status:      pb.AccountStatus_ACCOUNT_STATUS_OPEN,
+		OpenedDate:  timestamppb.New(s.now()),
+		AccessLevel: pb.AccessLevel_ACCOUNT_ACCESS_LEVEL_FULL_ACCESS,
+	}, sandbox)
+	s.accountsIds = append(s.accountsIds, id)
+	return id
+}
+
+// SetAccountAccessLevel - Изменение уровня доступа к счету, со счета с доступом "только чтение" нельзя выставлять заявки
+func (s *Server) SetAccountAccessLevel(accountId string, level pb.AccessLevel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, err := s.account(accountId)
+	if err != nil {
+		return err
+	}
+	acc.acc.AccessLevel = level
+	return nil
+}
+
+// CloseAccount - Закрытие счета
+func (s *Server) CloseAccount(accountId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeAccount(accountId)
+}
+
+// closeAccount - вызывается под s.mu
+func (s *Server) closeAccount(accountId string) error {
+	acc, err := s.account(accountId)
+	if err != nil {
+		return err
+	}
+	acc.acc.Status = pb.AccountStatus_ACCOUNT_STATUS_CLOSED
+	acc.acc.ClosedDate = timestamppb.New(s.now())
+	return nil
+}
+
+// account - Поиск открытого счета, вызывается под s.mu
+func (s *Server) account(accountId string) (*account, error) {
+	acc, ok := s.accounts[accountId]
+	if !ok || acc.acc.GetStatus() == pb.AccountStatus_ACCOUNT_STATUS_CLOSED {
+		return nil, Error(codes.NotFound, codeAccountNotFound, "Account not found")
+	}
+	return acc, nil
+}
+
+// PayIn - Пополнение счета
+func (s *Server) PayIn(accountId string, amount *pb.MoneyValue) error {
+	_, err := s.payIn(accountId, amount)
+	return err
+}
+
+func (s *Server) payIn(accountId string, amount *pb.MoneyValue) (*pb.MoneyValue, error) {
+	var balance *pb.MoneyValue
+	err := s.update(func(ev *events) error {
+		acc, err := s.account(accountId)
+		if err != nil {
+			return err
+		}
+		currency := strings.ToLower(amount.GetCurrency())
+		if currency == "" {
+			currency = DefaultCurrency
+		}
+		value := moneyToDecimal(amount)
+		acc.money[currency] = acc.money[currency].Add(value)
+		acc.operations = append(acc.operations, &pb.Operation{
+			Id:            s.nextId(),
+			Currency:      currency,
+			Payment:       toMoney(value, currency),
+			State:         pb.OperationState_OPERATION_STATE_EXECUTED,
+			Type:          "Пополнение брокерского счёта",
+			OperationType: pb.OperationType_OPERATION_TYPE_INPUT,
+			Date:          timestamppb.New(s.now()),
+		})
+		balance = toMoney(acc.money[currency], currency)
+		ev.changed(accountId)
+		return nil
+	})
+	return balance, err
+}
+
+// SetPosition - Установка позиции по инструменту, quantity в штуках, averagePrice - средняя цена позиции
+func (s *Server) SetPosition(accountId, instrumentId string, quantity int64, averagePrice *pb.Quotation) error {
+	return s.update(func(ev *events) error {
+		acc, err := s.account(accountId)
+		if err != nil {
+			return err
+		}
+		inst := s.instruments.find(instrumentId)
+		if inst == nil {
+			return Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+		}
+		acc.positions[inst.uid()] = &position{
+			inst:     inst,
+			balance:  quantity,
+			avgPrice: toDecimal(averagePrice),
+		}
+		ev.changed(accountId)
+		return nil
+	})
+}
+
+// Balance - Текущий остаток денег на счете в валюте currency
+func (s *Server) Balance(accountId, currency string) *pb.MoneyValue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, ok := s.accounts[accountId]
+	if !ok {
+		return toMoney(decimal.Zero, currency)
+	}
+	return toMoney(acc.money[strings.ToLower(currency)], currency)
+}
+
+// PositionBalance - Текущая позиция по инструменту в штуках
+func (s *Server) PositionBalance(accountId, instrumentId string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, ok := s.accounts[accountId]
+	inst := s.instruments.find(instrumentId)
+	if !ok || inst == nil {
+		return 0
+	}
+	if p, ok := acc.positions[inst.uid()]; ok {
+		return p.balance
+	}
+	return 0
+}
+
+// SetTariff - Установка тарифа пользователя, по нему считаются лимиты запросов
+func (s *Server) SetTariff(tariff *pb.GetUserTariffResponse) {
+	s.mu.Lock()
+	s.tariff = tariff
+	s.limits = make(map[string]*limitWindow)
+	s.mu.Unlock()
+}
+
+// SetInfo - Установка информации о пользователе
+func (s *Server) SetInfo(info *pb.GetInfoResponse) {
+	s.mu.Lock()
+	s.info = info
+	s.mu.Unlock()
+}
+
+// SetMarginAttributes - Установка маржинальных показателей по счету
+func (s *Server) SetMarginAttributes(accountId string, attrs *pb.GetMarginAttributesResponse) {
+	s.mu.Lock()
+	s.margin[accountId] = attrs
+	s.mu.Unlock()
+}
+
+// SetTradingSchedules - Установка расписания торгов, по умолчанию все будние дни торговые
+func (s *Server) SetTradingSchedules(schedules ...*pb.TradingSchedule) {
+	s.mu.Lock()
+	s.schedules = schedules
+	s.mu.Unlock()
+}
@@ -0,0 +1,186 @@
+package fake
+
+import (
+	"context"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// stopOrder - Стоп-заявка, при срабатывании превращается в биржевую заявку
+type stopOrder struct {
+	inst      *instrument
+	stopPrice decimal.Decimal
+	price     *pb.Quotation
+	state     *pb.StopOrder
+}
+
+type stopOrdersService struct {
+	pb.UnimplementedStopOrdersServiceServer
+	s *Server
+}
+
+func (ss *stopOrdersService) PostStopOrder(_ context.Context, req *pb.PostStopOrderRequest) (*pb.PostStopOrderResponse, error) {
+	var resp *pb.PostStopOrderResponse
+	err := ss.s.update(func(ev *events) error {
+		s := ss.s
+		acc, err := s.tradingAccount(req.GetAccountId(), false)
+		if err != nil {
+			return err
+		}
+		id := req.GetInstrumentId()
+		if id == "" {
+			id = req.GetFigi()
+		}
+		inst := s.instruments.find(id)
+		if inst == nil {
+			return Error(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+		}
+		if req.GetQuantity() <= 0 {
+			return Error(codes.InvalidArgument, codeInvalidArgument, "Quantity must be positive")
+		}
+		if req.GetDirection() == pb.StopOrderDirection_STOP_ORDER_DIRECTION_UNSPECIFIED ||
+			req.GetStopOrderType() == pb.StopOrderType_STOP_ORDER_TYPE_UNSPECIFIED {
+			return Error(codes.InvalidArgument, codeInvalidArgument, "Stop order direction and type are required")
+		}
+		stopPrice := toDecimal(req.GetStopPrice())
+		if !stopPrice.IsPositive() {
+			return Error(codes.InvalidArgument, codeInvalidArgument, "Stop price must be positive")
+		}
+		if req.GetStopOrderType() == pb.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT && !toDecimal(req.GetPrice()).IsPositive() {
+			return Error(codes.InvalidArgument, codeInvalidArgument, "Price is required for stop limit order")
+		}
+		if req.GetExpirationType() == pb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_DATE &&
+			req.GetExpireDate() == nil {
+			return Error(codes.InvalidArgument, codeInvalidArgument, "Expire date is required")
+		}
+
+		// дата снятия учитывается только для GOOD_TILL_DATE, SDK передает ее и для бессрочных стоп-заявок
+		var expireDate *timestamppb.Timestamp
+		if req.GetExpirationType() == pb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_DATE {
+			expireDate = req.GetExpireDate()
+		}
+		currency := strings.ToLower(inst.base.GetCurrency())
+		so := &stopOrder{
+			inst:      inst,
+			stopPrice: stopPrice,
+			price:     req.GetPrice(),
+			state: &pb.StopOrder{
+				StopOrderId:    s.nextId(),
+				LotsRequested:  req.GetQuantity(),
+				Figi:           inst.base.GetFigi(),
+				Direction:      req.GetDirection(),
+				Currency:       currency,
+				OrderType:      req.GetStopOrderType(),
+				CreateDate:     timestamppb.New(s.now()),
+				ExpirationTime: expireDate,
+				Price:          toMoney(toDecimal(req.GetPrice()), currency),
+				StopPrice:      toMoney(stopPrice, currency),
+				InstrumentUid:  inst.uid(),
+			},
+		}
+		acc.stopOrders[so.state.GetStopOrderId()] = so
+		acc.stopIds = append(acc.stopIds, so.state.GetStopOrderId())
+		resp = &pb.PostStopOrderResponse{StopOrderId: so.state.GetStopOrderId()}
+		s.matchStopOrders(inst, ev)
+		return nil
+	})
+	return resp, err
+}
+
+func (ss *stopOrdersService) GetStopOrders(_ context.Context, req *pb.GetStopOrdersRequest) (*pb.GetStopOrdersResponse, error) {
+	s := ss.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, err := s.account(req.GetAccountId())
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.GetStopOrdersResponse{}
+	for _, id := range acc.stopIds {
+		resp.StopOrders = append(resp.StopOrders, acc.stopOrders[id].state)
+	}
+	return resp, nil
+}
+
+func (ss *stopOrdersService) CancelStopOrder(_ context.Context, req *pb.CancelStopOrderRequest) (*pb.CancelStopOrderResponse, error) {
+	s := ss.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, err := s.tradingAccount(req.GetAccountId(), false)
+	if err != nil {
+		return nil, err
+	}
+	if !acc.removeStopOrder(req.GetStopOrderId()) {
+		return nil, Error(codes.NotFound, codeStopOrderNotFound, "Stop order not found")
+	}
+	return &pb.CancelStopOrderResponse{Time: timestamppb.New(s.now())}, nil
+}
+
+func (acc *account) removeStopOrder(id string) bool {
+	if _, ok := acc.stopOrders[id]; !ok {
+		return false
+	}
+	delete(acc.stopOrders, id)
+	for i, sid := range acc.stopIds {
+		if sid == id {
+			acc.stopIds = append(acc.stopIds[:i], acc.stopIds[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// triggered - Сработала ли стоп-заявка при цене последней сделки price
+func (so *stopOrder) triggered(price decimal.Decimal) bool {
+	buy := so.state.GetDirection() == pb.StopOrderDirection_STOP_ORDER_DIRECTION_BUY
+	switch so.state.GetOrderType() {
+	case pb.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT:
+		if buy {
+			return price.LessThanOrEqual(so.stopPrice)
+		}
+		return price.GreaterThanOrEqual(so.stopPrice)
+	default:
+		if buy {
+			return price.GreaterThanOrEqual(so.stopPrice)
+		}
+		return price.LessThanOrEqual(so.stopPrice)
+	}
+}
+
+// matchStopOrders - Снятие истекших и активация сработавших стоп-заявок по инструменту, вызывается под s.mu.
+// Стоп-заявки, для которых не удалось выставить биржевую заявку, снимаются
+func (s *Server) matchStopOrders(inst *instrument, ev *events) {
+	lp, ok := s.lastPrices[inst.uid()]
+	now := s.now()
+	for _, id := range s.accountsIds {
+		acc := s.accounts[id]
+		for _, sid := range append([]string(nil), acc.stopIds...) {
+			so := acc.stopOrders[sid]
+			if so.inst != inst {
+				continue
+			}
+			if exp := so.state.GetExpirationTime(); exp != nil && now.After(exp.AsTime()) {
+				acc.removeStopOrder(sid)
+				continue
+			}
+			if !ok || !so.triggered(toDecimal(lp.GetPrice())) {
+				continue
+			}
+			acc.removeStopOrder(sid)
+			direction := pb.OrderDirection_ORDER_DIRECTION_BUY
+			if so.state.GetDirection() == pb.StopOrderDirection_STOP_ORDER_DIRECTION_SELL {
+				direction = pb.OrderDirection_ORDER_DIRECTION_SELL
+			}
+			orderType := pb.OrderType_ORDER_TYPE_MARKET
+			if so.state.GetOrderType() == pb.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT {
+				orderType = pb.OrderType_ORDER_TYPE_LIMIT
+			}
+			_, _ = s.placeOrder(acc, inst.uid(), "", direction, orderType, so.state.GetLotsRequested(), so.price, ev)
+			ev.changed(id)
+		}
+	}
+}
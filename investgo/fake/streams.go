@@ -0,0 +1,580 @@
+package fake
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+)
+
+// subscriberBuffer - Размер буфера сообщений каждого подписчика
+const subscriberBuffer = 1024
+
+// subscriber - Получатель сообщений одного стрима
+type subscriber struct {
+	accounts map[string]struct{}
+	out      chan any
+	done     chan struct{}
+	md       *mdSubscriptions
+}
+
+func newSubscriber(accounts []string) *subscriber {
+	sub := &subscriber{
+		out:  make(chan any, subscriberBuffer),
+		done: make(chan struct{}),
+	}
+	if len(accounts) > 0 {
+		sub.accounts = make(map[string]struct{}, len(accounts))
+		for _, id := range accounts {
+			sub.accounts[id] = struct{}{}
+		}
+	}
+	return sub
+}
+
+// wantsAccount - Подписан ли стрим на счет, стрим без счетов получает сообщения по всем счетам
+func (sub *subscriber) wantsAccount(accountId string) bool {
+	if sub.accounts == nil {
+		return true
+	}
+	_, ok := sub.accounts[accountId]
+	return ok
+}
+
+func (sub *subscriber) send(msg any) {
+	select {
+	case sub.out <- msg:
+	case <-sub.done:
+	}
+}
+
+// serve - Отправка сообщений подписчика в стрим до его закрытия клиентом или разрыва через BreakStreams
+func (sub *subscriber) serve(ctx context.Context, broken <-chan struct{}, send func(msg any) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-broken:
+			return Error(codes.Unavailable, codeInternal, "Stream is broken")
+		case msg := <-sub.out:
+			if err := send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// streamsHub - Все открытые стримы сервера
+type streamsHub struct {
+	mu         sync.Mutex
+	broken     chan struct{}
+	md         map[*subscriber]struct{}
+	trades     map[*subscriber]struct{}
+	portfolios map[*subscriber]struct{}
+	positions  map[*subscriber]struct{}
+}
+
+func newStreamsHub() *streamsHub {
+	return &streamsHub{
+		broken:     make(chan struct{}),
+		md:         make(map[*subscriber]struct{}),
+		trades:     make(map[*subscriber]struct{}),
+		portfolios: make(map[*subscriber]struct{}),
+		positions:  make(map[*subscriber]struct{}),
+	}
+}
+
+// add - Регистрация подписчика, возвращает канал, который закроется при вызове BreakStreams
+func (h *streamsHub) add(set map[*subscriber]struct{}, sub *subscriber) <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	set[sub] = struct{}{}
+	return h.broken
+}
+
+func (h *streamsHub) remove(set map[*subscriber]struct{}, sub *subscriber) {
+	close(sub.done)
+	h.mu.Lock()
+	delete(set, sub)
+	h.mu.Unlock()
+}
+
+func (h *streamsHub) list(set map[*subscriber]struct{}) []*subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subs := make([]*subscriber, 0, len(set))
+	for sub := range set {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+func (h *streamsHub) publishMarketData(resp *pb.MarketDataResponse) {
+	for _, sub := range h.list(h.md) {
+		if msg := sub.md.filter(resp); msg != nil {
+			sub.send(msg)
+		}
+	}
+}
+
+func (h *streamsHub) publishTrades(trades *pb.OrderTrades) {
+	for _, sub := range h.list(h.trades) {
+		if sub.wantsAccount(trades.GetAccountId()) {
+			sub.send(trades)
+		}
+	}
+}
+
+func (h *streamsHub) publishPortfolio(portfolio *pb.PortfolioResponse) {
+	for _, sub := range h.list(h.portfolios) {
+		if sub.wantsAccount(portfolio.GetAccountId()) {
+			sub.send(portfolio)
+		}
+	}
+}
+
+func (h *streamsHub) publishPositions(positions *pb.PositionData) {
+	for _, sub := range h.list(h.positions) {
+		if sub.wantsAccount(positions.GetAccountId()) {
+			sub.send(positions)
+		}
+	}
+}
+
+// BreakStreams - Разрыв всех открытых стримов с кодом Unavailable, для проверки переподключений клиента
+func (s *Server) BreakStreams() {
+	h := s.streams
+	h.mu.Lock()
+	close(h.broken)
+	h.broken = make(chan struct{})
+	h.mu.Unlock()
+}
+
+type candleKey struct {
+	uid      string
+	interval pb.SubscriptionInterval
+}
+
+// mdSubscriptions - Подписки одного стрима рыночных данных
+type mdSubscriptions struct {
+	mu         sync.Mutex
+	candles    map[candleKey]*pb.CandleSubscription
+	orderBooks map[string]*pb.OrderBookSubscription
+	trades     map[string]*pb.TradeSubscription
+	info       map[string]*pb.InfoSubscription
+	lastPrices map[string]*pb.LastPriceSubscription
+}
+
+func newMdSubscriptions() *mdSubscriptions {
+	return &mdSubscriptions{
+		candles:    make(map[candleKey]*pb.CandleSubscription),
+		orderBooks: make(map[string]*pb.OrderBookSubscription),
+		trades:     make(map[string]*pb.TradeSubscription),
+		info:       make(map[string]*pb.InfoSubscription),
+		lastPrices: make(map[string]*pb.LastPriceSubscription),
+	}
+}
+
+// filter - Сообщение для подписчика или nil, если он на него не подписан
+func (m *mdSubscriptions) filter(resp *pb.MarketDataResponse) *pb.MarketDataResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch p := resp.GetPayload().(type) {
+	case *pb.MarketDataResponse_Candle:
+		if _, ok := m.candles[candleKey{uid: p.Candle.GetInstrumentUid(), interval: p.Candle.GetInterval()}]; ok {
+			return resp
+		}
+	case *pb.MarketDataResponse_Orderbook:
+		if sub, ok := m.orderBooks[p.Orderbook.GetInstrumentUid()]; ok {
+			return &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_Orderbook{
+				Orderbook: withDepth(p.Orderbook, sub.GetDepth()),
+			}}
+		}
+	case *pb.MarketDataResponse_Trade:
+		if _, ok := m.trades[p.Trade.GetInstrumentUid()]; ok {
+			return resp
+		}
+	case *pb.MarketDataResponse_TradingStatus:
+		if _, ok := m.info[p.TradingStatus.GetInstrumentUid()]; ok {
+			return resp
+		}
+	case *pb.MarketDataResponse_LastPrice:
+		if _, ok := m.lastPrices[p.LastPrice.GetInstrumentUid()]; ok {
+			return resp
+		}
+	}
+	return nil
+}
+
+func subscriptionStatus(action pb.SubscriptionAction, inst *instrument) pb.SubscriptionStatus {
+	switch {
+	case action != pb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE &&
+		action != pb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE:
+		return pb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUBSCRIPTION_ACTION_IS_INVALID
+	case inst == nil:
+		return pb.SubscriptionStatus_SUBSCRIPTION_STATUS_INSTRUMENT_NOT_FOUND
+	}
+	return pb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS
+}
+
+func instrumentId(id, figi string) string {
+	if id == "" {
+		return figi
+	}
+	return id
+}
+
+// handleMarketDataRequest - Обработка запроса на подписку, возвращает ответ со статусами подписок
+func (s *Server) handleMarketDataRequest(m *mdSubscriptions, req *pb.MarketDataRequest) *pb.MarketDataResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trackingId := uuid.NewString()
+	subscribe := func(action pb.SubscriptionAction) bool {
+		return action == pb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE
+	}
+
+	switch p := req.GetPayload().(type) {
+	case *pb.MarketDataRequest_SubscribeCandlesRequest:
+		action := p.SubscribeCandlesRequest.GetSubscriptionAction()
+		resp := &pb.SubscribeCandlesResponse{TrackingId: trackingId}
+		for _, ci := range p.SubscribeCandlesRequest.GetInstruments() {
+			id := instrumentId(ci.GetInstrumentId(), ci.GetFigi())
+			inst := s.instruments.find(id)
+			status := subscriptionStatus(action, inst)
+			if status == pb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS &&
+				ci.GetInterval() != pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE &&
+				ci.GetInterval() != pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_FIVE_MINUTES {
+				status = pb.SubscriptionStatus_SUBSCRIPTION_STATUS_INTERVAL_IS_INVALID
+			}
+			sub := &pb.CandleSubscription{Figi: ci.GetFigi(), Interval: ci.GetInterval(), SubscriptionStatus: status}
+			if inst != nil {
+				sub.Figi = inst.base.GetFigi()
+				sub.InstrumentUid = inst.uid()
+			}
+			if status == pb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
+				key := candleKey{uid: inst.uid(), interval: ci.GetInterval()}
+				if subscribe(action) {
+					m.candles[key] = sub
+				} else {
+					delete(m.candles, key)
+				}
+			}
+			resp.CandlesSubscriptions = append(resp.CandlesSubscriptions, sub)
+		}
+		return &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_SubscribeCandlesResponse{SubscribeCandlesResponse: resp}}
+
+	case *pb.MarketDataRequest_SubscribeOrderBookRequest:
+		action := p.SubscribeOrderBookRequest.GetSubscriptionAction()
+		resp := &pb.SubscribeOrderBookResponse{TrackingId: trackingId}
+		for _, oi := range p.SubscribeOrderBookRequest.GetInstruments() {
+			inst := s.instruments.find(instrumentId(oi.GetInstrumentId(), oi.GetFigi()))
+			status := subscriptionStatus(action, inst)
+			if status == pb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS && subscribe(action) && !validDepth(oi.GetDepth()) {
+				status = pb.SubscriptionStatus_SUBSCRIPTION_STATUS_DEPTH_IS_INVALID
+			}
+			sub := &pb.OrderBookSubscription{Figi: oi.GetFigi(), Depth: oi.GetDepth(), SubscriptionStatus: status}
+			if inst != nil {
+				sub.Figi = inst.base.GetFigi()
+				sub.InstrumentUid = inst.uid()
+			}
+			if status == pb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
+				if subscribe(action) {
+					m.orderBooks[inst.uid()] = sub
+				} else {
+					delete(m.orderBooks, inst.uid())
+				}
+			}
+			resp.OrderBookSubscriptions = append(resp.OrderBookSubscriptions, sub)
+		}
+		return &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_SubscribeOrderBookResponse{SubscribeOrderBookResponse: resp}}
+
+	case *pb.MarketDataRequest_SubscribeTradesRequest:
+		action := p.SubscribeTradesRequest.GetSubscriptionAction()
+		resp := &pb.SubscribeTradesResponse{TrackingId: trackingId}
+		for _, ti := range p.SubscribeTradesRequest.GetInstruments() {
+			inst := s.instruments.find(instrumentId(ti.GetInstrumentId(), ti.GetFigi()))
+			sub := &pb.TradeSubscription{Figi: ti.GetFigi(), SubscriptionStatus: subscriptionStatus(action, inst)}
+			if inst != nil {
+				sub.Figi = inst.base.GetFigi()
+				sub.InstrumentUid = inst.uid()
+				if sub.GetSubscriptionStatus() == pb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
+					if subscribe(action) {
+						m.trades[inst.uid()] = sub
+					} else {
+						delete(m.trades, inst.uid())
+					}
+				}
+			}
+			resp.TradeSubscriptions = append(resp.TradeSubscriptions, sub)
+		}
+		return &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_SubscribeTradesResponse{SubscribeTradesResponse: resp}}
+
+	case *pb.MarketDataRequest_SubscribeInfoRequest:
+		action := p.SubscribeInfoRequest.GetSubscriptionAction()
+		resp := &pb.SubscribeInfoResponse{TrackingId: trackingId}
+		for _, ii := range p.SubscribeInfoRequest.GetInstruments() {
+			inst := s.instruments.find(instrumentId(ii.GetInstrumentId(), ii.GetFigi()))
+			sub := &pb.InfoSubscription{Figi: ii.GetFigi(), SubscriptionStatus: subscriptionStatus(action, inst)}
+			if inst != nil {
+				sub.Figi = inst.base.GetFigi()
+				sub.InstrumentUid = inst.uid()
+				if sub.GetSubscriptionStatus() == pb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
+					if subscribe(action) {
+						m.info[inst.uid()] = sub
+					} else {
+						delete(m.info, inst.uid())
+					}
+				}
+			}
+			resp.InfoSubscriptions = append(resp.InfoSubscriptions, sub)
+		}
+		return &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_SubscribeInfoResponse{SubscribeInfoResponse: resp}}
+
+	case *pb.MarketDataRequest_SubscribeLastPriceRequest:
+		action := p.SubscribeLastPriceRequest.GetSubscriptionAction()
+		resp := &pb.SubscribeLastPriceResponse{TrackingId: trackingId}
+		for _, li := range p.SubscribeLastPriceRequest.GetInstruments() {
+			inst := s.instruments.find(instrumentId(li.GetInstrumentId(), li.GetFigi()))
+			sub := &pb.LastPriceSubscription{Figi: li.GetFigi(), SubscriptionStatus: subscriptionStatus(action, inst)}
+			if inst != nil {
+				sub.Figi = inst.base.GetFigi()
+				sub.InstrumentUid = inst.uid()
+				if sub.GetSubscriptionStatus() == pb.SubscriptionStatus_SUBSCRIPTION_STATUS_SUCCESS {
+					if subscribe(action) {
+						m.lastPrices[inst.uid()] = sub
+					} else {
+						delete(m.lastPrices, inst.uid())
+					}
+				}
+			}
+			resp.LastPriceSubscriptions = append(resp.LastPriceSubscriptions, sub)
+		}
+		return &pb.MarketDataResponse{Payload: &pb.MarketDataResponse_SubscribeLastPriceResponse{SubscribeLastPriceResponse: resp}}
+	}
+	return nil
+}
+
+// mySubscriptions - Ответы со всеми текущими подписками стрима, для GetMySubscriptions
+func (m *mdSubscriptions) mySubscriptions() []*pb.MarketDataResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trackingId := uuid.NewString()
+	candles := &pb.SubscribeCandlesResponse{TrackingId: trackingId}
+	for _, sub := range m.candles {
+		candles.CandlesSubscriptions = append(candles.CandlesSubscriptions, sub)
+	}
+	books := &pb.SubscribeOrderBookResponse{TrackingId: trackingId}
+	for _, sub := range m.orderBooks {
+		books.OrderBookSubscriptions = append(books.OrderBookSubscriptions, sub)
+	}
+	trades := &pb.SubscribeTradesResponse{TrackingId: trackingId}
+	for _, sub := range m.trades {
+		trades.TradeSubscriptions = append(trades.TradeSubscriptions, sub)
+	}
+	info := &pb.SubscribeInfoResponse{TrackingId: trackingId}
+	for _, sub := range m.info {
+		info.InfoSubscriptions = append(info.InfoSubscriptions, sub)
+	}
+	lastPrices := &pb.SubscribeLastPriceResponse{TrackingId: trackingId}
+	for _, sub := range m.lastPrices {
+		lastPrices.LastPriceSubscriptions = append(lastPrices.LastPriceSubscriptions, sub)
+	}
+	return []*pb.MarketDataResponse{
+		{Payload: &pb.MarketDataResponse_SubscribeCandlesResponse{SubscribeCandlesResponse: candles}},
+		{Payload: &pb.MarketDataResponse_SubscribeOrderBookResponse{SubscribeOrderBookResponse: books}},
+		{Payload: &pb.MarketDataResponse_SubscribeTradesResponse{SubscribeTradesResponse: trades}},
+		{Payload: &pb.MarketDataResponse_SubscribeInfoResponse{SubscribeInfoResponse: info}},
+		{Payload: &pb.MarketDataResponse_SubscribeLastPriceResponse{SubscribeLastPriceResponse: lastPrices}},
+	}
+}
+
+type marketDataStreamService struct {
+	pb.UnimplementedMarketDataStreamServiceServer
+	s *Server
+}
+
+func (ms *marketDataStreamService) MarketDataStream(stream pb.MarketDataStreamService_MarketDataStreamServer) error {
+	s := ms.s
+	sub := newSubscriber(nil)
+	sub.md = newMdSubscriptions()
+	broken := s.streams.add(s.streams.md, sub)
+	defer s.streams.remove(s.streams.md, sub)
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				recvErr <- err
+				cancel()
+				return
+			}
+			if _, ok := req.GetPayload().(*pb.MarketDataRequest_GetMySubscriptions); ok {
+				for _, resp := range sub.md.mySubscriptions() {
+					sub.send(resp)
+				}
+				continue
+			}
+			if resp := s.handleMarketDataRequest(sub.md, req); resp != nil {
+				sub.send(resp)
+			}
+		}
+	}()
+
+	err := sub.serve(ctx, broken, func(msg any) error {
+		return stream.Send(msg.(*pb.MarketDataResponse))
+	})
+	select {
+	case rerr := <-recvErr:
+		if err == nil && stream.Context().Err() == nil {
+			return rerr
+		}
+	default:
+	}
+	return err
+}
+
+func (ms *marketDataStreamService) MarketDataServerSideStream(req *pb.MarketDataServerSideStreamRequest, stream pb.MarketDataStreamService_MarketDataServerSideStreamServer) error {
+	s := ms.s
+	sub := newSubscriber(nil)
+	sub.md = newMdSubscriptions()
+	broken := s.streams.add(s.streams.md, sub)
+	defer s.streams.remove(s.streams.md, sub)
+
+	requests := []*pb.MarketDataRequest{}
+	if r := req.GetSubscribeCandlesRequest(); r != nil {
+		requests = append(requests, &pb.MarketDataRequest{Payload: &pb.MarketDataRequest_SubscribeCandlesRequest{SubscribeCandlesRequest: r}})
+	}
+	if r := req.GetSubscribeOrderBookRequest(); r != nil {
+		requests = append(requests, &pb.MarketDataRequest{Payload: &pb.MarketDataRequest_SubscribeOrderBookRequest{SubscribeOrderBookRequest: r}})
+	}
+	if r := req.GetSubscribeTradesRequest(); r != nil {
+		requests = append(requests, &pb.MarketDataRequest{Payload: &pb.MarketDataRequest_SubscribeTradesRequest{SubscribeTradesRequest: r}})
+	}
+	if r := req.GetSubscribeInfoRequest(); r != nil {
+		requests = append(requests, &pb.MarketDataRequest{Payload: &pb.MarketDataRequest_SubscribeInfoRequest{SubscribeInfoRequest: r}})
+	}
+	if r := req.GetSubscribeLastPriceRequest(); r != nil {
+		requests = append(requests, &pb.MarketDataRequest{Payload: &pb.MarketDataRequest_SubscribeLastPriceRequest{SubscribeLastPriceRequest: r}})
+	}
+	for _, r := range requests {
+		if err := stream.Send(s.handleMarketDataRequest(sub.md, r)); err != nil {
+			return err
+		}
+	}
+
+	return sub.serve(stream.Context(), broken, func(msg any) error {
+		return stream.Send(msg.(*pb.MarketDataResponse))
+	})
+}
+
+type ordersStreamService struct {
+	pb.UnimplementedOrdersStreamServiceServer
+	s *Server
+}
+
+func (os *ordersStreamService) TradesStream(req *pb.TradesStreamRequest, stream pb.OrdersStreamService_TradesStreamServer) error {
+	s := os.s
+	s.mu.Lock()
+	for _, id := range req.GetAccounts() {
+		if _, err := s.account(id); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.mu.Unlock()
+
+	sub := newSubscriber(req.GetAccounts())
+	broken := s.streams.add(s.streams.trades, sub)
+	defer s.streams.remove(s.streams.trades, sub)
+	return sub.serve(stream.Context(), broken, func(msg any) error {
+		return stream.Send(&pb.TradesStreamResponse{
+			Payload: &pb.TradesStreamResponse_OrderTrades{OrderTrades: msg.(*pb.OrderTrades)},
+		})
+	})
+}
+
+type operationsStreamService struct {
+	pb.UnimplementedOperationsStreamServiceServer
+	s *Server
+}
+
+func (os *operationsStreamService) PortfolioStream(req *pb.PortfolioStreamRequest, stream pb.OperationsStreamService_PortfolioStreamServer) error {
+	s := os.s
+	result := &pb.PortfolioSubscriptionResult{}
+	var accounts []string
+	s.mu.Lock()
+	for _, id := range req.GetAccounts() {
+		status := pb.PortfolioSubscriptionStatus_PORTFOLIO_SUBSCRIPTION_STATUS_SUCCESS
+		if _, err := s.account(id); err != nil {
+			status = pb.PortfolioSubscriptionStatus_PORTFOLIO_SUBSCRIPTION_STATUS_ACCOUNT_NOT_FOUND
+		} else {
+			accounts = append(accounts, id)
+		}
+		result.Accounts = append(result.Accounts, &pb.AccountSubscriptionStatus{AccountId: id, SubscriptionStatus: status})
+	}
+	s.mu.Unlock()
+
+	sub := newSubscriber(accounts)
+	if sub.accounts == nil {
+		sub.accounts = make(map[string]struct{})
+	}
+	broken := s.streams.add(s.streams.portfolios, sub)
+	defer s.streams.remove(s.streams.portfolios, sub)
+	err := stream.Send(&pb.PortfolioStreamResponse{
+		Payload: &pb.PortfolioStreamResponse_Subscriptions{Subscriptions: result},
+	})
+	if err != nil {
+		return err
+	}
+	return sub.serve(stream.Context(), broken, func(msg any) error {
+		return stream.Send(&pb.PortfolioStreamResponse{
+			Payload: &pb.PortfolioStreamResponse_Portfolio{Portfolio: msg.(*pb.PortfolioResponse)},
+		})
+	})
+}
+
+func (os *operationsStreamService) PositionsStream(req *pb.PositionsStreamRequest, stream pb.OperationsStreamService_PositionsStreamServer) error {
+	s := os.s
+	result := &pb.PositionsSubscriptionResult{}
+	var accounts []string
+	s.mu.Lock()
+	for _, id := range req.GetAccounts() {
+		status := pb.PositionsAccountSubscriptionStatus_POSITIONS_SUBSCRIPTION_STATUS_SUCCESS
+		if _, err := s.account(id); err != nil {
+			status = pb.PositionsAccountSubscriptionStatus_POSITIONS_SUBSCRIPTION_STATUS_ACCOUNT_NOT_FOUND
+		} else {
+			accounts = append(accounts, id)
+		}
+		result.Accounts = append(result.Accounts, &pb.PositionsSubscriptionStatus{AccountId: id, SubscriptionStatus: status})
+	}
+	s.mu.Unlock()
+
+	sub := newSubscriber(accounts)
+	if sub.accounts == nil {
+		sub.accounts = make(map[string]struct{})
+	}
+	broken := s.streams.add(s.streams.positions, sub)
+	defer s.streams.remove(s.streams.positions, sub)
+	err := stream.Send(&pb.PositionsStreamResponse{
+		Payload: &pb.PositionsStreamResponse_Subscriptions{Subscriptions: result},
+	})
+	if err != nil {
+		return err
+	}
+	return sub.serve(stream.Context(), broken, func(msg any) error {
+		return stream.Send(&pb.PositionsStreamResponse{
+			Payload: &pb.PositionsStreamResponse_Position{Position: msg.(*pb.PositionData)},
+		})
+	})
+}
@@ -0,0 +1,113 @@
+package fake_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+const streamTimeout = 5 * time.Second
+
+func TestMarketDataStream(t *testing.T) {
+	srv, client := newTestServer(t)
+	stream, err := client.NewMarketDataStreamClient().MarketDataStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Stop()
+	lastPrices, err := stream.SubscribeLastPrice([]string{testFigi})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderBooks, err := stream.SubscribeOrderBook([]string{testFigi}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = stream.Listen()
+	}()
+
+	// подписка применяется на сервере асинхронно, поэтому цена публикуется, пока не придет в стрим
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(streamTimeout)
+	for received := false; !received; {
+		select {
+		case <-ticker.C:
+			if err := srv.SetLastPrice(testFigi, fake.Quotation(251)); err != nil {
+				t.Fatal(err)
+			}
+		case lp := <-lastPrices:
+			if lp.GetFigi() != testFigi || lp.GetPrice().ToFloat() != 251 {
+				t.Fatalf("last price = %v, want %s at 251", lp, testFigi)
+			}
+			received = true
+		case <-deadline:
+			t.Fatal("last price was not received")
+		}
+	}
+
+	err = srv.SetOrderBook(testFigi,
+		[]*pb.Order{{Price: fake.Quotation(250), Quantity: 5}},
+		[]*pb.Order{{Price: fake.Quotation(252), Quantity: 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ob := <-orderBooks:
+		if len(ob.GetBids()) != 1 || len(ob.GetAsks()) != 1 || ob.GetAsks()[0].GetQuantity() != 7 {
+			t.Fatalf("order book = %v, want one bid and one ask of 7", ob)
+		}
+	case <-time.After(streamTimeout):
+		t.Fatal("order book was not received")
+	}
+}
+
+func TestTradesStream(t *testing.T) {
+	srv, client := newTestServer(t)
+	stream, err := client.NewOrdersStreamClient().TradesStream([]string{srv.AccountId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Stop()
+	go func() {
+		_ = stream.Listen()
+	}()
+
+	// стрим подключается асинхронно, поэтому заявки выставляются, пока исполнение не придет в стрим
+	orders := client.NewOrdersServiceClient()
+	posted := make(map[string]struct{})
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(streamTimeout)
+	for {
+		select {
+		case <-ticker.C:
+			resp, err := orders.Buy(&investgo.PostOrderRequestShort{
+				InstrumentId: testFigi,
+				Quantity:     1,
+				AccountId:    srv.AccountId(),
+				OrderType:    pb.OrderType_ORDER_TYPE_MARKET,
+				OrderId:      investgo.CreateUid(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			posted[resp.GetOrderId()] = struct{}{}
+		case trades := <-stream.Trades():
+			if _, ok := posted[trades.GetOrderId()]; !ok {
+				t.Fatalf("trades for unknown order %s", trades.GetOrderId())
+			}
+			if trades.GetDirection() != pb.OrderDirection_ORDER_DIRECTION_BUY || len(trades.GetTrades()) != 1 ||
+				trades.GetTrades()[0].GetQuantity() != 10 {
+				t.Fatalf("trades = %v, want buy of 10 shares", trades)
+			}
+			return
+		case <-deadline:
+			t.Fatal("trade was not received")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package fake
+
+import (
+	"context"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+type usersService struct {
+	pb.UnimplementedUsersServiceServer
+	s *Server
+}
+
+func (us *usersService) GetAccounts(_ context.Context, _ *pb.GetAccountsRequest) (*pb.GetAccountsResponse, error) {
+	return us.s.accountsList(false), nil
+}
+
+func (us *usersService) GetMarginAttributes(_ context.Context, req *pb.GetMarginAttributesRequest) (*pb.GetMarginAttributesResponse, error) {
+	s := us.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.ownAccount(req.GetAccountId(), false); err != nil {
+		return nil, err
+	}
+	if attrs, ok := s.margin[req.GetAccountId()]; ok {
+		return attrs, nil
+	}
+	return &pb.GetMarginAttributesResponse{}, nil
+}
+
+func (us *usersService) GetUserTariff(_ context.Context, _ *pb.GetUserTariffRequest) (*pb.GetUserTariffResponse, error) {
+	s := us.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tariff, nil
+}
+
+func (us *usersService) GetInfo(_ context.Context, _ *pb.GetInfoRequest) (*pb.GetInfoResponse, error) {
+	s := us.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.info, nil
+}
+
+// accountsList - Список всех счетов нужного вида, включая закрытые
+func (s *Server) accountsList(sandbox bool) *pb.GetAccountsResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &pb.GetAccountsResponse{}
+	for _, id := range s.accountsIds {
+		if acc := s.accounts[id]; acc.sandbox == sandbox {
+			resp.Accounts = append(resp.Accounts, acc.acc)
+		}
+	}
+	return resp
+}
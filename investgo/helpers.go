@@ -12,6 +12,13 @@ func CreateUid() string {
 	return uuid.NewString()
 }
 
+// OrderKey - Ключ идемпотентности заявки из ее имени в приложении, например "rebalance-2023-10-02-SBER".
+// Для одного имени ключ всегда один, поэтому после перезапуска приложение выставляет заявку с тем же ключом
+// и сервер не исполнит ее второй раз
+func OrderKey(name string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(name)).String()
+}
+
 // MessageFromHeader - Метод извлечения сообщения из заголовка
 func MessageFromHeader(md metadata.MD) string {
 	msgs := md.Get("message")
@@ -0,0 +1,65 @@
+package investgo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+const (
+	testFigi    = "BBG004730N88"
+	testTimeout = 5 * time.Second
+)
+
+type testLogger struct{}
+
+func (testLogger) Infof(string, ...any)  {}
+func (testLogger) Errorf(string, ...any) {}
+func (testLogger) Fatalf(string, ...any) {}
+
+// newTestServer - Сервер с акцией testFigi (лот 10, шаг цены 0.01) по цене 250 и 100000 рублей на счете
+func newTestServer(t *testing.T) *fake.Server {
+	t.Helper()
+	srv := fake.NewServer()
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+	srv.AddShare(&pb.Share{Figi: testFigi, Ticker: "SBER", ClassCode: "TQBR", Lot: 10, Currency: "rub", ApiTradeAvailableFlag: true})
+	if err := srv.PayIn(srv.AccountId(), fake.Money(100000, "rub")); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.SetLastPrice(testFigi, fake.Quotation(250)); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+// newTestClient - Клиент к серверу с конфигурацией conf
+func newTestClient(t *testing.T, conf investgo.Config, opts ...investgo.Option) *investgo.Client {
+	t.Helper()
+	client, err := investgo.NewClient(context.Background(), conf, testLogger{}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Stop()
+	})
+	return client
+}
+
+// eventually - Повторение fn, пока она не вернет true или не истечет testTimeout
+func eventually(t *testing.T, msg string, fn func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
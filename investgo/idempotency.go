@@ -0,0 +1,334 @@
+package investgo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrIdempotencyConflict - Ключ идемпотентности уже использован для заявки с другими параметрами
+var ErrIdempotencyConflict = errors.New("idempotency key is used by another order")
+
+const (
+	// DefaultIdempotencyTTL - Сколько хранятся ключи идемпотентности в хранилищах по умолчанию
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// ReplayHeader - Заголовок ответа, который добавляется, если ответ на повторную заявку взят из хранилища
+	// ключей идемпотентности, а запрос на сервер не отправлялся
+	ReplayHeader = "x-idempotency-replay"
+)
+
+// idempotentMethods - методы выставления и изменения заявок, для которых клиент хранит ключи идемпотентности
+var idempotentMethods = map[string]struct{}{
+	"/tinkoff.public.invest.api.contract.v1.OrdersService/PostOrder":            {},
+	"/tinkoff.public.invest.api.contract.v1.OrdersService/ReplaceOrder":         {},
+	"/tinkoff.public.invest.api.contract.v1.SandboxService/PostSandboxOrder":    {},
+	"/tinkoff.public.invest.api.contract.v1.SandboxService/ReplaceSandboxOrder": {},
+}
+
+// IdempotencyRecord - Запись о заявке в хранилище ключей идемпотентности
+type IdempotencyRecord struct {
+	// Key - Ключ идемпотентности: order_id для PostOrder, idempotency_key для ReplaceOrder
+	Key string
+	// Method - Полное имя метода grpc
+	Method string
+	// Fingerprint - Хэш параметров заявки без ключа, по нему повторная отправка отличается от другой заявки
+	// с тем же ключом
+	Fingerprint string
+	// Response - Ответ сервера в формате protobuf, пустой, пока ответ не получен
+	Response []byte
+	// CreatedAt - Время первой отправки заявки
+	CreatedAt time.Time
+}
+
+// IdempotencyStore - Хранилище ключей идемпотентности заявок. Запись сохраняется до первой отправки заявки
+// и дополняется ответом сервера, поэтому после перезапуска приложения повторная заявка с тем же ключом
+// не выставляется второй раз. Реализация должна быть безопасна для использования из нескольких горутин
+type IdempotencyStore interface {
+	// Get - Запись по ключу, nil если записи нет
+	Get(ctx context.Context, key string) (*IdempotencyRecord, error)
+	// Put - Сохранение записи
+	Put(ctx context.Context, rec *IdempotencyRecord) error
+}
+
+// MemoryIdempotencyStore - Хранилище ключей идемпотентности в памяти процесса, используется клиентом
+// по умолчанию. Записи старше ttl удаляются
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	records map[string]*IdempotencyRecord
+}
+
+// NewMemoryIdempotencyStore - Хранилище ключей идемпотентности в памяти, записи хранятся DefaultIdempotencyTTL
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		ttl:     DefaultIdempotencyTTL,
+		records: make(map[string]*IdempotencyRecord),
+	}
+}
+
+// Get - Запись по ключу, nil если записи нет
+func (s *MemoryIdempotencyStore) Get(_ context.Context, key string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[key]
+	if !ok || s.expired(rec) {
+		return nil, nil
+	}
+	r := *rec
+	return &r, nil
+}
+
+// Put - Сохранение записи
+func (s *MemoryIdempotencyStore) Put(_ context.Context, rec *IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(rec)
+	return nil
+}
+
+// put - сохранение записи и удаление устаревших, вызывается под s.mu
+func (s *MemoryIdempotencyStore) put(rec *IdempotencyRecord) {
+	for key, r := range s.records {
+		if s.expired(r) {
+			delete(s.records, key)
+		}
+	}
+	r := *rec
+	s.records[rec.Key] = &r
+}
+
+func (s *MemoryIdempotencyStore) expired(rec *IdempotencyRecord) bool {
+	return s.ttl > 0 && time.Since(rec.CreatedAt) > s.ttl
+}
+
+// FileIdempotencyStore - Хранилище ключей идемпотентности в JSON файле. Файл перезаписывается целиком
+// при каждом сохранении, поэтому подходит для приложений, которые выставляют не больше нескольких тысяч
+// заявок в день. Записи старше DefaultIdempotencyTTL удаляются
+type FileIdempotencyStore struct {
+	path   string
+	memory *MemoryIdempotencyStore
+}
+
+// NewFileIdempotencyStore - Хранилище ключей идемпотентности в файле path, записи из файла загружаются сразу
+func NewFileIdempotencyStore(path string) (*FileIdempotencyStore, error) {
+	s := &FileIdempotencyStore{
+		path:   path,
+		memory: NewMemoryIdempotencyStore(),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []*IdempotencyRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("idempotency store %s: %w", path, err)
+	}
+	for _, rec := range records {
+		if !s.memory.expired(rec) {
+			s.memory.records[rec.Key] = rec
+		}
+	}
+	return s, nil
+}
+
+// Get - Запись по ключу, nil если записи нет
+func (s *FileIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	return s.memory.Get(ctx, key)
+}
+
+// Put - Сохранение записи и запись файла
+func (s *FileIdempotencyStore) Put(_ context.Context, rec *IdempotencyRecord) error {
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+	s.memory.put(rec)
+
+	records := make([]*IdempotencyRecord, 0, len(s.memory.records))
+	for _, r := range s.memory.records {
+		records = append(records, r)
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	// запись через временный файл, чтобы при падении процесса не остался наполовину записанный файл
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// ReplayedFromHeader - Был ли ответ на заявку взят из хранилища ключей идемпотентности без запроса на сервер
+func ReplayedFromHeader(md metadata.MD) bool {
+	return len(md.Get(ReplayHeader)) > 0
+}
+
+// keyLocks - блокировки по ключам идемпотентности
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock - блокировка ключа, refs - число владельцев и ожидающих, при нуле блокировка удаляется
+type keyLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+func newKeyLocks() *keyLocks {
+	return &keyLocks{locks: make(map[string]*keyLock)}
+}
+
+// lock - захват блокировки ключа key до отмены ctx, возвращает функцию освобождения
+func (k *keyLocks) lock(ctx context.Context, key string) (func(), error) {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{ch: make(chan struct{}, 1)}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	release := func() {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, key)
+		}
+	}
+	select {
+	case l.ch <- struct{}{}:
+		return func() {
+			<-l.ch
+			release()
+		}, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// idempotencyUnaryInterceptor - хранение ключей идемпотентности заявок. Стоит до ретраеров, поэтому все попытки
+// отправляют один ключ. Если заявка с этим ключом уже получила ответ, например до перезапуска приложения,
+// ответ возвращается из хранилища, если ключ использован для другой заявки - возвращается ErrIdempotencyConflict.
+// Одновременные заявки с одним ключом отправляются по очереди, поэтому на сервер уходит только первая,
+// а остальные получают ее ответ из хранилища
+func idempotencyUnaryInterceptor(store IdempotencyStore, l Logger) grpc.UnaryClientInterceptor {
+	locks := newKeyLocks()
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := idempotentMethods[method]; !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		key, fingerprint, err := idempotencyKey(method, req)
+		if err != nil || key == "" {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		unlock, err := locks.lock(ctx, key)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		rec, err := store.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("idempotency store: %w", err)
+		}
+		switch {
+		case rec == nil:
+			rec = &IdempotencyRecord{
+				Key:         key,
+				Method:      method,
+				Fingerprint: fingerprint,
+				CreatedAt:   time.Now(),
+			}
+			if err := store.Put(ctx, rec); err != nil {
+				return fmt.Errorf("idempotency store: %w", err)
+			}
+		case rec.Fingerprint != fingerprint:
+			logWarn(l, "idempotency key conflict", FieldMethod, method, FieldOrderId, key)
+			return fmt.Errorf("%w: %s", ErrIdempotencyConflict, key)
+		case len(rec.Response) > 0:
+			if m, ok := reply.(proto.Message); ok && proto.Unmarshal(rec.Response, m) == nil {
+				logWarn(l, "duplicate order, response replayed", FieldMethod, method, FieldOrderId, key)
+				setReplayHeader(opts)
+				return nil
+			}
+		default:
+			// ответ на прошлую отправку не получен, сервер не выставит заявку с тем же ключом второй раз
+			logInfo(l, "resend order with the same idempotency key", FieldMethod, method, FieldOrderId, key)
+		}
+
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+		if m, ok := reply.(proto.Message); ok {
+			if rec.Response, err = proto.Marshal(m); err == nil {
+				if err := store.Put(ctx, rec); err != nil {
+					logError(l, "idempotency store", FieldMethod, method, FieldOrderId, key, FieldError, err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// idempotencyKey - ключ идемпотентности и хэш остальных параметров заявки
+func idempotencyKey(method string, req any) (string, string, error) {
+	var key string
+	var msg proto.Message
+	switch r := req.(type) {
+	case *pb.PostOrderRequest:
+		key = r.GetOrderId()
+		c := proto.Clone(r).(*pb.PostOrderRequest)
+		c.OrderId = ""
+		msg = c
+	case *pb.ReplaceOrderRequest:
+		key = r.GetIdempotencyKey()
+		c := proto.Clone(r).(*pb.ReplaceOrderRequest)
+		c.IdempotencyKey = ""
+		msg = c
+	default:
+		return "", "", nil
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(append([]byte(method), data...))
+	return key, hex.EncodeToString(sum[:]), nil
+}
+
+// setReplayHeader - заголовок ReplayHeader в ответе, который вернулся из хранилища
+func setReplayHeader(opts []grpc.CallOption) {
+	for _, opt := range opts {
+		if h, ok := opt.(grpc.HeaderCallOption); ok {
+			*h.HeaderAddr = metadata.Pairs(ReplayHeader, "true")
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package investgo_test
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const postOrderMethod = "OrdersService/PostOrder"
+
+func marketBuy(srv *fake.Server, orderId string, lots int64) *investgo.PostOrderRequest {
+	return &investgo.PostOrderRequest{
+		InstrumentId: testFigi,
+		Quantity:     lots,
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_MARKET,
+		OrderId:      orderId,
+	}
+}
+
+func TestPostOrderKeepsRequest(t *testing.T) {
+	srv := newTestServer(t)
+	orders := newTestClient(t, srv.Config()).NewOrdersServiceClient()
+
+	req := marketBuy(srv, "", 1)
+	first, err := orders.PostOrder(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.OrderId != "" {
+		t.Fatalf("request was modified: OrderId = %q", req.OrderId)
+	}
+	if first.RequestId == "" {
+		t.Fatal("response has no RequestId")
+	}
+	if investgo.ReplayedFromHeader(first.Header) {
+		t.Fatal("first order must not be replayed")
+	}
+
+	// повторная отправка с ключом из ответа получает прежний ответ без запроса на сервер
+	req.OrderId = first.RequestId
+	second, err := orders.PostOrder(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !investgo.ReplayedFromHeader(second.Header) || second.GetOrderId() != first.GetOrderId() {
+		t.Fatalf("second order %v, replayed: %v, want replay of %v", second.GetOrderId(),
+			investgo.ReplayedFromHeader(second.Header), first.GetOrderId())
+	}
+	if calls := srv.Calls(postOrderMethod); calls != 1 {
+		t.Fatalf("PostOrder calls = %d, want 1", calls)
+	}
+
+	// новый запрос без ключа - новая заявка
+	third, err := orders.Buy(&investgo.PostOrderRequestShort{
+		InstrumentId: testFigi,
+		Quantity:     1,
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_MARKET,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.RequestId == "" || third.RequestId == first.RequestId || third.GetOrderId() == first.GetOrderId() {
+		t.Fatalf("third order %v with key %q, want a new order", third.GetOrderId(), third.RequestId)
+	}
+}
+
+func TestReplaceOrderKeepsRequest(t *testing.T) {
+	srv := newTestServer(t)
+	orders := newTestClient(t, srv.Config()).NewOrdersServiceClient()
+	posted, err := orders.PostOrder(&investgo.PostOrderRequest{
+		InstrumentId: testFigi,
+		Quantity:     1,
+		Price:        fake.Quotation(240),
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_LIMIT,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &investgo.ReplaceOrderRequest{
+		AccountId: srv.AccountId(),
+		OrderId:   posted.GetOrderId(),
+		Quantity:  1,
+		Price:     fake.Quotation(241),
+		PriceType: pb.PriceType_PRICE_TYPE_CURRENCY,
+	}
+	resp, err := orders.ReplaceOrder(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.NewOrderId != "" || resp.RequestId == "" {
+		t.Fatalf("NewOrderId = %q, RequestId = %q, want request unchanged and key in response", req.NewOrderId, resp.RequestId)
+	}
+}
+
+func TestSandboxOrderKeepsRequest(t *testing.T) {
+	srv := newTestServer(t)
+	accountId := srv.OpenSandboxAccount()
+	if err := srv.PayIn(accountId, fake.Money(100000, "rub")); err != nil {
+		t.Fatal(err)
+	}
+	sandbox := newTestClient(t, srv.Config()).NewSandboxServiceClient()
+	req := marketBuy(srv, "", 1)
+	req.AccountId = accountId
+	resp, err := sandbox.PostSandboxOrder(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.OrderId != "" || resp.RequestId == "" {
+		t.Fatalf("OrderId = %q, RequestId = %q, want request unchanged and key in response", req.OrderId, resp.RequestId)
+	}
+}
+
+func TestIdempotencyConflict(t *testing.T) {
+	srv := newTestServer(t)
+	orders := newTestClient(t, srv.Config()).NewOrdersServiceClient()
+	if _, err := orders.PostOrder(marketBuy(srv, "order-1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := orders.PostOrder(marketBuy(srv, "order-1", 2))
+	if !errors.Is(err, investgo.ErrIdempotencyConflict) {
+		t.Fatalf("err = %v, want ErrIdempotencyConflict", err)
+	}
+	if calls := srv.Calls(postOrderMethod); calls != 1 {
+		t.Fatalf("PostOrder calls = %d, want 1", calls)
+	}
+}
+
+func TestConcurrentOrdersWithSameKey(t *testing.T) {
+	srv := newTestServer(t)
+	orders := newTestClient(t, srv.Config()).NewOrdersServiceClient()
+
+	const n = 10
+	var wg sync.WaitGroup
+	ids := make([]string, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := orders.PostOrder(marketBuy(srv, "order-1", 1))
+			ids[i], errs[i] = resp.GetOrderId(), err
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if ids[i] != ids[0] {
+			t.Fatalf("order ids %v and %v, want one order", ids[0], ids[i])
+		}
+	}
+	if calls := srv.Calls(postOrderMethod); calls != 1 {
+		t.Fatalf("PostOrder calls = %d, want 1", calls)
+	}
+}
+
+func TestIdempotentRetry(t *testing.T) {
+	srv := newTestServer(t)
+	orders := newTestClient(t, srv.Config()).NewOrdersServiceClient()
+	srv.InjectError(postOrderMethod, status.Error(codes.Unavailable, "unavailable"))
+
+	resp, err := orders.PostOrder(marketBuy(srv, "", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := srv.Calls(postOrderMethod); calls != 2 {
+		t.Fatalf("PostOrder calls = %d, want 2", calls)
+	}
+	if got := srv.PositionBalance(srv.AccountId(), testFigi); got != 10 {
+		t.Fatalf("position = %d, want 10 after a single order %v", got, resp.GetOrderId())
+	}
+}
+
+func TestFileIdempotencyStoreSurvivesRestart(t *testing.T) {
+	srv := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "keys.json")
+	post := func() *investgo.PostOrderResponse {
+		t.Helper()
+		store, err := investgo.NewFileIdempotencyStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := newTestClient(t, srv.Config(), investgo.WithIdempotencyStore(store))
+		resp, err := client.NewOrdersServiceClient().PostOrder(marketBuy(srv, investgo.OrderKey("rebalance"), 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	first, second := post(), post()
+	if !investgo.ReplayedFromHeader(second.Header) || second.GetOrderId() != first.GetOrderId() {
+		t.Fatalf("order after restart %v, want replay of %v", second.GetOrderId(), first.GetOrderId())
+	}
+	if calls := srv.Calls(postOrderMethod); calls != 1 {
+		t.Fatalf("PostOrder calls = %d, want 1", calls)
+	}
+}
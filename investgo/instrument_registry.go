@@ -0,0 +1,607 @@
+package investgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ErrInstrumentNotFound - Инструмент не найден в справочнике
+var ErrInstrumentNotFound = errors.New("instrument not found")
+
+const (
+	// DefaultRegistryTTL - Период обновления справочника инструментов
+	DefaultRegistryTTL = 24 * time.Hour
+	// DefaultRegistryRetry - Пауза перед повторным обновлением справочника после ошибки
+	DefaultRegistryRetry = time.Minute
+)
+
+// InstrumentLister - Списки инструментов для загрузки справочника, реализуется InstrumentsServiceClient
+type InstrumentLister interface {
+	SharesWithContext(ctx context.Context, status pb.InstrumentStatus) (*SharesResponse, error)
+	BondsWithContext(ctx context.Context, status pb.InstrumentStatus) (*BondsResponse, error)
+	EtfsWithContext(ctx context.Context, status pb.InstrumentStatus) (*EtfsResponse, error)
+	FuturesWithContext(ctx context.Context, status pb.InstrumentStatus) (*FuturesResponse, error)
+	CurrenciesWithContext(ctx context.Context, status pb.InstrumentStatus) (*CurrenciesResponse, error)
+	OptionsWithContext(ctx context.Context, status pb.InstrumentStatus) (*OptionsResponse, error)
+}
+
+// InstrumentRegistryOption - Параметры InstrumentRegistry
+type InstrumentRegistryOption func(*InstrumentRegistry)
+
+// WithRegistryTTL - Период обновления справочника, по умолчанию DefaultRegistryTTL
+func WithRegistryTTL(ttl time.Duration) InstrumentRegistryOption {
+	return func(r *InstrumentRegistry) {
+		if ttl > 0 {
+			r.ttl = ttl
+		}
+	}
+}
+
+// WithRegistryStatus - Статус загружаемых инструментов, по умолчанию INSTRUMENT_STATUS_BASE - инструменты,
+// доступные для торговли через API
+func WithRegistryStatus(status pb.InstrumentStatus) InstrumentRegistryOption {
+	return func(r *InstrumentRegistry) {
+		r.status = status
+	}
+}
+
+// WithRegistryKinds - Типы загружаемых инструментов, по умолчанию акции, облигации, фонды, фьючерсы, валюты и опционы
+func WithRegistryKinds(kinds ...pb.InstrumentType) InstrumentRegistryOption {
+	return func(r *InstrumentRegistry) {
+		r.kinds = kinds
+	}
+}
+
+// WithRegistrySnapshot - Файл со снимком справочника. Снимок записывается после каждого обновления и читается
+// в Load, поэтому при перезапуске приложения справочник доступен без запросов к серверу
+func WithRegistrySnapshot(path string) InstrumentRegistryOption {
+	return func(r *InstrumentRegistry) {
+		r.snapshot = path
+	}
+}
+
+// WithRegistryFallback - Источник инструментов, которых нет в справочнике, например InstrumentsServiceClient.
+// Найденные инструменты добавляются в справочник до следующего обновления
+func WithRegistryFallback(source InstrumentSource) InstrumentRegistryOption {
+	return func(r *InstrumentRegistry) {
+		r.fallback = source
+	}
+}
+
+// WithRegistryLogger - Логгер для ошибок обновления справочника и записи снимка
+func WithRegistryLogger(l Logger) InstrumentRegistryOption {
+	return func(r *InstrumentRegistry) {
+		r.logger = l
+	}
+}
+
+// InstrumentRegistry - Справочник инструментов в памяти. Загружает списки акций, облигаций, фондов, фьючерсов,
+// валют и опционов и ищет инструменты по figi, instrument_uid, position_uid и паре тикер + class_code без
+// запросов к серверу. Инструменты возвращаются в виде общего *pb.Instrument, изменять их нельзя
+type InstrumentRegistry struct {
+	source   InstrumentLister
+	fallback InstrumentSource
+	logger   Logger
+	ttl      time.Duration
+	status   pb.InstrumentStatus
+	kinds    []pb.InstrumentType
+	snapshot string
+
+	// refresh - обновления справочника выполняются по одному
+	refresh sync.Mutex
+	mu      sync.RWMutex
+	index   *registryIndex
+}
+
+// registryIndex - индексы справочника, после построения не изменяются, кроме добавления инструментов из fallback
+type registryIndex struct {
+	instruments   []*pb.Instrument
+	byFigi        map[string]*pb.Instrument
+	byUid         map[string]*pb.Instrument
+	byPositionUid map[string]*pb.Instrument
+	byTicker      map[string]*pb.Instrument
+	updatedAt     time.Time
+}
+
+// registrySnapshot - формат файла снимка справочника
+type registrySnapshot struct {
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Instruments []json.RawMessage `json:"instruments"`
+}
+
+// NewInstrumentRegistry - Создание справочника инструментов. Справочник пустой до вызова Load или Refresh
+func NewInstrumentRegistry(source InstrumentLister, opts ...InstrumentRegistryOption) *InstrumentRegistry {
+	r := &InstrumentRegistry{
+		source: source,
+		ttl:    DefaultRegistryTTL,
+		status: pb.InstrumentStatus_INSTRUMENT_STATUS_BASE,
+		kinds: []pb.InstrumentType{
+			pb.InstrumentType_INSTRUMENT_TYPE_SHARE,
+			pb.InstrumentType_INSTRUMENT_TYPE_BOND,
+			pb.InstrumentType_INSTRUMENT_TYPE_ETF,
+			pb.InstrumentType_INSTRUMENT_TYPE_FUTURES,
+			pb.InstrumentType_INSTRUMENT_TYPE_CURRENCY,
+			pb.InstrumentType_INSTRUMENT_TYPE_OPTION,
+		},
+		index: newRegistryIndex(nil, time.Time{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// NewInstrumentRegistry - Справочник инструментов клиента, инструменты не из справочника запрашиваются
+// через InstrumentsService
+func (c *Client) NewInstrumentRegistry(opts ...InstrumentRegistryOption) *InstrumentRegistry {
+	is := c.NewInstrumentsServiceClient()
+	return NewInstrumentRegistry(is,
+		append([]InstrumentRegistryOption{WithRegistryFallback(is), WithRegistryLogger(c.Logger)}, opts...)...)
+}
+
+// Load - Первая загрузка справочника. Если задан WithRegistrySnapshot и снимок моложе TTL, справочник читается
+// из файла без запросов к серверу. Устаревший снимок используется, пока справочник обновляется с сервера,
+// и остается в справочнике, если обновить его не удалось
+func (r *InstrumentRegistry) Load(ctx context.Context) error {
+	if r.snapshot != "" {
+		loaded, err := r.loadSnapshot()
+		if err != nil {
+			r.warn("instrument registry snapshot", FieldError, err)
+		}
+		if loaded && r.Age() < r.ttl {
+			return nil
+		}
+		if loaded {
+			if err := r.Refresh(ctx); err != nil {
+				r.warn("instrument registry refresh, using snapshot", FieldError, err)
+			}
+			return nil
+		}
+	}
+	return r.Refresh(ctx)
+}
+
+// Refresh - Загрузка списков инструментов с сервера и замена справочника. При ошибке справочник не изменяется
+func (r *InstrumentRegistry) Refresh(ctx context.Context) error {
+	r.refresh.Lock()
+	defer r.refresh.Unlock()
+
+	instruments := make([]*pb.Instrument, 0)
+	for _, kind := range r.kinds {
+		list, err := r.list(ctx, kind)
+		if err != nil {
+			return fmt.Errorf("instrument registry %s: %w", kind, err)
+		}
+		instruments = append(instruments, list...)
+	}
+	index := newRegistryIndex(instruments, time.Now())
+
+	r.mu.Lock()
+	r.index = index
+	r.mu.Unlock()
+
+	if r.snapshot != "" {
+		if err := r.saveSnapshot(instruments, index.updatedAt); err != nil {
+			r.warn("instrument registry snapshot", FieldError, err)
+		}
+	}
+	return nil
+}
+
+// Run - Обновление справочника раз в TTL до отмены ctx. После ошибки обновление повторяется через
+// DefaultRegistryRetry
+func (r *InstrumentRegistry) Run(ctx context.Context) error {
+	timer := time.NewTimer(r.ttl - r.Age())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			next := r.ttl
+			if err := r.Refresh(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				r.warn("instrument registry refresh", FieldError, err)
+				if next > DefaultRegistryRetry {
+					next = DefaultRegistryRetry
+				}
+			}
+			timer.Reset(next)
+		}
+	}
+}
+
+// Age - Время с последнего обновления справочника, для снимка - с момента его записи
+func (r *InstrumentRegistry) Age() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.index.updatedAt.IsZero() {
+		return r.ttl
+	}
+	return time.Since(r.index.updatedAt)
+}
+
+// UpdatedAt - Время последнего обновления справочника, нулевое, если справочник не загружен
+func (r *InstrumentRegistry) UpdatedAt() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.index.updatedAt
+}
+
+// Len - Количество инструментов в справочнике
+func (r *InstrumentRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.index.instruments)
+}
+
+// Instruments - Все инструменты справочника
+func (r *InstrumentRegistry) Instruments() []*pb.Instrument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*pb.Instrument(nil), r.index.instruments...)
+}
+
+// Lookup - Поиск инструмента по figi, instrument_uid или position_uid
+func (r *InstrumentRegistry) Lookup(id string) (*pb.Instrument, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.index.lookup(id)
+}
+
+// ByFigi - Поиск инструмента по figi
+func (r *InstrumentRegistry) ByFigi(figi string) (*pb.Instrument, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	inst, ok := r.index.byFigi[figi]
+	return inst, ok
+}
+
+// ByUid - Поиск инструмента по instrument_uid
+func (r *InstrumentRegistry) ByUid(uid string) (*pb.Instrument, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	inst, ok := r.index.byUid[uid]
+	return inst, ok
+}
+
+// ByPositionUid - Поиск инструмента по position_uid
+func (r *InstrumentRegistry) ByPositionUid(positionUid string) (*pb.Instrument, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	inst, ok := r.index.byPositionUid[positionUid]
+	return inst, ok
+}
+
+// ByTicker - Поиск инструмента по тикеру и class_code, регистр не учитывается
+func (r *InstrumentRegistry) ByTicker(ticker, classCode string) (*pb.Instrument, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	inst, ok := r.index.byTicker[registryTickerKey(ticker, classCode)]
+	return inst, ok
+}
+
+// InstrumentWithContext - Инструмент по figi, instrument_uid или position_uid. Если инструмента нет в справочнике,
+// он запрашивается через WithRegistryFallback, без него возвращается ErrInstrumentNotFound
+func (r *InstrumentRegistry) InstrumentWithContext(ctx context.Context, instrumentId string) (*pb.Instrument, error) {
+	if inst, ok := r.Lookup(instrumentId); ok {
+		return inst, nil
+	}
+	if r.fallback == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInstrumentNotFound, instrumentId)
+	}
+	inst, err := r.fallback.InstrumentWithContext(ctx, instrumentId)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if known, ok := r.index.lookup(instrumentId); ok {
+		return known, nil
+	}
+	r.index.add(inst)
+	return inst, nil
+}
+
+// Lot - Лотность инструмента по figi, instrument_uid или position_uid
+func (r *InstrumentRegistry) Lot(ctx context.Context, instrumentId string) (int64, error) {
+	inst, err := r.InstrumentWithContext(ctx, instrumentId)
+	if err != nil {
+		return 0, err
+	}
+	return int64(inst.GetLot()), nil
+}
+
+// Figi - figi инструмента по instrument_uid или position_uid, у опционов figi пустой
+func (r *InstrumentRegistry) Figi(id string) (string, bool) {
+	inst, ok := r.Lookup(id)
+	return inst.GetFigi(), ok
+}
+
+// Uid - instrument_uid инструмента по figi или position_uid
+func (r *InstrumentRegistry) Uid(id string) (string, bool) {
+	inst, ok := r.Lookup(id)
+	return inst.GetUid(), ok
+}
+
+// PositionUid - position_uid инструмента по figi или instrument_uid
+func (r *InstrumentRegistry) PositionUid(id string) (string, bool) {
+	inst, ok := r.Lookup(id)
+	return inst.GetPositionUid(), ok
+}
+
+// Ticker - Тикер и class_code инструмента по figi, instrument_uid или position_uid
+func (r *InstrumentRegistry) Ticker(id string) (ticker string, classCode string, ok bool) {
+	inst, ok := r.Lookup(id)
+	return inst.GetTicker(), inst.GetClassCode(), ok
+}
+
+// list - загрузка инструментов одного типа
+func (r *InstrumentRegistry) list(ctx context.Context, kind pb.InstrumentType) ([]*pb.Instrument, error) {
+	var res []*pb.Instrument
+	switch kind {
+	case pb.InstrumentType_INSTRUMENT_TYPE_SHARE:
+		resp, err := r.source.SharesWithContext(ctx, r.status)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range resp.GetInstruments() {
+			res = append(res, registryInstrument(s, s.GetIsin(), kind, "share"))
+		}
+	case pb.InstrumentType_INSTRUMENT_TYPE_BOND:
+		resp, err := r.source.BondsWithContext(ctx, r.status)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range resp.GetInstruments() {
+			res = append(res, registryInstrument(b, b.GetIsin(), kind, "bond"))
+		}
+	case pb.InstrumentType_INSTRUMENT_TYPE_ETF:
+		resp, err := r.source.EtfsWithContext(ctx, r.status)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range resp.GetInstruments() {
+			res = append(res, registryInstrument(e, e.GetIsin(), kind, "etf"))
+		}
+	case pb.InstrumentType_INSTRUMENT_TYPE_FUTURES:
+		resp, err := r.source.FuturesWithContext(ctx, r.status)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range resp.GetInstruments() {
+			res = append(res, registryInstrument(f, "", kind, "futures"))
+		}
+	case pb.InstrumentType_INSTRUMENT_TYPE_CURRENCY:
+		resp, err := r.source.CurrenciesWithContext(ctx, r.status)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range resp.GetInstruments() {
+			res = append(res, registryInstrument(c, c.GetIsin(), kind, "currency"))
+		}
+	case pb.InstrumentType_INSTRUMENT_TYPE_OPTION:
+		resp, err := r.source.OptionsWithContext(ctx, r.status)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range resp.GetInstruments() {
+			res = append(res, registryInstrument(registryOption{o}, "", kind, "option"))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported instrument type %s", kind)
+	}
+	return res, nil
+}
+
+// loadSnapshot - чтение снимка справочника, false если файла нет
+func (r *InstrumentRegistry) loadSnapshot() (bool, error) {
+	data, err := os.ReadFile(r.snapshot)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var snap registrySnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return false, fmt.Errorf("%s: %w", r.snapshot, err)
+	}
+	instruments := make([]*pb.Instrument, 0, len(snap.Instruments))
+	for _, raw := range snap.Instruments {
+		inst := &pb.Instrument{}
+		if err := protojson.Unmarshal(raw, inst); err != nil {
+			return false, fmt.Errorf("%s: %w", r.snapshot, err)
+		}
+		instruments = append(instruments, inst)
+	}
+	index := newRegistryIndex(instruments, snap.UpdatedAt)
+
+	r.mu.Lock()
+	r.index = index
+	r.mu.Unlock()
+	return true, nil
+}
+
+// saveSnapshot - запись снимка справочника
+func (r *InstrumentRegistry) saveSnapshot(instruments []*pb.Instrument, updatedAt time.Time) error {
+	snap := registrySnapshot{
+		UpdatedAt:   updatedAt,
+		Instruments: make([]json.RawMessage, 0, len(instruments)),
+	}
+	for _, inst := range instruments {
+		raw, err := protojson.Marshal(inst)
+		if err != nil {
+			return err
+		}
+		snap.Instruments = append(snap.Instruments, raw)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	// запись через временный файл, чтобы при падении процесса не остался наполовину записанный снимок
+	tmp, err := os.CreateTemp(filepath.Dir(r.snapshot), filepath.Base(r.snapshot)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), r.snapshot)
+}
+
+func (r *InstrumentRegistry) warn(msg string, keysAndValues ...any) {
+	if r.logger != nil {
+		logWarn(r.logger, msg, keysAndValues...)
+	}
+}
+
+func newRegistryIndex(instruments []*pb.Instrument, updatedAt time.Time) *registryIndex {
+	idx := &registryIndex{
+		instruments:   make([]*pb.Instrument, 0, len(instruments)),
+		byFigi:        make(map[string]*pb.Instrument, len(instruments)),
+		byUid:         make(map[string]*pb.Instrument, len(instruments)),
+		byPositionUid: make(map[string]*pb.Instrument, len(instruments)),
+		byTicker:      make(map[string]*pb.Instrument, len(instruments)),
+		updatedAt:     updatedAt,
+	}
+	for _, inst := range instruments {
+		idx.add(inst)
+	}
+	return idx
+}
+
+// add - добавление инструмента во все индексы, вызывается под r.mu
+func (idx *registryIndex) add(inst *pb.Instrument) {
+	idx.instruments = append(idx.instruments, inst)
+	if inst.GetFigi() != "" {
+		idx.byFigi[inst.GetFigi()] = inst
+	}
+	if inst.GetUid() != "" {
+		idx.byUid[inst.GetUid()] = inst
+	}
+	if inst.GetPositionUid() != "" {
+		idx.byPositionUid[inst.GetPositionUid()] = inst
+	}
+	if inst.GetTicker() != "" {
+		idx.byTicker[registryTickerKey(inst.GetTicker(), inst.GetClassCode())] = inst
+	}
+}
+
+func (idx *registryIndex) lookup(id string) (*pb.Instrument, bool) {
+	if inst, ok := idx.byUid[id]; ok {
+		return inst, true
+	}
+	if inst, ok := idx.byFigi[id]; ok {
+		return inst, true
+	}
+	inst, ok := idx.byPositionUid[id]
+	return inst, ok
+}
+
+func registryTickerKey(ticker, classCode string) string {
+	return strings.ToUpper(ticker) + "_" + strings.ToUpper(classCode)
+}
+
+// registryCommon - общие поля акций, облигаций, фондов, фьючерсов, валют и опционов
+type registryCommon interface {
+	GetFigi() string
+	GetTicker() string
+	GetClassCode() string
+	GetLot() int32
+	GetCurrency() string
+	GetName() string
+	GetExchange() string
+	GetRealExchange() pb.RealExchange
+	GetCountryOfRisk() string
+	GetCountryOfRiskName() string
+	GetShortEnabledFlag() bool
+	GetTradingStatus() pb.SecurityTradingStatus
+	GetOtcFlag() bool
+	GetBuyAvailableFlag() bool
+	GetSellAvailableFlag() bool
+	GetMinPriceIncrement() *pb.Quotation
+	GetApiTradeAvailableFlag() bool
+	GetUid() string
+	GetPositionUid() string
+	GetForIisFlag() bool
+	GetForQualInvestorFlag() bool
+	GetWeekendFlag() bool
+	GetBlockedTcaFlag() bool
+	GetFirst_1MinCandleDate() *timestamppb.Timestamp
+	GetFirst_1DayCandleDate() *timestamppb.Timestamp
+	GetKlong() *pb.Quotation
+	GetKshort() *pb.Quotation
+	GetDlong() *pb.Quotation
+	GetDshort() *pb.Quotation
+	GetDlongMin() *pb.Quotation
+	GetDshortMin() *pb.Quotation
+}
+
+// registryOption - у опциона нет figi, остальные общие поля совпадают с другими инструментами
+type registryOption struct {
+	*pb.Option
+}
+
+func (registryOption) GetFigi() string {
+	return ""
+}
+
+func registryInstrument(c registryCommon, isin string, kind pb.InstrumentType, typeName string) *pb.Instrument {
+	return &pb.Instrument{
+		Figi:                  c.GetFigi(),
+		Ticker:                c.GetTicker(),
+		ClassCode:             c.GetClassCode(),
+		Isin:                  isin,
+		Lot:                   c.GetLot(),
+		Currency:              c.GetCurrency(),
+		Klong:                 c.GetKlong(),
+		Kshort:                c.GetKshort(),
+		Dlong:                 c.GetDlong(),
+		Dshort:                c.GetDshort(),
+		DlongMin:              c.GetDlongMin(),
+		DshortMin:             c.GetDshortMin(),
+		ShortEnabledFlag:      c.GetShortEnabledFlag(),
+		Name:                  c.GetName(),
+		Exchange:              c.GetExchange(),
+		CountryOfRisk:         c.GetCountryOfRisk(),
+		CountryOfRiskName:     c.GetCountryOfRiskName(),
+		InstrumentType:        typeName,
+		TradingStatus:         c.GetTradingStatus(),
+		OtcFlag:               c.GetOtcFlag(),
+		BuyAvailableFlag:      c.GetBuyAvailableFlag(),
+		SellAvailableFlag:     c.GetSellAvailableFlag(),
+		MinPriceIncrement:     c.GetMinPriceIncrement(),
+		ApiTradeAvailableFlag: c.GetApiTradeAvailableFlag(),
+		Uid:                   c.GetUid(),
+		RealExchange:          c.GetRealExchange(),
+		PositionUid:           c.GetPositionUid(),
+		ForIisFlag:            c.GetForIisFlag(),
+		ForQualInvestorFlag:   c.GetForQualInvestorFlag(),
+		WeekendFlag:           c.GetWeekendFlag(),
+		BlockedTcaFlag:        c.GetBlockedTcaFlag(),
+		InstrumentKind:        kind,
+		First_1MinCandleDate:  c.GetFirst_1MinCandleDate(),
+		First_1DayCandleDate:  c.GetFirst_1DayCandleDate(),
+	}
+}
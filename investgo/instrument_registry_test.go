@@ -0,0 +1,157 @@
+package investgo_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const sharesMethod = "InstrumentsService/Shares"
+
+// newRegistryServer - Сервер с акцией testFigi, фьючерсом и акцией, недоступной для торговли через API
+func newRegistryServer(t *testing.T) *fake.Server {
+	t.Helper()
+	srv := newTestServer(t)
+	srv.AddFuture(&pb.Future{Figi: "FUTSI0000001", Ticker: "SiZ4", ClassCode: "SPBFUT", Lot: 1, ApiTradeAvailableFlag: true})
+	srv.AddShare(&pb.Share{Figi: "BBG000000OTC", Ticker: "OTC", ClassCode: "TQBR", Lot: 3})
+	return srv
+}
+
+func TestInstrumentRegistryLookup(t *testing.T) {
+	srv := newRegistryServer(t)
+	client := newTestClient(t, srv.Config())
+	r := client.NewInstrumentRegistry()
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 2 {
+		t.Fatalf("len = %d, want 2 instruments available through API", r.Len())
+	}
+
+	inst, ok := r.ByTicker("sber", "tqbr")
+	if !ok || inst.GetFigi() != testFigi || inst.GetLot() != 10 || inst.GetInstrumentKind() != pb.InstrumentType_INSTRUMENT_TYPE_SHARE {
+		t.Fatalf("instrument = %v, want share %s", inst, testFigi)
+	}
+	uid, _ := r.Uid(testFigi)
+	if figi, ok := r.Figi(uid); !ok || figi != testFigi {
+		t.Fatalf("figi by uid = %q, want %s", figi, testFigi)
+	}
+	if got, ok := r.ByPositionUid(inst.GetPositionUid()); !ok || got.GetUid() != uid {
+		t.Fatalf("instrument by position uid = %v, want %s", got, uid)
+	}
+
+	// инструмента нет в справочнике: он запрашивается через InstrumentsService и добавляется
+	lot, err := r.Lot(context.Background(), "BBG000000OTC")
+	if err != nil || lot != 3 {
+		t.Fatalf("lot = %d, err = %v, want 3 from fallback", lot, err)
+	}
+	if r.Len() != 3 {
+		t.Fatalf("len = %d, want fallback instrument added", r.Len())
+	}
+	if _, err := r.InstrumentWithContext(context.Background(), "unknown"); err == nil {
+		t.Fatal("want error for unknown instrument")
+	}
+
+	// без fallback
+	standalone := investgo.NewInstrumentRegistry(client.NewInstrumentsServiceClient())
+	if err := standalone.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := standalone.InstrumentWithContext(context.Background(), "BBG000000OTC"); !errors.Is(err, investgo.ErrInstrumentNotFound) {
+		t.Fatalf("err = %v, want ErrInstrumentNotFound", err)
+	}
+}
+
+func TestInstrumentRegistrySnapshot(t *testing.T) {
+	srv := newRegistryServer(t)
+	is := newTestClient(t, srv.Config()).NewInstrumentsServiceClient()
+	path := filepath.Join(t.TempDir(), "registry.json")
+	load := func(ttl time.Duration) *investgo.InstrumentRegistry {
+		t.Helper()
+		r := investgo.NewInstrumentRegistry(is, investgo.WithRegistrySnapshot(path), investgo.WithRegistryTTL(ttl))
+		if err := r.Load(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	first := load(time.Hour)
+	if calls := srv.Calls(sharesMethod); calls != 1 {
+		t.Fatalf("Shares calls = %d, want 1", calls)
+	}
+	// свежий снимок читается без запросов к серверу
+	second := load(time.Hour)
+	if calls := srv.Calls(sharesMethod); calls != 1 {
+		t.Fatalf("Shares calls = %d, want snapshot without requests", calls)
+	}
+	if second.Len() != first.Len() || !second.UpdatedAt().Equal(first.UpdatedAt()) {
+		t.Fatalf("snapshot: len = %d, updated at %v, want %d, %v", second.Len(), second.UpdatedAt(), first.Len(), first.UpdatedAt())
+	}
+	if inst, ok := second.ByTicker("SiZ4", "SPBFUT"); !ok || inst.GetInstrumentKind() != pb.InstrumentType_INSTRUMENT_TYPE_FUTURES {
+		t.Fatalf("future from snapshot = %v", inst)
+	}
+
+	// устаревший снимок остается, если обновить справочник не удалось
+	time.Sleep(time.Millisecond)
+	srv.InjectError(sharesMethod, status.Error(codes.InvalidArgument, "invalid"))
+	stale := load(time.Millisecond)
+	if stale.Len() != first.Len() || !stale.UpdatedAt().Equal(first.UpdatedAt()) {
+		t.Fatalf("len = %d, updated at %v, want stale snapshot", stale.Len(), stale.UpdatedAt())
+	}
+	// и обновляется с сервера при следующей загрузке
+	refreshed := load(time.Millisecond)
+	if calls := srv.Calls(sharesMethod); calls != 3 || !refreshed.UpdatedAt().After(first.UpdatedAt()) {
+		t.Fatalf("Shares calls = %d, updated at %v, want refresh", calls, refreshed.UpdatedAt())
+	}
+
+	// испорченный снимок заменяется справочником с сервера
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if r := load(time.Hour); r.Len() != first.Len() {
+		t.Fatalf("len = %d, want %d from server", r.Len(), first.Len())
+	}
+	if calls := srv.Calls(sharesMethod); calls != 4 {
+		t.Fatalf("Shares calls = %d, want 4", calls)
+	}
+}
+
+func TestInstrumentRegistryRun(t *testing.T) {
+	srv := newRegistryServer(t)
+	r := newTestClient(t, srv.Config()).NewInstrumentRegistry(investgo.WithRegistryTTL(20*time.Millisecond),
+		investgo.WithRegistryKinds(pb.InstrumentType_INSTRUMENT_TYPE_SHARE))
+	if err := r.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 1 {
+		t.Fatalf("len = %d, want only shares", r.Len())
+	}
+
+	// при ошибке обновления справочник не меняется
+	srv.InjectError(sharesMethod, status.Error(codes.InvalidArgument, "invalid"))
+	if err := r.Refresh(context.Background()); err == nil || r.Len() != 1 {
+		t.Fatalf("err = %v, len = %d, want error and unchanged registry", err, r.Len())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+	srv.AddShare(&pb.Share{Figi: "BBG004731032", Ticker: "LKOH", ClassCode: "TQBR", Lot: 1, ApiTradeAvailableFlag: true})
+	eventually(t, "registry was not refreshed", func() bool {
+		_, ok := r.ByFigi("BBG004731032")
+		return ok
+	})
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
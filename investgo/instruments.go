@@ -3,6 +3,7 @@ package investgo
 import (
 	"time"
 
+	"github.com/google/uuid"
 	pb "github.com/tinkoff/invest-api-go-sdk/proto"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -19,8 +20,13 @@ type InstrumentsServiceClient struct {
 
 // TradingSchedules - Метод получения расписания торгов торговых площадок
 func (is *InstrumentsServiceClient) TradingSchedules(exchange string, from, to time.Time) (*TradingSchedulesResponse, error) {
+	return is.TradingSchedulesWithContext(is.ctx, exchange, from, to)
+}
+
+// TradingSchedulesWithContext - Метод получения расписания торгов торговых площадок
+func (is *InstrumentsServiceClient) TradingSchedulesWithContext(ctx context.Context, exchange string, from, to time.Time) (*TradingSchedulesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.TradingSchedules(is.ctx, &pb.TradingSchedulesRequest{
+	resp, err := is.pbClient.TradingSchedules(ctx, &pb.TradingSchedulesRequest{
 		Exchange: exchange,
 		From:     TimeToTimestamp(from),
 		To:       TimeToTimestamp(to),
@@ -36,27 +42,47 @@ func (is *InstrumentsServiceClient) TradingSchedules(exchange string, from, to t
 
 // BondByFigi - Метод получения облигации по figi
 func (is *InstrumentsServiceClient) BondByFigi(id string) (*BondResponse, error) {
-	return is.bondBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
+	return is.BondByFigiWithContext(is.ctx, id)
+}
+
+// BondByFigiWithContext - Метод получения облигации по figi
+func (is *InstrumentsServiceClient) BondByFigiWithContext(ctx context.Context, id string) (*BondResponse, error) {
+	return is.bondBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
 }
 
 // BondByTicker - Метод получения облигации по Ticker
 func (is *InstrumentsServiceClient) BondByTicker(id string, classCode string) (*BondResponse, error) {
-	return is.bondBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
+	return is.BondByTickerWithContext(is.ctx, id, classCode)
+}
+
+// BondByTickerWithContext - Метод получения облигации по Ticker
+func (is *InstrumentsServiceClient) BondByTickerWithContext(ctx context.Context, id string, classCode string) (*BondResponse, error) {
+	return is.bondBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
 }
 
 // BondByUid - Метод получения облигации по Uid
 func (is *InstrumentsServiceClient) BondByUid(id string) (*BondResponse, error) {
-	return is.bondBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
+	return is.BondByUidWithContext(is.ctx, id)
+}
+
+// BondByUidWithContext - Метод получения облигации по Uid
+func (is *InstrumentsServiceClient) BondByUidWithContext(ctx context.Context, id string) (*BondResponse, error) {
+	return is.bondBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
 }
 
 // BondByPositionUid - Метод получения облигации по PositionUid
 func (is *InstrumentsServiceClient) BondByPositionUid(id string) (*BondResponse, error) {
-	return is.bondBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+	return is.BondByPositionUidWithContext(is.ctx, id)
+}
+
+// BondByPositionUidWithContext - Метод получения облигации по PositionUid
+func (is *InstrumentsServiceClient) BondByPositionUidWithContext(ctx context.Context, id string) (*BondResponse, error) {
+	return is.bondBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
 }
 
-func (is *InstrumentsServiceClient) bondBy(id string, idType pb.InstrumentIdType, classCode string) (*BondResponse, error) {
+func (is *InstrumentsServiceClient) bondBy(ctx context.Context, id string, idType pb.InstrumentIdType, classCode string) (*BondResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.BondBy(is.ctx, &pb.InstrumentRequest{
+	resp, err := is.pbClient.BondBy(ctx, &pb.InstrumentRequest{
 		IdType:    idType,
 		ClassCode: classCode,
 		Id:        id,
@@ -72,8 +98,13 @@ func (is *InstrumentsServiceClient) bondBy(id string, idType pb.InstrumentIdType
 
 // Bonds - Метод получения списка облигаций
 func (is *InstrumentsServiceClient) Bonds(status pb.InstrumentStatus) (*BondsResponse, error) {
+	return is.BondsWithContext(is.ctx, status)
+}
+
+// BondsWithContext - Метод получения списка облигаций
+func (is *InstrumentsServiceClient) BondsWithContext(ctx context.Context, status pb.InstrumentStatus) (*BondsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.Bonds(is.ctx, &pb.InstrumentsRequest{
+	resp, err := is.pbClient.Bonds(ctx, &pb.InstrumentsRequest{
 		InstrumentStatus: status,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -87,8 +118,13 @@ func (is *InstrumentsServiceClient) Bonds(status pb.InstrumentStatus) (*BondsRes
 
 // GetBondCoupons - Метод получения графика выплат купонов по облигации
 func (is *InstrumentsServiceClient) GetBondCoupons(figi string, from, to time.Time) (*GetBondCouponsResponse, error) {
+	return is.GetBondCouponsWithContext(is.ctx, figi, from, to)
+}
+
+// GetBondCouponsWithContext - Метод получения графика выплат купонов по облигации
+func (is *InstrumentsServiceClient) GetBondCouponsWithContext(ctx context.Context, figi string, from, to time.Time) (*GetBondCouponsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetBondCoupons(is.ctx, &pb.GetBondCouponsRequest{
+	resp, err := is.pbClient.GetBondCoupons(ctx, &pb.GetBondCouponsRequest{
 		Figi: figi,
 		From: TimeToTimestamp(from),
 		To:   TimeToTimestamp(to),
@@ -104,27 +140,47 @@ func (is *InstrumentsServiceClient) GetBondCoupons(figi string, from, to time.Ti
 
 // CurrencyByFigi - Метод получения валюты по Figi
 func (is *InstrumentsServiceClient) CurrencyByFigi(id string) (*CurrencyResponse, error) {
-	return is.currenceBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
+	return is.CurrencyByFigiWithContext(is.ctx, id)
+}
+
+// CurrencyByFigiWithContext - Метод получения валюты по Figi
+func (is *InstrumentsServiceClient) CurrencyByFigiWithContext(ctx context.Context, id string) (*CurrencyResponse, error) {
+	return is.currenceBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
 }
 
 // CurrencyByTicker - Метод получения валюты по Ticker
 func (is *InstrumentsServiceClient) CurrencyByTicker(id string, classCode string) (*CurrencyResponse, error) {
-	return is.currenceBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
+	return is.CurrencyByTickerWithContext(is.ctx, id, classCode)
+}
+
+// CurrencyByTickerWithContext - Метод получения валюты по Ticker
+func (is *InstrumentsServiceClient) CurrencyByTickerWithContext(ctx context.Context, id string, classCode string) (*CurrencyResponse, error) {
+	return is.currenceBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
 }
 
 // CurrencyByUid - Метод получения валюты по Uid
 func (is *InstrumentsServiceClient) CurrencyByUid(id string) (*CurrencyResponse, error) {
-	return is.currenceBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
+	return is.CurrencyByUidWithContext(is.ctx, id)
+}
+
+// CurrencyByUidWithContext - Метод получения валюты по Uid
+func (is *InstrumentsServiceClient) CurrencyByUidWithContext(ctx context.Context, id string) (*CurrencyResponse, error) {
+	return is.currenceBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
 }
 
 // CurrencyByPositionUid - Метод получения валюты по PositionUid
 func (is *InstrumentsServiceClient) CurrencyByPositionUid(id string) (*CurrencyResponse, error) {
-	return is.currenceBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+	return is.CurrencyByPositionUidWithContext(is.ctx, id)
 }
 
-func (is *InstrumentsServiceClient) currenceBy(id string, idType pb.InstrumentIdType, classCode string) (*CurrencyResponse, error) {
+// CurrencyByPositionUidWithContext - Метод получения валюты по PositionUid
+func (is *InstrumentsServiceClient) CurrencyByPositionUidWithContext(ctx context.Context, id string) (*CurrencyResponse, error) {
+	return is.currenceBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+}
+
+func (is *InstrumentsServiceClient) currenceBy(ctx context.Context, id string, idType pb.InstrumentIdType, classCode string) (*CurrencyResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.CurrencyBy(is.ctx, &pb.InstrumentRequest{
+	resp, err := is.pbClient.CurrencyBy(ctx, &pb.InstrumentRequest{
 		IdType:    idType,
 		ClassCode: classCode,
 		Id:        id,
@@ -140,8 +196,13 @@ func (is *InstrumentsServiceClient) currenceBy(id string, idType pb.InstrumentId
 
 // Currencies - Метод получения списка валют
 func (is *InstrumentsServiceClient) Currencies(status pb.InstrumentStatus) (*CurrenciesResponse, error) {
+	return is.CurrenciesWithContext(is.ctx, status)
+}
+
+// CurrenciesWithContext - Метод получения списка валют
+func (is *InstrumentsServiceClient) CurrenciesWithContext(ctx context.Context, status pb.InstrumentStatus) (*CurrenciesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.Currencies(is.ctx, &pb.InstrumentsRequest{
+	resp, err := is.pbClient.Currencies(ctx, &pb.InstrumentsRequest{
 		InstrumentStatus: status,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -155,27 +216,47 @@ func (is *InstrumentsServiceClient) Currencies(status pb.InstrumentStatus) (*Cur
 
 // EtfByFigi - Метод получения инвестиционного фонда по Figi
 func (is *InstrumentsServiceClient) EtfByFigi(id string) (*EtfResponse, error) {
-	return is.etfBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
+	return is.EtfByFigiWithContext(is.ctx, id)
+}
+
+// EtfByFigiWithContext - Метод получения инвестиционного фонда по Figi
+func (is *InstrumentsServiceClient) EtfByFigiWithContext(ctx context.Context, id string) (*EtfResponse, error) {
+	return is.etfBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
 }
 
 // EtfByTicker - Метод получения инвестиционного фонда по Ticker
 func (is *InstrumentsServiceClient) EtfByTicker(id string, classCode string) (*EtfResponse, error) {
-	return is.etfBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
+	return is.EtfByTickerWithContext(is.ctx, id, classCode)
+}
+
+// EtfByTickerWithContext - Метод получения инвестиционного фонда по Ticker
+func (is *InstrumentsServiceClient) EtfByTickerWithContext(ctx context.Context, id string, classCode string) (*EtfResponse, error) {
+	return is.etfBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
 }
 
 // EtfByUid - Метод получения инвестиционного фонда по Uid
 func (is *InstrumentsServiceClient) EtfByUid(id string) (*EtfResponse, error) {
-	return is.etfBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
+	return is.EtfByUidWithContext(is.ctx, id)
+}
+
+// EtfByUidWithContext - Метод получения инвестиционного фонда по Uid
+func (is *InstrumentsServiceClient) EtfByUidWithContext(ctx context.Context, id string) (*EtfResponse, error) {
+	return is.etfBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
 }
 
 // EtfByPositionUid - Метод получения инвестиционного фонда по PositionUid
 func (is *InstrumentsServiceClient) EtfByPositionUid(id string) (*EtfResponse, error) {
-	return is.etfBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+	return is.EtfByPositionUidWithContext(is.ctx, id)
 }
 
-func (is *InstrumentsServiceClient) etfBy(id string, idType pb.InstrumentIdType, classCode string) (*EtfResponse, error) {
+// EtfByPositionUidWithContext - Метод получения инвестиционного фонда по PositionUid
+func (is *InstrumentsServiceClient) EtfByPositionUidWithContext(ctx context.Context, id string) (*EtfResponse, error) {
+	return is.etfBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+}
+
+func (is *InstrumentsServiceClient) etfBy(ctx context.Context, id string, idType pb.InstrumentIdType, classCode string) (*EtfResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.EtfBy(is.ctx, &pb.InstrumentRequest{
+	resp, err := is.pbClient.EtfBy(ctx, &pb.InstrumentRequest{
 		IdType:    idType,
 		ClassCode: classCode,
 		Id:        id,
@@ -191,8 +272,13 @@ func (is *InstrumentsServiceClient) etfBy(id string, idType pb.InstrumentIdType,
 
 // Etfs - Метод получения списка инвестиционных фондов
 func (is *InstrumentsServiceClient) Etfs(status pb.InstrumentStatus) (*EtfsResponse, error) {
+	return is.EtfsWithContext(is.ctx, status)
+}
+
+// EtfsWithContext - Метод получения списка инвестиционных фондов
+func (is *InstrumentsServiceClient) EtfsWithContext(ctx context.Context, status pb.InstrumentStatus) (*EtfsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.Etfs(is.ctx, &pb.InstrumentsRequest{
+	resp, err := is.pbClient.Etfs(ctx, &pb.InstrumentsRequest{
 		InstrumentStatus: status,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -206,27 +292,47 @@ func (is *InstrumentsServiceClient) Etfs(status pb.InstrumentStatus) (*EtfsRespo
 
 // FutureByFigi - Метод получения фьючерса по Figi
 func (is *InstrumentsServiceClient) FutureByFigi(id string) (*FutureResponse, error) {
-	return is.futureBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
+	return is.FutureByFigiWithContext(is.ctx, id)
+}
+
+// FutureByFigiWithContext - Метод получения фьючерса по Figi
+func (is *InstrumentsServiceClient) FutureByFigiWithContext(ctx context.Context, id string) (*FutureResponse, error) {
+	return is.futureBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
 }
 
 // FutureByTicker - Метод получения фьючерса по Ticker
 func (is *InstrumentsServiceClient) FutureByTicker(id string, classCode string) (*FutureResponse, error) {
-	return is.futureBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
+	return is.FutureByTickerWithContext(is.ctx, id, classCode)
+}
+
+// FutureByTickerWithContext - Метод получения фьючерса по Ticker
+func (is *InstrumentsServiceClient) FutureByTickerWithContext(ctx context.Context, id string, classCode string) (*FutureResponse, error) {
+	return is.futureBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
 }
 
 // FutureByUid - Метод получения фьючерса по Uid
 func (is *InstrumentsServiceClient) FutureByUid(id string) (*FutureResponse, error) {
-	return is.futureBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
+	return is.FutureByUidWithContext(is.ctx, id)
+}
+
+// FutureByUidWithContext - Метод получения фьючерса по Uid
+func (is *InstrumentsServiceClient) FutureByUidWithContext(ctx context.Context, id string) (*FutureResponse, error) {
+	return is.futureBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
 }
 
 // FutureByPositionUid - Метод получения фьючерса по PositionUid
 func (is *InstrumentsServiceClient) FutureByPositionUid(id string) (*FutureResponse, error) {
-	return is.futureBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+	return is.FutureByPositionUidWithContext(is.ctx, id)
 }
 
-func (is *InstrumentsServiceClient) futureBy(id string, idType pb.InstrumentIdType, classCode string) (*FutureResponse, error) {
+// FutureByPositionUidWithContext - Метод получения фьючерса по PositionUid
+func (is *InstrumentsServiceClient) FutureByPositionUidWithContext(ctx context.Context, id string) (*FutureResponse, error) {
+	return is.futureBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+}
+
+func (is *InstrumentsServiceClient) futureBy(ctx context.Context, id string, idType pb.InstrumentIdType, classCode string) (*FutureResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.FutureBy(is.ctx, &pb.InstrumentRequest{
+	resp, err := is.pbClient.FutureBy(ctx, &pb.InstrumentRequest{
 		IdType:    idType,
 		ClassCode: classCode,
 		Id:        id,
@@ -242,8 +348,13 @@ func (is *InstrumentsServiceClient) futureBy(id string, idType pb.InstrumentIdTy
 
 // Futures - Метод получения списка фьючерсов
 func (is *InstrumentsServiceClient) Futures(status pb.InstrumentStatus) (*FuturesResponse, error) {
+	return is.FuturesWithContext(is.ctx, status)
+}
+
+// FuturesWithContext - Метод получения списка фьючерсов
+func (is *InstrumentsServiceClient) FuturesWithContext(ctx context.Context, status pb.InstrumentStatus) (*FuturesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.Futures(is.ctx, &pb.InstrumentsRequest{
+	resp, err := is.pbClient.Futures(ctx, &pb.InstrumentsRequest{
 		InstrumentStatus: status,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -257,22 +368,37 @@ func (is *InstrumentsServiceClient) Futures(status pb.InstrumentStatus) (*Future
 
 // OptionByTicker - Метод получения опциона по Ticker
 func (is *InstrumentsServiceClient) OptionByTicker(id string, classCode string) (*OptionResponse, error) {
-	return is.optionBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
+	return is.OptionByTickerWithContext(is.ctx, id, classCode)
+}
+
+// OptionByTickerWithContext - Метод получения опциона по Ticker
+func (is *InstrumentsServiceClient) OptionByTickerWithContext(ctx context.Context, id string, classCode string) (*OptionResponse, error) {
+	return is.optionBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
 }
 
 // OptionByUid - Метод получения опциона по Uid
 func (is *InstrumentsServiceClient) OptionByUid(id string) (*OptionResponse, error) {
-	return is.optionBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
+	return is.OptionByUidWithContext(is.ctx, id)
+}
+
+// OptionByUidWithContext - Метод получения опциона по Uid
+func (is *InstrumentsServiceClient) OptionByUidWithContext(ctx context.Context, id string) (*OptionResponse, error) {
+	return is.optionBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
 }
 
 // OptionByPositionUid - Метод получения опциона по PositionUid
 func (is *InstrumentsServiceClient) OptionByPositionUid(id string) (*OptionResponse, error) {
-	return is.optionBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+	return is.OptionByPositionUidWithContext(is.ctx, id)
+}
+
+// OptionByPositionUidWithContext - Метод получения опциона по PositionUid
+func (is *InstrumentsServiceClient) OptionByPositionUidWithContext(ctx context.Context, id string) (*OptionResponse, error) {
+	return is.optionBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
 }
 
-func (is *InstrumentsServiceClient) optionBy(id string, idType pb.InstrumentIdType, classCode string) (*OptionResponse, error) {
+func (is *InstrumentsServiceClient) optionBy(ctx context.Context, id string, idType pb.InstrumentIdType, classCode string) (*OptionResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.OptionBy(is.ctx, &pb.InstrumentRequest{
+	resp, err := is.pbClient.OptionBy(ctx, &pb.InstrumentRequest{
 		IdType:    idType,
 		ClassCode: classCode,
 		Id:        id,
@@ -290,8 +416,15 @@ func (is *InstrumentsServiceClient) optionBy(id string, idType pb.InstrumentIdTy
 //
 // Deprecated: Do not use
 func (is *InstrumentsServiceClient) Options(status pb.InstrumentStatus) (*OptionsResponse, error) {
+	return is.OptionsWithContext(is.ctx, status)
+}
+
+// OptionsWithContext - Метод получения списка опционов
+//
+// Deprecated: Do not use
+func (is *InstrumentsServiceClient) OptionsWithContext(ctx context.Context, status pb.InstrumentStatus) (*OptionsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.Options(is.ctx, &pb.InstrumentsRequest{
+	resp, err := is.pbClient.Options(ctx, &pb.InstrumentsRequest{
 		InstrumentStatus: status,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -305,27 +438,47 @@ func (is *InstrumentsServiceClient) Options(status pb.InstrumentStatus) (*Option
 
 // ShareByFigi - Метод получения акции по Figi
 func (is *InstrumentsServiceClient) ShareByFigi(id string) (*ShareResponse, error) {
-	return is.shareBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
+	return is.ShareByFigiWithContext(is.ctx, id)
+}
+
+// ShareByFigiWithContext - Метод получения акции по Figi
+func (is *InstrumentsServiceClient) ShareByFigiWithContext(ctx context.Context, id string) (*ShareResponse, error) {
+	return is.shareBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
 }
 
 // ShareByTicker - Метод получения акции по Ticker
 func (is *InstrumentsServiceClient) ShareByTicker(id string, classCode string) (*ShareResponse, error) {
-	return is.shareBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
+	return is.ShareByTickerWithContext(is.ctx, id, classCode)
+}
+
+// ShareByTickerWithContext - Метод получения акции по Ticker
+func (is *InstrumentsServiceClient) ShareByTickerWithContext(ctx context.Context, id string, classCode string) (*ShareResponse, error) {
+	return is.shareBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
 }
 
 // ShareByUid - Метод получения акции по Uid
 func (is *InstrumentsServiceClient) ShareByUid(id string) (*ShareResponse, error) {
-	return is.shareBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
+	return is.ShareByUidWithContext(is.ctx, id)
+}
+
+// ShareByUidWithContext - Метод получения акции по Uid
+func (is *InstrumentsServiceClient) ShareByUidWithContext(ctx context.Context, id string) (*ShareResponse, error) {
+	return is.shareBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
 }
 
 // ShareByPositionUid - Метод получения акции по PositionUid
 func (is *InstrumentsServiceClient) ShareByPositionUid(id string) (*ShareResponse, error) {
-	return is.shareBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+	return is.ShareByPositionUidWithContext(is.ctx, id)
 }
 
-func (is *InstrumentsServiceClient) shareBy(id string, idType pb.InstrumentIdType, classCode string) (*ShareResponse, error) {
+// ShareByPositionUidWithContext - Метод получения акции по PositionUid
+func (is *InstrumentsServiceClient) ShareByPositionUidWithContext(ctx context.Context, id string) (*ShareResponse, error) {
+	return is.shareBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+}
+
+func (is *InstrumentsServiceClient) shareBy(ctx context.Context, id string, idType pb.InstrumentIdType, classCode string) (*ShareResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.ShareBy(is.ctx, &pb.InstrumentRequest{
+	resp, err := is.pbClient.ShareBy(ctx, &pb.InstrumentRequest{
 		IdType:    idType,
 		ClassCode: classCode,
 		Id:        id,
@@ -341,8 +494,13 @@ func (is *InstrumentsServiceClient) shareBy(id string, idType pb.InstrumentIdTyp
 
 // Shares - Метод получения списка акций
 func (is *InstrumentsServiceClient) Shares(status pb.InstrumentStatus) (*SharesResponse, error) {
+	return is.SharesWithContext(is.ctx, status)
+}
+
+// SharesWithContext - Метод получения списка акций
+func (is *InstrumentsServiceClient) SharesWithContext(ctx context.Context, status pb.InstrumentStatus) (*SharesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.Shares(is.ctx, &pb.InstrumentsRequest{
+	resp, err := is.pbClient.Shares(ctx, &pb.InstrumentsRequest{
 		InstrumentStatus: status,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -356,27 +514,72 @@ func (is *InstrumentsServiceClient) Shares(status pb.InstrumentStatus) (*SharesR
 
 // InstrumentByFigi - Метод получения основной информации об инструменте
 func (is *InstrumentsServiceClient) InstrumentByFigi(id string) (*InstrumentResponse, error) {
-	return is.instrumentBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
+	return is.InstrumentByFigiWithContext(is.ctx, id)
+}
+
+// InstrumentByFigiWithContext - Метод получения основной информации об инструменте
+func (is *InstrumentsServiceClient) InstrumentByFigiWithContext(ctx context.Context, id string) (*InstrumentResponse, error) {
+	return is.instrumentBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, "")
 }
 
 // InstrumentByTicker - Метод получения основной информации об инструменте
 func (is *InstrumentsServiceClient) InstrumentByTicker(id string, classCode string) (*InstrumentResponse, error) {
-	return is.instrumentBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
+	return is.InstrumentByTickerWithContext(is.ctx, id, classCode)
+}
+
+// InstrumentByTickerWithContext - Метод получения основной информации об инструменте
+func (is *InstrumentsServiceClient) InstrumentByTickerWithContext(ctx context.Context, id string, classCode string) (*InstrumentResponse, error) {
+	return is.instrumentBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER, classCode)
 }
 
 // InstrumentByUid - Метод получения основной информации об инструменте
 func (is *InstrumentsServiceClient) InstrumentByUid(id string) (*InstrumentResponse, error) {
-	return is.instrumentBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
+	return is.InstrumentByUidWithContext(is.ctx, id)
+}
+
+// InstrumentByUidWithContext - Метод получения основной информации об инструменте
+func (is *InstrumentsServiceClient) InstrumentByUidWithContext(ctx context.Context, id string) (*InstrumentResponse, error) {
+	return is.instrumentBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID, "")
+}
+
+// Instrument - Основная информация об инструменте по figi или instrument_uid
+func (is *InstrumentsServiceClient) Instrument(instrumentId string) (*pb.Instrument, error) {
+	return is.InstrumentWithContext(is.ctx, instrumentId)
+}
+
+// InstrumentWithContext - Основная информация об инструменте по figi или instrument_uid
+func (is *InstrumentsServiceClient) InstrumentWithContext(ctx context.Context, instrumentId string) (*pb.Instrument, error) {
+	var resp *InstrumentResponse
+	var err error
+	if _, parseErr := uuid.Parse(instrumentId); parseErr == nil {
+		resp, err = is.InstrumentByUidWithContext(ctx, instrumentId)
+	} else {
+		resp, err = is.InstrumentByFigiWithContext(ctx, instrumentId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetInstrument(), nil
 }
 
 // InstrumentByPositionUid - Метод получения основной информации об инструменте
 func (is *InstrumentsServiceClient) InstrumentByPositionUid(id string) (*InstrumentResponse, error) {
-	return is.instrumentBy(id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
+	return is.InstrumentByPositionUidWithContext(is.ctx, id)
+}
+
+// InstrumentByPositionUidWithContext - Метод получения основной информации об инструменте
+func (is *InstrumentsServiceClient) InstrumentByPositionUidWithContext(ctx context.Context, id string) (*InstrumentResponse, error) {
+	return is.instrumentBy(ctx, id, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_POSITION_UID, "")
 }
 
 // LotByUid - Метод получения лотности инструмента по его Uid
 func (is *InstrumentsServiceClient) LotByUid(uid string) (int64, error) {
-	resp, err := is.InstrumentByUid(uid)
+	return is.LotByUidWithContext(is.ctx, uid)
+}
+
+// LotByUidWithContext - Метод получения лотности инструмента по его Uid
+func (is *InstrumentsServiceClient) LotByUidWithContext(ctx context.Context, uid string) (int64, error) {
+	resp, err := is.InstrumentByUidWithContext(ctx, uid)
 	if err != nil {
 		return 0, err
 	}
@@ -385,16 +588,21 @@ func (is *InstrumentsServiceClient) LotByUid(uid string) (int64, error) {
 
 // LotByFigi - Метод получения лотности инструмента по его FIGI
 func (is *InstrumentsServiceClient) LotByFigi(figi string) (int64, error) {
-	resp, err := is.InstrumentByFigi(figi)
+	return is.LotByFigiWithContext(is.ctx, figi)
+}
+
+// LotByFigiWithContext - Метод получения лотности инструмента по его FIGI
+func (is *InstrumentsServiceClient) LotByFigiWithContext(ctx context.Context, figi string) (int64, error) {
+	resp, err := is.InstrumentByFigiWithContext(ctx, figi)
 	if err != nil {
 		return 0, err
 	}
 	return int64(resp.GetInstrument().GetLot()), nil
 }
 
-func (is *InstrumentsServiceClient) instrumentBy(id string, idType pb.InstrumentIdType, classCode string) (*InstrumentResponse, error) {
+func (is *InstrumentsServiceClient) instrumentBy(ctx context.Context, id string, idType pb.InstrumentIdType, classCode string) (*InstrumentResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetInstrumentBy(is.ctx, &pb.InstrumentRequest{
+	resp, err := is.pbClient.GetInstrumentBy(ctx, &pb.InstrumentRequest{
 		IdType:    idType,
 		ClassCode: classCode,
 		Id:        id,
@@ -410,8 +618,13 @@ func (is *InstrumentsServiceClient) instrumentBy(id string, idType pb.Instrument
 
 // GetAccruedInterests - Метод получения накопленного купонного дохода по облигации
 func (is *InstrumentsServiceClient) GetAccruedInterests(figi string, from, to time.Time) (*GetAccruedInterestsResponse, error) {
+	return is.GetAccruedInterestsWithContext(is.ctx, figi, from, to)
+}
+
+// GetAccruedInterestsWithContext - Метод получения накопленного купонного дохода по облигации
+func (is *InstrumentsServiceClient) GetAccruedInterestsWithContext(ctx context.Context, figi string, from, to time.Time) (*GetAccruedInterestsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetAccruedInterests(is.ctx, &pb.GetAccruedInterestsRequest{
+	resp, err := is.pbClient.GetAccruedInterests(ctx, &pb.GetAccruedInterestsRequest{
 		Figi: figi,
 		From: TimeToTimestamp(from),
 		To:   TimeToTimestamp(to),
@@ -427,8 +640,13 @@ func (is *InstrumentsServiceClient) GetAccruedInterests(figi string, from, to ti
 
 // GetFuturesMargin - Метод получения размера гарантийного обеспечения по фьючерсам
 func (is *InstrumentsServiceClient) GetFuturesMargin(figi string) (*GetFuturesMarginResponse, error) {
+	return is.GetFuturesMarginWithContext(is.ctx, figi)
+}
+
+// GetFuturesMarginWithContext - Метод получения размера гарантийного обеспечения по фьючерсам
+func (is *InstrumentsServiceClient) GetFuturesMarginWithContext(ctx context.Context, figi string) (*GetFuturesMarginResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetFuturesMargin(is.ctx, &pb.GetFuturesMarginRequest{
+	resp, err := is.pbClient.GetFuturesMargin(ctx, &pb.GetFuturesMarginRequest{
 		Figi: figi,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -442,8 +660,13 @@ func (is *InstrumentsServiceClient) GetFuturesMargin(figi string) (*GetFuturesMa
 
 // GetDividents - Метод для получения событий выплаты дивидендов по инструменту
 func (is *InstrumentsServiceClient) GetDividents(figi string, from, to time.Time) (*GetDividendsResponse, error) {
+	return is.GetDividentsWithContext(is.ctx, figi, from, to)
+}
+
+// GetDividentsWithContext - Метод для получения событий выплаты дивидендов по инструменту
+func (is *InstrumentsServiceClient) GetDividentsWithContext(ctx context.Context, figi string, from, to time.Time) (*GetDividendsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetDividends(is.ctx, &pb.GetDividendsRequest{
+	resp, err := is.pbClient.GetDividends(ctx, &pb.GetDividendsRequest{
 		Figi: figi,
 		From: TimeToTimestamp(from),
 		To:   TimeToTimestamp(to),
@@ -459,8 +682,13 @@ func (is *InstrumentsServiceClient) GetDividents(figi string, from, to time.Time
 
 // GetAssetBy - Метод получения актива по его uid идентификатору.
 func (is *InstrumentsServiceClient) GetAssetBy(id string) (*AssetResponse, error) {
+	return is.GetAssetByWithContext(is.ctx, id)
+}
+
+// GetAssetByWithContext - Метод получения актива по его uid идентификатору.
+func (is *InstrumentsServiceClient) GetAssetByWithContext(ctx context.Context, id string) (*AssetResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetAssetBy(is.ctx, &pb.AssetRequest{
+	resp, err := is.pbClient.GetAssetBy(ctx, &pb.AssetRequest{
 		Id: id,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -474,8 +702,13 @@ func (is *InstrumentsServiceClient) GetAssetBy(id string) (*AssetResponse, error
 
 // GetAssets - Метод получения списка активов
 func (is *InstrumentsServiceClient) GetAssets() (*AssetsResponse, error) {
+	return is.GetAssetsWithContext(is.ctx)
+}
+
+// GetAssetsWithContext - Метод получения списка активов
+func (is *InstrumentsServiceClient) GetAssetsWithContext(ctx context.Context) (*AssetsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetAssets(is.ctx, &pb.AssetsRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
+	resp, err := is.pbClient.GetAssets(ctx, &pb.AssetsRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
 		header = trailer
 	}
@@ -487,8 +720,13 @@ func (is *InstrumentsServiceClient) GetAssets() (*AssetsResponse, error) {
 
 // GetFavorites - Метод получения списка избранных инструментов
 func (is *InstrumentsServiceClient) GetFavorites() (*GetFavoritesResponse, error) {
+	return is.GetFavoritesWithContext(is.ctx)
+}
+
+// GetFavoritesWithContext - Метод получения списка избранных инструментов
+func (is *InstrumentsServiceClient) GetFavoritesWithContext(ctx context.Context) (*GetFavoritesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetFavorites(is.ctx, &pb.GetFavoritesRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
+	resp, err := is.pbClient.GetFavorites(ctx, &pb.GetFavoritesRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
 		header = trailer
 	}
@@ -500,12 +738,17 @@ func (is *InstrumentsServiceClient) GetFavorites() (*GetFavoritesResponse, error
 
 // EditFavorites - Метод редактирования списка избранных инструментов
 func (is *InstrumentsServiceClient) EditFavorites(instruments []string, actionType pb.EditFavoritesActionType) (*EditFavoritesResponse, error) {
+	return is.EditFavoritesWithContext(is.ctx, instruments, actionType)
+}
+
+// EditFavoritesWithContext - Метод редактирования списка избранных инструментов
+func (is *InstrumentsServiceClient) EditFavoritesWithContext(ctx context.Context, instruments []string, actionType pb.EditFavoritesActionType) (*EditFavoritesResponse, error) {
 	var header, trailer metadata.MD
 	ids := make([]*pb.EditFavoritesRequestInstrument, 0, len(instruments))
 	for _, id := range instruments {
 		ids = append(ids, &pb.EditFavoritesRequestInstrument{Figi: id})
 	}
-	resp, err := is.pbClient.EditFavorites(is.ctx, &pb.EditFavoritesRequest{
+	resp, err := is.pbClient.EditFavorites(ctx, &pb.EditFavoritesRequest{
 		Instruments: ids,
 		ActionType:  actionType,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
@@ -520,8 +763,13 @@ func (is *InstrumentsServiceClient) EditFavorites(instruments []string, actionTy
 
 // GetCountries - Метод получения списка стран
 func (is *InstrumentsServiceClient) GetCountries() (*GetCountriesResponse, error) {
+	return is.GetCountriesWithContext(is.ctx)
+}
+
+// GetCountriesWithContext - Метод получения списка стран
+func (is *InstrumentsServiceClient) GetCountriesWithContext(ctx context.Context) (*GetCountriesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetCountries(is.ctx, &pb.GetCountriesRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
+	resp, err := is.pbClient.GetCountries(ctx, &pb.GetCountriesRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
 		header = trailer
 	}
@@ -533,8 +781,13 @@ func (is *InstrumentsServiceClient) GetCountries() (*GetCountriesResponse, error
 
 // GetBrands - Метод получения списка брендов
 func (is *InstrumentsServiceClient) GetBrands() (*GetBrandsResponse, error) {
+	return is.GetBrandsWithContext(is.ctx)
+}
+
+// GetBrandsWithContext - Метод получения списка брендов
+func (is *InstrumentsServiceClient) GetBrandsWithContext(ctx context.Context) (*GetBrandsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetBrands(is.ctx, &pb.GetBrandsRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
+	resp, err := is.pbClient.GetBrands(ctx, &pb.GetBrandsRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
 		header = trailer
 	}
@@ -546,8 +799,13 @@ func (is *InstrumentsServiceClient) GetBrands() (*GetBrandsResponse, error) {
 
 // GetBrandBy - Метод получения бренда по его uid идентификатору
 func (is *InstrumentsServiceClient) GetBrandBy(id string) (*Brand, error) {
+	return is.GetBrandByWithContext(is.ctx, id)
+}
+
+// GetBrandByWithContext - Метод получения бренда по его uid идентификатору
+func (is *InstrumentsServiceClient) GetBrandByWithContext(ctx context.Context, id string) (*Brand, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.GetBrandBy(is.ctx, &pb.GetBrandRequest{
+	resp, err := is.pbClient.GetBrandBy(ctx, &pb.GetBrandRequest{
 		Id: id,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -561,8 +819,13 @@ func (is *InstrumentsServiceClient) GetBrandBy(id string) (*Brand, error) {
 
 // FindInstrument - Метод поиска инструмента, например по тикеру или названию компании
 func (is *InstrumentsServiceClient) FindInstrument(query string) (*FindInstrumentResponse, error) {
+	return is.FindInstrumentWithContext(is.ctx, query)
+}
+
+// FindInstrumentWithContext - Метод поиска инструмента, например по тикеру или названию компании
+func (is *InstrumentsServiceClient) FindInstrumentWithContext(ctx context.Context, query string) (*FindInstrumentResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := is.pbClient.FindInstrument(is.ctx, &pb.FindInstrumentRequest{
+	resp, err := is.pbClient.FindInstrument(ctx, &pb.FindInstrumentRequest{
 		Query: query,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -0,0 +1,157 @@
+package investgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Logger - Логгер клиента. Если логгер реализует LeveledLogger, SDK пишет в него структурированные сообщения
+type Logger interface {
+	Infof(template string, args ...any)
+	Errorf(template string, args ...any)
+	Fatalf(template string, args ...any)
+}
+
+// LeveledLogger - Логгер с уровнями и полями. Поля передаются парами ключ-значение, как в zap.SugaredLogger,
+// который реализует этот интерфейс без адаптера. Адаптеры для zap.Logger и slog.Logger - в пакете investgo/logging
+type LeveledLogger interface {
+	Logger
+	Debugw(msg string, keysAndValues ...any)
+	Infow(msg string, keysAndValues ...any)
+	Warnw(msg string, keysAndValues ...any)
+	Errorw(msg string, keysAndValues ...any)
+}
+
+// Ключи полей, которые SDK добавляет в сообщения логгера
+const (
+	FieldMethod       = "method"
+	FieldInstrumentId = "instrument_id"
+	FieldAccountId    = "account_id"
+	FieldOrderId      = "order_id"
+	FieldRequestId    = "order_request_id"
+	FieldTrackingId   = "tracking_id"
+	FieldAttempt      = "attempt"
+	FieldWait         = "wait"
+	FieldError        = "error"
+)
+
+// logDebug - отладочное сообщение, логгер без уровней его не получает
+func logDebug(l Logger, msg string, keysAndValues ...any) {
+	if ll, ok := l.(LeveledLogger); ok {
+		ll.Debugw(msg, keysAndValues...)
+	}
+}
+
+// logInfo - информационное сообщение
+func logInfo(l Logger, msg string, keysAndValues ...any) {
+	if ll, ok := l.(LeveledLogger); ok {
+		ll.Infow(msg, keysAndValues...)
+		return
+	}
+	l.Infof("%s", formatFields(msg, keysAndValues))
+}
+
+// logWarn - предупреждение, логгер без уровней получает его через Infof
+func logWarn(l Logger, msg string, keysAndValues ...any) {
+	if ll, ok := l.(LeveledLogger); ok {
+		ll.Warnw(msg, keysAndValues...)
+		return
+	}
+	l.Infof("%s", formatFields(msg, keysAndValues))
+}
+
+// logError - сообщение об ошибке
+func logError(l Logger, msg string, keysAndValues ...any) {
+	if ll, ok := l.(LeveledLogger); ok {
+		ll.Errorw(msg, keysAndValues...)
+		return
+	}
+	l.Errorf("%s", formatFields(msg, keysAndValues))
+}
+
+// formatFields - сообщение с полями в виде "msg key=value key=value" для логгера без полей
+func formatFields(msg string, keysAndValues []any) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		b.WriteString(" ")
+		if i+1 < len(keysAndValues) {
+			fmt.Fprintf(&b, "%v=%v", keysAndValues[i], keysAndValues[i+1])
+		} else {
+			fmt.Fprintf(&b, "%v", keysAndValues[i])
+		}
+	}
+	return b.String()
+}
+
+// requestFields - поля сообщения из запроса: идентификаторы счета, инструмента и заявки, если они есть в запросе
+func requestFields(req any) []any {
+	m, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+	r := m.ProtoReflect()
+	fields := r.Descriptor().Fields()
+	var kv []any
+	added := make(map[string]bool)
+	for _, f := range []struct {
+		name protoreflect.Name
+		key  string
+	}{
+		{"account_id", FieldAccountId},
+		{"instrument_id", FieldInstrumentId},
+		{"figi", FieldInstrumentId},
+		{"order_id", FieldOrderId},
+		{"stop_order_id", FieldOrderId},
+	} {
+		fd := fields.ByName(f.name)
+		if added[f.key] || fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+			continue
+		}
+		if v := r.Get(fd).String(); v != "" {
+			kv = append(kv, f.key, v)
+			added[f.key] = true
+		}
+	}
+	return kv
+}
+
+// expectedCodes - коды ошибок, которые приложение получает в обычной работе: заявка уже исполнена или отменена,
+// запрос отменен самим приложением. Такие ошибки логируются на уровне debug
+var expectedCodes = map[codes.Code]struct{}{
+	codes.NotFound: {},
+	codes.Canceled: {},
+}
+
+// loggingUnaryInterceptor - логирование unary-запросов: успешные и ошибки из expectedCodes на уровне debug,
+// остальные ошибки на уровне warn
+func loggingUnaryInterceptor(l Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var header, trailer metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header), grpc.Trailer(&trailer))...)
+		kv := append([]any{FieldMethod, method}, requestFields(req)...)
+		if trackingId := firstValue(metadata.Join(header, trailer), "x-tracking-id"); trackingId != "" {
+			kv = append(kv, FieldTrackingId, trackingId)
+		}
+		if err != nil {
+			code := status.Code(err)
+			kv = append(kv, "code", code.String(), FieldError, err)
+			if _, ok := expectedCodes[code]; ok {
+				logDebug(l, "request failed", kv...)
+			} else {
+				logWarn(l, "request failed", kv...)
+			}
+			return err
+		}
+		logDebug(l, "request", kv...)
+		return nil
+	}
+}
@@ -0,0 +1,168 @@
+package investgo_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type logEntry struct {
+	level  string
+	msg    string
+	code   string
+	fields map[string]any
+}
+
+// recordingLogger - LeveledLogger, который запоминает сообщения
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) record(level, msg string, kv []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := logEntry{level: level, msg: msg, fields: make(map[string]any)}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i] == "code" {
+			e.code = fmt.Sprint(kv[i+1])
+		}
+		e.fields[fmt.Sprint(kv[i])] = kv[i+1]
+	}
+	l.entries = append(l.entries, e)
+}
+
+func (l *recordingLogger) failures() []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var res []logEntry
+	for _, e := range l.entries {
+		if e.msg == "request failed" || e.level == "infof" {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// find - Первое сообщение msg
+func (l *recordingLogger) find(msg string) (logEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		if e.msg == msg {
+			return e, true
+		}
+	}
+	return logEntry{}, false
+}
+
+func (l *recordingLogger) reset() {
+	l.mu.Lock()
+	l.entries = nil
+	l.mu.Unlock()
+}
+
+func (l *recordingLogger) Infof(template string, args ...any) {
+	l.record("infof", fmt.Sprintf(template, args...), nil)
+}
+func (l *recordingLogger) Errorf(template string, args ...any) {
+	l.record("errorf", fmt.Sprintf(template, args...), nil)
+}
+func (l *recordingLogger) Fatalf(template string, args ...any) {
+	l.record("fatalf", fmt.Sprintf(template, args...), nil)
+}
+func (l *recordingLogger) Debugw(msg string, kv ...any) { l.record("debug", msg, kv) }
+func (l *recordingLogger) Infow(msg string, kv ...any)  { l.record("info", msg, kv) }
+func (l *recordingLogger) Warnw(msg string, kv ...any)  { l.record("warn", msg, kv) }
+func (l *recordingLogger) Errorw(msg string, kv ...any) { l.record("error", msg, kv) }
+
+// plainLogger - Logger без уровней
+type plainLogger struct {
+	rec *recordingLogger
+}
+
+func (l plainLogger) Infof(template string, args ...any)  { l.rec.Infof(template, args...) }
+func (l plainLogger) Errorf(template string, args ...any) { l.rec.Errorf(template, args...) }
+func (l plainLogger) Fatalf(template string, args ...any) { l.rec.Fatalf(template, args...) }
+
+func TestLoggingFailedRequests(t *testing.T) {
+	srv := newTestServer(t)
+	rec := &recordingLogger{}
+	client, err := investgo.NewClient(context.Background(), srv.Config(), rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+	orders := client.NewOrdersServiceClient()
+
+	// заявки нет - ожидаемая ошибка
+	_, err = orders.GetOrderState(srv.AccountId(), "unknown")
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("err = %v, want NotFound", err)
+	}
+	if got := rec.failures(); len(got) != 1 || got[0].level != "debug" || got[0].code != "NotFound" {
+		t.Fatalf("log = %+v, want one debug entry with NotFound", got)
+	}
+
+	rec.reset()
+	srv.InjectError("UsersService/GetInfo", status.Error(codes.PermissionDenied, "denied"))
+	if _, err := client.NewUsersServiceClient().GetInfo(); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("err = %v, want PermissionDenied", err)
+	}
+	if got := rec.failures(); len(got) != 1 || got[0].level != "warn" || got[0].code != "PermissionDenied" {
+		t.Fatalf("log = %+v, want one warn entry with PermissionDenied", got)
+	}
+}
+
+func TestLoggingPlainLoggerSkipsExpected(t *testing.T) {
+	srv := newTestServer(t)
+	rec := &recordingLogger{}
+	client, err := investgo.NewClient(context.Background(), srv.Config(), plainLogger{rec: rec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+	rec.reset()
+
+	_, err = client.NewOrdersServiceClient().GetOrderState(srv.AccountId(), "unknown")
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("err = %v, want NotFound", err)
+	}
+	if got := rec.failures(); len(got) != 0 {
+		t.Fatalf("log = %+v, want no entries for NotFound", got)
+	}
+}
+
+func TestLoggingResourceExhaustedWait(t *testing.T) {
+	srv := newTestServer(t)
+	rec := &recordingLogger{}
+	client, err := investgo.NewClient(context.Background(), srv.Config(), rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	// до сброса лимита остается секунда, ретраер ждет ее и повторяет запрос
+	now := time.Date(2024, 1, 1, 10, 0, 59, 0, time.UTC)
+	srv.SetClock(func() time.Time { return now })
+	srv.InjectError("UsersService/GetInfo", status.Error(codes.ResourceExhausted, "exhausted"))
+	if _, err := client.NewUsersServiceClient().GetInfo(); err != nil {
+		t.Fatal(err)
+	}
+	e, ok := rec.find("resource exhausted, retry")
+	if !ok {
+		t.Fatal("no resource exhausted entry")
+	}
+	if _, ok := e.fields[investgo.FieldAttempt]; ok {
+		t.Fatalf("fields = %v, want wait instead of attempt", e.fields)
+	}
+	if wait, ok := e.fields[investgo.FieldWait].(time.Duration); !ok || wait != time.Second {
+		t.Fatalf("fields = %v, want wait 1s", e.fields)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+)
+
+// Slog - Адаптер slog.Logger, если l == nil используется slog.Default()
+func Slog(l *slog.Logger) investgo.LeveledLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s *slogLogger) Infof(template string, args ...any) {
+	s.l.Info(fmt.Sprintf(template, args...))
+}
+
+func (s *slogLogger) Errorf(template string, args ...any) {
+	s.l.Error(fmt.Sprintf(template, args...))
+}
+
+// Fatalf - Сообщение на уровне error и завершение программы, как у zap и log
+func (s *slogLogger) Fatalf(template string, args ...any) {
+	s.l.Error(fmt.Sprintf(template, args...))
+	os.Exit(1)
+}
+
+func (s *slogLogger) Debugw(msg string, keysAndValues ...any) {
+	s.l.Log(context.Background(), slog.LevelDebug, msg, keysAndValues...)
+}
+
+func (s *slogLogger) Infow(msg string, keysAndValues ...any) {
+	s.l.Log(context.Background(), slog.LevelInfo, msg, keysAndValues...)
+}
+
+func (s *slogLogger) Warnw(msg string, keysAndValues ...any) {
+	s.l.Log(context.Background(), slog.LevelWarn, msg, keysAndValues...)
+}
+
+func (s *slogLogger) Errorw(msg string, keysAndValues ...any) {
+	s.l.Log(context.Background(), slog.LevelError, msg, keysAndValues...)
+}
@@ -0,0 +1,21 @@
+/*
+Package logging содержит адаптеры логгеров zap и log/slog к интерфейсу investgo.LeveledLogger.
+
+	client, err := investgo.NewClient(ctx, config, logging.Zap(zapLogger))
+	client, err := investgo.NewClient(ctx, config, logging.Slog(slog.Default()))
+
+SDK пишет сообщения с полями method, instrument_id, account_id, order_id и tracking_id, поэтому их можно
+фильтровать по инструменту или счету.
+*/
+package logging
+
+import (
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"go.uber.org/zap"
+)
+
+// Zap - Адаптер zap.Logger. zap.SugaredLogger уже реализует investgo.LeveledLogger и передается в клиент без адаптера
+func Zap(l *zap.Logger) investgo.LeveledLogger {
+	// пропускаем внутреннюю функцию SDK, чтобы caller указывал на место логирования
+	return l.WithOptions(zap.AddCallerSkip(1)).Sugar()
+}
@@ -21,8 +21,13 @@ type MarketDataServiceClient struct {
 
 // GetCandles - Метод запроса исторических свечей по инструменту
 func (md *MarketDataServiceClient) GetCandles(instrumentId string, interval pb.CandleInterval, from, to time.Time) (*GetCandlesResponse, error) {
+	return md.GetCandlesWithContext(md.ctx, instrumentId, interval, from, to)
+}
+
+// GetCandlesWithContext - Метод запроса исторических свечей по инструменту
+func (md *MarketDataServiceClient) GetCandlesWithContext(ctx context.Context, instrumentId string, interval pb.CandleInterval, from, to time.Time) (*GetCandlesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := md.pbClient.GetCandles(md.ctx, &pb.GetCandlesRequest{
+	resp, err := md.pbClient.GetCandles(ctx, &pb.GetCandlesRequest{
 		From:         TimeToTimestamp(from),
 		To:           TimeToTimestamp(to),
 		Interval:     interval,
@@ -39,8 +44,13 @@ func (md *MarketDataServiceClient) GetCandles(instrumentId string, interval pb.C
 
 // GetLastPrices - Метод запроса цен последних сделок по инструментам
 func (md *MarketDataServiceClient) GetLastPrices(instrumentIds []string) (*GetLastPricesResponse, error) {
+	return md.GetLastPricesWithContext(md.ctx, instrumentIds)
+}
+
+// GetLastPricesWithContext - Метод запроса цен последних сделок по инструментам
+func (md *MarketDataServiceClient) GetLastPricesWithContext(ctx context.Context, instrumentIds []string) (*GetLastPricesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := md.pbClient.GetLastPrices(md.ctx, &pb.GetLastPricesRequest{
+	resp, err := md.pbClient.GetLastPrices(ctx, &pb.GetLastPricesRequest{
 		InstrumentId: instrumentIds,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -54,8 +64,13 @@ func (md *MarketDataServiceClient) GetLastPrices(instrumentIds []string) (*GetLa
 
 // GetOrderBook - Метод получения стакана по инструменту
 func (md *MarketDataServiceClient) GetOrderBook(instrumentId string, depth int32) (*GetOrderBookResponse, error) {
+	return md.GetOrderBookWithContext(md.ctx, instrumentId, depth)
+}
+
+// GetOrderBookWithContext - Метод получения стакана по инструменту
+func (md *MarketDataServiceClient) GetOrderBookWithContext(ctx context.Context, instrumentId string, depth int32) (*GetOrderBookResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := md.pbClient.GetOrderBook(md.ctx, &pb.GetOrderBookRequest{
+	resp, err := md.pbClient.GetOrderBook(ctx, &pb.GetOrderBookRequest{
 		Depth:        depth,
 		InstrumentId: instrumentId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
@@ -70,8 +85,13 @@ func (md *MarketDataServiceClient) GetOrderBook(instrumentId string, depth int32
 
 // GetTradingStatus - Метод запроса статуса торгов по инструменту
 func (md *MarketDataServiceClient) GetTradingStatus(instrumentId string) (*GetTradingStatusResponse, error) {
+	return md.GetTradingStatusWithContext(md.ctx, instrumentId)
+}
+
+// GetTradingStatusWithContext - Метод запроса статуса торгов по инструменту
+func (md *MarketDataServiceClient) GetTradingStatusWithContext(ctx context.Context, instrumentId string) (*GetTradingStatusResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := md.pbClient.GetTradingStatus(md.ctx, &pb.GetTradingStatusRequest{
+	resp, err := md.pbClient.GetTradingStatus(ctx, &pb.GetTradingStatusRequest{
 		InstrumentId: instrumentId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -85,8 +105,13 @@ func (md *MarketDataServiceClient) GetTradingStatus(instrumentId string) (*GetTr
 
 // GetTradingStatuses - Метод запроса статуса торгов по инструментам
 func (md *MarketDataServiceClient) GetTradingStatuses(instrumentIds []string) (*GetTradingStatusesResponse, error) {
+	return md.GetTradingStatusesWithContext(md.ctx, instrumentIds)
+}
+
+// GetTradingStatusesWithContext - Метод запроса статуса торгов по инструментам
+func (md *MarketDataServiceClient) GetTradingStatusesWithContext(ctx context.Context, instrumentIds []string) (*GetTradingStatusesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := md.pbClient.GetTradingStatuses(md.ctx, &pb.GetTradingStatusesRequest{
+	resp, err := md.pbClient.GetTradingStatuses(ctx, &pb.GetTradingStatusesRequest{
 		InstrumentId: instrumentIds,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -100,8 +125,13 @@ func (md *MarketDataServiceClient) GetTradingStatuses(instrumentIds []string) (*
 
 // GetLastTrades - Метод запроса обезличенных сделок за последний час
 func (md *MarketDataServiceClient) GetLastTrades(instrumentId string, from, to time.Time) (*GetLastTradesResponse, error) {
+	return md.GetLastTradesWithContext(md.ctx, instrumentId, from, to)
+}
+
+// GetLastTradesWithContext - Метод запроса обезличенных сделок за последний час
+func (md *MarketDataServiceClient) GetLastTradesWithContext(ctx context.Context, instrumentId string, from, to time.Time) (*GetLastTradesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := md.pbClient.GetLastTrades(md.ctx, &pb.GetLastTradesRequest{
+	resp, err := md.pbClient.GetLastTrades(ctx, &pb.GetLastTradesRequest{
 		From:         TimeToTimestamp(from),
 		To:           TimeToTimestamp(to),
 		InstrumentId: instrumentId,
@@ -117,12 +147,17 @@ func (md *MarketDataServiceClient) GetLastTrades(instrumentId string, from, to t
 
 // GetClosePrices - Метод запроса цен закрытия торговой сессии по инструментам
 func (md *MarketDataServiceClient) GetClosePrices(instrumentIds []string) (*GetClosePricesResponse, error) {
+	return md.GetClosePricesWithContext(md.ctx, instrumentIds)
+}
+
+// GetClosePricesWithContext - Метод запроса цен закрытия торговой сессии по инструментам
+func (md *MarketDataServiceClient) GetClosePricesWithContext(ctx context.Context, instrumentIds []string) (*GetClosePricesResponse, error) {
 	var header, trailer metadata.MD
 	instruments := make([]*pb.InstrumentClosePriceRequest, 0, len(instrumentIds))
 	for _, id := range instrumentIds {
 		instruments = append(instruments, &pb.InstrumentClosePriceRequest{InstrumentId: id})
 	}
-	resp, err := md.pbClient.GetClosePrices(md.ctx, &pb.GetClosePricesRequest{
+	resp, err := md.pbClient.GetClosePrices(ctx, &pb.GetClosePricesRequest{
 		Instruments: instruments,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -139,6 +174,14 @@ func (md *MarketDataServiceClient) GetClosePrices(instrumentIds []string) (*GetC
 // свечей в формате: instrumentId;time;open;close;high;low;volume.
 // Имя файла по умолчанию: "candles hh:mm:ss"
 func (md *MarketDataServiceClient) GetHistoricCandles(req *GetHistoricCandlesRequest) ([]*pb.HistoricCandle, error) {
+	return md.GetHistoricCandlesWithContext(md.ctx, req)
+}
+
+// GetHistoricCandlesWithContext - Метод загрузки исторических свечей.
+// Если указать File = true, то создастся .csv файл с записями
+// свечей в формате: instrumentId;time;open;close;high;low;volume.
+// Имя файла по умолчанию: "candles hh:mm:ss"
+func (md *MarketDataServiceClient) GetHistoricCandlesWithContext(ctx context.Context, req *GetHistoricCandlesRequest) ([]*pb.HistoricCandle, error) {
 	// by default 1 hour
 	if req.Interval == pb.CandleInterval_CANDLE_INTERVAL_UNSPECIFIED {
 		req.Interval = pb.CandleInterval_CANDLE_INTERVAL_HOUR
@@ -161,11 +204,24 @@ func (md *MarketDataServiceClient) GetHistoricCandles(req *GetHistoricCandlesReq
 	candles := make([]*pb.HistoricCandle, 0)
 	requests := 0
 	for i := len(intervals) - 1; i > 0; i-- {
+		// без лимитера и ретраев ResourceExhausted выдерживаем паузу, чтобы не превысить лимит запросов в минуту
+		if requests == 299 {
+			if md.config.DisableResourceExhaustedRetry && !md.config.EnableRateLimiter {
+				timer := time.NewTimer(time.Minute)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, ctx.Err()
+				case <-timer.C:
+				}
+			}
+			requests = 0
+		}
+		requests++
 		// идем с конца слайса так как там более раннее время
 		// from - i элемент
 		// to - i-1 элемент
-		requests++
-		resp, err := md.GetCandles(req.Instrument, req.Interval, intervals[i], intervals[i-1])
+		resp, err := md.GetCandlesWithContext(ctx, req.Instrument, req.Interval, intervals[i], intervals[i-1])
 		if err != nil {
 			return nil, err
 		}
@@ -173,12 +229,6 @@ func (md *MarketDataServiceClient) GetHistoricCandles(req *GetHistoricCandlesReq
 			continue
 		}
 		candles = append(candles, resp.GetCandles()[1:]...)
-		if requests == 299 {
-			if md.config.DisableResourceExhaustedRetry {
-				time.Sleep(time.Minute)
-			}
-			requests = 0
-		}
 	}
 
 	if req.File {
@@ -193,15 +243,20 @@ func (md *MarketDataServiceClient) GetHistoricCandles(req *GetHistoricCandlesReq
 
 // GetAllHistoricCandles - Метод получения всех свечей по инструменту, поля from, to игнорируются
 func (md *MarketDataServiceClient) GetAllHistoricCandles(req *GetHistoricCandlesRequest) ([]*pb.HistoricCandle, error) {
+	return md.GetAllHistoricCandlesWithContext(md.ctx, req)
+}
+
+// GetAllHistoricCandlesWithContext - Метод получения всех свечей по инструменту, поля from, to игнорируются
+func (md *MarketDataServiceClient) GetAllHistoricCandlesWithContext(ctx context.Context, req *GetHistoricCandlesRequest) ([]*pb.HistoricCandle, error) {
 	instrumentsService := &InstrumentsServiceClient{
 		conn:     md.conn,
 		config:   md.config,
 		logger:   md.logger,
-		ctx:      md.ctx,
+		ctx:      ctx,
 		pbClient: pb.NewInstrumentsServiceClient(md.conn),
 	}
 
-	resp, err := instrumentsService.FindInstrument(req.Instrument)
+	resp, err := instrumentsService.FindInstrumentWithContext(ctx, req.Instrument)
 	if err != nil {
 		return nil, err
 	}
@@ -218,7 +273,7 @@ func (md *MarketDataServiceClient) GetAllHistoricCandles(req *GetHistoricCandles
 		from = instruments[0].GetFirst_1MinCandleDate().AsTime()
 	}
 
-	return md.GetHistoricCandles(&GetHistoricCandlesRequest{
+	return md.GetHistoricCandlesWithContext(ctx, &GetHistoricCandlesRequest{
 		Instrument: req.Instrument,
 		Interval:   req.Interval,
 		From:       from,
@@ -265,7 +320,7 @@ func (md *MarketDataServiceClient) writeCandlesToFile(candles []*pb.HistoricCand
 	defer func() {
 		err = file.Close()
 		if err != nil {
-			md.logger.Errorf(err.Error())
+			logError(md.logger, "close candles file", FieldInstrumentId, id, FieldError, err)
 		}
 	}()
 	for _, candle := range candles {
@@ -0,0 +1,235 @@
+package investgo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// MarketDataKind - Тип биржевой информации в MarketDataStream
+type MarketDataKind int
+
+const (
+	// MarketDataCandle - Свечи
+	MarketDataCandle MarketDataKind = iota
+	// MarketDataOrderBook - Стаканы
+	MarketDataOrderBook
+	// MarketDataTrade - Обезличенные сделки
+	MarketDataTrade
+	// MarketDataLastPrice - Последние цены
+	MarketDataLastPrice
+	// MarketDataTradingStatus - Торговые статусы
+	MarketDataTradingStatus
+)
+
+// OverflowPolicy - Поведение подписчика при заполненном буфере канала
+type OverflowPolicy int
+
+const (
+	// OverflowBlock - Ждать, пока подписчик прочитает канал. Медленный подписчик задерживает Listen
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest - Выбросить самое старое сообщение из буфера и записать новое
+	OverflowDropOldest
+	// OverflowDropNewest - Выбросить новое сообщение
+	OverflowDropNewest
+)
+
+// DefaultSubscriberBuffer - Размер буфера канала подписчика по умолчанию
+const DefaultSubscriberBuffer = 100
+
+// SubscriberOptions - Параметры подписчика MarketDataStream
+type SubscriberOptions struct {
+	// InstrumentIds - figi или instrument_uid инструментов, если пустой - все инструменты
+	InstrumentIds []string
+	// Kinds - Типы биржевой информации, если пустой - все типы
+	Kinds []MarketDataKind
+	// Buffer - Размер буфера канала, если 0 - DefaultSubscriberBuffer
+	Buffer int
+	// Overflow - Поведение при заполненном буфере
+	Overflow OverflowPolicy
+}
+
+// Subscriber - Подписчик MarketDataStream со своим каналом
+type Subscriber struct {
+	updates  chan *pb.MarketDataResponse
+	ids      map[string]struct{}
+	kinds    map[MarketDataKind]struct{}
+	overflow OverflowPolicy
+	dropped  atomic.Uint64
+
+	// done - закрывается при удалении подписчика, чтобы разблокировать ожидающую отправку
+	done chan struct{}
+	once sync.Once
+}
+
+// Updates - Канал сообщений подписчика. Закрывается при удалении подписчика или завершении стрима
+func (s *Subscriber) Updates() <-chan *pb.MarketDataResponse {
+	return s.updates
+}
+
+// Dropped - Количество сообщений, выброшенных из-за переполнения буфера
+func (s *Subscriber) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// fanout - подписчики MarketDataStream
+type fanout struct {
+	// mu - на чтение берется при рассылке, на запись при изменении списка подписчиков
+	mu          sync.RWMutex
+	subscribers map[*Subscriber]struct{}
+	closed      bool
+}
+
+func newFanout() *fanout {
+	return &fanout{
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// AddSubscriber - Добавление подписчика с собственным каналом, отфильтрованным по инструментам и типам данных.
+// Подписчик получает данные только по подпискам стрима, сделанным через Subscribe* методы.
+// Пока у стрима есть подписчики, каналы, которые возвращают Subscribe* методы, не заполняются, после удаления
+// последнего подписчика сообщения снова приходят в них
+func (mds *MarketDataStream) AddSubscriber(opts SubscriberOptions) *Subscriber {
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = DefaultSubscriberBuffer
+	}
+	s := &Subscriber{
+		updates:  make(chan *pb.MarketDataResponse, buffer),
+		ids:      make(map[string]struct{}, len(opts.InstrumentIds)),
+		kinds:    make(map[MarketDataKind]struct{}, len(opts.Kinds)),
+		overflow: opts.Overflow,
+		done:     make(chan struct{}),
+	}
+	for _, id := range opts.InstrumentIds {
+		s.ids[id] = struct{}{}
+	}
+	for _, kind := range opts.Kinds {
+		s.kinds[kind] = struct{}{}
+	}
+
+	f := mds.fanout
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		close(s.updates)
+		return s
+	}
+	f.subscribers[s] = struct{}{}
+	return s
+}
+
+// RemoveSubscriber - Удаление подписчика, его канал закрывается
+func (mds *MarketDataStream) RemoveSubscriber(s *Subscriber) {
+	f := mds.fanout
+	// сначала разблокируем отправку этому подписчику, иначе рассылка удерживает f.mu
+	s.once.Do(func() { close(s.done) })
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subscribers[s]; ok {
+		delete(f.subscribers, s)
+		close(s.updates)
+	}
+}
+
+// publish - рассылка сообщения подписчикам, возвращает false, если подписчиков нет
+// или сообщение не относится к биржевой информации
+func (f *fanout) publish(ctx context.Context, resp *pb.MarketDataResponse) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.subscribers) == 0 {
+		return false
+	}
+	kind, id, ok := marketDataKind(resp)
+	if !ok {
+		return false
+	}
+	for s := range f.subscribers {
+		if s.match(kind, id) {
+			s.send(ctx, resp)
+		}
+	}
+	return true
+}
+
+// close - закрытие каналов всех подписчиков при завершении стрима
+func (f *fanout) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for s := range f.subscribers {
+		close(s.updates)
+	}
+	f.subscribers = make(map[*Subscriber]struct{})
+	f.closed = true
+}
+
+func (s *Subscriber) match(kind MarketDataKind, id instrumentIds) bool {
+	if len(s.kinds) > 0 {
+		if _, ok := s.kinds[kind]; !ok {
+			return false
+		}
+	}
+	if len(s.ids) > 0 {
+		_, figi := s.ids[id.GetFigi()]
+		_, uid := s.ids[id.GetInstrumentUid()]
+		return figi || uid
+	}
+	return true
+}
+
+// send - отправка сообщения подписчику с учетом политики переполнения, вызывается только из Listen
+func (s *Subscriber) send(ctx context.Context, resp *pb.MarketDataResponse) {
+	switch s.overflow {
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.updates <- resp:
+				return
+			default:
+			}
+			select {
+			case <-s.updates:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	case OverflowDropNewest:
+		select {
+		case s.updates <- resp:
+		default:
+			s.dropped.Add(1)
+		}
+	default:
+		select {
+		case s.updates <- resp:
+		case <-s.done:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// instrumentIds - идентификаторы инструмента в сообщениях MarketDataStream
+type instrumentIds interface {
+	GetFigi() string
+	GetInstrumentUid() string
+}
+
+func marketDataKind(resp *pb.MarketDataResponse) (MarketDataKind, instrumentIds, bool) {
+	switch resp.GetPayload().(type) {
+	case *pb.MarketDataResponse_Candle:
+		return MarketDataCandle, resp.GetCandle(), true
+	case *pb.MarketDataResponse_Orderbook:
+		return MarketDataOrderBook, resp.GetOrderbook(), true
+	case *pb.MarketDataResponse_Trade:
+		return MarketDataTrade, resp.GetTrade(), true
+	case *pb.MarketDataResponse_LastPrice:
+		return MarketDataLastPrice, resp.GetLastPrice(), true
+	case *pb.MarketDataResponse_TradingStatus:
+		return MarketDataTradingStatus, resp.GetTradingStatus(), true
+	default:
+		return 0, nil, false
+	}
+}
@@ -0,0 +1,110 @@
+package investgo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// receiveLastPrice - Публикация цены price, пока она не придет в канал ch
+func receiveLastPrice(t *testing.T, srv *fake.Server, price int64, ch <-chan *pb.LastPrice) {
+	t.Helper()
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(testTimeout)
+	for {
+		select {
+		case <-ticker.C:
+			if err := srv.SetLastPrice(testFigi, fake.Quotation(price)); err != nil {
+				t.Fatal(err)
+			}
+		case lp := <-ch:
+			if lp.GetPrice().GetUnits() == price {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("last price %d was not received", price)
+		}
+	}
+}
+
+func TestSubscriberDivertsAndRestoresChannels(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	stream, err := client.NewMarketDataStreamClient().MarketDataStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Stop()
+	legacy, err := stream.SubscribeLastPrice([]string{testFigi})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := stream.AddSubscriber(investgo.SubscriberOptions{
+		InstrumentIds: []string{testFigi},
+		Kinds:         []investgo.MarketDataKind{investgo.MarketDataLastPrice},
+	})
+	go func() {
+		_ = stream.Listen()
+	}()
+
+	updates := make(chan *pb.LastPrice)
+	go func() {
+		for resp := range sub.Updates() {
+			updates <- resp.GetLastPrice()
+		}
+		close(updates)
+	}()
+	receiveLastPrice(t, srv, 251, updates)
+	select {
+	case lp := <-legacy:
+		t.Fatalf("legacy channel received %v while a subscriber exists", lp)
+	default:
+	}
+
+	stream.RemoveSubscriber(sub)
+	for range updates {
+	}
+	receiveLastPrice(t, srv, 252, legacy)
+}
+
+func TestSubscriberFilter(t *testing.T) {
+	srv := newTestServer(t)
+	srv.AddShare(&pb.Share{Figi: "BBG000000002", Ticker: "OTHER", ClassCode: "TQBR", Lot: 1, Currency: "rub", ApiTradeAvailableFlag: true})
+	client := newTestClient(t, srv.Config())
+	stream, err := client.NewMarketDataStreamClient().MarketDataStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Stop()
+	if _, err := stream.SubscribeLastPrice([]string{testFigi, "BBG000000002"}); err != nil {
+		t.Fatal(err)
+	}
+	other := stream.AddSubscriber(investgo.SubscriberOptions{InstrumentIds: []string{"BBG000000002"}})
+	all := stream.AddSubscriber(investgo.SubscriberOptions{Overflow: investgo.OverflowDropOldest, Buffer: 1})
+	go func() {
+		_ = stream.Listen()
+	}()
+
+	eventually(t, "subscriber for all instruments received nothing", func() bool {
+		_ = srv.SetLastPrice(testFigi, fake.Quotation(251))
+		select {
+		case resp := <-all.Updates():
+			return resp.GetLastPrice().GetFigi() == testFigi
+		default:
+			return false
+		}
+	})
+	_ = srv.SetLastPrice("BBG000000002", fake.Quotation(10))
+	select {
+	case resp := <-other.Updates():
+		if resp.GetLastPrice().GetFigi() != "BBG000000002" {
+			t.Fatalf("filtered subscriber received %v", resp)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("filtered subscriber received nothing")
+	}
+}
@@ -0,0 +1,113 @@
+package investgo
+
+import (
+	"context"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// marketDataServerSideStreamMethod - полное имя метода стрима для поля method в логах
+const marketDataServerSideStreamMethod = "/tinkoff.public.invest.api.contract.v1.MarketDataStreamService/MarketDataServerSideStream"
+
+// MarketDataServerSideStream - серверный стрим биржевой информации, подписки задаются один раз при создании.
+// В отличие от MarketDataStream, при разрыве соединения стрим переоткрывается ретраером с тем же запросом
+type MarketDataServerSideStream struct {
+	stream    pb.MarketDataStreamService_MarketDataServerSideStreamClient
+	mdsClient *MarketDataStreamClient
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	candle        chan *pb.Candle
+	trade         chan *pb.Trade
+	orderBook     chan *pb.OrderBook
+	lastPrice     chan *pb.LastPrice
+	tradingStatus chan *pb.TradingStatus
+}
+
+// Candles - Метод возвращает канал свечей
+func (s *MarketDataServerSideStream) Candles() <-chan *pb.Candle {
+	return s.candle
+}
+
+// Trades - Метод возвращает канал обезличенных сделок
+func (s *MarketDataServerSideStream) Trades() <-chan *pb.Trade {
+	return s.trade
+}
+
+// OrderBooks - Метод возвращает канал стаканов
+func (s *MarketDataServerSideStream) OrderBooks() <-chan *pb.OrderBook {
+	return s.orderBook
+}
+
+// LastPrices - Метод возвращает канал последних цен
+func (s *MarketDataServerSideStream) LastPrices() <-chan *pb.LastPrice {
+	return s.lastPrice
+}
+
+// TradingStatuses - Метод возвращает канал торговых статусов
+func (s *MarketDataServerSideStream) TradingStatuses() <-chan *pb.TradingStatus {
+	return s.tradingStatus
+}
+
+// Listen - метод начинает слушать стрим и отправлять информацию в каналы
+func (s *MarketDataServerSideStream) Listen() error {
+	defer s.shutdown()
+	for {
+		select {
+		case <-s.ctx.Done():
+			logInfo(s.mdsClient.logger, "stop listening stream", FieldMethod, marketDataServerSideStreamMethod)
+			return nil
+		default:
+			resp, err := s.stream.Recv()
+			if err != nil {
+				switch {
+				case status.Code(err) == codes.Canceled:
+					logInfo(s.mdsClient.logger, "stop listening stream", FieldMethod, marketDataServerSideStreamMethod)
+					return nil
+				default:
+					return err
+				}
+			} else {
+				s.sendRespToChannel(resp)
+			}
+		}
+	}
+}
+
+func (s *MarketDataServerSideStream) sendRespToChannel(resp *pb.MarketDataResponse) {
+	switch resp.GetPayload().(type) {
+	case *pb.MarketDataResponse_Candle:
+		s.candle <- resp.GetCandle()
+	case *pb.MarketDataResponse_Orderbook:
+		s.orderBook <- resp.GetOrderbook()
+	case *pb.MarketDataResponse_Trade:
+		s.trade <- resp.GetTrade()
+	case *pb.MarketDataResponse_LastPrice:
+		s.lastPrice <- resp.GetLastPrice()
+	case *pb.MarketDataResponse_TradingStatus:
+		s.tradingStatus <- resp.GetTradingStatus()
+	default:
+		logDebug(s.mdsClient.logger, "info from stream", FieldMethod, marketDataServerSideStreamMethod, "payload", resp.String())
+	}
+}
+
+func (s *MarketDataServerSideStream) restart(_ context.Context, attempt uint, err error) {
+	logWarn(s.mdsClient.logger, "try to restart stream", FieldMethod, marketDataServerSideStreamMethod, FieldAttempt, attempt, FieldError, err)
+}
+
+func (s *MarketDataServerSideStream) shutdown() {
+	logInfo(s.mdsClient.logger, "close stream", FieldMethod, marketDataServerSideStreamMethod)
+	close(s.candle)
+	close(s.trade)
+	close(s.lastPrice)
+	close(s.orderBook)
+	close(s.tradingStatus)
+}
+
+// Stop - Завершение работы стрима
+func (s *MarketDataServerSideStream) Stop() {
+	s.cancel()
+}
@@ -0,0 +1,140 @@
+package investgo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+const serverSideStreamMethod = "MarketDataStreamService/MarketDataServerSideStream"
+
+// awaitSubscribed - Отправка последних цен, пока одна из них не придет в стрим. Подписка на последние цены
+// оформляется сервером последней, значит, остальные подписки из запроса к этому моменту тоже действуют
+func awaitSubscribed(t *testing.T, srv *fake.Server, stream *investgo.MarketDataServerSideStream, price *int64) {
+	t.Helper()
+	eventually(t, "stream was not subscribed", func() bool {
+		*price++
+		if err := srv.SetLastPrice(testFigi, fake.Quotation(*price)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-stream.LastPrices():
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	})
+}
+
+// receive - Ожидание значения из канала ch не дольше testTimeout
+func receive[T any](t *testing.T, what string, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v, ok := <-ch:
+		if !ok {
+			t.Fatalf("%s channel closed", what)
+		}
+		return v
+	case <-time.After(testTimeout):
+		t.Fatalf("%s was not received", what)
+	}
+	var zero T
+	return zero
+}
+
+func TestMarketDataServerSideStream(t *testing.T) {
+	srv := newTestServer(t)
+	conf := srv.Config()
+	conf.RetryPolicies = map[string]investgo.RetryPolicy{string(investgo.RetryCategoryStream): {
+		Codes:          []string{"Unavailable"},
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+	}}
+	rec := &recordingLogger{}
+	client, err := investgo.NewClient(context.Background(), conf, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Stop()
+	})
+
+	stream, err := client.NewMarketDataStreamClient().MarketDataServerSideStream(&investgo.MarketDataServerSideStreamRequest{
+		Candles:         []string{testFigi},
+		CandleInterval:  pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE,
+		Trades:          []string{testFigi},
+		TradingStatuses: []string{testFigi},
+		LastPrices:      []string{testFigi},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.Listen()
+	}()
+	price := int64(250)
+	awaitSubscribed(t, srv, stream, &price)
+
+	// свеча другого интервала не доставляется, подписка на свечи создана с CandleInterval
+	for _, interval := range []pb.SubscriptionInterval{
+		pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_FIVE_MINUTES,
+		pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE,
+	} {
+		if err := srv.PushCandle(testFigi, &pb.Candle{Interval: interval, Close: fake.Quotation(250)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c := receive(t, "candle", stream.Candles()); c.GetInterval() != pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE {
+		t.Fatalf("candle interval = %v, want one minute", c.GetInterval())
+	}
+
+	if err := srv.PushTrade(testFigi, pb.TradeDirection_TRADE_DIRECTION_BUY, fake.Quotation(251), 7); err != nil {
+		t.Fatal(err)
+	}
+	if tr := receive(t, "trade", stream.Trades()); tr.GetFigi() != testFigi || tr.GetQuantity() != 7 {
+		t.Fatalf("trade = %v, want 7 lots of %s", tr, testFigi)
+	}
+	if lp := receive(t, "last price", stream.LastPrices()); lp.GetPrice().GetUnits() != 251 {
+		t.Fatalf("last price = %v, want 251", lp.GetPrice())
+	}
+	if err := srv.SetTradingStatus(testFigi, pb.SecurityTradingStatus_SECURITY_TRADING_STATUS_BREAK_IN_TRADING); err != nil {
+		t.Fatal(err)
+	}
+	if ts := receive(t, "trading status", stream.TradingStatuses()); ts.GetTradingStatus() != pb.SecurityTradingStatus_SECURITY_TRADING_STATUS_BREAK_IN_TRADING {
+		t.Fatalf("trading status = %v, want break in trading", ts.GetTradingStatus())
+	}
+
+	// после разрыва стрим переоткрывается с тем же запросом
+	srv.BreakStreams()
+	eventually(t, "stream was not restarted", func() bool {
+		_, ok := rec.find("try to restart stream")
+		return ok && srv.Calls(serverSideStreamMethod) == 2
+	})
+	// данные, отправленные до восстановления подписок, теряются
+	awaitSubscribed(t, srv, stream, &price)
+	if err := srv.PushTrade(testFigi, pb.TradeDirection_TRADE_DIRECTION_SELL, fake.Quotation(price), 3); err != nil {
+		t.Fatal(err)
+	}
+	if tr := receive(t, "trade after restart", stream.Trades()); tr.GetQuantity() != 3 {
+		t.Fatalf("trade = %v, want 3 lots", tr)
+	}
+	receive(t, "last price after restart", stream.LastPrices())
+
+	stream.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Listen = %v, want nil after Stop", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("Listen was not stopped")
+	}
+	if _, ok := <-stream.Trades(); ok {
+		t.Fatal("trades channel is not closed after Stop")
+	}
+}
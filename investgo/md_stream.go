@@ -2,8 +2,12 @@ package investgo
 
 import (
 	"context"
+	"io"
+	"sync"
+	"time"
 
 	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"github.com/tinkoff/invest-api-go-sdk/retry"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -13,6 +17,9 @@ type MDStream struct {
 	*MarketDataStream
 }
 
+// marketDataStreamMethod - полное имя метода стрима для поля method в логах
+const marketDataStreamMethod = "/tinkoff.public.invest.api.contract.v1.MarketDataStreamService/MarketDataStream"
+
 // MarketDataStream - стрим биржевой информации
 type MarketDataStream struct {
 	stream    pb.MarketDataStreamService_MarketDataStreamClient
@@ -26,8 +33,32 @@ type MarketDataStream struct {
 	orderBook     chan *pb.OrderBook
 	lastPrice     chan *pb.LastPrice
 	tradingStatus chan *pb.TradingStatus
+	reconnect     chan ReconnectEvent
+	fanout        *fanout
 
+	// mu - защищает stream и subs, так как при переподключении стрим заменяется из горутины Listen
+	mu   sync.Mutex
 	subs subscriptions
+
+	// attempt и cause - номер последней попытки переподключения и ошибка, из-за которой стрим был разорван.
+	// Сбрасываются, когда новый стрим получает первое сообщение, используются только из горутины Listen
+	attempt uint
+	cause   error
+}
+
+// ReconnectEvent - событие переподключения стрима после разрыва соединения
+type ReconnectEvent struct {
+	// Attempt - номер попытки, на которой стрим удалось переподключить
+	Attempt uint
+	// Err - ошибка, из-за которой стрим был разорван
+	Err error
+}
+
+// candleKey - Подписка на свечи инструмента с одним интервалом, на один инструмент можно подписаться
+// с несколькими интервалами
+type candleKey struct {
+	id       string
+	interval pb.SubscriptionInterval
 }
 
 type candleSub struct {
@@ -36,7 +67,7 @@ type candleSub struct {
 }
 
 type subscriptions struct {
-	candles         map[string]candleSub
+	candles         map[candleKey]bool
 	orderBooks      map[string]int32
 	trades          map[string]struct{}
 	tradingStatuses map[string]struct{}
@@ -45,24 +76,28 @@ type subscriptions struct {
 
 // SubscribeCandle - Метод подписки на свечи с заданным интервалом
 func (mds *MarketDataStream) SubscribeCandle(ids []string, interval pb.SubscriptionInterval, waitingClose bool) (<-chan *pb.Candle, error) {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	err := mds.sendCandlesReq(ids, interval, pb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE, waitingClose)
 	if err != nil {
 		return nil, err
 	}
 	for _, id := range ids {
-		mds.subs.candles[id] = candleSub{interval: interval, waitingClose: waitingClose}
+		mds.subs.candles[candleKey{id: id, interval: interval}] = waitingClose
 	}
 	return mds.candle, nil
 }
 
-// UnSubscribeCandle - Метод отписки от свечей
+// UnSubscribeCandle - Метод отписки от свечей с заданным интервалом, подписки с другими интервалами сохраняются
 func (mds *MarketDataStream) UnSubscribeCandle(ids []string, interval pb.SubscriptionInterval, waitingClose bool) error {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	err := mds.sendCandlesReq(ids, interval, pb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE, waitingClose)
 	if err != nil {
 		return err
 	}
 	for _, id := range ids {
-		delete(mds.subs.candles, id)
+		delete(mds.subs.candles, candleKey{id: id, interval: interval})
 	}
 	return nil
 }
@@ -87,6 +122,8 @@ func (mds *MarketDataStream) sendCandlesReq(ids []string, interval pb.Subscripti
 
 // SubscribeOrderBook - метод подписки на стаканы инструментов с одинаковой глубиной
 func (mds *MarketDataStream) SubscribeOrderBook(ids []string, depth int32) (<-chan *pb.OrderBook, error) {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	err := mds.sendOrderBookReq(ids, depth, pb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE)
 	if err != nil {
 		return nil, err
@@ -99,6 +136,8 @@ func (mds *MarketDataStream) SubscribeOrderBook(ids []string, depth int32) (<-ch
 
 // UnSubscribeOrderBook - метод отдписки от стаканов инструментов
 func (mds *MarketDataStream) UnSubscribeOrderBook(ids []string) error {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	err := mds.sendOrderBookReq(ids, 0, pb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE)
 	if err != nil {
 		return err
@@ -127,6 +166,8 @@ func (mds *MarketDataStream) sendOrderBookReq(ids []string, depth int32, act pb.
 
 // SubscribeTrade - метод подписки на ленту обезличенных сделок
 func (mds *MarketDataStream) SubscribeTrade(ids []string) (<-chan *pb.Trade, error) {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	err := mds.sendTradesReq(ids, pb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE)
 	if err != nil {
 		return nil, err
@@ -139,6 +180,8 @@ func (mds *MarketDataStream) SubscribeTrade(ids []string) (<-chan *pb.Trade, err
 
 // UnSubscribeTrade - метод отписки от ленты обезличенных сделок
 func (mds *MarketDataStream) UnSubscribeTrade(ids []string) error {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	err := mds.sendTradesReq(ids, pb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE)
 	if err != nil {
 		return err
@@ -166,6 +209,8 @@ func (mds *MarketDataStream) sendTradesReq(ids []string, act pb.SubscriptionActi
 
 // SubscribeInfo - метод подписки на торговые статусы инструментов
 func (mds *MarketDataStream) SubscribeInfo(ids []string) (<-chan *pb.TradingStatus, error) {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	err := mds.sendInfoReq(ids, pb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE)
 	if err != nil {
 		return nil, err
@@ -178,6 +223,8 @@ func (mds *MarketDataStream) SubscribeInfo(ids []string) (<-chan *pb.TradingStat
 
 // UnSubscribeInfo - метод отписки от торговых статусов инструментов
 func (mds *MarketDataStream) UnSubscribeInfo(ids []string) error {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	err := mds.sendInfoReq(ids, pb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE)
 	if err != nil {
 		return err
@@ -205,6 +252,8 @@ func (mds *MarketDataStream) sendInfoReq(ids []string, act pb.SubscriptionAction
 
 // SubscribeLastPrice - метод подписки на последние цены инструментов
 func (mds *MarketDataStream) SubscribeLastPrice(ids []string) (<-chan *pb.LastPrice, error) {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	err := mds.sendLastPriceReq(ids, pb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE)
 	if err != nil {
 		return nil, err
@@ -217,6 +266,8 @@ func (mds *MarketDataStream) SubscribeLastPrice(ids []string) (<-chan *pb.LastPr
 
 // UnSubscribeLastPrice - метод отписки от последних цен инструментов
 func (mds *MarketDataStream) UnSubscribeLastPrice(ids []string) error {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	err := mds.sendLastPriceReq(ids, pb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE)
 	if err != nil {
 		return err
@@ -244,31 +295,38 @@ func (mds *MarketDataStream) sendLastPriceReq(ids []string, act pb.SubscriptionA
 
 // GetMySubscriptions - метод получения подписок в рамках данного стрима
 func (mds *MarketDataStream) GetMySubscriptions() error {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
 	return mds.stream.Send(&pb.MarketDataRequest{
 		Payload: &pb.MarketDataRequest_GetMySubscriptions{
 			GetMySubscriptions: &pb.GetMySubscriptions{}}})
 }
 
-// Listen - метод начинает слушать стрим и отправлять информацию в каналы
+// Listen - метод начинает слушать стрим и отправлять информацию в каналы.
+// При разрыве соединения стрим переподключается и восстанавливает текущие подписки,
+// о каждом переподключении сообщает канал Reconnects
 func (mds *MarketDataStream) Listen() error {
 	defer mds.shutdown()
 	for {
 		select {
 		case <-mds.ctx.Done():
-			mds.mdsClient.logger.Infof("stop listening market data stream")
+			logInfo(mds.mdsClient.logger, "stop listening stream", FieldMethod, marketDataStreamMethod)
 			return nil
 		default:
-			resp, err := mds.stream.Recv()
+			resp, err := mds.getStream().Recv()
 			if err != nil {
 				// если ошибка связана с завершением контекста, обрабатываем ее
 				switch {
 				case status.Code(err) == codes.Canceled:
-					mds.mdsClient.logger.Infof("stop listening market data stream")
+					logInfo(mds.mdsClient.logger, "stop listening stream", FieldMethod, marketDataStreamMethod)
 					return nil
 				default:
-					return err
+					if err := mds.reconnectStream(err); err != nil {
+						return err
+					}
 				}
 			} else {
+				mds.reconnected()
 				// логика определения того что пришло и отправка информации в нужный канал
 				mds.sendRespToChannel(resp)
 			}
@@ -276,7 +334,147 @@ func (mds *MarketDataStream) Listen() error {
 	}
 }
 
+// Reconnects - канал событий переподключения стрима. Событие отправляется, когда новый стрим получает первое
+// сообщение. Канал не блокирует Listen, если предыдущее событие еще не прочитано, новое событие отбрасывается
+func (mds *MarketDataStream) Reconnects() <-chan ReconnectEvent {
+	return mds.reconnect
+}
+
+func (mds *MarketDataStream) getStream() pb.MarketDataStreamService_MarketDataStreamClient {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
+	return mds.stream
+}
+
+// streamPolicy - политика ретраев MarketDataStream: по имени метода, затем по категории stream
+func (mds *MarketDataStream) streamPolicy() RetryPolicy {
+	return mds.mdsClient.config.RetryPolicy(marketDataStreamMethod, RetryCategoryStream)
+}
+
+// reconnectStream - переподключение стрима по политике ретраев стрима: при ошибках с кодами политики,
+// с ожиданием между попытками от InitialBackoff до MaxBackoff со случайным отклонением Jitter. Упавший стрим
+// считается первой попыткой, а попытки продолжают считаться, пока новый стрим не получит первое сообщение,
+// поэтому переподключений подряд не больше MaxAttempts - 1. Возвращает ошибку, если попытки закончились
+// или ошибка не повторяется, и nil при отмене контекста
+func (mds *MarketDataStream) reconnectStream(cause error) error {
+	conf := mds.mdsClient.config
+	policy := mds.streamPolicy()
+	if conf.DisableAllRetry || !policy.retryable(cause) {
+		return cause
+	}
+	if mds.attempt == 0 {
+		mds.cause = cause
+	}
+	backoff := policy.backoff()
+	err := cause
+	for mds.attempt+1 < policy.maxAttempts(conf.MaxRetries) {
+		mds.attempt++
+		mds.restart(mds.ctx, mds.attempt, err)
+		timer := time.NewTimer(backoff(mds.ctx, mds.attempt))
+		select {
+		case <-mds.ctx.Done():
+			timer.Stop()
+			// остановку стрима обработает Listen
+			return nil
+		case <-timer.C:
+		}
+		err = mds.resubscribe()
+		if err == nil {
+			return nil
+		}
+		if !policy.retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// reconnected - событие в Reconnects после первого сообщения нового стрима
+func (mds *MarketDataStream) reconnected() {
+	if mds.attempt == 0 {
+		return
+	}
+	select {
+	case mds.reconnect <- ReconnectEvent{Attempt: mds.attempt, Err: mds.cause}:
+	default:
+	}
+	mds.attempt, mds.cause = 0, nil
+}
+
+// resubscribe - открывает новый стрим и подписывает его на все текущие подписки
+func (mds *MarketDataStream) resubscribe() error {
+	mds.mu.Lock()
+	defer mds.mu.Unlock()
+
+	// попытки и ожидание задает reconnectStream, поэтому ретраер стримов здесь не нужен
+	stream, err := mds.mdsClient.pbClient.MarketDataStream(mds.ctx, retry.Disable())
+	if err != nil {
+		return err
+	}
+	mds.stream = stream
+
+	err = mds.sendSubscriptions()
+	if err == io.EOF {
+		// при io.EOF настоящую ошибку стрима возвращает Recv
+		_, err = stream.Recv()
+	}
+	return err
+}
+
+func (mds *MarketDataStream) sendSubscriptions() error {
+	subscribe := pb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE
+
+	candles := make(map[candleSub][]string, 0)
+	for k, waitingClose := range mds.subs.candles {
+		c := candleSub{interval: k.interval, waitingClose: waitingClose}
+		candles[c] = append(candles[c], k.id)
+	}
+	for c, ids := range candles {
+		if err := mds.sendCandlesReq(ids, c.interval, subscribe, c.waitingClose); err != nil {
+			return err
+		}
+	}
+
+	orderBooks := make(map[int32][]string, 0)
+	for id, depth := range mds.subs.orderBooks {
+		orderBooks[depth] = append(orderBooks[depth], id)
+	}
+	for depth, ids := range orderBooks {
+		if err := mds.sendOrderBookReq(ids, depth, subscribe); err != nil {
+			return err
+		}
+	}
+
+	if ids := subsIds(mds.subs.trades); len(ids) > 0 {
+		if err := mds.sendTradesReq(ids, subscribe); err != nil {
+			return err
+		}
+	}
+	if ids := subsIds(mds.subs.tradingStatuses); len(ids) > 0 {
+		if err := mds.sendInfoReq(ids, subscribe); err != nil {
+			return err
+		}
+	}
+	if ids := subsIds(mds.subs.lastPrices); len(ids) > 0 {
+		if err := mds.sendLastPriceReq(ids, subscribe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func subsIds(subs map[string]struct{}) []string {
+	ids := make([]string, 0, len(subs))
+	for id := range subs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func (mds *MarketDataStream) sendRespToChannel(resp *pb.MarketDataResponse) {
+	if mds.fanout.publish(mds.ctx, resp) {
+		return
+	}
 	switch resp.GetPayload().(type) {
 	case *pb.MarketDataResponse_Candle:
 		mds.candle <- resp.GetCandle()
@@ -289,17 +487,19 @@ func (mds *MarketDataStream) sendRespToChannel(resp *pb.MarketDataResponse) {
 	case *pb.MarketDataResponse_TradingStatus:
 		mds.tradingStatus <- resp.GetTradingStatus()
 	default:
-		mds.mdsClient.logger.Infof("info from MD stream %v", resp.String())
+		logDebug(mds.mdsClient.logger, "info from stream", FieldMethod, marketDataStreamMethod, "payload", resp.String())
 	}
 }
 
 func (mds *MarketDataStream) shutdown() {
-	mds.mdsClient.logger.Infof("close market data stream")
+	logInfo(mds.mdsClient.logger, "close stream", FieldMethod, marketDataStreamMethod)
 	close(mds.candle)
 	close(mds.trade)
 	close(mds.lastPrice)
 	close(mds.orderBook)
 	close(mds.tradingStatus)
+	close(mds.reconnect)
+	mds.fanout.close()
 }
 
 // Stop - Завершение работы стрима
@@ -309,64 +509,51 @@ func (mds *MarketDataStream) Stop() {
 
 // UnSubscribeAll - Метод отписки от всей информации, отслеживаемой на данный момент
 func (mds *MarketDataStream) UnSubscribeAll() error {
-	ids := make([]string, 0)
-	if len(mds.subs.candles) > 0 {
-		candleSubs := make(map[candleSub][]string, 0)
-
-		for id, c := range mds.subs.candles {
-			candleSubs[c] = append(candleSubs[c], id)
-			delete(mds.subs.candles, id)
-		}
-		for c, ids := range candleSubs {
-			err := mds.UnSubscribeCandle(ids, c.interval, c.waitingClose)
-			if err != nil {
-				return err
-			}
+	mds.mu.Lock()
+	candleSubs := make(map[candleSub][]string, 0)
+	for k, waitingClose := range mds.subs.candles {
+		c := candleSub{interval: k.interval, waitingClose: waitingClose}
+		candleSubs[c] = append(candleSubs[c], k.id)
+	}
+	trades := subsIds(mds.subs.trades)
+	tradingStatuses := subsIds(mds.subs.tradingStatuses)
+	lastPrices := subsIds(mds.subs.lastPrices)
+	orderBooks := make([]string, 0, len(mds.subs.orderBooks))
+	for id := range mds.subs.orderBooks {
+		orderBooks = append(orderBooks, id)
+	}
+	mds.mu.Unlock()
+
+	for c, ids := range candleSubs {
+		err := mds.UnSubscribeCandle(ids, c.interval, c.waitingClose)
+		if err != nil {
+			return err
 		}
 	}
 
-	if len(mds.subs.trades) > 0 {
-		for id := range mds.subs.trades {
-			ids = append(ids, id)
-			delete(mds.subs.trades, id)
-		}
-		err := mds.UnSubscribeTrade(ids)
+	if len(trades) > 0 {
+		err := mds.UnSubscribeTrade(trades)
 		if err != nil {
 			return err
 		}
-		ids = nil
 	}
 
-	if len(mds.subs.tradingStatuses) > 0 {
-		for id := range mds.subs.tradingStatuses {
-			ids = append(ids, id)
-			delete(mds.subs.tradingStatuses, id)
-		}
-		err := mds.UnSubscribeInfo(ids)
+	if len(tradingStatuses) > 0 {
+		err := mds.UnSubscribeInfo(tradingStatuses)
 		if err != nil {
 			return err
 		}
-		ids = nil
 	}
 
-	if len(mds.subs.lastPrices) > 0 {
-		for id := range mds.subs.lastPrices {
-			ids = append(ids, id)
-			delete(mds.subs.lastPrices, id)
-		}
-		err := mds.UnSubscribeLastPrice(ids)
+	if len(lastPrices) > 0 {
+		err := mds.UnSubscribeLastPrice(lastPrices)
 		if err != nil {
 			return err
 		}
-		ids = nil
 	}
 
-	if len(mds.subs.orderBooks) > 0 {
-		for id := range mds.subs.orderBooks {
-			ids = append(ids, id)
-			delete(mds.subs.orderBooks, id)
-		}
-		err := mds.UnSubscribeOrderBook(ids)
+	if len(orderBooks) > 0 {
+		err := mds.UnSubscribeOrderBook(orderBooks)
 		if err != nil {
 			return err
 		}
@@ -376,5 +563,5 @@ func (mds *MarketDataStream) UnSubscribeAll() error {
 }
 
 func (mds *MarketDataStream) restart(_ context.Context, attempt uint, err error) {
-	mds.mdsClient.logger.Infof("try to restart md stream err = %v, attempt = %v", err.Error(), attempt)
+	logWarn(mds.mdsClient.logger, "try to restart stream", FieldMethod, marketDataStreamMethod, FieldAttempt, attempt, FieldError, err)
 }
@@ -18,7 +18,13 @@ type MarketDataStreamClient struct {
 
 // MarketDataStream - метод возвращает стрим биржевой информации
 func (c *MarketDataStreamClient) MarketDataStream() (*MarketDataStream, error) {
-	ctx, cancel := context.WithCancel(c.ctx)
+	return c.MarketDataStreamWithContext(c.ctx)
+}
+
+// MarketDataStreamWithContext - метод возвращает стрим биржевой информации
+// ctx определяет время жизни стрима, после его отмены стрим завершается
+func (c *MarketDataStreamClient) MarketDataStreamWithContext(ctx context.Context) (*MarketDataStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	mds := &MarketDataStream{
 		stream:        nil,
 		mdsClient:     c,
@@ -29,8 +35,10 @@ func (c *MarketDataStreamClient) MarketDataStream() (*MarketDataStream, error) {
 		orderBook:     make(chan *pb.OrderBook, 1),
 		lastPrice:     make(chan *pb.LastPrice, 1),
 		tradingStatus: make(chan *pb.TradingStatus, 1),
+		reconnect:     make(chan ReconnectEvent, 1),
+		fanout:        newFanout(),
 		subs: subscriptions{
-			candles:         make(map[string]candleSub, 0),
+			candles:         make(map[candleKey]bool, 0),
 			orderBooks:      make(map[string]int32, 0),
 			trades:          make(map[string]struct{}, 0),
 			tradingStatuses: make(map[string]struct{}, 0),
@@ -38,7 +46,8 @@ func (c *MarketDataStreamClient) MarketDataStream() (*MarketDataStream, error) {
 		},
 	}
 
-	stream, err := c.pbClient.MarketDataStream(ctx, retry.WithOnRetryCallback(mds.restart))
+	// ретраер не используется, переподключением и восстановлением подписок управляет сам MarketDataStream
+	stream, err := c.pbClient.MarketDataStream(ctx, retry.Disable())
 	if err != nil {
 		cancel()
 		return nil, err
@@ -47,6 +56,99 @@ func (c *MarketDataStreamClient) MarketDataStream() (*MarketDataStream, error) {
 	return mds, nil
 }
 
+// MarketDataServerSideStream - метод возвращает серверный стрим биржевой информации с подписками из req
+func (c *MarketDataStreamClient) MarketDataServerSideStream(req *MarketDataServerSideStreamRequest) (*MarketDataServerSideStream, error) {
+	return c.MarketDataServerSideStreamWithContext(c.ctx, req)
+}
+
+// MarketDataServerSideStreamWithContext - метод возвращает серверный стрим биржевой информации с подписками из req
+// ctx определяет время жизни стрима, после его отмены стрим завершается
+func (c *MarketDataStreamClient) MarketDataServerSideStreamWithContext(ctx context.Context, req *MarketDataServerSideStreamRequest) (*MarketDataServerSideStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &MarketDataServerSideStream{
+		stream:        nil,
+		mdsClient:     c,
+		ctx:           ctx,
+		cancel:        cancel,
+		candle:        make(chan *pb.Candle, 1),
+		trade:         make(chan *pb.Trade, 1),
+		orderBook:     make(chan *pb.OrderBook, 1),
+		lastPrice:     make(chan *pb.LastPrice, 1),
+		tradingStatus: make(chan *pb.TradingStatus, 1),
+	}
+
+	stream, err := c.pbClient.MarketDataServerSideStream(ctx, serverSideStreamRequest(req), retry.WithOnRetryCallback(s.restart))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s.stream = stream
+	return s, nil
+}
+
+func serverSideStreamRequest(req *MarketDataServerSideStreamRequest) *pb.MarketDataServerSideStreamRequest {
+	subscribe := pb.SubscriptionAction_SUBSCRIPTION_ACTION_SUBSCRIBE
+	pbReq := &pb.MarketDataServerSideStreamRequest{}
+	if len(req.Candles) > 0 {
+		instruments := make([]*pb.CandleInstrument, 0, len(req.Candles))
+		for _, id := range req.Candles {
+			instruments = append(instruments, &pb.CandleInstrument{
+				InstrumentId: id,
+				Interval:     req.CandleInterval,
+			})
+		}
+		pbReq.SubscribeCandlesRequest = &pb.SubscribeCandlesRequest{
+			SubscriptionAction: subscribe,
+			Instruments:        instruments,
+			WaitingClose:       req.WaitingClose,
+		}
+	}
+	if len(req.OrderBooks) > 0 {
+		instruments := make([]*pb.OrderBookInstrument, 0, len(req.OrderBooks))
+		for _, id := range req.OrderBooks {
+			instruments = append(instruments, &pb.OrderBookInstrument{
+				InstrumentId: id,
+				Depth:        req.OrderBookDepth,
+			})
+		}
+		pbReq.SubscribeOrderBookRequest = &pb.SubscribeOrderBookRequest{
+			SubscriptionAction: subscribe,
+			Instruments:        instruments,
+		}
+	}
+	if len(req.Trades) > 0 {
+		instruments := make([]*pb.TradeInstrument, 0, len(req.Trades))
+		for _, id := range req.Trades {
+			instruments = append(instruments, &pb.TradeInstrument{InstrumentId: id})
+		}
+		pbReq.SubscribeTradesRequest = &pb.SubscribeTradesRequest{
+			SubscriptionAction: subscribe,
+			Instruments:        instruments,
+		}
+	}
+	if len(req.TradingStatuses) > 0 {
+		instruments := make([]*pb.InfoInstrument, 0, len(req.TradingStatuses))
+		for _, id := range req.TradingStatuses {
+			instruments = append(instruments, &pb.InfoInstrument{InstrumentId: id})
+		}
+		pbReq.SubscribeInfoRequest = &pb.SubscribeInfoRequest{
+			SubscriptionAction: subscribe,
+			Instruments:        instruments,
+		}
+	}
+	if len(req.LastPrices) > 0 {
+		instruments := make([]*pb.LastPriceInstrument, 0, len(req.LastPrices))
+		for _, id := range req.LastPrices {
+			instruments = append(instruments, &pb.LastPriceInstrument{InstrumentId: id})
+		}
+		pbReq.SubscribeLastPriceRequest = &pb.SubscribeLastPriceRequest{
+			SubscriptionAction: subscribe,
+			Instruments:        instruments,
+		}
+	}
+	return pbReq
+}
+
 // Deprecated: Use MarketDataStreamClient
 type MDStreamClient struct {
 	conn     *grpc.ClientConn
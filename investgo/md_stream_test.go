@@ -0,0 +1,197 @@
+package investgo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const marketDataStreamMethod = "MarketDataStreamService/MarketDataStream"
+
+// listenLastPrice - Стрим с подпиской на последние цены testFigi, Listen работает в отдельной горутине
+// и возвращает ошибку в канал
+func listenLastPrice(t *testing.T, srv *fake.Server, policy investgo.RetryPolicy) (*investgo.MarketDataStream, <-chan *pb.LastPrice, <-chan error) {
+	t.Helper()
+	conf := srv.Config()
+	conf.RetryPolicies = map[string]investgo.RetryPolicy{string(investgo.RetryCategoryStream): policy}
+	client := newTestClient(t, conf)
+	stream, err := client.NewMarketDataStreamClient().MarketDataStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stream.Stop)
+	prices, err := stream.SubscribeLastPrice([]string{testFigi})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.Listen()
+	}()
+	receiveLastPrice(t, srv, 251, prices)
+	return stream, prices, done
+}
+
+func TestMarketDataStreamReconnectsByPolicy(t *testing.T) {
+	srv := newTestServer(t)
+	stream, prices, done := listenLastPrice(t, srv, investgo.RetryPolicy{
+		Codes:          []string{"Unavailable"},
+		MaxAttempts:    4,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+	})
+	opened := srv.Calls(marketDataStreamMethod)
+
+	// первое переподключение падает, второе проходит
+	srv.InjectError(marketDataStreamMethod, status.Error(codes.Unavailable, "unavailable"))
+	srv.BreakStreams()
+	select {
+	case ev := <-stream.Reconnects():
+		if ev.Attempt != 2 || status.Code(ev.Err) != codes.Unavailable {
+			t.Fatalf("reconnect = %+v, want attempt 2 after Unavailable", ev)
+		}
+	case err := <-done:
+		t.Fatalf("Listen returned %v, want reconnect", err)
+	case <-time.After(time.Second):
+		// ожидание по умолчанию WAIT_BETWEEN не укладывается в секунду на две попытки
+		t.Fatal("stream was not reconnected with policy backoff")
+	}
+	if calls := srv.Calls(marketDataStreamMethod) - opened; calls != 2 {
+		t.Fatalf("stream reopened %d times, want 2", calls)
+	}
+	// подписки восстановлены
+	receiveLastPrice(t, srv, 252, prices)
+}
+
+func TestMarketDataStreamAttemptsExhausted(t *testing.T) {
+	srv := newTestServer(t)
+	_, _, done := listenLastPrice(t, srv, investgo.RetryPolicy{
+		Codes:          []string{"Unavailable"},
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	})
+	opened := srv.Calls(marketDataStreamMethod)
+
+	srv.InjectError(marketDataStreamMethod, status.Error(codes.Unavailable, "unavailable"))
+	srv.BreakStreams()
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("Listen returned %v, want Unavailable", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("Listen did not stop after the last attempt")
+	}
+	if calls := srv.Calls(marketDataStreamMethod) - opened; calls != 1 {
+		t.Fatalf("stream reopened %d times, want 1", calls)
+	}
+}
+
+func TestMarketDataStreamCodeNotInPolicy(t *testing.T) {
+	srv := newTestServer(t)
+	_, _, done := listenLastPrice(t, srv, investgo.RetryPolicy{
+		Codes:          []string{"Internal"},
+		InitialBackoff: time.Millisecond,
+	})
+	opened := srv.Calls(marketDataStreamMethod)
+
+	srv.BreakStreams()
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("Listen returned %v, want Unavailable", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("Listen did not stop on an error outside the policy")
+	}
+	if calls := srv.Calls(marketDataStreamMethod) - opened; calls != 0 {
+		t.Fatalf("stream reopened %d times, want 0", calls)
+	}
+}
+
+// candleRound - Отправка свечей testFigi с интервалами минута и пять минут с меткой в цене закрытия.
+// Возвращает интервалы, пришедшие до пятиминутной свечи с меткой, или nil, если она не пришла
+func candleRound(t *testing.T, srv *fake.Server, ch <-chan *pb.Candle, mark int64) map[pb.SubscriptionInterval]bool {
+	t.Helper()
+	oneMin := pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE
+	fiveMin := pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_FIVE_MINUTES
+	for _, interval := range []pb.SubscriptionInterval{oneMin, fiveMin} {
+		if err := srv.PushCandle(testFigi, &pb.Candle{Interval: interval, Close: fake.Quotation(mark)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := make(map[pb.SubscriptionInterval]bool)
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case c := <-ch:
+			if c.GetClose().GetUnits() != mark {
+				continue
+			}
+			got[c.GetInterval()] = true
+			if c.GetInterval() == fiveMin {
+				return got
+			}
+		case <-timeout:
+			return nil
+		}
+	}
+}
+
+func TestMarketDataStreamCandleIntervals(t *testing.T) {
+	srv := newTestServer(t)
+	conf := srv.Config()
+	conf.RetryPolicies = map[string]investgo.RetryPolicy{string(investgo.RetryCategoryStream): {
+		Codes:          []string{"Unavailable"},
+		InitialBackoff: 10 * time.Millisecond,
+	}}
+	stream, err := newTestClient(t, conf).NewMarketDataStreamClient().MarketDataStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stream.Stop)
+	oneMin := pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_ONE_MINUTE
+	fiveMin := pb.SubscriptionInterval_SUBSCRIPTION_INTERVAL_FIVE_MINUTES
+	candles, err := stream.SubscribeCandle([]string{testFigi}, oneMin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.SubscribeCandle([]string{testFigi}, fiveMin, false); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = stream.Listen()
+	}()
+
+	mark := int64(0)
+	both := func() bool {
+		mark++
+		got := candleRound(t, srv, candles, mark)
+		return got[oneMin] && got[fiveMin]
+	}
+	eventually(t, "candles of both intervals were not received", both)
+
+	// после переподключения восстанавливаются подписки с обоими интервалами
+	srv.BreakStreams()
+	select {
+	case <-stream.Reconnects():
+	case <-time.After(testTimeout):
+		t.Fatal("stream was not reconnected")
+	}
+	eventually(t, "candles of both intervals were not received after reconnect", both)
+
+	// отписка от минутных свечей сохраняет пятиминутные
+	if err := stream.UnSubscribeCandle([]string{testFigi}, oneMin, false); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "one minute candles were not unsubscribed", func() bool {
+		mark++
+		got := candleRound(t, srv, candles, mark)
+		return got != nil && !got[oneMin]
+	})
+}
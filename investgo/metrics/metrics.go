@@ -0,0 +1,173 @@
+/*
+Package metrics собирает метрики вызовов InvestAPI в формате Prometheus: длительность и количество unary-запросов
+по методам и кодам ответа, ретраи, остаток лимита запросов, открытые стримы и количество сообщений в стримах
+MarketDataStream, TradesStream, PortfolioStream и PositionsStream.
+
+	m := metrics.New(metrics.NewRegistry())
+	client, err := investgo.NewClient(ctx, config, logger, m.ClientOptions()...)
+	...
+	http.Handle("/metrics", m.Registry().Handler())
+*/
+package metrics
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/retry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Metrics - Метрики клиента InvestAPI
+type Metrics struct {
+	registry *Registry
+
+	requests           *Counter
+	requestDuration    *Histogram
+	retries            *Counter
+	rateLimitRemaining *Gauge
+	streamsOpen        *Gauge
+	streamMessages     *Counter
+	streamErrors       *Counter
+}
+
+// New - Регистрация метрик клиента в registry
+func New(registry *Registry) *Metrics {
+	return &Metrics{
+		registry: registry,
+		requests: registry.NewCounter("investgo_requests_total",
+			"Number of unary requests by method and grpc code.", "method", "code"),
+		requestDuration: registry.NewHistogram("investgo_request_duration_seconds",
+			"Unary request duration including retries.", DefaultBuckets, "method"),
+		retries: registry.NewCounter("investgo_retries_total",
+			"Number of retried unary request attempts.", "method"),
+		rateLimitRemaining: registry.NewGauge("investgo_ratelimit_remaining",
+			"Remaining requests from the last x-ratelimit-remaining header.", "method"),
+		streamsOpen: registry.NewGauge("investgo_streams_open",
+			"Number of open streams.", "stream"),
+		streamMessages: registry.NewCounter("investgo_stream_messages_total",
+			"Number of received stream messages by payload type.", "stream", "payload"),
+		streamErrors: registry.NewCounter("investgo_stream_errors_total",
+			"Number of stream receive errors by grpc code.", "stream", "code"),
+	}
+}
+
+// Registry - Набор метрик, в котором зарегистрированы метрики клиента
+func (m *Metrics) Registry() *Registry {
+	return m.registry
+}
+
+// ClientOptions - Опции для investgo.NewClient, которые добавляют интерсепторы метрик
+func (m *Metrics) ClientOptions() []investgo.Option {
+	return []investgo.Option{
+		investgo.WithUnaryInterceptors(m.UnaryInterceptor()),
+		investgo.WithUnaryAttemptInterceptors(m.UnaryAttemptInterceptor()),
+		investgo.WithStreamAttemptInterceptors(m.StreamInterceptor()),
+	}
+}
+
+// UnaryInterceptor - Интерсептор вызова: количество и длительность запросов
+func (m *Metrics) UnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		name := shortMethod(method)
+		m.requestDuration.Observe(time.Since(start).Seconds(), name)
+		m.requests.Inc(name, status.Code(err).String())
+		return err
+	}
+}
+
+// UnaryAttemptInterceptor - Интерсептор попытки: ретраи и остаток лимита запросов
+func (m *Metrics) UnaryAttemptInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		name := shortMethod(method)
+		if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(retry.AttemptMetadataKey)) > 0 {
+			m.retries.Inc(name)
+		}
+		var header, trailer metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header), grpc.Trailer(&trailer))...)
+		if err != nil {
+			header = trailer
+		}
+		if remaining := investgo.RemainingLimitFromHeader(header); remaining >= 0 {
+			m.rateLimitRemaining.Set(float64(remaining), name)
+		}
+		return err
+	}
+}
+
+// StreamInterceptor - Интерсептор стримов: открытые стримы, сообщения по типам и ошибки
+func (m *Metrics) StreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		name := shortMethod(method)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			m.streamErrors.Inc(name, status.Code(err).String())
+			return nil, err
+		}
+		m.streamsOpen.Add(1, name)
+		return &observedStream{ClientStream: stream, metrics: m, name: name}, nil
+	}
+}
+
+// observedStream - обертка стрима, которая считает полученные сообщения
+type observedStream struct {
+	grpc.ClientStream
+	metrics *Metrics
+	name    string
+	once    sync.Once
+}
+
+func (s *observedStream) RecvMsg(msg any) error {
+	err := s.ClientStream.RecvMsg(msg)
+	if err != nil {
+		s.once.Do(func() {
+			s.metrics.streamsOpen.Add(-1, s.name)
+			// io.EOF и отмена контекста - штатное завершение стрима
+			if err != io.EOF && status.Code(err) != codes.Canceled {
+				s.metrics.streamErrors.Inc(s.name, status.Code(err).String())
+			}
+		})
+		return err
+	}
+	s.metrics.streamMessages.Inc(s.name, payloadName(msg))
+	return nil
+}
+
+// payloadName - тип сообщения стрима по заполненному полю oneof payload, например candle или ping
+func payloadName(msg any) string {
+	pm, ok := msg.(proto.Message)
+	if !ok {
+		return "unknown"
+	}
+	r := pm.ProtoReflect()
+	oneof := r.Descriptor().Oneofs().ByName("payload")
+	if oneof == nil {
+		return "unknown"
+	}
+	field := r.WhichOneof(oneof)
+	if field == nil {
+		return "none"
+	}
+	return string(field.Name())
+}
+
+// shortMethod - имя метода без пакета: "/tinkoff.public.invest.api.contract.v1.UsersService/GetInfo" -> "UsersService/GetInfo"
+func shortMethod(method string) string {
+	method = strings.TrimPrefix(method, "/")
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		if j := strings.LastIndex(method[:i], "."); j >= 0 {
+			return method[j+1:]
+		}
+	}
+	return method
+}
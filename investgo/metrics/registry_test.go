@@ -0,0 +1,83 @@
+package metrics_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo/metrics"
+)
+
+const golden = `# HELP requests_total Requests by method.
+# TYPE requests_total counter
+requests_total{method="GetCandles",code="OK"} 2
+requests_total{method="PostOrder",code="say \"hi\"\\\n"} 1.5
+# HELP in_flight Streams in flight,\nwith a \\ backslash
+# TYPE in_flight gauge
+in_flight 3
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="GetCandles",le="0.1"} 1
+latency_seconds_bucket{method="GetCandles",le="0.5"} 3
+latency_seconds_bucket{method="GetCandles",le="1"} 3
+latency_seconds_bucket{method="GetCandles",le="+Inf"} 4
+latency_seconds_sum{method="GetCandles"} 3.35
+latency_seconds_count{method="GetCandles"} 4
+`
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := metrics.NewRegistry()
+	requests := r.NewCounter("requests_total", "Requests by method.", "method", "code")
+	gauge := r.NewGauge("in_flight", "Streams in flight,\nwith a \\ backslash")
+	// бакеты сортируются при регистрации
+	latency := r.NewHistogram("latency_seconds", "Request latency.", []float64{1, 0.1, 0.5}, "method")
+	// метрика без значений не выводится
+	r.NewCounter("unused_total", "Unused.")
+
+	requests.Inc("GetCandles", "OK")
+	requests.Inc("GetCandles", "OK")
+	requests.Add(1.5, "PostOrder", "say \"hi\"\\\n")
+	gauge.Set(5)
+	gauge.Add(-2)
+	// граница бакета входит в бакет, значение больше всех границ попадает только в +Inf
+	for _, v := range []float64{0.1, 0.25, 0.5, 2.5} {
+		latency.Observe(v, "GetCandles")
+	}
+
+	var sb strings.Builder
+	n, err := r.WriteTo(&sb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != golden {
+		t.Fatalf("output:\n%s\nwant:\n%s", sb.String(), golden)
+	}
+	if n != int64(len(golden)) {
+		t.Fatalf("n = %d, want %d", n, len(golden))
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") || rec.Body.String() != golden {
+		t.Fatalf("content type %q, body:\n%s", ct, rec.Body.String())
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := r.NewCounter("requests_total", "Requests.", "method")
+	for name, fn := range map[string]func(){
+		"duplicate":          func() { r.NewGauge("requests_total", "Duplicate.") },
+		"label count":        func() { c.Inc("a", "b") },
+		"decreasing counter": func() { c.Add(-1, "a") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%s: want panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -40,6 +40,9 @@ type ReplaceOrderRequest struct {
 type PostOrderResponse struct {
 	*pb.PostOrderResponse
 	Header metadata.MD
+	// RequestId - Ключ идемпотентности, с которым отправлена заявка: OrderId или NewOrderId из запроса
+	// или созданный клиентом, если в запросе он пустой. Заполняется и при ошибке
+	RequestId string
 }
 
 func (p *PostOrderResponse) GetHeader() metadata.MD {
@@ -595,3 +598,16 @@ type GetHistoricCandlesRequest struct {
 	File       bool
 	FileName   string
 }
+
+// MarketDataServerSideStreamRequest - Подписки серверного стрима маркетдаты, пустой список инструментов означает
+// отсутствие подписки на этот тип данных
+type MarketDataServerSideStreamRequest struct {
+	Candles         []string
+	CandleInterval  pb.SubscriptionInterval
+	WaitingClose    bool
+	OrderBooks      []string
+	OrderBookDepth  int32
+	Trades          []string
+	TradingStatuses []string
+	LastPrices      []string
+}
@@ -19,8 +19,13 @@ type OperationsServiceClient struct {
 
 // GetOperations - Метод получения списка операций по счёту
 func (os *OperationsServiceClient) GetOperations(req *GetOperationsRequest) (*OperationsResponse, error) {
+	return os.GetOperationsWithContext(os.ctx, req)
+}
+
+// GetOperationsWithContext - Метод получения списка операций по счёту
+func (os *OperationsServiceClient) GetOperationsWithContext(ctx context.Context, req *GetOperationsRequest) (*OperationsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetOperations(os.ctx, &pb.OperationsRequest{
+	resp, err := os.pbClient.GetOperations(ctx, &pb.OperationsRequest{
 		AccountId: req.AccountId,
 		From:      TimeToTimestamp(req.From),
 		To:        TimeToTimestamp(req.To),
@@ -38,8 +43,13 @@ func (os *OperationsServiceClient) GetOperations(req *GetOperationsRequest) (*Op
 
 // GetPortfolio - Метод получения портфеля по счёту
 func (os *OperationsServiceClient) GetPortfolio(accountId string, currency pb.PortfolioRequest_CurrencyRequest) (*PortfolioResponse, error) {
+	return os.GetPortfolioWithContext(os.ctx, accountId, currency)
+}
+
+// GetPortfolioWithContext - Метод получения портфеля по счёту
+func (os *OperationsServiceClient) GetPortfolioWithContext(ctx context.Context, accountId string, currency pb.PortfolioRequest_CurrencyRequest) (*PortfolioResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetPortfolio(os.ctx, &pb.PortfolioRequest{
+	resp, err := os.pbClient.GetPortfolio(ctx, &pb.PortfolioRequest{
 		AccountId: accountId,
 		Currency:  currency,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
@@ -54,8 +64,13 @@ func (os *OperationsServiceClient) GetPortfolio(accountId string, currency pb.Po
 
 // GetPositions - Метод получения списка позиций по счёту
 func (os *OperationsServiceClient) GetPositions(accountId string) (*PositionsResponse, error) {
+	return os.GetPositionsWithContext(os.ctx, accountId)
+}
+
+// GetPositionsWithContext - Метод получения списка позиций по счёту
+func (os *OperationsServiceClient) GetPositionsWithContext(ctx context.Context, accountId string) (*PositionsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetPositions(os.ctx, &pb.PositionsRequest{
+	resp, err := os.pbClient.GetPositions(ctx, &pb.PositionsRequest{
 		AccountId: accountId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -69,8 +84,13 @@ func (os *OperationsServiceClient) GetPositions(accountId string) (*PositionsRes
 
 // GetWithdrawLimits - Метод получения доступного остатка для вывода средств
 func (os *OperationsServiceClient) GetWithdrawLimits(accountId string) (*WithdrawLimitsResponse, error) {
+	return os.GetWithdrawLimitsWithContext(os.ctx, accountId)
+}
+
+// GetWithdrawLimitsWithContext - Метод получения доступного остатка для вывода средств
+func (os *OperationsServiceClient) GetWithdrawLimitsWithContext(ctx context.Context, accountId string) (*WithdrawLimitsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetWithdrawLimits(os.ctx, &pb.WithdrawLimitsRequest{
+	resp, err := os.pbClient.GetWithdrawLimits(ctx, &pb.WithdrawLimitsRequest{
 		AccountId: accountId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -84,8 +104,13 @@ func (os *OperationsServiceClient) GetWithdrawLimits(accountId string) (*Withdra
 
 // GetBrokerReport - Метод получения брокерского отчёта
 func (os *OperationsServiceClient) GetBrokerReport(taskId string, page int32) (*GetBrokerReportResponse, error) {
+	return os.GetBrokerReportWithContext(os.ctx, taskId, page)
+}
+
+// GetBrokerReportWithContext - Метод получения брокерского отчёта
+func (os *OperationsServiceClient) GetBrokerReportWithContext(ctx context.Context, taskId string, page int32) (*GetBrokerReportResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetBrokerReport(os.ctx, &pb.BrokerReportRequest{
+	resp, err := os.pbClient.GetBrokerReport(ctx, &pb.BrokerReportRequest{
 		Payload: &pb.BrokerReportRequest_GetBrokerReportRequest{
 			GetBrokerReportRequest: &pb.GetBrokerReportRequest{
 				TaskId: taskId,
@@ -104,8 +129,13 @@ func (os *OperationsServiceClient) GetBrokerReport(taskId string, page int32) (*
 
 // GenerateBrokerReport - Метод получения брокерского отчёта
 func (os *OperationsServiceClient) GenerateBrokerReport(accountId string, from, to time.Time) (*GenerateBrokerReportResponse, error) {
+	return os.GenerateBrokerReportWithContext(os.ctx, accountId, from, to)
+}
+
+// GenerateBrokerReportWithContext - Метод получения брокерского отчёта
+func (os *OperationsServiceClient) GenerateBrokerReportWithContext(ctx context.Context, accountId string, from, to time.Time) (*GenerateBrokerReportResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetBrokerReport(os.ctx, &pb.BrokerReportRequest{
+	resp, err := os.pbClient.GetBrokerReport(ctx, &pb.BrokerReportRequest{
 		Payload: &pb.BrokerReportRequest_GenerateBrokerReportRequest{
 			GenerateBrokerReportRequest: &pb.GenerateBrokerReportRequest{
 				AccountId: accountId,
@@ -125,8 +155,13 @@ func (os *OperationsServiceClient) GenerateBrokerReport(accountId string, from,
 
 // GetDividentsForeignIssuer - Метод получения отчёта "Справка о доходах за пределами РФ"
 func (os *OperationsServiceClient) GetDividentsForeignIssuer(taskId string, page int32) (*GetDividendsForeignIssuerResponse, error) {
+	return os.GetDividentsForeignIssuerWithContext(os.ctx, taskId, page)
+}
+
+// GetDividentsForeignIssuerWithContext - Метод получения отчёта "Справка о доходах за пределами РФ"
+func (os *OperationsServiceClient) GetDividentsForeignIssuerWithContext(ctx context.Context, taskId string, page int32) (*GetDividendsForeignIssuerResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetDividendsForeignIssuer(os.ctx, &pb.GetDividendsForeignIssuerRequest{
+	resp, err := os.pbClient.GetDividendsForeignIssuer(ctx, &pb.GetDividendsForeignIssuerRequest{
 		Payload: &pb.GetDividendsForeignIssuerRequest_GetDivForeignIssuerReport{
 			GetDivForeignIssuerReport: &pb.GetDividendsForeignIssuerReportRequest{
 				TaskId: taskId,
@@ -145,8 +180,13 @@ func (os *OperationsServiceClient) GetDividentsForeignIssuer(taskId string, page
 
 // GenerateDividentsForeignIssuer - Метод получения отчёта "Справка о доходах за пределами РФ"
 func (os *OperationsServiceClient) GenerateDividentsForeignIssuer(accountId string, from, to time.Time) (*GetDividendsForeignIssuerResponse, error) {
+	return os.GenerateDividentsForeignIssuerWithContext(os.ctx, accountId, from, to)
+}
+
+// GenerateDividentsForeignIssuerWithContext - Метод получения отчёта "Справка о доходах за пределами РФ"
+func (os *OperationsServiceClient) GenerateDividentsForeignIssuerWithContext(ctx context.Context, accountId string, from, to time.Time) (*GetDividendsForeignIssuerResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetDividendsForeignIssuer(os.ctx, &pb.GetDividendsForeignIssuerRequest{
+	resp, err := os.pbClient.GetDividendsForeignIssuer(ctx, &pb.GetDividendsForeignIssuerRequest{
 		Payload: &pb.GetDividendsForeignIssuerRequest_GenerateDivForeignIssuerReport{
 			GenerateDivForeignIssuerReport: &pb.GenerateDividendsForeignIssuerReportRequest{
 				AccountId: accountId,
@@ -166,15 +206,25 @@ func (os *OperationsServiceClient) GenerateDividentsForeignIssuer(accountId stri
 
 // GetOperationsByCursorShort - Метод получения списка операций по счёту с пагинацией
 func (os *OperationsServiceClient) GetOperationsByCursorShort(accountId string) (*GetOperationsByCursorResponse, error) {
-	return os.GetOperationsByCursor(&GetOperationsByCursorRequest{
+	return os.GetOperationsByCursorShortWithContext(os.ctx, accountId)
+}
+
+// GetOperationsByCursorShortWithContext - Метод получения списка операций по счёту с пагинацией
+func (os *OperationsServiceClient) GetOperationsByCursorShortWithContext(ctx context.Context, accountId string) (*GetOperationsByCursorResponse, error) {
+	return os.GetOperationsByCursorWithContext(ctx, &GetOperationsByCursorRequest{
 		AccountId: accountId,
 	})
 }
 
 // GetOperationsByCursor - Метод получения списка операций по счёту с пагинацией
 func (os *OperationsServiceClient) GetOperationsByCursor(req *GetOperationsByCursorRequest) (*GetOperationsByCursorResponse, error) {
+	return os.GetOperationsByCursorWithContext(os.ctx, req)
+}
+
+// GetOperationsByCursorWithContext - Метод получения списка операций по счёту с пагинацией
+func (os *OperationsServiceClient) GetOperationsByCursorWithContext(ctx context.Context, req *GetOperationsByCursorRequest) (*GetOperationsByCursorResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetOperationsByCursor(os.ctx, &pb.GetOperationsByCursorRequest{
+	resp, err := os.pbClient.GetOperationsByCursor(ctx, &pb.GetOperationsByCursorRequest{
 		AccountId:          req.AccountId,
 		InstrumentId:       req.InstrumentId,
 		From:               TimeToTimestamp(req.From),
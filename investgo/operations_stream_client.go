@@ -18,13 +18,20 @@ type OperationsStreamClient struct {
 
 // PortfolioStream - Server-side stream обновлений портфеля
 func (o *OperationsStreamClient) PortfolioStream(accounts []string) (*PortfolioStream, error) {
-	ctx, cancel := context.WithCancel(o.ctx)
+	return o.PortfolioStreamWithContext(o.ctx, accounts)
+}
+
+// PortfolioStreamWithContext - Server-side stream обновлений портфеля
+// ctx определяет время жизни стрима, после его отмены стрим завершается
+func (o *OperationsStreamClient) PortfolioStreamWithContext(ctx context.Context, accounts []string) (*PortfolioStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	ps := &PortfolioStream{
 		stream:           nil,
 		operationsClient: o,
 		portfolios:       make(chan *pb.PortfolioResponse),
 		ctx:              ctx,
 		cancel:           cancel,
+		accounts:         accounts,
 	}
 	stream, err := o.pbClient.PortfolioStream(ctx, &pb.PortfolioStreamRequest{
 		Accounts: accounts,
@@ -39,13 +46,20 @@ func (o *OperationsStreamClient) PortfolioStream(accounts []string) (*PortfolioS
 
 // PositionsStream - Server-side stream обновлений информации по изменению позиций портфеля
 func (o *OperationsStreamClient) PositionsStream(accounts []string) (*PositionsStream, error) {
-	ctx, cancel := context.WithCancel(o.ctx)
+	return o.PositionsStreamWithContext(o.ctx, accounts)
+}
+
+// PositionsStreamWithContext - Server-side stream обновлений информации по изменению позиций портфеля
+// ctx определяет время жизни стрима, после его отмены стрим завершается
+func (o *OperationsStreamClient) PositionsStreamWithContext(ctx context.Context, accounts []string) (*PositionsStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	ps := &PositionsStream{
 		stream:           nil,
 		operationsClient: o,
 		positions:        make(chan *pb.PositionData),
 		ctx:              ctx,
 		cancel:           cancel,
+		accounts:         accounts,
 	}
 	stream, err := o.pbClient.PositionsStream(ctx, &pb.PositionsStreamRequest{
 		Accounts: accounts,
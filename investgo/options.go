@@ -0,0 +1,70 @@
+package investgo
+
+import "google.golang.org/grpc"
+
+// Option - Дополнительная настройка клиента при создании через NewClient
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	unaryInterceptors         []grpc.UnaryClientInterceptor
+	unaryAttemptInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors        []grpc.StreamClientInterceptor
+	streamAttemptInterceptors []grpc.StreamClientInterceptor
+	dialOptions               []grpc.DialOption
+	accountSelector           AccountSelector
+	idempotencyStore          IdempotencyStore
+}
+
+// WithUnaryInterceptors - Интерсепторы unary-запросов, которые вызываются один раз на вызов метода, до ретраев.
+// Они получают итоговый результат вызова, ошибки уже переведены в *Error
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(o *clientOptions) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithUnaryAttemptInterceptors - Интерсепторы unary-запросов, которые вызываются на каждую попытку после ретраеров
+// и лимитера, то есть непосредственно перед отправкой запроса. Ошибки в них приходят в виде статусов grpc
+func WithUnaryAttemptInterceptors(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(o *clientOptions) {
+		o.unaryAttemptInterceptors = append(o.unaryAttemptInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors - Интерсепторы стримов, которые вызываются один раз при открытии стрима, до ретраера
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) Option {
+	return func(o *clientOptions) {
+		o.streamInterceptors = append(o.streamInterceptors, interceptors...)
+	}
+}
+
+// WithStreamAttemptInterceptors - Интерсепторы стримов, которые вызываются после ретраера, в том числе при каждом
+// переоткрытии серверного стрима
+func WithStreamAttemptInterceptors(interceptors ...grpc.StreamClientInterceptor) Option {
+	return func(o *clientOptions) {
+		o.streamAttemptInterceptors = append(o.streamAttemptInterceptors, interceptors...)
+	}
+}
+
+// WithDialOptions - Дополнительные опции grpc соединения, применяются после опций по умолчанию
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *clientOptions) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+// WithAccountSelector - Политика выбора счета, если в конфигурации не указан AccountId.
+// Заменяет политику из AccountName и AccountType конфигурации
+func WithAccountSelector(selector AccountSelector) Option {
+	return func(o *clientOptions) {
+		o.accountSelector = selector
+	}
+}
+
+// WithIdempotencyStore - Хранилище ключей идемпотентности заявок, например NewFileIdempotencyStore, чтобы ключи
+// переживали перезапуск приложения. По умолчанию ключи хранятся в памяти процесса
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(o *clientOptions) {
+		o.idempotencyStore = store
+	}
+}
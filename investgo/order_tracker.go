@@ -0,0 +1,982 @@
+package investgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Ошибки ожидания заявок OrderTracker
+var (
+	// ErrOrderNotTracked - Заявка с таким order_request_id не отслеживается
+	ErrOrderNotTracked = errors.New("order is not tracked")
+	// ErrOrderRejected - Заявка отклонена
+	ErrOrderRejected = errors.New("order rejected")
+	// ErrOrderCancelled - Заявка отменена
+	ErrOrderCancelled = errors.New("order cancelled")
+	// ErrOrderPending - Выставление заявки не подтверждено, см. TrackedOrder.Pending
+	ErrOrderPending = errors.New("order is pending")
+)
+
+const (
+	// DefaultReconcileInterval - Период сверки активных заявок через GetOrderState, пока TradesStream недоступен
+	DefaultReconcileInterval = 2 * time.Second
+	// DefaultStreamReconcileInterval - Период сверки активных заявок, пока TradesStream работает
+	DefaultStreamReconcileInterval = time.Minute
+	// DefaultPendingTimeout - Сколько заявка без ответа PostOrder ищется в GetOrders и TradesStream, прежде чем
+	// считаться невыставленной
+	DefaultPendingTimeout = 10 * time.Second
+	// maxOrphanTrades - сколько событий об исполнении незнакомых заявок хранится до ответа PostOrder
+	maxOrphanTrades = 1000
+	// maxUntrackedTrades - сколько последних событий по заявкам не из OrderTracker хранится для UntrackedTrades
+	maxUntrackedTrades = 1000
+)
+
+// TrackedOrder - Состояние заявки в OrderTracker
+type TrackedOrder struct {
+	// RequestId - Идентификатор запроса выставления заявки order_request_id, ключ заявки в OrderTracker
+	RequestId string
+	// OrderId - Идентификатор заявки на бирже, пустой, пока выставление заявки не подтверждено
+	OrderId       string
+	AccountId     string
+	InstrumentId  string
+	Direction     pb.OrderDirection
+	Status        pb.OrderExecutionReportStatus
+	LotsRequested int64
+	LotsExecuted  int64
+	// Trades - Сделки по заявке из TradesStream
+	Trades []*pb.OrderTrade
+	// State - Последнее состояние заявки из GetOrderState, nil до первой сверки
+	State *pb.OrderState
+	// UpdatedAt - Время последнего изменения
+	UpdatedAt time.Time
+}
+
+// Done - Заявка в конечном статусе: исполнена, отклонена или отменена
+func (o TrackedOrder) Done() bool {
+	switch o.Status {
+	case pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL,
+		pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED,
+		pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED:
+		return true
+	default:
+		return false
+	}
+}
+
+// Pending - PostOrder вернул ошибку без ответа сервера, и выставлена ли заявка, пока не известно. Такую заявку
+// сверка ищет по order_request_id в GetOrders, а к сделкам TradesStream по незнакомой заявке она привязывается,
+// если это единственная ожидающая заявка по тому же счету, инструменту и направлению. Если заявка не нашлась
+// за WithPendingTimeout, она получает статус REJECTED. Без TradesStream так может завершиться и заявка,
+// которая успела исполниться полностью
+func (o TrackedOrder) Pending() bool {
+	return o.OrderId == "" && !o.Done()
+}
+
+// Filled - Заявка исполнена полностью
+func (o TrackedOrder) Filled() bool {
+	return o.Status == pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL
+}
+
+// Err - Ошибка для отклоненной или отмененной заявки, иначе nil
+func (o TrackedOrder) Err() error {
+	switch o.Status {
+	case pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED:
+		return ErrOrderRejected
+	case pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED:
+		return ErrOrderCancelled
+	default:
+		return nil
+	}
+}
+
+// OrderTrackerOption - Параметры OrderTracker
+type OrderTrackerOption func(*OrderTracker)
+
+// WithReconcileInterval - Период сверки заявок через GetOrderState, пока TradesStream недоступен
+func WithReconcileInterval(d time.Duration) OrderTrackerOption {
+	return func(t *OrderTracker) {
+		t.reconcileInterval = d
+	}
+}
+
+// WithStreamReconcileInterval - Период сверки заявок, пока TradesStream работает
+func WithStreamReconcileInterval(d time.Duration) OrderTrackerOption {
+	return func(t *OrderTracker) {
+		t.streamReconcileInterval = d
+	}
+}
+
+// WithPendingTimeout - Сколько заявка без ответа PostOrder ищется, прежде чем считаться невыставленной
+func WithPendingTimeout(d time.Duration) OrderTrackerOption {
+	return func(t *OrderTracker) {
+		t.pendingTimeout = d
+	}
+}
+
+// WithOrderCallback - Функция, которая вызывается при каждом изменении заявки. Вызовы идут из одной горутины
+// по порядку изменений, из функции можно выставлять новые заявки через OrderTracker
+func WithOrderCallback(fn func(o TrackedOrder)) OrderTrackerOption {
+	return func(t *OrderTracker) {
+		t.callbacks = append(t.callbacks, fn)
+	}
+}
+
+// WithOrderTrackerLogger - Логгер для ошибок сверки и переключения на опрос GetOrderState
+func WithOrderTrackerLogger(l Logger) OrderTrackerOption {
+	return func(t *OrderTracker) {
+		t.logger = l
+	}
+}
+
+// OrderTracker - Менеджер заявок. Запоминает выставленные через него заявки по order_request_id, обновляет их
+// состояние по событиям TradesStream и сверяет через GetOrderState, когда стрим недоступен. Об изменениях
+// сообщает через функции WithOrderCallback, каналы Subscribe и ожидание Await
+type OrderTracker struct {
+	trading                 Trading
+	logger                  Logger
+	reconcileInterval       time.Duration
+	streamReconcileInterval time.Duration
+	pendingTimeout          time.Duration
+	callbacks               []func(o TrackedOrder)
+
+	mu sync.Mutex
+	// orders - заявки по order_request_id
+	orders map[string]*trackedOrder
+	// byOrderId - заявки по order_id биржи
+	byOrderId map[string]*trackedOrder
+	// orphans - события TradesStream, которые пришли раньше ответа PostOrder
+	orphans      map[string][]*pb.OrderTrades
+	orphansCount int
+	// untracked - последние события TradesStream по заявкам, которых не было в OrderTracker
+	untracked []untrackedTrades
+	// stale - заявки, которые нужно сверить после события TradesStream
+	stale       map[string]struct{}
+	wake        chan struct{}
+	subscribers map[*OrderSubscription]struct{}
+	closed      bool
+
+	// queue - изменения для рассылки, рассылает одна горутина, чтобы функции могли вызывать OrderTracker
+	queue     []notification
+	queueWake chan struct{}
+	done      chan struct{}
+}
+
+// trackedOrder - заявка и канал, который закрывается при ее изменении
+type trackedOrder struct {
+	TrackedOrder
+	changed chan struct{}
+	// postedAt - время отправки PostOrder, сделки ожидающей заявки ищутся с этого момента
+	postedAt time.Time
+}
+
+// untrackedTrades - событие TradesStream по незнакомой заявке и время его получения
+type untrackedTrades struct {
+	trades     *pb.OrderTrades
+	receivedAt time.Time
+}
+
+// notification - изменение заявки или удаление подписчика для горутины рассылки
+type notification struct {
+	order       TrackedOrder
+	unsubscribe *OrderSubscription
+}
+
+// OrderSubscription - Подписка на изменения заявок OrderTracker
+type OrderSubscription struct {
+	updates  chan TrackedOrder
+	overflow OverflowPolicy
+	dropped  atomic.Uint64
+
+	done chan struct{}
+	once sync.Once
+}
+
+// Updates - Канал изменений заявок. Закрывается при отписке или OrderTracker.Close
+func (s *OrderSubscription) Updates() <-chan TrackedOrder {
+	return s.updates
+}
+
+// Dropped - Количество изменений, выброшенных из-за переполнения буфера
+func (s *OrderSubscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// NewOrderTracker - Менеджер заявок для торговых операций trading
+func NewOrderTracker(trading Trading, opts ...OrderTrackerOption) *OrderTracker {
+	t := &OrderTracker{
+		trading:                 trading,
+		reconcileInterval:       DefaultReconcileInterval,
+		streamReconcileInterval: DefaultStreamReconcileInterval,
+		pendingTimeout:          DefaultPendingTimeout,
+		orders:                  make(map[string]*trackedOrder),
+		byOrderId:               make(map[string]*trackedOrder),
+		orphans:                 make(map[string][]*pb.OrderTrades),
+		stale:                   make(map[string]struct{}),
+		wake:                    make(chan struct{}, 1),
+		subscribers:             make(map[*OrderSubscription]struct{}),
+		queueWake:               make(chan struct{}, 1),
+		done:                    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	go t.dispatch()
+	return t
+}
+
+// NewOrderTracker - Менеджер заявок для торговых операций контура из конфигурации клиента
+func (c *Client) NewOrderTracker(opts ...OrderTrackerOption) *OrderTracker {
+	return NewOrderTracker(c.NewTrading(), append([]OrderTrackerOption{WithOrderTrackerLogger(c.Logger)}, opts...)...)
+}
+
+// PostOrder - Выставление заявки с отслеживанием. Если req.OrderId пустой, order_request_id создается через
+// CreateUid. Возвращает состояние заявки из ответа PostOrder. При ошибке тоже возвращается заявка с RequestId:
+// при отказе сервера или проверок риска - со статусом REJECTED и без отслеживания. Если ответ сервера
+// не получен, например при Unavailable или DeadlineExceeded, заявка могла быть выставлена, она отслеживается
+// как ожидающая подтверждения, см. TrackedOrder.Pending
+func (t *OrderTracker) PostOrder(ctx context.Context, req *PostOrderRequest) (TrackedOrder, error) {
+	r := *req
+	if r.OrderId == "" {
+		r.OrderId = CreateUid()
+	}
+	postedAt := time.Now()
+	resp, err := t.trading.PostOrder(ctx, &r)
+	if err != nil {
+		o := TrackedOrder{
+			RequestId:     r.OrderId,
+			AccountId:     r.AccountId,
+			InstrumentId:  r.InstrumentId,
+			Direction:     r.Direction,
+			LotsRequested: r.Quantity,
+		}
+		if !orderOutcomeUnknown(err) {
+			o.Status = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED
+			return o, err
+		}
+		t.warn("order outcome is unknown, track it as pending", FieldRequestId, r.OrderId,
+			FieldInstrumentId, r.InstrumentId, FieldError, err)
+		return t.registerPending(o, postedAt), err
+	}
+	o := TrackedOrder{
+		RequestId:     r.OrderId,
+		OrderId:       resp.GetOrderId(),
+		AccountId:     r.AccountId,
+		InstrumentId:  r.InstrumentId,
+		Direction:     r.Direction,
+		Status:        resp.GetExecutionReportStatus(),
+		LotsRequested: resp.GetLotsRequested(),
+		LotsExecuted:  resp.GetLotsExecuted(),
+	}
+	return t.register(o), nil
+}
+
+// Track - Отслеживание заявки, выставленной в обход OrderTracker, например до перезапуска приложения.
+// Состояние заявки запрашивается через GetOrderState
+func (t *OrderTracker) Track(ctx context.Context, accountId, orderId string) (TrackedOrder, error) {
+	resp, err := t.trading.GetOrderState(ctx, accountId, orderId)
+	if err != nil {
+		return TrackedOrder{}, err
+	}
+	state := resp.OrderState
+	requestId := state.GetOrderRequestId()
+	if requestId == "" {
+		requestId = orderId
+	}
+	o := TrackedOrder{
+		RequestId:    requestId,
+		OrderId:      orderId,
+		AccountId:    accountId,
+		InstrumentId: state.GetInstrumentUid(),
+		Direction:    state.GetDirection(),
+	}
+	applyOrderState(&o, state)
+	return t.register(o), nil
+}
+
+// CancelOrder - Отмена отслеживаемой заявки по order_request_id. Статус отмены приходит через сверку.
+// Ожидающая заявка сначала ищется в GetOrders, если среди активных ее нет, возвращается ErrOrderNotFound
+func (t *OrderTracker) CancelOrder(ctx context.Context, requestId string) error {
+	o, ok := t.Order(requestId)
+	if !ok {
+		return ErrOrderNotTracked
+	}
+	if o.Pending() {
+		if err := t.reconcilePending(ctx); err != nil {
+			return err
+		}
+		o, _ = t.Order(requestId)
+	}
+	if o.OrderId == "" {
+		return fmt.Errorf("order %s is not active: %w", requestId, ErrOrderNotFound)
+	}
+	if _, err := t.trading.CancelOrder(ctx, o.AccountId, o.OrderId); err != nil {
+		return err
+	}
+	t.markStale(o.OrderId)
+	return nil
+}
+
+// ReplaceOrder - Изменение отслеживаемой заявки через ReplaceOrder. Новая заявка отслеживается с order_request_id
+// newRequestId, если он пустой - создается через CreateUid. priceType - тип цены price, с PRICE_TYPE_UNSPECIFIED
+// цена понимается так же, как в PostOrder. Статус отмены старой заявки приходит через сверку. Ожидающую
+// заявку изменить нельзя, возвращается ErrOrderPending
+func (t *OrderTracker) ReplaceOrder(ctx context.Context, requestId, newRequestId string, quantity int64, price *pb.Quotation, priceType pb.PriceType) (TrackedOrder, error) {
+	old, ok := t.Order(requestId)
+	if !ok {
+		return TrackedOrder{}, ErrOrderNotTracked
+	}
+	if old.Pending() {
+		return TrackedOrder{}, ErrOrderPending
+	}
+	if newRequestId == "" {
+		newRequestId = CreateUid()
+	}
+	resp, err := t.trading.ReplaceOrder(ctx, &ReplaceOrderRequest{
+		AccountId:  old.AccountId,
+		OrderId:    old.OrderId,
+		NewOrderId: newRequestId,
+		Quantity:   quantity,
+		Price:      price,
+		PriceType:  priceType,
+	})
+	if err != nil {
+		return TrackedOrder{}, err
+	}
+	t.markStale(old.OrderId)
+	o := TrackedOrder{
+		RequestId:     newRequestId,
+		OrderId:       resp.GetOrderId(),
+		AccountId:     old.AccountId,
+		InstrumentId:  old.InstrumentId,
+		Direction:     old.Direction,
+		Status:        resp.GetExecutionReportStatus(),
+		LotsRequested: resp.GetLotsRequested(),
+		LotsExecuted:  resp.GetLotsExecuted(),
+	}
+	return t.register(o), nil
+}
+
+// Order - Состояние заявки по order_request_id
+func (t *OrderTracker) Order(requestId string) (TrackedOrder, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, ok := t.orders[requestId]
+	if !ok {
+		return TrackedOrder{}, false
+	}
+	return o.snapshot(), true
+}
+
+// Orders - Состояния всех отслеживаемых заявок
+func (t *OrderTracker) Orders() []TrackedOrder {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	orders := make([]TrackedOrder, 0, len(t.orders))
+	for _, o := range t.orders {
+		orders = append(orders, o.snapshot())
+	}
+	return orders
+}
+
+// UntrackedTrades - События TradesStream по заявкам, выставленным не через OrderTracker, например по биржевым
+// заявкам сработавших стоп-заявок. Возвращает события по счету, инструменту (figi или instrument_uid)
+// и направлению, полученные не раньше since. События приходят только в Run с каналом TradesStream, хранятся
+// последние 1000
+func (t *OrderTracker) UntrackedTrades(accountId, instrumentId string, direction pb.OrderDirection, since time.Time) []*pb.OrderTrades {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var trades []*pb.OrderTrades
+	for _, u := range t.untracked {
+		ot := u.trades
+		// заявка могла оказаться своей, если событие пришло раньше ответа PostOrder
+		if _, ok := t.byOrderId[ot.GetOrderId()]; ok {
+			continue
+		}
+		if u.receivedAt.Before(since) || ot.GetAccountId() != accountId || ot.GetDirection() != direction {
+			continue
+		}
+		if ot.GetFigi() != instrumentId && ot.GetInstrumentUid() != instrumentId {
+			continue
+		}
+		trades = append(trades, ot)
+	}
+	return trades
+}
+
+// Forget - Прекращение отслеживания заявки, например после обработки конечного статуса
+func (t *OrderTracker) Forget(requestId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, ok := t.orders[requestId]
+	if !ok {
+		return
+	}
+	delete(t.orders, requestId)
+	delete(t.byOrderId, o.OrderId)
+	delete(t.stale, o.OrderId)
+	close(o.changed)
+}
+
+// Await - Ожидание, пока заявка не перейдет в один из статусов statuses или в конечный статус.
+// Возвращает последнее состояние заявки и ошибку ctx, если дождаться не удалось
+func (t *OrderTracker) Await(ctx context.Context, requestId string, statuses ...pb.OrderExecutionReportStatus) (TrackedOrder, error) {
+	for {
+		t.mu.Lock()
+		o, ok := t.orders[requestId]
+		if !ok {
+			t.mu.Unlock()
+			return TrackedOrder{}, ErrOrderNotTracked
+		}
+		snapshot, changed := o.snapshot(), o.changed
+		t.mu.Unlock()
+
+		if awaited(snapshot, statuses) {
+			return snapshot, nil
+		}
+		select {
+		case <-ctx.Done():
+			return snapshot, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// AwaitFilled - Ожидание полного исполнения заявки не дольше timeout. Если заявка отклонена или отменена,
+// возвращает ErrOrderRejected или ErrOrderCancelled, по истечении timeout - context.DeadlineExceeded
+func (t *OrderTracker) AwaitFilled(ctx context.Context, requestId string, timeout time.Duration) (TrackedOrder, error) {
+	o, err := t.AwaitDone(ctx, requestId, timeout)
+	if err != nil {
+		return o, err
+	}
+	return o, o.Err()
+}
+
+// AwaitDone - Ожидание конечного статуса заявки не дольше timeout, по истечении timeout возвращает
+// context.DeadlineExceeded
+func (t *OrderTracker) AwaitDone(ctx context.Context, requestId string, timeout time.Duration) (TrackedOrder, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return t.Await(ctx, requestId)
+}
+
+// Subscribe - Подписка на изменения заявок через канал с буфером buffer, если buffer <= 0 -
+// DefaultSubscriberBuffer. При overflow = OverflowBlock медленный подписчик задерживает рассылку остальным
+func (t *OrderTracker) Subscribe(buffer int, overflow OverflowPolicy) *OrderSubscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriberBuffer
+	}
+	s := &OrderSubscription{
+		updates:  make(chan TrackedOrder, buffer),
+		overflow: overflow,
+		done:     make(chan struct{}),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		close(s.updates)
+		return s
+	}
+	t.subscribers[s] = struct{}{}
+	return s
+}
+
+// Unsubscribe - Отписка от изменений заявок, канал подписки закрывается
+func (t *OrderTracker) Unsubscribe(s *OrderSubscription) {
+	s.once.Do(func() { close(s.done) })
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// после Close подписчик остается в t.subscribers, его канал закроет горутина рассылки при остановке
+	if _, ok := t.subscribers[s]; !ok || t.closed {
+		return
+	}
+	delete(t.subscribers, s)
+	// канал закрывает горутина рассылки, она единственная в него пишет
+	t.enqueue(notification{unsubscribe: s})
+}
+
+// Run - Обновление заявок по событиям TradesStream из канала trades и периодическая сверка через
+// GetOrderState до отмены ctx. Если trades nil или закрыт, например при обрыве стрима, заявки сверяются
+// с периодом WithReconcileInterval. Ожидающие заявки ищутся в GetOrders с периодом WithReconcileInterval
+// и при работающем стриме
+func (t *OrderTracker) Run(ctx context.Context, trades <-chan *pb.OrderTrades) error {
+	interval := t.streamReconcileInterval
+	if trades == nil {
+		interval = t.reconcileInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pending := time.NewTicker(t.reconcileInterval)
+	defer pending.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ot, ok := <-trades:
+			if !ok {
+				t.warn("trades stream closed, reconcile orders by GetOrderState")
+				trades = nil
+				ticker.Reset(t.reconcileInterval)
+				_ = t.Reconcile(ctx)
+				continue
+			}
+			t.applyTrades(ot)
+		case <-t.wake:
+			t.reconcileStale(ctx)
+		case <-ticker.C:
+			_ = t.Reconcile(ctx)
+		case <-pending.C:
+			_ = t.reconcilePending(ctx)
+		}
+	}
+}
+
+// Reconcile - Сверка всех незавершенных заявок через GetOrderState, ожидающих заявок - через GetOrders.
+// Возвращает ошибки запросов, состояние остальных заявок при этом обновляется
+func (t *OrderTracker) Reconcile(ctx context.Context) error {
+	t.mu.Lock()
+	active := make([]TrackedOrder, 0, len(t.orders))
+	for _, o := range t.orders {
+		if !o.Done() && !o.Pending() {
+			active = append(active, o.TrackedOrder)
+		}
+	}
+	t.mu.Unlock()
+
+	var errs []error
+	if err := t.reconcilePending(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	for _, o := range active {
+		if err := t.reconcile(ctx, o.AccountId, o.OrderId); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close - Остановка рассылки изменений, каналы подписок закрываются
+func (t *OrderTracker) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	for s := range t.subscribers {
+		s.once.Do(func() { close(s.done) })
+	}
+	close(t.done)
+}
+
+// register - добавление заявки и событий, которые пришли раньше нее
+func (t *OrderTracker) register(o TrackedOrder) TrackedOrder {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o.UpdatedAt = time.Now()
+	tracked, ok := t.orders[o.RequestId]
+	if ok {
+		// повторное выставление с тем же order_request_id возвращает ту же заявку
+		if tracked.OrderId != o.OrderId {
+			delete(t.byOrderId, tracked.OrderId)
+		}
+		if statusRank(o.Status) >= statusRank(tracked.Status) {
+			tracked.Status = o.Status
+			tracked.LotsExecuted = o.LotsExecuted
+		}
+		tracked.OrderId = o.OrderId
+		tracked.LotsRequested = o.LotsRequested
+		if o.State != nil {
+			tracked.State = o.State
+		}
+		tracked.UpdatedAt = o.UpdatedAt
+	} else {
+		tracked = &trackedOrder{TrackedOrder: o, changed: make(chan struct{})}
+		t.orders[o.RequestId] = tracked
+	}
+	t.byOrderId[o.OrderId] = tracked
+	t.claimOrphans(tracked)
+	t.changed(tracked)
+	return tracked.snapshot()
+}
+
+// registerPending - добавление заявки без ответа PostOrder и поиск ее среди уже полученных событий TradesStream
+func (t *OrderTracker) registerPending(o TrackedOrder, postedAt time.Time) TrackedOrder {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tracked, ok := t.orders[o.RequestId]; ok {
+		// заявка с этим order_request_id уже отслеживается
+		return tracked.snapshot()
+	}
+	o.UpdatedAt = time.Now()
+	tracked := &trackedOrder{TrackedOrder: o, changed: make(chan struct{}), postedAt: postedAt}
+	t.orders[o.RequestId] = tracked
+	for _, u := range t.untracked {
+		if _, ok := t.byOrderId[u.trades.GetOrderId()]; !ok && t.pendingFor(u) == tracked {
+			t.attach(tracked, u.trades.GetOrderId())
+			break
+		}
+	}
+	t.changed(tracked)
+	// поиск в GetOrders
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+	return tracked.snapshot()
+}
+
+// attach - ожидающая заявка нашлась на бирже под orderId, вызывается под t.mu
+func (t *OrderTracker) attach(tracked *trackedOrder, orderId string) {
+	tracked.OrderId = orderId
+	tracked.UpdatedAt = time.Now()
+	t.byOrderId[orderId] = tracked
+	t.claimOrphans(tracked)
+	// точный статус дает сверка
+	t.stale[orderId] = struct{}{}
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// claimOrphans - перенос событий, которые пришли раньше заявки, вызывается под t.mu
+func (t *OrderTracker) claimOrphans(tracked *trackedOrder) {
+	orphans, ok := t.orphans[tracked.OrderId]
+	if !ok {
+		return
+	}
+	delete(t.orphans, tracked.OrderId)
+	t.orphansCount -= len(orphans)
+	for _, ot := range orphans {
+		t.addTrades(tracked, ot)
+	}
+}
+
+// pendingFor - ожидающая заявка, к которой относится событие TradesStream по незнакомой заявке: единственная
+// заявка по тому же счету, инструменту и направлению, выставленная до получения события. Вызывается под t.mu
+func (t *OrderTracker) pendingFor(u untrackedTrades) *trackedOrder {
+	ot := u.trades
+	var found *trackedOrder
+	for _, o := range t.orders {
+		if !o.Pending() || u.receivedAt.Before(o.postedAt) || o.AccountId != ot.GetAccountId() || o.Direction != ot.GetDirection() {
+			continue
+		}
+		if o.InstrumentId != ot.GetFigi() && o.InstrumentId != ot.GetInstrumentUid() {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = o
+	}
+	return found
+}
+
+// reconcilePending - поиск ожидающих заявок в GetOrders по order_request_id. Заявка, которая не нашлась
+// дольше WithPendingTimeout, считается невыставленной и получает статус REJECTED
+func (t *OrderTracker) reconcilePending(ctx context.Context) error {
+	t.mu.Lock()
+	accounts := make(map[string]struct{})
+	for _, o := range t.orders {
+		if o.Pending() {
+			accounts[o.AccountId] = struct{}{}
+		}
+	}
+	t.mu.Unlock()
+
+	var errs []error
+	for accountId := range accounts {
+		resp, err := t.trading.GetOrders(ctx, accountId)
+		if err != nil {
+			t.warn("reconcile pending orders failed", FieldAccountId, accountId, FieldError, err)
+			errs = append(errs, err)
+			continue
+		}
+		active := make(map[string]*pb.OrderState, len(resp.GetOrders()))
+		for _, state := range resp.GetOrders() {
+			active[state.GetOrderRequestId()] = state
+		}
+		t.mu.Lock()
+		for _, o := range t.orders {
+			if !o.Pending() || o.AccountId != accountId {
+				continue
+			}
+			if state, ok := active[o.RequestId]; ok {
+				t.attach(o, state.GetOrderId())
+				applyOrderState(&o.TrackedOrder, state)
+				t.changed(o)
+				continue
+			}
+			if time.Since(o.postedAt) > t.pendingTimeout {
+				t.warn("pending order is not found, consider it rejected", FieldRequestId, o.RequestId, FieldAccountId, accountId)
+				o.Status = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED
+				o.UpdatedAt = time.Now()
+				t.changed(o)
+			}
+		}
+		t.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// applyTrades - событие TradesStream. Статус по событию предварительный, точный статус дает сверка
+func (t *OrderTracker) applyTrades(ot *pb.OrderTrades) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tracked, ok := t.byOrderId[ot.GetOrderId()]
+	if !ok {
+		u := untrackedTrades{trades: ot, receivedAt: time.Now()}
+		if tracked = t.pendingFor(u); tracked == nil {
+			if len(t.untracked) == maxUntrackedTrades {
+				t.untracked = append(t.untracked[:0], t.untracked[1:]...)
+			}
+			t.untracked = append(t.untracked, u)
+			if t.orphansCount >= maxOrphanTrades {
+				return
+			}
+			t.orphans[ot.GetOrderId()] = append(t.orphans[ot.GetOrderId()], ot)
+			t.orphansCount++
+			return
+		}
+		t.attach(tracked, ot.GetOrderId())
+	}
+	t.addTrades(tracked, ot)
+	t.changed(tracked)
+}
+
+// addTrades - добавление сделок к заявке, вызывается под t.mu
+func (t *OrderTracker) addTrades(tracked *trackedOrder, ot *pb.OrderTrades) {
+	tracked.Trades = append(tracked.Trades, ot.GetTrades()...)
+	tracked.UpdatedAt = time.Now()
+	if !tracked.Done() {
+		tracked.Status = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL
+	}
+	t.stale[tracked.OrderId] = struct{}{}
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// markStale - запрос сверки заявки в Run
+func (t *OrderTracker) markStale(orderId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stale[orderId] = struct{}{}
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// reconcileStale - поиск ожидающих заявок и сверка заявок, по которым были события
+func (t *OrderTracker) reconcileStale(ctx context.Context) {
+	_ = t.reconcilePending(ctx)
+	t.mu.Lock()
+	stale := make([]TrackedOrder, 0, len(t.stale))
+	for orderId := range t.stale {
+		if o, ok := t.byOrderId[orderId]; ok {
+			stale = append(stale, o.TrackedOrder)
+		}
+	}
+	t.stale = make(map[string]struct{})
+	t.mu.Unlock()
+
+	for _, o := range stale {
+		_ = t.reconcile(ctx, o.AccountId, o.OrderId)
+	}
+}
+
+// reconcile - обновление заявки по GetOrderState
+func (t *OrderTracker) reconcile(ctx context.Context, accountId, orderId string) error {
+	resp, err := t.trading.GetOrderState(ctx, accountId, orderId)
+	if err != nil {
+		t.warn("reconcile order failed", FieldAccountId, accountId, FieldOrderId, orderId, FieldError, err)
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tracked, ok := t.byOrderId[orderId]
+	if !ok {
+		return nil
+	}
+	before, lots := tracked.Status, tracked.LotsExecuted
+	applyOrderState(&tracked.TrackedOrder, resp.OrderState)
+	if tracked.Status != before || tracked.LotsExecuted != lots {
+		tracked.UpdatedAt = time.Now()
+		t.changed(tracked)
+	}
+	return nil
+}
+
+// orderOutcomeUnknown - ответ PostOrder не получен, и заявка могла быть выставлена. Отказ проверок риска
+// и ошибки InvestAPI с ответом сервера означают, что заявки нет
+func orderOutcomeUnknown(err error) bool {
+	if errors.Is(err, ErrRiskRejected) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// applyOrderState - перенос состояния из GetOrderState в заявку
+func applyOrderState(o *TrackedOrder, state *pb.OrderState) {
+	o.State = state
+	o.Status = state.GetExecutionReportStatus()
+	o.LotsRequested = state.GetLotsRequested()
+	o.LotsExecuted = state.GetLotsExecuted()
+}
+
+// changed - пробуждение ожидающих Await и постановка изменения в очередь рассылки, вызывается под t.mu
+func (t *OrderTracker) changed(tracked *trackedOrder) {
+	close(tracked.changed)
+	tracked.changed = make(chan struct{})
+	t.enqueue(notification{order: tracked.snapshot()})
+}
+
+// enqueue - добавление в очередь рассылки, вызывается под t.mu
+func (t *OrderTracker) enqueue(n notification) {
+	if t.closed {
+		return
+	}
+	t.queue = append(t.queue, n)
+	select {
+	case t.queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch - горутина рассылки изменений функциям и подписчикам
+func (t *OrderTracker) dispatch() {
+	for {
+		select {
+		case <-t.done:
+			t.mu.Lock()
+			for s := range t.subscribers {
+				s.once.Do(func() { close(s.done) })
+				close(s.updates)
+			}
+			// отписки, которые не успели дойти до рассылки до Close
+			for _, n := range t.queue {
+				if n.unsubscribe != nil {
+					close(n.unsubscribe.updates)
+				}
+			}
+			t.subscribers = nil
+			t.queue = nil
+			t.mu.Unlock()
+			return
+		case <-t.queueWake:
+		}
+		for {
+			t.mu.Lock()
+			if len(t.queue) == 0 || t.closed {
+				t.mu.Unlock()
+				break
+			}
+			n := t.queue[0]
+			t.queue = t.queue[1:]
+			subscribers := make([]*OrderSubscription, 0, len(t.subscribers))
+			for s := range t.subscribers {
+				subscribers = append(subscribers, s)
+			}
+			t.mu.Unlock()
+
+			if n.unsubscribe != nil {
+				close(n.unsubscribe.updates)
+				continue
+			}
+			for _, fn := range t.callbacks {
+				fn(n.order)
+			}
+			for _, s := range subscribers {
+				s.send(n.order)
+			}
+		}
+	}
+}
+
+// send - отправка изменения подписчику по его политике переполнения
+func (s *OrderSubscription) send(o TrackedOrder) {
+	switch s.overflow {
+	case OverflowDropNewest:
+		select {
+		case s.updates <- o:
+		default:
+			s.dropped.Add(1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.updates <- o:
+				return
+			default:
+			}
+			select {
+			case <-s.updates:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.updates <- o:
+		case <-s.done:
+		}
+	}
+}
+
+// warn - предупреждение в логгер, если он задан
+func (t *OrderTracker) warn(msg string, keysAndValues ...any) {
+	if t.logger != nil {
+		logWarn(t.logger, msg, keysAndValues...)
+	}
+}
+
+// snapshot - копия заявки для передачи наружу
+func (o *trackedOrder) snapshot() TrackedOrder {
+	s := o.TrackedOrder
+	s.Trades = append([]*pb.OrderTrade(nil), o.Trades...)
+	return s
+}
+
+// awaited - дождались ли статуса заявки
+func awaited(o TrackedOrder, statuses []pb.OrderExecutionReportStatus) bool {
+	if len(statuses) == 0 {
+		return o.Done()
+	}
+	for _, st := range statuses {
+		if o.Status == st {
+			return true
+		}
+	}
+	return o.Done()
+}
+
+// statusRank - порядок статусов заявки, статус из более старого ответа не перезаписывает более новый
+func statusRank(s pb.OrderExecutionReportStatus) int {
+	switch s {
+	case pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW:
+		return 1
+	case pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL:
+		return 2
+	case pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL,
+		pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED,
+		pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED:
+		return 3
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,271 @@
+package investgo_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// runTracker - Трекер заявок клиента со сверкой каждые 20мс, работает до конца теста
+func runTracker(t *testing.T, client *investgo.Client) *investgo.OrderTracker {
+	t.Helper()
+	return startTracker(t, client.NewOrderTracker(investgo.WithReconcileInterval(20*time.Millisecond)))
+}
+
+// startTracker - Run трекера без TradesStream до конца теста
+func startTracker(t *testing.T, tracker *investgo.OrderTracker) *investgo.OrderTracker {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = tracker.Run(ctx, nil)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		tracker.Close()
+	})
+	return tracker
+}
+
+func postLimit(t *testing.T, tracker *investgo.OrderTracker, srv *fake.Server, price int64) investgo.TrackedOrder {
+	t.Helper()
+	o, err := tracker.PostOrder(context.Background(), &investgo.PostOrderRequest{
+		InstrumentId: testFigi,
+		Quantity:     1,
+		Price:        fake.Quotation(price),
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_LIMIT,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW {
+		t.Fatalf("status = %v, want NEW", o.Status)
+	}
+	return o
+}
+
+func TestOrderTrackerFill(t *testing.T) {
+	srv := newTestServer(t)
+	tracker := runTracker(t, newTestClient(t, srv.Config()))
+	sub := tracker.Subscribe(16, investgo.OverflowDropOldest)
+
+	o := postLimit(t, tracker, srv, 240)
+	if err := srv.FillOrder(srv.AccountId(), o.OrderId, 1); err != nil {
+		t.Fatal(err)
+	}
+	filled, err := tracker.AwaitFilled(context.Background(), o.RequestId, testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filled.LotsExecuted != 1 || filled.State == nil {
+		t.Fatalf("order = %+v, want 1 lot executed and reconciled state", filled)
+	}
+
+	for {
+		select {
+		case u := <-sub.Updates():
+			if u.RequestId == o.RequestId && u.Filled() {
+				return
+			}
+		case <-time.After(testTimeout):
+			t.Fatal("subscriber did not receive the fill")
+		}
+	}
+}
+
+func TestOrderTrackerCancel(t *testing.T) {
+	srv := newTestServer(t)
+	tracker := runTracker(t, newTestClient(t, srv.Config()))
+
+	o := postLimit(t, tracker, srv, 240)
+	if err := tracker.CancelOrder(context.Background(), o.RequestId); err != nil {
+		t.Fatal(err)
+	}
+	_, err := tracker.AwaitFilled(context.Background(), o.RequestId, testTimeout)
+	if !errors.Is(err, investgo.ErrOrderCancelled) {
+		t.Fatalf("err = %v, want ErrOrderCancelled", err)
+	}
+	if err := tracker.CancelOrder(context.Background(), "unknown"); !errors.Is(err, investgo.ErrOrderNotTracked) {
+		t.Fatalf("err = %v, want ErrOrderNotTracked", err)
+	}
+}
+
+// awaitClosed - Ожидание закрытия канала подписки
+func awaitClosed(t *testing.T, sub *investgo.OrderSubscription) {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case _, ok := <-sub.Updates():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("subscription channel was not closed")
+		}
+	}
+}
+
+func TestOrderTrackerUnsubscribeAndClose(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		tracker := investgo.NewOrderTracker(nil)
+		subs := make([]*investgo.OrderSubscription, 8)
+		for j := range subs {
+			subs[j] = tracker.Subscribe(1, investgo.OverflowBlock)
+		}
+		var wg sync.WaitGroup
+		for _, sub := range subs[:4] {
+			wg.Add(1)
+			go func(sub *investgo.OrderSubscription) {
+				defer wg.Done()
+				tracker.Unsubscribe(sub)
+			}(sub)
+		}
+		tracker.Close()
+		wg.Wait()
+		// отписка после Close тоже закрывает канал
+		for _, sub := range subs[4:] {
+			tracker.Unsubscribe(sub)
+		}
+		for _, sub := range subs {
+			awaitClosed(t, sub)
+		}
+	}
+	tracker := investgo.NewOrderTracker(nil)
+	tracker.Close()
+	awaitClosed(t, tracker.Subscribe(1, investgo.OverflowBlock))
+}
+
+// lostResponse - Trading, который теряет ответы PostOrder: следующие lost заявок выставляются, если place,
+// а вызывающий получает Unavailable
+type lostResponse struct {
+	investgo.Trading
+	mu    sync.Mutex
+	lost  int
+	place bool
+}
+
+func (l *lostResponse) PostOrder(ctx context.Context, req *investgo.PostOrderRequest) (*investgo.PostOrderResponse, error) {
+	l.mu.Lock()
+	lose := l.lost > 0
+	if lose {
+		l.lost--
+	}
+	l.mu.Unlock()
+	if !lose {
+		return l.Trading.PostOrder(ctx, req)
+	}
+	if l.place {
+		if _, err := l.Trading.PostOrder(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return nil, status.Error(codes.Unavailable, "response lost")
+}
+
+func limitRequest(srv *fake.Server, price int64) *investgo.PostOrderRequest {
+	return &investgo.PostOrderRequest{
+		InstrumentId: testFigi,
+		Quantity:     1,
+		Price:        fake.Quotation(price),
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_LIMIT,
+	}
+}
+
+func TestOrderTrackerPendingOrder(t *testing.T) {
+	srv := newTestServer(t)
+	trading := &lostResponse{Trading: newTestClient(t, srv.Config()).NewTrading()}
+	tracker := startTracker(t, investgo.NewOrderTracker(trading, investgo.WithReconcileInterval(20*time.Millisecond),
+		investgo.WithPendingTimeout(200*time.Millisecond)))
+	ctx := context.Background()
+
+	// отказ сервера: заявки нет, она не отслеживается, но order_request_id возвращается
+	srv.InjectError(postOrderMethod, status.Error(codes.InvalidArgument, "invalid"))
+	o, err := tracker.PostOrder(ctx, limitRequest(srv, 245))
+	if err == nil || o.RequestId == "" || o.Pending() {
+		t.Fatalf("order = %+v, err = %v, want error with request id", o, err)
+	}
+	if _, ok := tracker.Order(o.RequestId); ok {
+		t.Fatal("rejected order is tracked")
+	}
+
+	// ответ потерян, заявка выставлена: сверка находит ее в GetOrders по order_request_id
+	trading.mu.Lock()
+	trading.lost, trading.place = 1, true
+	trading.mu.Unlock()
+	o, err = tracker.PostOrder(ctx, limitRequest(srv, 245))
+	if status.Code(err) != codes.Unavailable || !o.Pending() || o.RequestId == "" {
+		t.Fatalf("order = %+v, err = %v, want pending order", o, err)
+	}
+	if _, err := tracker.ReplaceOrder(ctx, o.RequestId, "", 2, nil, pb.PriceType_PRICE_TYPE_UNSPECIFIED); !errors.Is(err, investgo.ErrOrderPending) {
+		t.Fatalf("err = %v, want ErrOrderPending", err)
+	}
+	found, err := tracker.Await(ctx, o.RequestId, pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW)
+	if err != nil || found.OrderId == "" || found.Pending() {
+		t.Fatalf("order = %+v, err = %v, want order found on the exchange", found, err)
+	}
+	if err := tracker.CancelOrder(ctx, o.RequestId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.AwaitFilled(ctx, o.RequestId, testTimeout); !errors.Is(err, investgo.ErrOrderCancelled) {
+		t.Fatalf("err = %v, want ErrOrderCancelled", err)
+	}
+
+	// ответ потерян, заявки нет: отменять нечего, по истечении WithPendingTimeout заявка отклонена
+	trading.mu.Lock()
+	trading.lost, trading.place = 1, false
+	trading.mu.Unlock()
+	o, _ = tracker.PostOrder(ctx, limitRequest(srv, 245))
+	if err := tracker.CancelOrder(ctx, o.RequestId); !errors.Is(err, investgo.ErrOrderNotFound) {
+		t.Fatalf("err = %v, want ErrOrderNotFound", err)
+	}
+	rejected, err := tracker.AwaitFilled(ctx, o.RequestId, testTimeout)
+	if !errors.Is(err, investgo.ErrOrderRejected) || rejected.OrderId != "" {
+		t.Fatalf("order = %+v, err = %v, want rejected after pending timeout", rejected, err)
+	}
+}
+
+func TestOrderTrackerPendingOrderTrades(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	trading := &lostResponse{Trading: client.NewTrading()}
+	// сверка по GetOrders не найдет исполненную заявку, ее находит событие TradesStream
+	tracker := startStreamTracker(t, client, srv, investgo.NewOrderTracker(trading, investgo.WithPendingTimeout(time.Minute)))
+	// события TradesStream приходят, когда стрим подключен
+	eventually(t, "trades stream is not connected", func() bool {
+		o, err := tracker.PostOrder(context.Background(), marketBuy(srv, "", 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		o, _ = tracker.Order(o.RequestId)
+		return len(o.Trades) > 0
+	})
+
+	trading.mu.Lock()
+	trading.lost, trading.place = 1, true
+	trading.mu.Unlock()
+	// событие могло прийти раньше ошибки PostOrder, тогда заявка возвращается уже найденной
+	o, err := tracker.PostOrder(context.Background(), marketBuy(srv, "", 1))
+	if status.Code(err) != codes.Unavailable || o.RequestId == "" || o.Done() {
+		t.Fatalf("order = %+v, err = %v, want tracked order", o, err)
+	}
+	filled, err := tracker.AwaitFilled(context.Background(), o.RequestId, testTimeout)
+	if err != nil || filled.OrderId == "" || filled.LotsExecuted != 1 || len(filled.Trades) == 0 {
+		t.Fatalf("order = %+v, err = %v, want filled order with trades", filled, err)
+	}
+}
@@ -18,14 +18,25 @@ type OrdersServiceClient struct {
 
 // PostOrder - Метод выставления биржевой заявки
 func (os *OrdersServiceClient) PostOrder(req *PostOrderRequest) (*PostOrderResponse, error) {
+	return os.PostOrderWithContext(os.ctx, req)
+}
+
+// PostOrderWithContext - Метод выставления биржевой заявки.
+// Если req.OrderId пустой, создается новый ключ идемпотентности, он возвращается в RequestId ответа, и с ним запрос можно
+// отправить повторно. Запрос req не изменяется
+func (os *OrdersServiceClient) PostOrderWithContext(ctx context.Context, req *PostOrderRequest) (*PostOrderResponse, error) {
+	orderId := req.OrderId
+	if orderId == "" {
+		orderId = CreateUid()
+	}
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.PostOrder(os.ctx, &pb.PostOrderRequest{
+	resp, err := os.pbClient.PostOrder(ctx, &pb.PostOrderRequest{
 		Quantity:     req.Quantity,
 		Price:        req.Price,
 		Direction:    req.Direction,
 		AccountId:    req.AccountId,
 		OrderType:    req.OrderType,
-		OrderId:      req.OrderId,
+		OrderId:      orderId,
 		InstrumentId: req.InstrumentId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -34,19 +45,31 @@ func (os *OrdersServiceClient) PostOrder(req *PostOrderRequest) (*PostOrderRespo
 	return &PostOrderResponse{
 		PostOrderResponse: resp,
 		Header:            header,
+		RequestId:         orderId,
 	}, err
 }
 
 // Buy - Метод выставления поручения на покупку инструмента
 func (os *OrdersServiceClient) Buy(req *PostOrderRequestShort) (*PostOrderResponse, error) {
+	return os.BuyWithContext(os.ctx, req)
+}
+
+// BuyWithContext - Метод выставления поручения на покупку инструмента.
+// Если req.OrderId пустой, создается новый ключ идемпотентности, он возвращается в RequestId ответа, и с ним запрос можно
+// отправить повторно. Запрос req не изменяется
+func (os *OrdersServiceClient) BuyWithContext(ctx context.Context, req *PostOrderRequestShort) (*PostOrderResponse, error) {
+	orderId := req.OrderId
+	if orderId == "" {
+		orderId = CreateUid()
+	}
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.PostOrder(os.ctx, &pb.PostOrderRequest{
+	resp, err := os.pbClient.PostOrder(ctx, &pb.PostOrderRequest{
 		Quantity:     req.Quantity,
 		Price:        req.Price,
 		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
 		AccountId:    req.AccountId,
 		OrderType:    req.OrderType,
-		OrderId:      req.OrderId,
+		OrderId:      orderId,
 		InstrumentId: req.InstrumentId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -55,19 +78,31 @@ func (os *OrdersServiceClient) Buy(req *PostOrderRequestShort) (*PostOrderRespon
 	return &PostOrderResponse{
 		PostOrderResponse: resp,
 		Header:            header,
+		RequestId:         orderId,
 	}, err
 }
 
 // Sell - Метод выставления поручения на продажу инструмента
 func (os *OrdersServiceClient) Sell(req *PostOrderRequestShort) (*PostOrderResponse, error) {
+	return os.SellWithContext(os.ctx, req)
+}
+
+// SellWithContext - Метод выставления поручения на продажу инструмента.
+// Если req.OrderId пустой, создается новый ключ идемпотентности, он возвращается в RequestId ответа, и с ним запрос можно
+// отправить повторно. Запрос req не изменяется
+func (os *OrdersServiceClient) SellWithContext(ctx context.Context, req *PostOrderRequestShort) (*PostOrderResponse, error) {
+	orderId := req.OrderId
+	if orderId == "" {
+		orderId = CreateUid()
+	}
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.PostOrder(os.ctx, &pb.PostOrderRequest{
+	resp, err := os.pbClient.PostOrder(ctx, &pb.PostOrderRequest{
 		Quantity:     req.Quantity,
 		Price:        req.Price,
 		Direction:    pb.OrderDirection_ORDER_DIRECTION_SELL,
 		AccountId:    req.AccountId,
 		OrderType:    req.OrderType,
-		OrderId:      req.OrderId,
+		OrderId:      orderId,
 		InstrumentId: req.InstrumentId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -76,13 +111,19 @@ func (os *OrdersServiceClient) Sell(req *PostOrderRequestShort) (*PostOrderRespo
 	return &PostOrderResponse{
 		PostOrderResponse: resp,
 		Header:            header,
+		RequestId:         orderId,
 	}, err
 }
 
 // CancelOrder - Метод отмены биржевой заявки
 func (os *OrdersServiceClient) CancelOrder(accountId, orderId string) (*CancelOrderResponse, error) {
+	return os.CancelOrderWithContext(os.ctx, accountId, orderId)
+}
+
+// CancelOrderWithContext - Метод отмены биржевой заявки
+func (os *OrdersServiceClient) CancelOrderWithContext(ctx context.Context, accountId, orderId string) (*CancelOrderResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.CancelOrder(os.ctx, &pb.CancelOrderRequest{
+	resp, err := os.pbClient.CancelOrder(ctx, &pb.CancelOrderRequest{
 		AccountId: accountId,
 		OrderId:   orderId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
@@ -97,8 +138,13 @@ func (os *OrdersServiceClient) CancelOrder(accountId, orderId string) (*CancelOr
 
 // GetOrderState - Метод получения статуса торгового поручения
 func (os *OrdersServiceClient) GetOrderState(accountId, orderId string) (*GetOrderStateResponse, error) {
+	return os.GetOrderStateWithContext(os.ctx, accountId, orderId)
+}
+
+// GetOrderStateWithContext - Метод получения статуса торгового поручения
+func (os *OrdersServiceClient) GetOrderStateWithContext(ctx context.Context, accountId, orderId string) (*GetOrderStateResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetOrderState(os.ctx, &pb.GetOrderStateRequest{
+	resp, err := os.pbClient.GetOrderState(ctx, &pb.GetOrderStateRequest{
 		AccountId: accountId,
 		OrderId:   orderId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
@@ -113,8 +159,13 @@ func (os *OrdersServiceClient) GetOrderState(accountId, orderId string) (*GetOrd
 
 // GetOrders - Метод получения списка активных заявок по счёту
 func (os *OrdersServiceClient) GetOrders(accountId string) (*GetOrdersResponse, error) {
+	return os.GetOrdersWithContext(os.ctx, accountId)
+}
+
+// GetOrdersWithContext - Метод получения списка активных заявок по счёту
+func (os *OrdersServiceClient) GetOrdersWithContext(ctx context.Context, accountId string) (*GetOrdersResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.GetOrders(os.ctx, &pb.GetOrdersRequest{
+	resp, err := os.pbClient.GetOrders(ctx, &pb.GetOrdersRequest{
 		AccountId: accountId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -128,11 +179,22 @@ func (os *OrdersServiceClient) GetOrders(accountId string) (*GetOrdersResponse,
 
 // ReplaceOrder - Метод изменения выставленной заявки
 func (os *OrdersServiceClient) ReplaceOrder(req *ReplaceOrderRequest) (*PostOrderResponse, error) {
+	return os.ReplaceOrderWithContext(os.ctx, req)
+}
+
+// ReplaceOrderWithContext - Метод изменения выставленной заявки.
+// Если req.NewOrderId пустой, создается новый ключ идемпотентности, он возвращается в RequestId ответа, и с ним запрос можно
+// отправить повторно. Запрос req не изменяется
+func (os *OrdersServiceClient) ReplaceOrderWithContext(ctx context.Context, req *ReplaceOrderRequest) (*PostOrderResponse, error) {
+	orderId := req.NewOrderId
+	if orderId == "" {
+		orderId = CreateUid()
+	}
 	var header, trailer metadata.MD
-	resp, err := os.pbClient.ReplaceOrder(os.ctx, &pb.ReplaceOrderRequest{
+	resp, err := os.pbClient.ReplaceOrder(ctx, &pb.ReplaceOrderRequest{
 		AccountId:      req.AccountId,
 		OrderId:        req.OrderId,
-		IdempotencyKey: req.NewOrderId,
+		IdempotencyKey: orderId,
 		Quantity:       req.Quantity,
 		Price:          req.Price,
 		PriceType:      req.PriceType,
@@ -143,5 +205,6 @@ func (os *OrdersServiceClient) ReplaceOrder(req *ReplaceOrderRequest) (*PostOrde
 	return &PostOrderResponse{
 		PostOrderResponse: resp,
 		Header:            header,
+		RequestId:         orderId,
 	}, err
 }
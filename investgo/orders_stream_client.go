@@ -18,13 +18,20 @@ type OrdersStreamClient struct {
 
 // TradesStream - Стрим сделок по запрашиваемым аккаунтам
 func (o *OrdersStreamClient) TradesStream(accounts []string) (*TradesStream, error) {
-	ctx, cancel := context.WithCancel(o.ctx)
+	return o.TradesStreamWithContext(o.ctx, accounts)
+}
+
+// TradesStreamWithContext - Стрим сделок по запрашиваемым аккаунтам
+// ctx определяет время жизни стрима, после его отмены стрим завершается
+func (o *OrdersStreamClient) TradesStreamWithContext(ctx context.Context, accounts []string) (*TradesStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	ts := &TradesStream{
 		stream:       nil,
 		ordersClient: o,
 		trades:       make(chan *pb.OrderTrades),
 		ctx:          ctx,
 		cancel:       cancel,
+		accounts:     accounts,
 	}
 	stream, err := o.pbClient.TradesStream(ctx, &pb.TradesStreamRequest{
 		Accounts: accounts,
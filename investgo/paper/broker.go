@@ -0,0 +1,502 @@
+// Package paper - Paper-брокер: торговля на счете в памяти процесса с исполнением заявок по биржевой информации
+// из MarketDataStream. Broker реализует investgo.Trading и операции со стоп-заявками и отдает события OrderTrades
+// в том же виде, что и TradesStream, поэтому стратегия переключается между боевым контуром, песочницей
+// и paper-брокером без изменений.
+//
+// Брокер не обращается к InvestAPI: инструменты добавляются через AddInstrument, деньги через PayIn,
+// а биржевая информация передается через Listen или On* методы:
+//
+//	broker := paper.NewBroker(paper.WithCommission(decimal.RequireFromString("0.0005")))
+//	broker.AddInstrument(instrumentResp.GetInstrument())
+//	broker.PayIn("rub", decimal.NewFromInt(100000))
+//	sub := mds.AddSubscriber(paper.SubscriberOptions())
+//	go broker.Listen(ctx, sub.Updates())
+package paper
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultAccountId - Идентификатор счета paper-брокера по умолчанию
+const DefaultAccountId = "paper"
+
+// Коды ошибок InvestAPI, которые возвращает брокер
+const (
+	codeInvalidArgument     = "30001"
+	codeInsufficientBalance = "30034"
+	codeNotEnoughAssets     = "30042"
+	codeNotAvailableTrading = "30079"
+	codeInstrumentNotFound  = "50002"
+	codeAccountNotFound     = "50004"
+	codeOrderNotFound       = "50005"
+	codeStopOrderNotFound   = "50006"
+)
+
+var (
+	_ investgo.Trading          = (*Broker)(nil)
+	_ investgo.StopOrderTrading = (*Broker)(nil)
+	_ investgo.InstrumentSource = (*Broker)(nil)
+)
+
+// Option - Настройка брокера при создании через NewBroker
+type Option func(*Broker)
+
+// WithAccountId - Идентификатор счета, по умолчанию DefaultAccountId
+func WithAccountId(id string) Option {
+	return func(b *Broker) {
+		b.accountId = id
+	}
+}
+
+// WithCommission - Комиссия брокера в долях от суммы сделки, например 0.0005 для 0.05%. По умолчанию комиссии нет
+func WithCommission(rate decimal.Decimal) Option {
+	return func(b *Broker) {
+		b.commission = rate
+	}
+}
+
+// WithSlippage - Проскальзывание рыночных заявок в долях от цены исполнения, например 0.001 для 0.1%.
+// Цена сдвигается не в пользу клиента и округляется к шагу цены инструмента. По умолчанию проскальзывания нет
+func WithSlippage(rate decimal.Decimal) Option {
+	return func(b *Broker) {
+		b.slippage = rate
+	}
+}
+
+// WithTradesHandler - Функция, которая синхронно получает события об исполнении заявок в той горутине,
+// которая вызвала исполнение, после снятия блокировки брокера. Нужна для детерминированного воспроизведения истории
+func WithTradesHandler(fn func(t *pb.OrderTrades)) Option {
+	return func(b *Broker) {
+		b.tradesHandler = fn
+	}
+}
+
+// WithClock - Источник времени для заявок и операций, по умолчанию time.Now
+func WithClock(now func() time.Time) Option {
+	return func(b *Broker) {
+		b.now = now
+	}
+}
+
+// Broker - Paper-брокер с одним счетом. Безопасен для использования из нескольких горутин
+type Broker struct {
+	mu         sync.Mutex
+	accountId  string
+	commission decimal.Decimal
+	slippage   decimal.Decimal
+	now        func() time.Time
+	seq        int64
+
+	tradesHandler func(t *pb.OrderTrades)
+
+	// instruments - инструменты по figi и uid
+	instruments map[string]*pb.Instrument
+	// books, lastPrices и statuses - биржевая информация по uid инструмента
+	books      map[string]*book
+	lastPrices map[string]decimal.Decimal
+	statuses   map[string]pb.SecurityTradingStatus
+
+	money      map[string]decimal.Decimal
+	positions  map[string]*position
+	orders     map[string]*order
+	orderIds   []string
+	requests   map[string]*order
+	stopOrders map[string]*stopOrder
+	stopIds    []string
+	operations []*pb.Operation
+
+	trades *tradesFeed
+}
+
+// NewBroker - Создание paper-брокера с пустым счетом
+func NewBroker(opts ...Option) *Broker {
+	b := &Broker{
+		accountId:   DefaultAccountId,
+		commission:  decimal.Zero,
+		slippage:    decimal.Zero,
+		now:         time.Now,
+		instruments: make(map[string]*pb.Instrument),
+		books:       make(map[string]*book),
+		lastPrices:  make(map[string]decimal.Decimal),
+		statuses:    make(map[string]pb.SecurityTradingStatus),
+		money:       make(map[string]decimal.Decimal),
+		positions:   make(map[string]*position),
+		orders:      make(map[string]*order),
+		requests:    make(map[string]*order),
+		stopOrders:  make(map[string]*stopOrder),
+		trades:      newTradesFeed(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// AccountId - Идентификатор счета брокера
+func (b *Broker) AccountId() string {
+	return b.accountId
+}
+
+// AddInstrument - Добавление инструмента, по которому можно торговать. Лотность, шаг цены, валюта и возможность
+// шорта берутся из инструмента, например из ответа InstrumentsServiceClient.InstrumentByFigi
+func (b *Broker) AddInstrument(inst *pb.Instrument) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if inst.GetFigi() != "" {
+		b.instruments[inst.GetFigi()] = inst
+	}
+	if inst.GetUid() != "" {
+		b.instruments[inst.GetUid()] = inst
+	}
+	if status := inst.GetTradingStatus(); status != pb.SecurityTradingStatus_SECURITY_TRADING_STATUS_UNSPECIFIED {
+		b.statuses[uid(inst)] = status
+	}
+}
+
+// InstrumentWithContext - Инструмент, добавленный через AddInstrument, по figi или uid
+func (b *Broker) InstrumentWithContext(_ context.Context, instrumentId string) (*pb.Instrument, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.instrument(instrumentId)
+}
+
+// PayIn - Пополнение счета на amount в валюте currency
+func (b *Broker) PayIn(currency string, amount decimal.Decimal) *pb.MoneyValue {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	currency = strings.ToLower(currency)
+	b.money[currency] = b.money[currency].Add(amount)
+	b.operations = append(b.operations, &pb.Operation{
+		Id:            b.nextId(),
+		Currency:      currency,
+		Payment:       investgo.DecimalToMoneyValue(amount, currency),
+		State:         pb.OperationState_OPERATION_STATE_EXECUTED,
+		Type:          "Пополнение брокерского счёта",
+		OperationType: pb.OperationType_OPERATION_TYPE_INPUT,
+		Date:          timestamppb.New(b.now()),
+	})
+	return investgo.DecimalToMoneyValue(b.money[currency], currency)
+}
+
+// SetPosition - Установка позиции по инструменту, quantity в штуках, averagePrice - средняя цена позиции
+func (b *Broker) SetPosition(instrumentId string, quantity int64, averagePrice decimal.Decimal) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	inst, err := b.instrument(instrumentId)
+	if err != nil {
+		return err
+	}
+	b.positions[uid(inst)] = &position{inst: inst, balance: quantity, avgPrice: averagePrice}
+	return nil
+}
+
+// Trades - Канал событий об исполнении заявок в том же виде, что и TradesStream.Trades. События копятся только
+// после первого вызова Trades, исполнение заявок не ждет чтения канала. Канал закрывается в Close
+func (b *Broker) Trades() <-chan *pb.OrderTrades {
+	return b.trades.subscribe()
+}
+
+// Close - Закрытие канала Trades, неотправленные события выбрасываются
+func (b *Broker) Close() {
+	b.trades.close()
+}
+
+// Listen - Исполнение заявок по биржевой информации из канала updates до его закрытия или отмены ctx.
+// Канал подписчика MarketDataStream с параметрами SubscriberOptions передает все нужные брокеру данные
+func (b *Broker) Listen(ctx context.Context, updates <-chan *pb.MarketDataResponse) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case resp, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			b.OnMarketData(resp)
+		}
+	}
+}
+
+// SubscriberOptions - Параметры подписчика MarketDataStream для Listen: стаканы, сделки, последние цены
+// и торговые статусы по инструментам ids, если ids пустой - по всем инструментам стрима
+func SubscriberOptions(ids ...string) investgo.SubscriberOptions {
+	return investgo.SubscriberOptions{
+		InstrumentIds: ids,
+		Kinds: []investgo.MarketDataKind{
+			investgo.MarketDataOrderBook,
+			investgo.MarketDataTrade,
+			investgo.MarketDataLastPrice,
+			investgo.MarketDataTradingStatus,
+		},
+	}
+}
+
+// OnMarketData - Обработка сообщения MarketDataStream, остальные сообщения стрима пропускаются
+func (b *Broker) OnMarketData(resp *pb.MarketDataResponse) {
+	switch resp.GetPayload().(type) {
+	case *pb.MarketDataResponse_Candle:
+		b.OnCandle(resp.GetCandle())
+	case *pb.MarketDataResponse_Orderbook:
+		b.OnOrderBook(resp.GetOrderbook())
+	case *pb.MarketDataResponse_Trade:
+		b.OnTrade(resp.GetTrade())
+	case *pb.MarketDataResponse_LastPrice:
+		b.OnLastPrice(resp.GetLastPrice())
+	case *pb.MarketDataResponse_TradingStatus:
+		b.OnTradingStatus(resp.GetTradingStatus())
+	}
+}
+
+// OnOrderBook - Новый стакан по инструменту. Активные заявки исполняются по встречной стороне стакана,
+// ликвидность стакана расходуется до прихода следующего стакана
+func (b *Broker) OnOrderBook(ob *pb.OrderBook) {
+	b.update(func(ev *events) {
+		inst := b.find(ob.GetInstrumentUid(), ob.GetFigi())
+		if inst == nil {
+			return
+		}
+		b.books[uid(inst)] = newBook(ob)
+		b.matchOrders(inst, ev)
+	})
+}
+
+// OnTrade - Обезличенная сделка по инструменту. Цена сделки становится ценой последней сделки, лимитные заявки
+// с ценой не хуже цены сделки исполняются по своей цене в пределах объема сделки, срабатывают стоп-заявки
+func (b *Broker) OnTrade(t *pb.Trade) {
+	b.update(func(ev *events) {
+		inst := b.find(t.GetInstrumentUid(), t.GetFigi())
+		if inst == nil {
+			return
+		}
+		price := t.GetPrice().ToDecimal()
+		b.lastPrices[uid(inst)] = price
+		b.matchPrice(inst, price, t.GetQuantity(), ev)
+		b.matchStopOrders(inst, ev)
+	})
+}
+
+// OnCandle - Свеча по инструменту. Цена внутри свечи проходит путь open, low, high, close для растущей свечи
+// и open, high, low, close для падающей. В каждой точке пути срабатывают стоп-заявки и исполняются заявки
+// в пределах объема свечи, если объем не указан - без ограничения объема
+func (b *Broker) OnCandle(c *pb.Candle) {
+	b.update(func(ev *events) {
+		inst := b.find(c.GetInstrumentUid(), c.GetFigi())
+		if inst == nil {
+			return
+		}
+		volume := c.GetVolume()
+		if volume <= 0 {
+			volume = math.MaxInt64
+		}
+		for _, price := range candlePath(c) {
+			b.lastPrices[uid(inst)] = price
+			volume = b.matchPrice(inst, price, volume, ev)
+			b.matchStopOrders(inst, ev)
+		}
+	})
+}
+
+// candlePath - Точки пути цены внутри свечи
+func candlePath(c *pb.Candle) []decimal.Decimal {
+	open, high, low, closePrice := c.GetOpen().ToDecimal(), c.GetHigh().ToDecimal(), c.GetLow().ToDecimal(), c.GetClose().ToDecimal()
+	if closePrice.GreaterThanOrEqual(open) {
+		return []decimal.Decimal{open, low, high, closePrice}
+	}
+	return []decimal.Decimal{open, high, low, closePrice}
+}
+
+// OnLastPrice - Цена последней сделки по инструменту, по ней срабатывают стоп-заявки
+func (b *Broker) OnLastPrice(lp *pb.LastPrice) {
+	b.update(func(ev *events) {
+		inst := b.find(lp.GetInstrumentUid(), lp.GetFigi())
+		if inst == nil {
+			return
+		}
+		b.lastPrices[uid(inst)] = lp.GetPrice().ToDecimal()
+		b.matchStopOrders(inst, ev)
+	})
+}
+
+// OnTradingStatus - Торговый статус инструмента, заявки принимаются только в статусе нормальной торговли
+func (b *Broker) OnTradingStatus(ts *pb.TradingStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if inst := b.find(ts.GetInstrumentUid(), ts.GetFigi()); inst != nil {
+		b.statuses[uid(inst)] = ts.GetTradingStatus()
+	}
+}
+
+// events - события OrderTrades, которые нужно отправить после снятия блокировки
+type events struct {
+	trades []*pb.OrderTrades
+}
+
+// update - изменение состояния под b.mu и отправка накопленных событий
+func (b *Broker) update(fn func(ev *events)) {
+	ev := &events{}
+	b.mu.Lock()
+	fn(ev)
+	b.mu.Unlock()
+	for _, t := range ev.trades {
+		if b.tradesHandler != nil {
+			b.tradesHandler(t)
+		}
+		b.trades.push(t)
+	}
+}
+
+// nextId - новый идентификатор заявки, сделки или операции, вызывается под b.mu
+func (b *Broker) nextId() string {
+	b.seq++
+	return strconv.FormatInt(b.seq, 10)
+}
+
+// account - проверка идентификатора счета из запроса
+func (b *Broker) account(accountId string) error {
+	if accountId != b.accountId {
+		return investgo.NewError(codes.NotFound, codeAccountNotFound, "Account not found")
+	}
+	return nil
+}
+
+// find - инструмент по uid или figi, вызывается под b.mu
+func (b *Broker) find(ids ...string) *pb.Instrument {
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		if inst, ok := b.instruments[id]; ok {
+			return inst
+		}
+	}
+	return nil
+}
+
+// instrument - инструмент по идентификатору из запроса или ошибка InvestAPI, вызывается под b.mu
+func (b *Broker) instrument(id string) (*pb.Instrument, error) {
+	inst := b.find(id)
+	if inst == nil {
+		return nil, investgo.NewError(codes.NotFound, codeInstrumentNotFound, "Instrument not found")
+	}
+	return inst, nil
+}
+
+// tradable - торгуется ли инструмент, если статус неизвестен - считается, что торгуется
+func (b *Broker) tradable(inst *pb.Instrument) bool {
+	status, ok := b.statuses[uid(inst)]
+	return !ok || status == pb.SecurityTradingStatus_SECURITY_TRADING_STATUS_NORMAL_TRADING
+}
+
+// uid - ключ инструмента в состоянии брокера
+func uid(inst *pb.Instrument) string {
+	if inst.GetUid() != "" {
+		return inst.GetUid()
+	}
+	return inst.GetFigi()
+}
+
+// lot - лотность инструмента, не меньше 1
+func lot(inst *pb.Instrument) int64 {
+	if inst.GetLot() > 0 {
+		return int64(inst.GetLot())
+	}
+	return 1
+}
+
+func currency(inst *pb.Instrument) string {
+	return strings.ToLower(inst.GetCurrency())
+}
+
+// tradesFeed - очередь событий OrderTrades без ограничения размера, чтобы исполнение заявок не ждало читателя
+type tradesFeed struct {
+	mu      sync.Mutex
+	queue   []*pb.OrderTrades
+	ch      chan *pb.OrderTrades
+	wake    chan struct{}
+	done    chan struct{}
+	started bool
+	closed  bool
+}
+
+func newTradesFeed() *tradesFeed {
+	return &tradesFeed{
+		ch:   make(chan *pb.OrderTrades),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+}
+
+// subscribe - запуск отправки событий в канал при первом вызове
+func (f *tradesFeed) subscribe() <-chan *pb.OrderTrades {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.started && !f.closed {
+		f.started = true
+		go f.run()
+	}
+	return f.ch
+}
+
+func (f *tradesFeed) push(t *pb.OrderTrades) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.started || f.closed {
+		return
+	}
+	f.queue = append(f.queue, t)
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (f *tradesFeed) run() {
+	defer close(f.ch)
+	for {
+		f.mu.Lock()
+		var next *pb.OrderTrades
+		if len(f.queue) > 0 {
+			next = f.queue[0]
+			f.queue = f.queue[1:]
+		}
+		f.mu.Unlock()
+
+		if next == nil {
+			select {
+			case <-f.wake:
+				continue
+			case <-f.done:
+				return
+			}
+		}
+		select {
+		case f.ch <- next:
+		case <-f.done:
+			return
+		}
+	}
+}
+
+func (f *tradesFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	f.closed = true
+	close(f.done)
+	if !f.started {
+		close(f.ch)
+	}
+}
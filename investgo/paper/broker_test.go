@@ -0,0 +1,357 @@
+package paper_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/paper"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testFigi = "BBG004730N88"
+	testUid  = "e6123145-9665-43e0-8413-cd61b8aa9b13"
+)
+
+var ctx = context.Background()
+
+// newBroker - Брокер с акцией testFigi (лот 10, шаг цены 0.01) и 100000 рублей на счете
+func newBroker(t *testing.T, opts ...paper.Option) *paper.Broker {
+	t.Helper()
+	b := paper.NewBroker(opts...)
+	t.Cleanup(b.Close)
+	b.AddInstrument(&pb.Instrument{
+		Figi:              testFigi,
+		Uid:               testUid,
+		Ticker:            "SBER",
+		Lot:               10,
+		Currency:          "rub",
+		MinPriceIncrement: &pb.Quotation{Nano: 10000000},
+		InstrumentKind:    pb.InstrumentType_INSTRUMENT_TYPE_SHARE,
+	})
+	b.PayIn("rub", decimal.NewFromInt(100000))
+	return b
+}
+
+func q(v string) *pb.Quotation {
+	return investgo.DecimalToQuotation(decimal.RequireFromString(v))
+}
+
+func order(price string, lots int64) *pb.Order {
+	return &pb.Order{Price: q(price), Quantity: lots}
+}
+
+func post(t *testing.T, b *paper.Broker, direction pb.OrderDirection, orderType pb.OrderType, lots int64, price string) *pb.PostOrderResponse {
+	t.Helper()
+	req := &investgo.PostOrderRequest{
+		InstrumentId: testFigi,
+		Quantity:     lots,
+		Direction:    direction,
+		AccountId:    b.AccountId(),
+		OrderType:    orderType,
+	}
+	if price != "" {
+		req.Price = q(price)
+	}
+	resp, err := b.PostOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.PostOrderResponse
+}
+
+// balance - Деньги без учета блокировок и количество бумаг testFigi в штуках
+func balance(t *testing.T, b *paper.Broker) (decimal.Decimal, int64) {
+	t.Helper()
+	resp, err := b.GetPositions(ctx, b.AccountId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	money := decimal.Zero
+	for i, m := range resp.GetMoney() {
+		money = money.Add(m.ToDecimal()).Add(resp.GetBlocked()[i].ToDecimal())
+	}
+	var pieces int64
+	for _, s := range resp.GetSecurities() {
+		pieces += s.GetBalance() + s.GetBlocked()
+	}
+	return money, pieces
+}
+
+func wantBalance(t *testing.T, b *paper.Broker, money string, pieces int64) {
+	t.Helper()
+	gotMoney, gotPieces := balance(t, b)
+	if !gotMoney.Equal(decimal.RequireFromString(money)) || gotPieces != pieces {
+		t.Fatalf("balance = %v rub, %d pieces, want %v rub, %d pieces", gotMoney, gotPieces, money, pieces)
+	}
+}
+
+func TestMarketOrderFillsAgainstBook(t *testing.T) {
+	b := newBroker(t, paper.WithCommission(decimal.RequireFromString("0.001")))
+	b.OnOrderBook(&pb.OrderBook{
+		Figi: testFigi,
+		Bids: []*pb.Order{order("249", 10)},
+		Asks: []*pb.Order{order("250", 1), order("251", 5)},
+	})
+
+	resp := post(t, b, pb.OrderDirection_ORDER_DIRECTION_BUY, pb.OrderType_ORDER_TYPE_MARKET, 3, "")
+	if resp.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL {
+		t.Fatalf("status = %v, want FILL", resp.GetExecutionReportStatus())
+	}
+	// 1 лот по 250 и 2 лота по 251, комиссия 0.1% от 7520
+	if got := resp.GetExecutedOrderPrice().ToDecimal(); !got.Equal(decimal.NewFromInt(7520)) {
+		t.Fatalf("executed = %v, want 7520", got)
+	}
+	wantBalance(t, b, "92472.48", 30)
+
+	// в стакане осталось 3 лота, заявка исполняется частично
+	resp = post(t, b, pb.OrderDirection_ORDER_DIRECTION_BUY, pb.OrderType_ORDER_TYPE_MARKET, 4, "")
+	if resp.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL ||
+		resp.GetLotsExecuted() != 3 {
+		t.Fatalf("status = %v, lots executed = %d, want PARTIALLYFILL with 3 lots",
+			resp.GetExecutionReportStatus(), resp.GetLotsExecuted())
+	}
+}
+
+func TestMarketOrderSlippage(t *testing.T) {
+	b := newBroker(t, paper.WithSlippage(decimal.RequireFromString("0.001")))
+	b.OnLastPrice(&pb.LastPrice{Figi: testFigi, Price: q("250")})
+
+	resp := post(t, b, pb.OrderDirection_ORDER_DIRECTION_BUY, pb.OrderType_ORDER_TYPE_MARKET, 1, "")
+	// 250 * 1.001 = 250.25, цена сдвигается не в пользу покупателя
+	if got := resp.GetExecutedOrderPrice().ToDecimal(); !got.Equal(decimal.RequireFromString("2502.5")) {
+		t.Fatalf("executed = %v, want 2502.5", got)
+	}
+	resp = post(t, b, pb.OrderDirection_ORDER_DIRECTION_SELL, pb.OrderType_ORDER_TYPE_MARKET, 1, "")
+	if got := resp.GetExecutedOrderPrice().ToDecimal(); !got.Equal(decimal.RequireFromString("2497.5")) {
+		t.Fatalf("executed = %v, want 2497.5", got)
+	}
+}
+
+func TestLimitOrderFillsOnTrades(t *testing.T) {
+	b := newBroker(t)
+	trades := b.Trades()
+	b.OnLastPrice(&pb.LastPrice{Figi: testFigi, Price: q("250")})
+
+	resp := post(t, b, pb.OrderDirection_ORDER_DIRECTION_BUY, pb.OrderType_ORDER_TYPE_LIMIT, 2, "245")
+	if resp.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW {
+		t.Fatalf("status = %v, want NEW", resp.GetExecutionReportStatus())
+	}
+	positions, err := b.GetPositions(ctx, b.AccountId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := positions.GetBlocked()[0].ToDecimal(); !got.Equal(decimal.NewFromInt(4900)) {
+		t.Fatalf("blocked = %v, want 4900", got)
+	}
+
+	b.OnTrade(&pb.Trade{Figi: testFigi, Price: q("246"), Quantity: 10})
+	b.OnTrade(&pb.Trade{Figi: testFigi, Price: q("244"), Quantity: 1})
+	state, err := b.GetOrderState(ctx, b.AccountId(), resp.GetOrderId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL {
+		t.Fatalf("status = %v, want PARTIALLYFILL", state.GetExecutionReportStatus())
+	}
+
+	b.OnTrade(&pb.Trade{Figi: testFigi, Price: q("245"), Quantity: 5})
+	state, err = b.GetOrderState(ctx, b.AccountId(), resp.GetOrderId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL {
+		t.Fatalf("status = %v, want FILL", state.GetExecutionReportStatus())
+	}
+	// обе части исполнены по лимитной цене
+	wantBalance(t, b, "95100", 20)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case ot := <-trades:
+			if ot.GetOrderId() != resp.GetOrderId() || !ot.GetTrades()[0].GetPrice().ToDecimal().Equal(decimal.NewFromInt(245)) {
+				t.Fatalf("trade = %v, want order %v at 245", ot, resp.GetOrderId())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("trade %d was not received", i)
+		}
+	}
+}
+
+func TestCandlePath(t *testing.T) {
+	b := newBroker(t)
+	if err := b.SetPosition(testFigi, 10, decimal.NewFromInt(240)); err != nil {
+		t.Fatal(err)
+	}
+	b.OnLastPrice(&pb.LastPrice{Figi: testFigi, Price: q("250")})
+	resp := post(t, b, pb.OrderDirection_ORDER_DIRECTION_SELL, pb.OrderType_ORDER_TYPE_LIMIT, 1, "260")
+
+	// растущая свеча проходит open, low, high, close и задевает 260
+	b.OnCandle(&pb.Candle{Figi: testFigi, Open: q("250"), High: q("262"), Low: q("248"), Close: q("255")})
+	state, err := b.GetOrderState(ctx, b.AccountId(), resp.GetOrderId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL {
+		t.Fatalf("status = %v, want FILL", state.GetExecutionReportStatus())
+	}
+	wantBalance(t, b, "102600", 0)
+}
+
+func TestStopOrderTriggers(t *testing.T) {
+	b := newBroker(t)
+	if err := b.SetPosition(testFigi, 20, decimal.NewFromInt(250)); err != nil {
+		t.Fatal(err)
+	}
+	b.OnLastPrice(&pb.LastPrice{Figi: testFigi, Price: q("250")})
+	_, err := b.PostStopOrder(ctx, &investgo.PostStopOrderRequest{
+		InstrumentId:   testFigi,
+		Quantity:       2,
+		StopPrice:      q("240"),
+		Direction:      pb.StopOrderDirection_STOP_ORDER_DIRECTION_SELL,
+		AccountId:      b.AccountId(),
+		ExpirationType: pb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_CANCEL,
+		StopOrderType:  pb.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.OnLastPrice(&pb.LastPrice{Figi: testFigi, Price: q("245")})
+	stops, err := b.GetStopOrders(ctx, b.AccountId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stops.GetStopOrders()) != 1 {
+		t.Fatalf("stop orders = %d, want 1", len(stops.GetStopOrders()))
+	}
+
+	b.OnLastPrice(&pb.LastPrice{Figi: testFigi, Price: q("239")})
+	stops, err = b.GetStopOrders(ctx, b.AccountId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stops.GetStopOrders()) != 0 {
+		t.Fatalf("stop orders = %d, want 0 after trigger", len(stops.GetStopOrders()))
+	}
+	wantBalance(t, b, "104780", 0)
+}
+
+func TestReplaceOrder(t *testing.T) {
+	b := newBroker(t)
+	b.OnLastPrice(&pb.LastPrice{Figi: testFigi, Price: q("250")})
+	old := post(t, b, pb.OrderDirection_ORDER_DIRECTION_BUY, pb.OrderType_ORDER_TYPE_LIMIT, 2, "245")
+
+	resp, err := b.ReplaceOrder(ctx, &investgo.ReplaceOrderRequest{
+		AccountId: b.AccountId(),
+		OrderId:   old.GetOrderId(),
+		Price:     q("246"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := b.GetOrderState(ctx, b.AccountId(), old.GetOrderId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.GetExecutionReportStatus() != pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED {
+		t.Fatalf("old status = %v, want CANCELLED", state.GetExecutionReportStatus())
+	}
+	orders, err := b.GetOrders(ctx, b.AccountId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders.GetOrders()) != 1 || orders.GetOrders()[0].GetOrderId() != resp.GetOrderId() ||
+		orders.GetOrders()[0].GetLotsRequested() != 2 {
+		t.Fatalf("orders = %v, want only the new order with 2 lots", orders.GetOrders())
+	}
+
+	// заменить отмененную заявку нельзя
+	_, err = b.ReplaceOrder(ctx, &investgo.ReplaceOrderRequest{AccountId: b.AccountId(), OrderId: old.GetOrderId()})
+	if !errors.Is(err, investgo.ErrOrderNotFound) {
+		t.Fatalf("err = %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestOrderRequestIdIsIdempotent(t *testing.T) {
+	b := newBroker(t)
+	b.OnLastPrice(&pb.LastPrice{Figi: testFigi, Price: q("250")})
+	req := &investgo.PostOrderRequest{
+		InstrumentId: testFigi,
+		Quantity:     1,
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+		AccountId:    b.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_MARKET,
+		OrderId:      "request-1",
+	}
+	first, err := b.PostOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := b.PostOrder(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.GetOrderId() != second.GetOrderId() {
+		t.Fatalf("order ids = %v and %v, want the same order", first.GetOrderId(), second.GetOrderId())
+	}
+	wantBalance(t, b, "97500", 10)
+}
+
+func TestOrderErrors(t *testing.T) {
+	b := newBroker(t)
+	b.OnLastPrice(&pb.LastPrice{Figi: testFigi, Price: q("250")})
+	base := investgo.PostOrderRequest{
+		InstrumentId: testFigi,
+		Quantity:     1,
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+		AccountId:    b.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_LIMIT,
+		Price:        q("250"),
+	}
+	tests := []struct {
+		name   string
+		modify func(r *investgo.PostOrderRequest)
+		code   codes.Code
+		target error
+	}{
+		{"unknown account", func(r *investgo.PostOrderRequest) { r.AccountId = "other" }, codes.NotFound, nil},
+		{"unknown instrument", func(r *investgo.PostOrderRequest) { r.InstrumentId = "unknown" }, codes.NotFound, nil},
+		{"zero quantity", func(r *investgo.PostOrderRequest) { r.Quantity = 0 }, codes.InvalidArgument, nil},
+		{"price step", func(r *investgo.PostOrderRequest) { r.Price = q("250.005") }, codes.InvalidArgument, nil},
+		{"not enough money", func(r *investgo.PostOrderRequest) { r.Quantity = 100 }, codes.InvalidArgument, investgo.ErrInsufficientFunds},
+		{"not enough assets", func(r *investgo.PostOrderRequest) { r.Direction = pb.OrderDirection_ORDER_DIRECTION_SELL }, codes.InvalidArgument, investgo.ErrInsufficientFunds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := base
+			tt.modify(&req)
+			_, err := b.PostOrder(ctx, &req)
+			if status.Code(err) != tt.code {
+				t.Fatalf("err = %v, want %v", err, tt.code)
+			}
+			if tt.target != nil && !errors.Is(err, tt.target) {
+				t.Fatalf("err = %v, want %v", err, tt.target)
+			}
+		})
+	}
+
+	b.OnTradingStatus(&pb.TradingStatus{Figi: testFigi, TradingStatus: pb.SecurityTradingStatus_SECURITY_TRADING_STATUS_BREAK_IN_TRADING})
+	req := base
+	if _, err := b.PostOrder(ctx, &req); !errors.Is(err, investgo.ErrNotTradable) {
+		t.Fatalf("err = %v, want ErrNotTradable", err)
+	}
+	if _, err := b.CancelOrder(ctx, b.AccountId(), "unknown"); !errors.Is(err, investgo.ErrOrderNotFound) {
+		t.Fatalf("err = %v, want ErrOrderNotFound", err)
+	}
+	if _, err := b.CancelStopOrder(ctx, b.AccountId(), "unknown"); !errors.Is(err, investgo.ErrOrderNotFound) {
+		t.Fatalf("err = %v, want ErrOrderNotFound", err)
+	}
+	wantBalance(t, b, "100000", 0)
+}
@@ -0,0 +1,498 @@
+package paper
+
+import (
+	"context"
+
+	"github.com/shopspring/decimal"
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// order - Биржевая заявка, state отдается клиенту как есть
+type order struct {
+	inst *pb.Instrument
+	// limit - цена лимитной заявки или оценка цены исполнения рыночной заявки на момент выставления
+	limit    decimal.Decimal
+	executed decimal.Decimal
+	fee      decimal.Decimal
+	state    *pb.OrderState
+}
+
+func (o *order) active() bool {
+	status := o.state.GetExecutionReportStatus()
+	return status == pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW ||
+		status == pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL
+}
+
+func (o *order) market() bool {
+	return o.state.GetOrderType() != pb.OrderType_ORDER_TYPE_LIMIT
+}
+
+func (o *order) lotsLeft() int64 {
+	return o.state.GetLotsRequested() - o.state.GetLotsExecuted()
+}
+
+func (o *order) response() *pb.PostOrderResponse {
+	st := o.state
+	return &pb.PostOrderResponse{
+		OrderId:               st.GetOrderId(),
+		ExecutionReportStatus: st.GetExecutionReportStatus(),
+		LotsRequested:         st.GetLotsRequested(),
+		LotsExecuted:          st.GetLotsExecuted(),
+		InitialOrderPrice:     st.GetInitialOrderPrice(),
+		ExecutedOrderPrice:    st.GetExecutedOrderPrice(),
+		TotalOrderAmount:      st.GetTotalOrderAmount(),
+		InitialCommission:     st.GetInitialCommission(),
+		ExecutedCommission:    st.GetExecutedCommission(),
+		Figi:                  st.GetFigi(),
+		Direction:             st.GetDirection(),
+		InitialSecurityPrice:  st.GetInitialSecurityPrice(),
+		OrderType:             st.GetOrderType(),
+		InstrumentUid:         st.GetInstrumentUid(),
+	}
+}
+
+// level - Ценовой уровень стакана, количество в лотах
+type level struct {
+	price decimal.Decimal
+	lots  int64
+}
+
+// book - Стакан, из которого вычитается исполненный брокером объем
+type book struct {
+	bids []level
+	asks []level
+}
+
+func newBook(ob *pb.OrderBook) *book {
+	return &book{
+		bids: levels(ob.GetBids()),
+		asks: levels(ob.GetAsks()),
+	}
+}
+
+func levels(orders []*pb.Order) []level {
+	res := make([]level, 0, len(orders))
+	for _, o := range orders {
+		if o.GetQuantity() > 0 {
+			res = append(res, level{price: o.GetPrice().ToDecimal(), lots: o.GetQuantity()})
+		}
+	}
+	return res
+}
+
+// side - встречная для заявки сторона стакана
+func (bk *book) side(direction pb.OrderDirection) *[]level {
+	if direction == pb.OrderDirection_ORDER_DIRECTION_SELL {
+		return &bk.bids
+	}
+	return &bk.asks
+}
+
+// PostOrder - Выставление заявки. Рыночная заявка исполняется по встречной стороне стакана, а если стакана нет -
+// по цене последней сделки, лимитная заявка исполняется, если цена стакана не хуже лимитной
+func (b *Broker) PostOrder(_ context.Context, req *investgo.PostOrderRequest) (*investgo.PostOrderResponse, error) {
+	var resp *pb.PostOrderResponse
+	var err error
+	b.update(func(ev *events) {
+		if err = b.account(req.AccountId); err != nil {
+			return
+		}
+		var o *order
+		o, err = b.placeOrder(req.InstrumentId, req.OrderId, req.Direction, req.OrderType, req.Quantity, req.Price, ev)
+		if err != nil {
+			return
+		}
+		resp = o.response()
+	})
+	return &investgo.PostOrderResponse{PostOrderResponse: resp, RequestId: req.OrderId}, err
+}
+
+// ReplaceOrder - Отмена заявки и выставление новой лимитной заявки с остатком лотов, как на бирже
+func (b *Broker) ReplaceOrder(_ context.Context, req *investgo.ReplaceOrderRequest) (*investgo.PostOrderResponse, error) {
+	var resp *pb.PostOrderResponse
+	var err error
+	b.update(func(ev *events) {
+		if err = b.account(req.AccountId); err != nil {
+			return
+		}
+		old, ok := b.orders[req.OrderId]
+		if !ok || !old.active() {
+			err = investgo.NewError(codes.NotFound, codeOrderNotFound, "Order not found")
+			return
+		}
+		price := req.Price
+		if price == nil {
+			price = investgo.DecimalToQuotation(old.limit)
+		}
+		quantity := req.Quantity
+		if quantity <= 0 {
+			quantity = old.lotsLeft()
+		}
+		status := old.state.GetExecutionReportStatus()
+		old.state.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED
+		var o *order
+		o, err = b.placeOrder(uid(old.inst), req.NewOrderId, old.state.GetDirection(),
+			pb.OrderType_ORDER_TYPE_LIMIT, quantity, price, ev)
+		if err != nil {
+			old.state.ExecutionReportStatus = status
+			return
+		}
+		resp = o.response()
+	})
+	return &investgo.PostOrderResponse{PostOrderResponse: resp, RequestId: req.NewOrderId}, err
+}
+
+// CancelOrder - Отмена активной заявки
+func (b *Broker) CancelOrder(_ context.Context, accountId, orderId string) (*investgo.CancelOrderResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.account(accountId); err != nil {
+		return &investgo.CancelOrderResponse{}, err
+	}
+	o, ok := b.orders[orderId]
+	if !ok || !o.active() {
+		return &investgo.CancelOrderResponse{}, investgo.NewError(codes.NotFound, codeOrderNotFound, "Order not found")
+	}
+	o.state.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED
+	return &investgo.CancelOrderResponse{
+		CancelOrderResponse: &pb.CancelOrderResponse{Time: timestamppb.New(b.now())},
+	}, nil
+}
+
+// GetOrderState - Статус заявки, в том числе исполненной или отмененной
+func (b *Broker) GetOrderState(_ context.Context, accountId, orderId string) (*investgo.GetOrderStateResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.account(accountId); err != nil {
+		return &investgo.GetOrderStateResponse{}, err
+	}
+	o, ok := b.orders[orderId]
+	if !ok {
+		return &investgo.GetOrderStateResponse{}, investgo.NewError(codes.NotFound, codeOrderNotFound, "Order not found")
+	}
+	return &investgo.GetOrderStateResponse{OrderState: o.state}, nil
+}
+
+// GetOrders - Список активных заявок
+func (b *Broker) GetOrders(_ context.Context, accountId string) (*investgo.GetOrdersResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.account(accountId); err != nil {
+		return &investgo.GetOrdersResponse{}, err
+	}
+	resp := &pb.GetOrdersResponse{}
+	for _, id := range b.orderIds {
+		if o := b.orders[id]; o.active() {
+			resp.Orders = append(resp.Orders, o.state)
+		}
+	}
+	return &investgo.GetOrdersResponse{GetOrdersResponse: resp}, nil
+}
+
+// placeOrder - Выставление заявки и ее исполнение, если рынок позволяет, вызывается под b.mu
+func (b *Broker) placeOrder(instrumentId, requestId string, direction pb.OrderDirection, orderType pb.OrderType,
+	quantity int64, price *pb.Quotation, ev *events) (*order, error) {
+	if requestId != "" {
+		if o, ok := b.requests[requestId]; ok {
+			return o, nil
+		}
+	}
+	inst, err := b.instrument(instrumentId)
+	if err != nil {
+		return nil, err
+	}
+	if quantity <= 0 {
+		return nil, investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Quantity must be positive")
+	}
+	if direction == pb.OrderDirection_ORDER_DIRECTION_UNSPECIFIED {
+		return nil, investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Direction is not specified")
+	}
+	if !b.tradable(inst) {
+		return nil, investgo.NewError(codes.FailedPrecondition, codeNotAvailableTrading, "Instrument is not available for trading")
+	}
+
+	var limit decimal.Decimal
+	switch orderType {
+	case pb.OrderType_ORDER_TYPE_LIMIT:
+		limit = price.ToDecimal()
+		if !limit.IsPositive() {
+			return nil, investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Price must be positive")
+		}
+		step := inst.GetMinPriceIncrement().ToDecimal()
+		if step.IsPositive() && !limit.Mod(step).IsZero() {
+			return nil, investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Price is not a multiple of min price increment")
+		}
+	case pb.OrderType_ORDER_TYPE_MARKET, pb.OrderType_ORDER_TYPE_BESTPRICE:
+		market, ok := b.marketPrice(inst, direction)
+		if !ok {
+			return nil, investgo.NewError(codes.FailedPrecondition, codeNotAvailableTrading, "No market price for instrument")
+		}
+		limit = market
+	default:
+		return nil, investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Order type is not specified")
+	}
+
+	pieces := quantity * lot(inst)
+	amount := limit.Mul(decimal.NewFromInt(pieces))
+	fee := amount.Mul(b.commission)
+	cur := currency(inst)
+	if direction == pb.OrderDirection_ORDER_DIRECTION_BUY {
+		if b.availableMoney(cur).LessThan(amount.Add(fee)) {
+			return nil, investgo.NewError(codes.InvalidArgument, codeInsufficientBalance, "Not enough balance")
+		}
+	} else if !inst.GetShortEnabledFlag() && b.availablePieces(inst) < pieces {
+		return nil, investgo.NewError(codes.InvalidArgument, codeNotEnoughAssets, "Not enough assets for a margin trade")
+	}
+
+	o := &order{
+		inst:  inst,
+		limit: limit,
+		state: &pb.OrderState{
+			OrderId:               b.nextId(),
+			ExecutionReportStatus: pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_NEW,
+			LotsRequested:         quantity,
+			InitialOrderPrice:     investgo.DecimalToMoneyValue(amount, cur),
+			ExecutedOrderPrice:    investgo.DecimalToMoneyValue(decimal.Zero, cur),
+			TotalOrderAmount:      investgo.DecimalToMoneyValue(decimal.Zero, cur),
+			InitialCommission:     investgo.DecimalToMoneyValue(fee, cur),
+			ExecutedCommission:    investgo.DecimalToMoneyValue(decimal.Zero, cur),
+			ServiceCommission:     investgo.DecimalToMoneyValue(decimal.Zero, cur),
+			Figi:                  inst.GetFigi(),
+			Direction:             direction,
+			InitialSecurityPrice:  investgo.DecimalToMoneyValue(limit, cur),
+			Currency:              cur,
+			OrderType:             orderType,
+			OrderDate:             timestamppb.New(b.now()),
+			InstrumentUid:         inst.GetUid(),
+			OrderRequestId:        requestId,
+		},
+	}
+	b.orders[o.state.GetOrderId()] = o
+	b.orderIds = append(b.orderIds, o.state.GetOrderId())
+	if requestId != "" {
+		b.requests[requestId] = o
+	}
+	b.matchOrder(o, ev)
+	return o, nil
+}
+
+// crosses - Можно ли исполнить заявку по цене price при лимитной цене limit
+func crosses(direction pb.OrderDirection, price, limit decimal.Decimal) bool {
+	if direction == pb.OrderDirection_ORDER_DIRECTION_BUY {
+		return price.LessThanOrEqual(limit)
+	}
+	return price.GreaterThanOrEqual(limit)
+}
+
+// marketPrice - Цена исполнения рыночной заявки: лучшая цена встречной стороны стакана или цена последней сделки
+func (b *Broker) marketPrice(inst *pb.Instrument, direction pb.OrderDirection) (decimal.Decimal, bool) {
+	if bk, ok := b.books[uid(inst)]; ok {
+		if side := *bk.side(direction); len(side) > 0 {
+			return side[0].price, true
+		}
+	}
+	lp, ok := b.lastPrices[uid(inst)]
+	return lp, ok
+}
+
+// matchOrders - Исполнение активных заявок по инструменту после нового стакана, вызывается под b.mu
+func (b *Broker) matchOrders(inst *pb.Instrument, ev *events) {
+	for _, id := range b.orderIds {
+		if o := b.orders[id]; o.inst == inst && o.active() {
+			b.matchBook(o, b.books[uid(inst)], ev)
+		}
+	}
+}
+
+// matchPrice - Исполнение активных заявок по инструменту сделкой по цене price объемом volume лотов.
+// Лимитные заявки с ценой не хуже price исполняются по своей цене, рыночные - по price.
+// Возвращает неизрасходованный объем, вызывается под b.mu
+func (b *Broker) matchPrice(inst *pb.Instrument, price decimal.Decimal, volume int64, ev *events) int64 {
+	for _, id := range b.orderIds {
+		if volume <= 0 {
+			break
+		}
+		o := b.orders[id]
+		if o.inst != inst || !o.active() {
+			continue
+		}
+		fillPrice := b.slipped(o, price)
+		if !o.market() {
+			if !crosses(o.state.GetDirection(), price, o.limit) {
+				continue
+			}
+			fillPrice = o.limit
+		}
+		lots := min64(volume, o.lotsLeft())
+		b.fill(o, lots, fillPrice, ev)
+		volume -= lots
+	}
+	return volume
+}
+
+// matchOrder - Исполнение новой заявки по стакану, а без стакана - по цене последней сделки, вызывается под b.mu
+func (b *Broker) matchOrder(o *order, ev *events) {
+	if bk, ok := b.books[uid(o.inst)]; ok {
+		b.matchBook(o, bk, ev)
+		return
+	}
+	lp, ok := b.lastPrices[uid(o.inst)]
+	if !ok {
+		return
+	}
+	if o.market() {
+		b.fill(o, o.lotsLeft(), b.slipped(o, lp), ev)
+	} else if crosses(o.state.GetDirection(), lp, o.limit) {
+		b.fill(o, o.lotsLeft(), lp, ev)
+	}
+}
+
+// matchBook - Исполнение заявки по встречной стороне стакана, исполненный объем вычитается из стакана
+func (b *Broker) matchBook(o *order, bk *book, ev *events) {
+	side := bk.side(o.state.GetDirection())
+	for len(*side) > 0 && o.lotsLeft() > 0 {
+		lvl := &(*side)[0]
+		if !o.market() && !crosses(o.state.GetDirection(), lvl.price, o.limit) {
+			return
+		}
+		lots := min64(lvl.lots, o.lotsLeft())
+		price := lvl.price
+		if o.market() {
+			price = b.slipped(o, price)
+		}
+		b.fill(o, lots, price, ev)
+		lvl.lots -= lots
+		if lvl.lots == 0 {
+			*side = (*side)[1:]
+		}
+	}
+}
+
+// slipped - Цена исполнения рыночной заявки с проскальзыванием не в пользу клиента, округленная к шагу цены
+func (b *Broker) slipped(o *order, price decimal.Decimal) decimal.Decimal {
+	if !o.market() || !b.slippage.IsPositive() {
+		return price
+	}
+	mode := investgo.RoundUp
+	delta := price.Mul(b.slippage)
+	if o.state.GetDirection() == pb.OrderDirection_ORDER_DIRECTION_SELL {
+		mode = investgo.RoundDown
+		delta = delta.Neg()
+	}
+	rounded, err := investgo.RoundQuotation(investgo.DecimalToQuotation(price.Add(delta)), o.inst.GetMinPriceIncrement(), mode)
+	if err != nil {
+		return price.Add(delta)
+	}
+	return rounded.ToDecimal()
+}
+
+// fill - Исполнение lots лотов заявки по цене price, вызывается под b.mu
+func (b *Broker) fill(o *order, lots int64, price decimal.Decimal, ev *events) {
+	inst := o.inst
+	cur := o.state.GetCurrency()
+	quantity := lots * lot(inst)
+	amount := price.Mul(decimal.NewFromInt(quantity))
+	fee := amount.Mul(b.commission)
+	now := b.now()
+	tradeId := b.nextId()
+
+	signed := quantity
+	payment := amount.Neg()
+	opType := pb.OperationType_OPERATION_TYPE_BUY
+	opName := "Покупка ценных бумаг"
+	if o.state.GetDirection() == pb.OrderDirection_ORDER_DIRECTION_SELL {
+		signed = -quantity
+		payment = amount
+		opType = pb.OperationType_OPERATION_TYPE_SELL
+		opName = "Продажа ценных бумаг"
+	}
+	b.money[cur] = b.money[cur].Add(payment).Sub(fee)
+
+	p, ok := b.positions[uid(inst)]
+	if !ok {
+		p = &position{inst: inst}
+		b.positions[uid(inst)] = p
+	}
+	p.apply(signed, price)
+
+	o.executed = o.executed.Add(amount)
+	o.fee = o.fee.Add(fee)
+	st := o.state
+	st.LotsExecuted += lots
+	st.ExecutedOrderPrice = investgo.DecimalToMoneyValue(o.executed, cur)
+	st.ExecutedCommission = investgo.DecimalToMoneyValue(o.fee, cur)
+	if st.GetDirection() == pb.OrderDirection_ORDER_DIRECTION_BUY {
+		st.TotalOrderAmount = investgo.DecimalToMoneyValue(o.executed.Add(o.fee), cur)
+	} else {
+		st.TotalOrderAmount = investgo.DecimalToMoneyValue(o.executed.Sub(o.fee), cur)
+	}
+	st.AveragePositionPrice = investgo.DecimalToMoneyValue(
+		o.executed.Div(decimal.NewFromInt(st.GetLotsExecuted()*lot(inst))), cur)
+	st.Stages = append(st.Stages, &pb.OrderStage{
+		Price:    investgo.DecimalToMoneyValue(price, cur),
+		Quantity: lots,
+		TradeId:  tradeId,
+	})
+	if o.lotsLeft() > 0 {
+		st.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL
+	} else {
+		st.ExecutionReportStatus = pb.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL
+	}
+
+	opId := b.nextId()
+	b.operations = append(b.operations, &pb.Operation{
+		Id:             opId,
+		Currency:       cur,
+		Payment:        investgo.DecimalToMoneyValue(payment, cur),
+		Price:          investgo.DecimalToMoneyValue(price, cur),
+		State:          pb.OperationState_OPERATION_STATE_EXECUTED,
+		Quantity:       quantity,
+		Figi:           inst.GetFigi(),
+		InstrumentType: inst.GetInstrumentType(),
+		Date:           timestamppb.New(now),
+		Type:           opName,
+		OperationType:  opType,
+		Trades: []*pb.OperationTrade{{
+			TradeId:  tradeId,
+			DateTime: timestamppb.New(now),
+			Quantity: quantity,
+			Price:    investgo.DecimalToMoneyValue(price, cur),
+		}},
+		PositionUid:   inst.GetPositionUid(),
+		InstrumentUid: inst.GetUid(),
+	})
+	if fee.IsPositive() {
+		b.operations = append(b.operations, &pb.Operation{
+			Id:                b.nextId(),
+			ParentOperationId: opId,
+			Currency:          cur,
+			Payment:           investgo.DecimalToMoneyValue(fee.Neg(), cur),
+			State:             pb.OperationState_OPERATION_STATE_EXECUTED,
+			Figi:              inst.GetFigi(),
+			InstrumentType:    inst.GetInstrumentType(),
+			Date:              timestamppb.New(now),
+			Type:              "Удержание комиссии за операцию",
+			OperationType:     pb.OperationType_OPERATION_TYPE_BROKER_FEE,
+			PositionUid:       inst.GetPositionUid(),
+			InstrumentUid:     inst.GetUid(),
+		})
+	}
+
+	ev.trades = append(ev.trades, &pb.OrderTrades{
+		OrderId:   st.GetOrderId(),
+		CreatedAt: timestamppb.New(now),
+		Direction: st.GetDirection(),
+		Figi:      inst.GetFigi(),
+		Trades: []*pb.OrderTrade{{
+			DateTime: timestamppb.New(now),
+			Price:    investgo.DecimalToQuotation(price),
+			Quantity: quantity,
+			TradeId:  tradeId,
+		}},
+		AccountId:     b.accountId,
+		InstrumentUid: inst.GetUid(),
+	})
+}
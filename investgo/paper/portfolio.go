@@ -0,0 +1,359 @@
+package paper
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+)
+
+// position - Позиция по инструменту в штуках, отрицательная для шорта
+type position struct {
+	inst     *pb.Instrument
+	balance  int64
+	avgPrice decimal.Decimal
+}
+
+// apply - Изменение позиции на signed штук по цене price с пересчетом средней цены
+func (p *position) apply(signed int64, price decimal.Decimal) {
+	balance := p.balance + signed
+	switch {
+	case balance == 0:
+		p.avgPrice = decimal.Zero
+	case p.balance == 0 || (p.balance > 0) == (signed > 0):
+		total := p.avgPrice.Mul(decimal.NewFromInt(abs(p.balance))).Add(price.Mul(decimal.NewFromInt(abs(signed))))
+		p.avgPrice = total.Div(decimal.NewFromInt(abs(balance)))
+	case (p.balance > 0) != (balance > 0):
+		p.avgPrice = price
+	}
+	p.balance = balance
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// blockedMoney - Деньги, заблокированные активными заявками на покупку, вызывается под b.mu
+func (b *Broker) blockedMoney(cur string) decimal.Decimal {
+	blocked := decimal.Zero
+	for _, id := range b.orderIds {
+		o := b.orders[id]
+		if !o.active() || o.state.GetDirection() != pb.OrderDirection_ORDER_DIRECTION_BUY || o.state.GetCurrency() != cur {
+			continue
+		}
+		amount := o.limit.Mul(decimal.NewFromInt(o.lotsLeft() * lot(o.inst)))
+		blocked = blocked.Add(amount).Add(amount.Mul(b.commission))
+	}
+	return blocked
+}
+
+// blockedPieces - Бумаги, заблокированные активными заявками на продажу, в штуках, вызывается под b.mu
+func (b *Broker) blockedPieces(inst *pb.Instrument) int64 {
+	var blocked int64
+	for _, id := range b.orderIds {
+		o := b.orders[id]
+		if o.active() && o.inst == inst && o.state.GetDirection() == pb.OrderDirection_ORDER_DIRECTION_SELL {
+			blocked += o.lotsLeft() * lot(inst)
+		}
+	}
+	return blocked
+}
+
+// availableMoney - Деньги, доступные для новых заявок, вызывается под b.mu
+func (b *Broker) availableMoney(cur string) decimal.Decimal {
+	return b.money[cur].Sub(b.blockedMoney(cur))
+}
+
+// availablePieces - Бумаги, доступные для продажи без шорта, в штуках, вызывается под b.mu
+func (b *Broker) availablePieces(inst *pb.Instrument) int64 {
+	var balance int64
+	if p, ok := b.positions[uid(inst)]; ok {
+		balance = p.balance
+	}
+	return balance - b.blockedPieces(inst)
+}
+
+// GetPositions - Деньги и бумаги на счете, заблокированные активными заявками суммы указаны отдельно
+func (b *Broker) GetPositions(_ context.Context, accountId string) (*investgo.PositionsResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.account(accountId); err != nil {
+		return &investgo.PositionsResponse{}, err
+	}
+	resp := &pb.PositionsResponse{}
+	for _, cur := range b.currencies() {
+		blocked := b.blockedMoney(cur)
+		resp.Money = append(resp.Money, investgo.DecimalToMoneyValue(b.money[cur].Sub(blocked), cur))
+		resp.Blocked = append(resp.Blocked, investgo.DecimalToMoneyValue(blocked, cur))
+	}
+	for _, p := range b.sortedPositions() {
+		inst := p.inst
+		blocked := b.blockedPieces(inst)
+		switch inst.GetInstrumentKind() {
+		case pb.InstrumentType_INSTRUMENT_TYPE_FUTURES:
+			resp.Futures = append(resp.Futures, &pb.PositionsFutures{
+				Figi:          inst.GetFigi(),
+				Blocked:       blocked,
+				Balance:       p.balance - blocked,
+				PositionUid:   inst.GetPositionUid(),
+				InstrumentUid: inst.GetUid(),
+			})
+		case pb.InstrumentType_INSTRUMENT_TYPE_OPTION:
+			resp.Options = append(resp.Options, &pb.PositionsOptions{
+				PositionUid:   inst.GetPositionUid(),
+				InstrumentUid: inst.GetUid(),
+				Blocked:       blocked,
+				Balance:       p.balance - blocked,
+			})
+		default:
+			resp.Securities = append(resp.Securities, &pb.PositionsSecurities{
+				Figi:           inst.GetFigi(),
+				Blocked:        blocked,
+				Balance:        p.balance - blocked,
+				PositionUid:    inst.GetPositionUid(),
+				InstrumentUid:  inst.GetUid(),
+				InstrumentType: inst.GetInstrumentType(),
+			})
+		}
+	}
+	return &investgo.PositionsResponse{PositionsResponse: resp}, nil
+}
+
+// GetPortfolio - Портфель по счету. Курсы валют брокеру неизвестны, поэтому в итоговые суммы портфеля
+// входят только деньги и позиции в валюте portfolioCurrency
+func (b *Broker) GetPortfolio(_ context.Context, accountId string, portfolioCurrency pb.PortfolioRequest_CurrencyRequest) (*investgo.PortfolioResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.account(accountId); err != nil {
+		return &investgo.PortfolioResponse{}, err
+	}
+	cur := strings.ToLower(portfolioCurrency.String())
+	totals := make(map[pb.InstrumentType]decimal.Decimal)
+	cost, yield := decimal.Zero, decimal.Zero
+	resp := &pb.PortfolioResponse{AccountId: b.accountId}
+
+	for _, p := range b.sortedPositions() {
+		inst := p.inst
+		instCurrency := currency(inst)
+		balance := decimal.NewFromInt(p.balance)
+		price := p.avgPrice
+		if lp, ok := b.lastPrices[uid(inst)]; ok {
+			price = lp
+		}
+		positionYield := price.Sub(p.avgPrice).Mul(balance)
+		if instCurrency == cur {
+			kind := inst.GetInstrumentKind()
+			totals[kind] = totals[kind].Add(price.Mul(balance))
+			cost = cost.Add(p.avgPrice.Mul(balance).Abs())
+			yield = yield.Add(positionYield)
+		}
+		resp.Positions = append(resp.Positions, &pb.PortfolioPosition{
+			Figi:                     inst.GetFigi(),
+			InstrumentType:           inst.GetInstrumentType(),
+			Quantity:                 investgo.DecimalToQuotation(balance),
+			AveragePositionPrice:     investgo.DecimalToMoneyValue(p.avgPrice, instCurrency),
+			ExpectedYield:            investgo.DecimalToQuotation(positionYield),
+			CurrentPrice:             investgo.DecimalToMoneyValue(price, instCurrency),
+			AveragePositionPriceFifo: investgo.DecimalToMoneyValue(p.avgPrice, instCurrency),
+			QuantityLots:             investgo.DecimalToQuotation(balance.Div(decimal.NewFromInt(lot(inst)))),
+			BlockedLots:              investgo.DecimalToQuotation(decimal.NewFromInt(b.blockedPieces(inst) / lot(inst))),
+			PositionUid:              inst.GetPositionUid(),
+			InstrumentUid:            inst.GetUid(),
+			VarMargin:                investgo.DecimalToMoneyValue(decimal.Zero, instCurrency),
+			ExpectedYieldFifo:        investgo.DecimalToQuotation(positionYield),
+		})
+	}
+
+	money := b.money[cur]
+	totals[pb.InstrumentType_INSTRUMENT_TYPE_CURRENCY] = totals[pb.InstrumentType_INSTRUMENT_TYPE_CURRENCY].Add(money)
+	total := decimal.Zero
+	for _, v := range totals {
+		total = total.Add(v)
+	}
+	resp.TotalAmountShares = investgo.DecimalToMoneyValue(totals[pb.InstrumentType_INSTRUMENT_TYPE_SHARE], cur)
+	resp.TotalAmountBonds = investgo.DecimalToMoneyValue(totals[pb.InstrumentType_INSTRUMENT_TYPE_BOND], cur)
+	resp.TotalAmountEtf = investgo.DecimalToMoneyValue(totals[pb.InstrumentType_INSTRUMENT_TYPE_ETF], cur)
+	resp.TotalAmountCurrencies = investgo.DecimalToMoneyValue(totals[pb.InstrumentType_INSTRUMENT_TYPE_CURRENCY], cur)
+	resp.TotalAmountFutures = investgo.DecimalToMoneyValue(totals[pb.InstrumentType_INSTRUMENT_TYPE_FUTURES], cur)
+	resp.TotalAmountOptions = investgo.DecimalToMoneyValue(totals[pb.InstrumentType_INSTRUMENT_TYPE_OPTION], cur)
+	resp.TotalAmountSp = investgo.DecimalToMoneyValue(totals[pb.InstrumentType_INSTRUMENT_TYPE_SP], cur)
+	resp.TotalAmountPortfolio = investgo.DecimalToMoneyValue(total, cur)
+	resp.ExpectedYield = investgo.DecimalToQuotation(decimal.Zero)
+	if cost.IsPositive() {
+		resp.ExpectedYield = investgo.DecimalToQuotation(yield.Div(cost).Mul(decimal.NewFromInt(100)).Round(2))
+	}
+	return &investgo.PortfolioResponse{PortfolioResponse: resp}, nil
+}
+
+// GetOperations - Список операций по счету с фильтрами по figi, статусу и периоду
+func (b *Broker) GetOperations(_ context.Context, req *investgo.GetOperationsRequest) (*investgo.OperationsResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.account(req.AccountId); err != nil {
+		return &investgo.OperationsResponse{}, err
+	}
+	resp := &pb.OperationsResponse{}
+	for _, op := range b.operations {
+		date := op.GetDate().AsTime()
+		switch {
+		case !req.From.IsZero() && date.Before(req.From):
+		case !req.To.IsZero() && date.After(req.To):
+		case req.State != pb.OperationState_OPERATION_STATE_UNSPECIFIED && op.GetState() != req.State:
+		case req.Figi != "" && op.GetFigi() != req.Figi:
+		default:
+			resp.Operations = append(resp.Operations, op)
+		}
+	}
+	return &investgo.OperationsResponse{OperationsResponse: resp}, nil
+}
+
+// GetOperationsByCursor - Список операций по счету с пагинацией, курсором служит номер операции на счете
+func (b *Broker) GetOperationsByCursor(_ context.Context, req *investgo.GetOperationsByCursorRequest) (*investgo.GetOperationsByCursorResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.account(req.AccountId); err != nil {
+		return &investgo.GetOperationsByCursorResponse{}, err
+	}
+	start := 0
+	if req.Cursor != "" {
+		var err error
+		start, err = strconv.Atoi(req.Cursor)
+		if err != nil || start < 0 {
+			return &investgo.GetOperationsByCursorResponse{}, investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Invalid cursor")
+		}
+	}
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	var inst *pb.Instrument
+	if req.InstrumentId != "" {
+		var err error
+		if inst, err = b.instrument(req.InstrumentId); err != nil {
+			return &investgo.GetOperationsByCursorResponse{}, err
+		}
+	}
+	types := make(map[pb.OperationType]struct{}, len(req.OperationTypes))
+	for _, t := range req.OperationTypes {
+		types[t] = struct{}{}
+	}
+
+	resp := &pb.GetOperationsByCursorResponse{}
+	for i := start; i < len(b.operations); i++ {
+		op := b.operations[i]
+		if _, ok := types[op.GetOperationType()]; len(types) > 0 && !ok {
+			continue
+		}
+		date := op.GetDate().AsTime()
+		switch {
+		case inst != nil && op.GetInstrumentUid() != inst.GetUid():
+		case !req.From.IsZero() && date.Before(req.From):
+		case !req.To.IsZero() && date.After(req.To):
+		case req.State != pb.OperationState_OPERATION_STATE_UNSPECIFIED && op.GetState() != req.State:
+		case req.WithoutCommissions && op.GetOperationType() == pb.OperationType_OPERATION_TYPE_BROKER_FEE:
+		default:
+			if len(resp.Items) == limit {
+				resp.HasNext = true
+				resp.NextCursor = strconv.Itoa(i)
+				return &investgo.GetOperationsByCursorResponse{GetOperationsByCursorResponse: resp}, nil
+			}
+			resp.Items = append(resp.Items, b.operationItem(op, i, req.WithoutTrades))
+		}
+	}
+	return &investgo.GetOperationsByCursorResponse{GetOperationsByCursorResponse: resp}, nil
+}
+
+func (b *Broker) operationItem(op *pb.Operation, index int, withoutTrades bool) *pb.OperationItem {
+	item := &pb.OperationItem{
+		Cursor:            strconv.Itoa(index),
+		BrokerAccountId:   b.accountId,
+		Id:                op.GetId(),
+		ParentOperationId: op.GetParentOperationId(),
+		Name:              op.GetType(),
+		Date:              op.GetDate(),
+		Type:              op.GetOperationType(),
+		Description:       op.GetType(),
+		State:             op.GetState(),
+		InstrumentUid:     op.GetInstrumentUid(),
+		Figi:              op.GetFigi(),
+		InstrumentType:    op.GetInstrumentType(),
+		PositionUid:       op.GetPositionUid(),
+		Payment:           op.GetPayment(),
+		Price:             op.GetPrice(),
+		Quantity:          op.GetQuantity(),
+		QuantityDone:      op.GetQuantity() - op.GetQuantityRest(),
+		QuantityRest:      op.GetQuantityRest(),
+	}
+	if inst := b.find(op.GetInstrumentUid()); inst != nil {
+		item.InstrumentKind = inst.GetInstrumentKind()
+	}
+	if !withoutTrades && len(op.GetTrades()) > 0 {
+		item.TradesInfo = &pb.OperationItemTrades{}
+		for _, t := range op.GetTrades() {
+			item.TradesInfo.Trades = append(item.TradesInfo.Trades, &pb.OperationItemTrade{
+				Num:      t.GetTradeId(),
+				Date:     t.GetDateTime(),
+				Quantity: t.GetQuantity(),
+				Price:    t.GetPrice(),
+			})
+		}
+	}
+	return item
+}
+
+// GetWithdrawLimits - Доступный для вывода остаток: деньги за вычетом заблокированных активными заявками
+func (b *Broker) GetWithdrawLimits(_ context.Context, accountId string) (*investgo.WithdrawLimitsResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.account(accountId); err != nil {
+		return &investgo.WithdrawLimitsResponse{}, err
+	}
+	resp := &pb.WithdrawLimitsResponse{}
+	for _, cur := range b.currencies() {
+		blocked := b.blockedMoney(cur)
+		resp.Money = append(resp.Money, investgo.DecimalToMoneyValue(b.money[cur].Sub(blocked), cur))
+		resp.Blocked = append(resp.Blocked, investgo.DecimalToMoneyValue(blocked, cur))
+		resp.BlockedGuarantee = append(resp.BlockedGuarantee, investgo.DecimalToMoneyValue(decimal.Zero, cur))
+	}
+	return &investgo.WithdrawLimitsResponse{WithdrawLimitsResponse: resp}, nil
+}
+
+func (b *Broker) currencies() []string {
+	currencies := make([]string, 0, len(b.money))
+	for cur := range b.money {
+		currencies = append(currencies, cur)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+func (b *Broker) sortedPositions() []*position {
+	positions := make([]*position, 0, len(b.positions))
+	for _, p := range b.positions {
+		if p.balance != 0 {
+			positions = append(positions, p)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return uid(positions[i].inst) < uid(positions[j].inst)
+	})
+	return positions
+}
@@ -0,0 +1,174 @@
+package paper
+
+import (
+	"context"
+
+	"github.com/shopspring/decimal"
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// stopOrder - Стоп-заявка, при срабатывании превращается в биржевую заявку
+type stopOrder struct {
+	inst      *pb.Instrument
+	stopPrice decimal.Decimal
+	price     *pb.Quotation
+	state     *pb.StopOrder
+}
+
+// PostStopOrder - Выставление стоп-заявки. Стоп-заявка срабатывает по цене последней сделки из OnTrade и OnLastPrice
+// и выставляет рыночную заявку, а стоп-лимит - лимитную заявку по цене Price
+func (b *Broker) PostStopOrder(_ context.Context, req *investgo.PostStopOrderRequest) (*investgo.PostStopOrderResponse, error) {
+	var resp *pb.PostStopOrderResponse
+	var err error
+	b.update(func(ev *events) {
+		if err = b.account(req.AccountId); err != nil {
+			return
+		}
+		var inst *pb.Instrument
+		inst, err = b.instrument(req.InstrumentId)
+		if err != nil {
+			return
+		}
+		if err = validateStopOrder(req); err != nil {
+			return
+		}
+		var expire *timestamppb.Timestamp
+		if !req.ExpireDate.IsZero() {
+			expire = timestamppb.New(req.ExpireDate)
+		}
+		cur := currency(inst)
+		so := &stopOrder{
+			inst:      inst,
+			stopPrice: req.StopPrice.ToDecimal(),
+			price:     req.Price,
+			state: &pb.StopOrder{
+				StopOrderId:    b.nextId(),
+				LotsRequested:  req.Quantity,
+				Figi:           inst.GetFigi(),
+				Direction:      req.Direction,
+				Currency:       cur,
+				OrderType:      req.StopOrderType,
+				CreateDate:     timestamppb.New(b.now()),
+				ExpirationTime: expire,
+				Price:          investgo.DecimalToMoneyValue(req.Price.ToDecimal(), cur),
+				StopPrice:      investgo.DecimalToMoneyValue(req.StopPrice.ToDecimal(), cur),
+				InstrumentUid:  inst.GetUid(),
+			},
+		}
+		b.stopOrders[so.state.GetStopOrderId()] = so
+		b.stopIds = append(b.stopIds, so.state.GetStopOrderId())
+		resp = &pb.PostStopOrderResponse{StopOrderId: so.state.GetStopOrderId()}
+		b.matchStopOrders(inst, ev)
+	})
+	return &investgo.PostStopOrderResponse{PostStopOrderResponse: resp}, err
+}
+
+func validateStopOrder(req *investgo.PostStopOrderRequest) error {
+	switch {
+	case req.Quantity <= 0:
+		return investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Quantity must be positive")
+	case req.Direction == pb.StopOrderDirection_STOP_ORDER_DIRECTION_UNSPECIFIED ||
+		req.StopOrderType == pb.StopOrderType_STOP_ORDER_TYPE_UNSPECIFIED:
+		return investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Stop order direction and type are required")
+	case !req.StopPrice.ToDecimal().IsPositive():
+		return investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Stop price must be positive")
+	case req.StopOrderType == pb.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT && !req.Price.ToDecimal().IsPositive():
+		return investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Price is required for stop limit order")
+	case req.ExpirationType == pb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_DATE && req.ExpireDate.IsZero():
+		return investgo.NewError(codes.InvalidArgument, codeInvalidArgument, "Expire date is required")
+	}
+	return nil
+}
+
+// GetStopOrders - Список активных стоп-заявок
+func (b *Broker) GetStopOrders(_ context.Context, accountId string) (*investgo.GetStopOrdersResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.account(accountId); err != nil {
+		return &investgo.GetStopOrdersResponse{}, err
+	}
+	resp := &pb.GetStopOrdersResponse{}
+	for _, id := range b.stopIds {
+		resp.StopOrders = append(resp.StopOrders, b.stopOrders[id].state)
+	}
+	return &investgo.GetStopOrdersResponse{GetStopOrdersResponse: resp}, nil
+}
+
+// CancelStopOrder - Отмена стоп-заявки
+func (b *Broker) CancelStopOrder(_ context.Context, accountId, stopOrderId string) (*investgo.CancelStopOrderResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.account(accountId); err != nil {
+		return &investgo.CancelStopOrderResponse{}, err
+	}
+	if !b.removeStopOrder(stopOrderId) {
+		return &investgo.CancelStopOrderResponse{}, investgo.NewError(codes.NotFound, codeStopOrderNotFound, "Stop order not found")
+	}
+	return &investgo.CancelStopOrderResponse{
+		CancelStopOrderResponse: &pb.CancelStopOrderResponse{Time: timestamppb.New(b.now())},
+	}, nil
+}
+
+func (b *Broker) removeStopOrder(id string) bool {
+	if _, ok := b.stopOrders[id]; !ok {
+		return false
+	}
+	delete(b.stopOrders, id)
+	for i, sid := range b.stopIds {
+		if sid == id {
+			b.stopIds = append(b.stopIds[:i], b.stopIds[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// triggered - Сработала ли стоп-заявка при цене последней сделки price
+func (so *stopOrder) triggered(price decimal.Decimal) bool {
+	buy := so.state.GetDirection() == pb.StopOrderDirection_STOP_ORDER_DIRECTION_BUY
+	switch so.state.GetOrderType() {
+	case pb.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT:
+		if buy {
+			return price.LessThanOrEqual(so.stopPrice)
+		}
+		return price.GreaterThanOrEqual(so.stopPrice)
+	default:
+		if buy {
+			return price.GreaterThanOrEqual(so.stopPrice)
+		}
+		return price.LessThanOrEqual(so.stopPrice)
+	}
+}
+
+// matchStopOrders - Снятие истекших и активация сработавших стоп-заявок по инструменту, вызывается под b.mu.
+// Стоп-заявки, для которых не удалось выставить биржевую заявку, снимаются
+func (b *Broker) matchStopOrders(inst *pb.Instrument, ev *events) {
+	lp, ok := b.lastPrices[uid(inst)]
+	now := b.now()
+	for _, sid := range append([]string(nil), b.stopIds...) {
+		so := b.stopOrders[sid]
+		if so.inst != inst {
+			continue
+		}
+		if exp := so.state.GetExpirationTime(); exp != nil && now.After(exp.AsTime()) {
+			b.removeStopOrder(sid)
+			continue
+		}
+		if !ok || !so.triggered(lp) {
+			continue
+		}
+		b.removeStopOrder(sid)
+		direction := pb.OrderDirection_ORDER_DIRECTION_BUY
+		if so.state.GetDirection() == pb.StopOrderDirection_STOP_ORDER_DIRECTION_SELL {
+			direction = pb.OrderDirection_ORDER_DIRECTION_SELL
+		}
+		orderType := pb.OrderType_ORDER_TYPE_MARKET
+		if so.state.GetOrderType() == pb.StopOrderType_STOP_ORDER_TYPE_STOP_LIMIT {
+			orderType = pb.OrderType_ORDER_TYPE_LIMIT
+		}
+		_, _ = b.placeOrder(uid(inst), "", direction, orderType, so.state.GetLotsRequested(), so.price, ev)
+	}
+}
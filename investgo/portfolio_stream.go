@@ -2,18 +2,24 @@ package investgo
 
 import (
 	"context"
+	"strings"
 
 	pb "github.com/tinkoff/invest-api-go-sdk/proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// portfolioStreamMethod - полное имя метода стрима для поля method в логах
+const portfolioStreamMethod = "/tinkoff.public.invest.api.contract.v1.OperationsStreamService/PortfolioStream"
+
 type PortfolioStream struct {
 	stream           pb.OperationsStreamService_PortfolioStreamClient
 	operationsClient *OperationsStreamClient
 
 	ctx    context.Context
 	cancel context.CancelFunc
+	// accounts - счета стрима для поля account_id в логах
+	accounts []string
 
 	portfolios chan *pb.PortfolioResponse
 }
@@ -35,7 +41,7 @@ func (p *PortfolioStream) Listen() error {
 			if err != nil {
 				switch {
 				case status.Code(err) == codes.Canceled:
-					p.operationsClient.logger.Infof("stop listening portfolios")
+					logInfo(p.operationsClient.logger, "stop listening stream", p.logFields()...)
 					return nil
 				default:
 					return err
@@ -45,7 +51,7 @@ func (p *PortfolioStream) Listen() error {
 				case *pb.PortfolioStreamResponse_Portfolio:
 					p.portfolios <- resp.GetPortfolio()
 				default:
-					p.operationsClient.logger.Infof("info from Portfolio stream %v", resp.String())
+					logDebug(p.operationsClient.logger, "info from stream", append(p.logFields(), "payload", resp.String())...)
 				}
 			}
 		}
@@ -53,14 +59,19 @@ func (p *PortfolioStream) Listen() error {
 }
 
 func (p *PortfolioStream) restart(_ context.Context, attempt uint, err error) {
-	p.operationsClient.logger.Infof("try to restart portfolio stream err = %v, attempt = %v", err.Error(), attempt)
+	logWarn(p.operationsClient.logger, "try to restart stream", append(p.logFields(), FieldAttempt, attempt, FieldError, err)...)
 }
 
 func (p *PortfolioStream) shutdown() {
-	p.operationsClient.logger.Infof("close portfolio stream")
+	logInfo(p.operationsClient.logger, "close stream", p.logFields()...)
 	close(p.portfolios)
 }
 
+// logFields - поля сообщений логгера для стрима
+func (p *PortfolioStream) logFields() []any {
+	return []any{FieldMethod, portfolioStreamMethod, FieldAccountId, strings.Join(p.accounts, ",")}
+}
+
 // Stop - Завершение работы стрима
 func (p *PortfolioStream) Stop() {
 	p.cancel()
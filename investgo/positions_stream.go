@@ -2,18 +2,24 @@ package investgo
 
 import (
 	"context"
+	"strings"
 
 	pb "github.com/tinkoff/invest-api-go-sdk/proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// positionsStreamMethod - полное имя метода стрима для поля method в логах
+const positionsStreamMethod = "/tinkoff.public.invest.api.contract.v1.OperationsStreamService/PositionsStream"
+
 type PositionsStream struct {
 	stream           pb.OperationsStreamService_PositionsStreamClient
 	operationsClient *OperationsStreamClient
 
 	ctx    context.Context
 	cancel context.CancelFunc
+	// accounts - счета стрима для поля account_id в логах
+	accounts []string
 
 	positions chan *pb.PositionData
 }
@@ -35,7 +41,7 @@ func (p *PositionsStream) Listen() error {
 			if err != nil {
 				switch {
 				case status.Code(err) == codes.Canceled:
-					p.operationsClient.logger.Infof("stop listening positions")
+					logInfo(p.operationsClient.logger, "stop listening stream", p.logFields()...)
 					return nil
 				default:
 					return err
@@ -45,7 +51,7 @@ func (p *PositionsStream) Listen() error {
 				case *pb.PositionsStreamResponse_Position:
 					p.positions <- resp.GetPosition()
 				default:
-					p.operationsClient.logger.Infof("info from Positions stream %v", resp.String())
+					logDebug(p.operationsClient.logger, "info from stream", append(p.logFields(), "payload", resp.String())...)
 				}
 			}
 		}
@@ -53,14 +59,19 @@ func (p *PositionsStream) Listen() error {
 }
 
 func (p *PositionsStream) restart(_ context.Context, attempt uint, err error) {
-	p.operationsClient.logger.Infof("try to restart positions stream err = %v, attempt = %v", err.Error(), attempt)
+	logWarn(p.operationsClient.logger, "try to restart stream", append(p.logFields(), FieldAttempt, attempt, FieldError, err)...)
 }
 
 func (p *PositionsStream) shutdown() {
-	p.operationsClient.logger.Infof("close positions stream")
+	logInfo(p.operationsClient.logger, "close stream", p.logFields()...)
 	close(p.positions)
 }
 
+// logFields - поля сообщений логгера для стрима
+func (p *PositionsStream) logFields() []any {
+	return []any{FieldMethod, positionsStreamMethod, FieldAccountId, strings.Join(p.accounts, ",")}
+}
+
 // Stop - Завершение работы стрима
 func (p *PositionsStream) Stop() {
 	p.cancel()
@@ -0,0 +1,161 @@
+package investgo
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// rateLimiter - клиентское ограничение частоты unary-запросов по лимитам тарифа пользователя.
+// Для каждой группы методов из GetUserTariff заводится token bucket, который пополняется
+// равномерно в течение минуты. Если токенов нет, запрос ждет их появления, а не получает ResourceExhausted
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	// limit - лимит запросов в минуту, он же емкость бакета
+	limit float64
+	// tokens - может быть отрицательным, если запросы уже зарезервировали будущие токены
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket, 0),
+	}
+}
+
+// load - заполнение бакетов по лимитам тарифа, методы одной группы делят один бакет
+func (rl *rateLimiter) load(tariff *pb.GetUserTariffResponse) {
+	now := time.Now()
+	buckets := make(map[string]*tokenBucket, 0)
+	for _, limit := range tariff.GetUnaryLimits() {
+		if limit.GetLimitPerMinute() <= 0 {
+			continue
+		}
+		b := &tokenBucket{
+			limit:   float64(limit.GetLimitPerMinute()),
+			tokens:  float64(limit.GetLimitPerMinute()),
+			updated: now,
+		}
+		for _, method := range limit.GetMethods() {
+			buckets[strings.TrimPrefix(method, "/")] = b
+		}
+	}
+	rl.mu.Lock()
+	rl.buckets = buckets
+	rl.mu.Unlock()
+}
+
+// rate - количество токенов в секунду
+func (b *tokenBucket) rate() float64 {
+	return b.limit / time.Minute.Seconds()
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.updated).Seconds() * b.rate()
+	if b.tokens > b.limit {
+		b.tokens = b.limit
+	}
+	b.updated = now
+}
+
+// wait - резервирует токен для метода и ждет, пока он станет доступен
+func (rl *rateLimiter) wait(ctx context.Context, method string) error {
+	rl.mu.Lock()
+	b, ok := rl.buckets[strings.TrimPrefix(method, "/")]
+	if !ok {
+		rl.mu.Unlock()
+		return nil
+	}
+	b.refill(time.Now())
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate() * float64(time.Second))
+	}
+	rl.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// токен не использован, возвращаем его
+		rl.mu.Lock()
+		b.tokens++
+		rl.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// observe - корректировка бакета по остатку запросов, который вернул сервер. Лимит общий для всех
+// клиентов с этим токеном, поэтому серверный остаток может быть меньше локального
+func (rl *rateLimiter) observe(method string, md metadata.MD) {
+	remaining := RemainingLimitFromHeader(md)
+	if remaining < 0 {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b, ok := rl.buckets[strings.TrimPrefix(method, "/")]
+	if !ok {
+		return
+	}
+	b.refill(time.Now())
+	if remaining > 0 {
+		if float64(remaining) < b.tokens {
+			b.tokens = float64(remaining)
+		}
+		return
+	}
+	// лимит исчерпан, следующий токен появится не раньше сброса лимита
+	reset := resetFromHeader(md)
+	if tokens := 1 - reset.Seconds()*b.rate(); tokens < b.tokens {
+		b.tokens = tokens
+	}
+}
+
+// resetFromHeader - время до сброса лимита запросов из заголовка x-ratelimit-reset
+func resetFromHeader(md metadata.MD) time.Duration {
+	values := md.Get("x-ratelimit-reset")
+	if len(values) < 1 {
+		return 0
+	}
+	sec, err := strconv.Atoi(values[0])
+	if err != nil {
+		return 0
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// unaryInterceptor - ожидание токена перед запросом и корректировка бакета по заголовкам ответа
+func (rl *rateLimiter) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := rl.wait(ctx, method); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		var header, trailer metadata.MD
+		opts = append(opts, grpc.Header(&header), grpc.Trailer(&trailer))
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err != nil {
+			header = trailer
+		}
+		rl.observe(method, header)
+		return err
+	}
+}
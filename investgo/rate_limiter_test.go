@@ -0,0 +1,108 @@
+package investgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// newTestLimiter - Лимитер с общим лимитом 60 запросов в минуту на методы svc/A и svc/B
+func newTestLimiter() *rateLimiter {
+	rl := newRateLimiter()
+	rl.load(&pb.GetUserTariffResponse{UnaryLimits: []*pb.UnaryLimit{
+		{LimitPerMinute: 60, Methods: []string{"svc/A", "svc/B"}},
+	}})
+	return rl
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	start := time.Now()
+	b := &tokenBucket{limit: 60, tokens: 0, updated: start}
+
+	b.refill(start.Add(500 * time.Millisecond))
+	if b.tokens != 0.5 {
+		t.Fatalf("tokens = %v, want 0.5 after half a second", b.tokens)
+	}
+	// бакет не наполняется больше лимита
+	b.refill(start.Add(2 * time.Minute))
+	if b.tokens != 60 {
+		t.Fatalf("tokens = %v, want 60", b.tokens)
+	}
+}
+
+func TestRateLimiterSharedBucket(t *testing.T) {
+	rl := newTestLimiter()
+	if rl.buckets["svc/A"] != rl.buckets["svc/B"] {
+		t.Fatal("methods of one limit must share a bucket")
+	}
+	for i := 0; i < 30; i++ {
+		if err := rl.wait(context.Background(), "/svc/A"); err != nil {
+			t.Fatal(err)
+		}
+		if err := rl.wait(context.Background(), "/svc/B"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tokens := rl.buckets["svc/A"].tokens; tokens > 1 {
+		t.Fatalf("tokens = %v, want bucket drained by both methods", tokens)
+	}
+	// метод без лимита не ждет
+	if err := rl.wait(context.Background(), "/svc/other"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimiterWaitContext(t *testing.T) {
+	rl := newTestLimiter()
+	b := rl.buckets["svc/A"]
+	b.tokens = -10
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := rl.wait(ctx, "/svc/A"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("wait took %v, want return on context deadline", elapsed)
+	}
+	// неиспользованный токен возвращается
+	if b.tokens < -10 {
+		t.Fatalf("tokens = %v, want the reserved token returned", b.tokens)
+	}
+}
+
+func TestRateLimiterObserve(t *testing.T) {
+	rl := newTestLimiter()
+	b := rl.buckets["svc/A"]
+
+	// без заголовков бакет не меняется
+	rl.observe("/svc/A", metadata.MD{})
+	if b.tokens != 60 {
+		t.Fatalf("tokens = %v, want 60", b.tokens)
+	}
+	// серверный остаток меньше локального
+	rl.observe("/svc/A", metadata.Pairs("x-ratelimit-remaining", "5", "x-ratelimit-reset", "30"))
+	if b.tokens < 5 || b.tokens > 5.1 {
+		t.Fatalf("tokens = %v, want 5 from header", b.tokens)
+	}
+	// больший серверный остаток не добавляет токенов
+	rl.observe("/svc/A", metadata.Pairs("x-ratelimit-remaining", "50", "x-ratelimit-reset", "30"))
+	if b.tokens > 5.1 {
+		t.Fatalf("tokens = %v, want at most 5", b.tokens)
+	}
+	// лимит исчерпан: следующий токен через 2 секунды до сброса
+	rl.observe("/svc/A", metadata.Pairs("x-ratelimit-remaining", "0", "x-ratelimit-reset", "2"))
+	if b.tokens < -1 || b.tokens > -0.9 {
+		t.Fatalf("tokens = %v, want -1", b.tokens)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := rl.wait(ctx, "/svc/A"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want wait until reset", err)
+	}
+}
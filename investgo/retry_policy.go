@@ -0,0 +1,270 @@
+package investgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/retry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryCategory - Категория методов для политики ретраев
+type RetryCategory string
+
+const (
+	// RetryCategoryRead - Unary-методы, которые не меняют состояние счета: получение инструментов, котировок,
+	// портфеля, статусов заявок и т.д. К ним же относятся методы, которых нет в других категориях
+	RetryCategoryRead RetryCategory = "read"
+	// RetryCategoryOrder - Unary-методы, которые меняют состояние счета: выставление, изменение и отмена заявок
+	// и стоп-заявок, операции со счетами песочницы
+	RetryCategoryOrder RetryCategory = "order"
+	// RetryCategoryStream - Открытие и переоткрытие стримов
+	RetryCategoryStream RetryCategory = "stream"
+)
+
+const (
+	// DefaultInitialBackoff - Ожидание перед первым повтором по умолчанию
+	DefaultInitialBackoff = WAIT_BETWEEN
+	// DefaultMaxBackoff - Максимальное ожидание между повторами по умолчанию
+	DefaultMaxBackoff = 5 * time.Second
+	// DefaultBackoffJitter - Случайное отклонение ожидания по умолчанию, в долях
+	DefaultBackoffJitter = 0.2
+)
+
+// orderMethods - методы категории RetryCategoryOrder
+var orderMethods = map[string]struct{}{
+	"/tinkoff.public.invest.api.contract.v1.OrdersService/PostOrder":            {},
+	"/tinkoff.public.invest.api.contract.v1.OrdersService/ReplaceOrder":         {},
+	"/tinkoff.public.invest.api.contract.v1.OrdersService/CancelOrder":          {},
+	"/tinkoff.public.invest.api.contract.v1.StopOrdersService/PostStopOrder":    {},
+	"/tinkoff.public.invest.api.contract.v1.StopOrdersService/CancelStopOrder":  {},
+	"/tinkoff.public.invest.api.contract.v1.SandboxService/PostSandboxOrder":    {},
+	"/tinkoff.public.invest.api.contract.v1.SandboxService/ReplaceSandboxOrder": {},
+	"/tinkoff.public.invest.api.contract.v1.SandboxService/CancelSandboxOrder":  {},
+	"/tinkoff.public.invest.api.contract.v1.SandboxService/SandboxPayIn":        {},
+	"/tinkoff.public.invest.api.contract.v1.SandboxService/OpenSandboxAccount":  {},
+	"/tinkoff.public.invest.api.contract.v1.SandboxService/CloseSandboxAccount": {},
+}
+
+// RetryPolicy - Политика ретраев метода или категории методов. Длительности в yaml и toml задаются строкой,
+// например "500ms", в json - числом наносекунд
+type RetryPolicy struct {
+	// Codes - Коды grpc, при которых запрос повторяется, например ["Unavailable", "Internal"]
+	Codes []string `yaml:"Codes" json:"Codes" toml:"Codes"`
+	// MaxAttempts - Максимальное количество попыток вместе с первой, 1 - без повторов, 0 - Config.MaxRetries
+	MaxAttempts uint `yaml:"MaxAttempts" json:"MaxAttempts" toml:"MaxAttempts"`
+	// InitialBackoff - Ожидание перед первым повтором, дальше ожидание удваивается. 0 - DefaultInitialBackoff
+	InitialBackoff time.Duration `yaml:"InitialBackoff" json:"InitialBackoff" toml:"InitialBackoff"`
+	// MaxBackoff - Максимальное ожидание между повторами. 0 - DefaultMaxBackoff
+	MaxBackoff time.Duration `yaml:"MaxBackoff" json:"MaxBackoff" toml:"MaxBackoff"`
+	// Jitter - Случайное отклонение ожидания в долях от 0 до 1, например 0.2 - ожидание меняется на ±20%
+	Jitter float64 `yaml:"Jitter" json:"Jitter" toml:"Jitter"`
+}
+
+// DefaultRetryPolicies - Политики ретраев по умолчанию. Запросы на чтение и стримы повторяются при Unavailable
+// и Internal. Заявки с ключом идемпотентности повторяются только при Unavailable, отмена заявок - при Unavailable
+// и Internal, остальные методы категории order, например PostStopOrder и SandboxPayIn, не повторяются,
+// так как повтор может выполнить операцию второй раз
+func DefaultRetryPolicies() map[string]RetryPolicy {
+	safe := RetryPolicy{
+		Codes:  []string{codes.Unavailable.String(), codes.Internal.String()},
+		Jitter: DefaultBackoffJitter,
+	}
+	idempotent := RetryPolicy{
+		Codes:  []string{codes.Unavailable.String()},
+		Jitter: DefaultBackoffJitter,
+	}
+	once := RetryPolicy{
+		MaxAttempts: 1,
+	}
+	return map[string]RetryPolicy{
+		string(RetryCategoryRead):   safe,
+		string(RetryCategoryStream): safe,
+		string(RetryCategoryOrder):  once,
+
+		"/tinkoff.public.invest.api.contract.v1.OrdersService/PostOrder":            idempotent,
+		"/tinkoff.public.invest.api.contract.v1.OrdersService/ReplaceOrder":         idempotent,
+		"/tinkoff.public.invest.api.contract.v1.SandboxService/PostSandboxOrder":    idempotent,
+		"/tinkoff.public.invest.api.contract.v1.SandboxService/ReplaceSandboxOrder": idempotent,
+		"/tinkoff.public.invest.api.contract.v1.OrdersService/CancelOrder":          safe,
+		"/tinkoff.public.invest.api.contract.v1.SandboxService/CancelSandboxOrder":  safe,
+		"/tinkoff.public.invest.api.contract.v1.StopOrdersService/CancelStopOrder":  safe,
+	}
+}
+
+// MethodCategory - Категория unary-метода по полному имени grpc
+func MethodCategory(method string) RetryCategory {
+	if _, ok := orderMethods[method]; ok {
+		return RetryCategoryOrder
+	}
+	return RetryCategoryRead
+}
+
+// RetryPolicy - Политика ретраев метода: политика из RetryPolicies по полному имени метода, затем по категории,
+// затем политика по умолчанию из DefaultRetryPolicies по тем же правилам
+func (c Config) RetryPolicy(method string, category RetryCategory) RetryPolicy {
+	defaults := DefaultRetryPolicies()
+	for _, policies := range []map[string]RetryPolicy{c.RetryPolicies, defaults} {
+		if p, ok := policies[method]; ok {
+			return p
+		}
+		if p, ok := policies[string(category)]; ok {
+			return p
+		}
+	}
+	return defaults[string(RetryCategoryRead)]
+}
+
+// validateRetryPolicies - проверка ключей и значений RetryPolicies
+func (c Config) validateRetryPolicies() []error {
+	var errs []error
+	for key, p := range c.RetryPolicies {
+		switch RetryCategory(key) {
+		case RetryCategoryRead, RetryCategoryOrder, RetryCategoryStream:
+		default:
+			if !strings.HasPrefix(key, "/") {
+				errs = append(errs, fmt.Errorf("%w: retry policy %q: key must be a category or a full grpc method name", ErrInvalidConfig, key))
+			}
+		}
+		for _, name := range p.Codes {
+			if _, err := parseCode(name); err != nil {
+				errs = append(errs, fmt.Errorf("%w: retry policy %q: %v", ErrInvalidConfig, key, err))
+			}
+		}
+		if p.MaxAttempts > MaxRetriesLimit {
+			errs = append(errs, fmt.Errorf("%w: retry policy %q: MaxAttempts = %v, must be at most %v", ErrInvalidConfig, key, p.MaxAttempts, MaxRetriesLimit))
+		}
+		if p.Jitter < 0 || p.Jitter > 1 {
+			errs = append(errs, fmt.Errorf("%w: retry policy %q: Jitter = %v, must be between 0 and 1", ErrInvalidConfig, key, p.Jitter))
+		}
+		if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+			errs = append(errs, fmt.Errorf("%w: retry policy %q: negative backoff", ErrInvalidConfig, key))
+		}
+	}
+	return errs
+}
+
+// callOptions - опции ретраера для политики, maxRetries - значение MaxAttempts по умолчанию
+func (p RetryPolicy) callOptions(maxRetries uint) []retry.CallOption {
+	return []retry.CallOption{
+		retry.WithCodes(p.codes()...),
+		retry.WithMax(p.maxAttempts(maxRetries)),
+		retry.WithBackoff(p.backoff()),
+	}
+}
+
+// codes - коды grpc политики, неизвестные имена пропускаются
+func (p RetryPolicy) codes() []codes.Code {
+	retryCodes := make([]codes.Code, 0, len(p.Codes))
+	for _, name := range p.Codes {
+		if code, err := parseCode(name); err == nil {
+			retryCodes = append(retryCodes, code)
+		}
+	}
+	return retryCodes
+}
+
+// retryable - повторяется ли запрос после ошибки err
+func (p RetryPolicy) retryable(err error) bool {
+	code := status.Code(err)
+	for _, c := range p.codes() {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// maxAttempts - количество попыток вместе с первой, maxRetries - значение по умолчанию
+func (p RetryPolicy) maxAttempts(maxRetries uint) uint {
+	if p.MaxAttempts == 0 {
+		return maxRetries
+	}
+	return p.MaxAttempts
+}
+
+// backoff - ожидание перед повтором, растет экспоненциально от InitialBackoff до MaxBackoff со случайным отклонением
+func (p RetryPolicy) backoff() retry.BackoffFunc {
+	initial := p.InitialBackoff
+	if initial == 0 {
+		initial = DefaultInitialBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	return retry.BackoffExponentialWithJitterBounded(initial, p.Jitter, maxBackoff)
+}
+
+// parseCode - код grpc по имени, например "Unavailable", "unavailable" или "UNAVAILABLE"
+func parseCode(name string) (codes.Code, error) {
+	normalized := strings.ReplaceAll(name, "_", "")
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.EqualFold(normalized, c.String()) {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown grpc code %q", name)
+}
+
+// retryPolicyUnaryInterceptor - опции ретраера по политике метода. Стоит перед ретраером, опции вызова,
+// переданные в метод явно, например retry.Disable(), применяются после политики
+func retryPolicyUnaryInterceptor(conf Config) grpc.UnaryClientInterceptor {
+	methods := make(map[string][]grpc.CallOption)
+	for method := range orderMethods {
+		methods[method] = policyCallOptions(conf, method, RetryCategoryOrder)
+	}
+	for method := range conf.RetryPolicies {
+		if strings.HasPrefix(method, "/") {
+			methods[method] = policyCallOptions(conf, method, MethodCategory(method))
+		}
+	}
+	read := policyCallOptions(conf, "", RetryCategoryRead)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		policyOpts, ok := methods[method]
+		if !ok {
+			policyOpts = read
+		}
+		return invoker(ctx, method, req, reply, cc, withPolicy(policyOpts, opts)...)
+	}
+}
+
+// retryPolicyStreamInterceptor - опции ретраера стримов по политике метода или категории stream
+func retryPolicyStreamInterceptor(conf Config) grpc.StreamClientInterceptor {
+	methods := make(map[string][]grpc.CallOption)
+	for method := range conf.RetryPolicies {
+		if strings.HasPrefix(method, "/") {
+			methods[method] = policyCallOptions(conf, method, RetryCategoryStream)
+		}
+	}
+	stream := policyCallOptions(conf, "", RetryCategoryStream)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		policyOpts, ok := methods[method]
+		if !ok {
+			policyOpts = stream
+		}
+		return streamer(ctx, desc, cc, method, withPolicy(policyOpts, opts)...)
+	}
+}
+
+// withPolicy - опции политики перед опциями вызова, чтобы опции вызова имели приоритет
+func withPolicy(policyOpts, opts []grpc.CallOption) []grpc.CallOption {
+	return append(append(make([]grpc.CallOption, 0, len(policyOpts)+len(opts)), policyOpts...), opts...)
+}
+
+// policyCallOptions - опции вызова для политики метода
+func policyCallOptions(conf Config, method string, category RetryCategory) []grpc.CallOption {
+	retryOpts := conf.RetryPolicy(method, category).callOptions(conf.MaxRetries)
+	if conf.DisableAllRetry {
+		retryOpts = append(retryOpts, retry.Disable())
+	}
+	opts := make([]grpc.CallOption, 0, len(retryOpts))
+	for _, opt := range retryOpts {
+		opts = append(opts, opt)
+	}
+	return opts
+}
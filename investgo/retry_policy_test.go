@@ -0,0 +1,98 @@
+package investgo_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const postOrderFullMethod = "/tinkoff.public.invest.api.contract.v1.OrdersService/PostOrder"
+
+func TestRetryPolicyMatching(t *testing.T) {
+	method := investgo.RetryPolicy{MaxAttempts: 5}
+	category := investgo.RetryPolicy{MaxAttempts: 2}
+	conf := investgo.Config{RetryPolicies: map[string]investgo.RetryPolicy{
+		postOrderFullMethod: method,
+		"read":              category,
+	}}
+	defaults := investgo.DefaultRetryPolicies()
+
+	tests := []struct {
+		name     string
+		method   string
+		category investgo.RetryCategory
+		want     uint
+	}{
+		{"method from config", postOrderFullMethod, investgo.RetryCategoryOrder, 5},
+		{"category from config", "/tinkoff.public.invest.api.contract.v1.UsersService/GetInfo", investgo.RetryCategoryRead, 2},
+		{"default method", "/tinkoff.public.invest.api.contract.v1.OrdersService/CancelOrder", investgo.RetryCategoryOrder,
+			defaults["/tinkoff.public.invest.api.contract.v1.OrdersService/CancelOrder"].MaxAttempts},
+		{"default category", "/tinkoff.public.invest.api.contract.v1.StopOrdersService/PostStopOrder", investgo.RetryCategoryOrder, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conf.RetryPolicy(tt.method, tt.category).MaxAttempts; got != tt.want {
+				t.Fatalf("MaxAttempts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+	if got := investgo.MethodCategory(postOrderFullMethod); got != investgo.RetryCategoryOrder {
+		t.Fatalf("category = %v, want order", got)
+	}
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	conf := investgo.DefaultConfig()
+	conf.Token = "token"
+	conf.EndPoint = investgo.SandboxEndPoint
+	conf.RetryPolicies = map[string]investgo.RetryPolicy{
+		"unknown": {},
+		"read":    {Codes: []string{"NotACode"}, Jitter: 2, InitialBackoff: -time.Second},
+	}
+	err := conf.Validate()
+	if !errors.Is(err, investgo.ErrInvalidConfig) {
+		t.Fatalf("err = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestUnaryRetryPolicy(t *testing.T) {
+	srv := newTestServer(t)
+	conf := srv.Config()
+	conf.RetryPolicies = map[string]investgo.RetryPolicy{
+		"read": {Codes: []string{"Internal"}, MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	}
+	users := newTestClient(t, conf).NewUsersServiceClient()
+
+	// код из политики повторяется
+	srv.InjectError("UsersService/GetInfo", status.Error(codes.Internal, "internal"))
+	srv.InjectError("UsersService/GetInfo", status.Error(codes.Internal, "internal"))
+	if _, err := users.GetInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if calls := srv.Calls("UsersService/GetInfo"); calls != 3 {
+		t.Fatalf("calls = %d, want 3", calls)
+	}
+
+	// код вне политики не повторяется
+	srv.InjectError("UsersService/GetInfo", status.Error(codes.Unavailable, "unavailable"))
+	if _, err := users.GetInfo(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+	if calls := srv.Calls("UsersService/GetInfo"); calls != 4 {
+		t.Fatalf("calls = %d, want 4", calls)
+	}
+
+	// заявки без ключа идемпотентности по умолчанию не повторяются
+	srv.InjectError("StopOrdersService/PostStopOrder", status.Error(codes.Unavailable, "unavailable"))
+	_, err := newTestClient(t, srv.Config()).NewStopOrdersServiceClient().PostStopOrder(&investgo.PostStopOrderRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+	if calls := srv.Calls("StopOrdersService/PostStopOrder"); calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+}
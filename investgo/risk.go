@@ -0,0 +1,596 @@
+package investgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc"
+)
+
+// Категории отказов предторговых проверок для проверки через errors.Is(err, investgo.ErrMaxOrderValue).
+// Все отказы также проверяются через errors.Is(err, investgo.ErrRiskRejected)
+var (
+	// ErrRiskRejected - Заявка отклонена предторговыми проверками
+	ErrRiskRejected = errors.New("order rejected by risk checks")
+	// ErrKillSwitch - Включен аварийный выключатель, новые заявки запрещены
+	ErrKillSwitch = errors.New("kill switch is engaged")
+	// ErrInstrumentNotAllowed - Инструмента нет в списке разрешенных
+	ErrInstrumentNotAllowed = errors.New("instrument is not allowed")
+	// ErrMaxOrderValue - Превышена максимальная стоимость заявки
+	ErrMaxOrderValue = errors.New("max order value exceeded")
+	// ErrMaxPosition - Превышена максимальная позиция по инструменту
+	ErrMaxPosition = errors.New("max position exceeded")
+	// ErrMaxDailyLoss - Достигнут максимальный убыток за день
+	ErrMaxDailyLoss = errors.New("max daily loss reached")
+	// ErrPriceCollar - Цена заявки вне допустимого коридора
+	ErrPriceCollar = errors.New("price is out of collar")
+	// ErrInsufficientMargin - Недостаточно маржи
+	ErrInsufficientMargin = errors.New("insufficient margin")
+)
+
+// RiskError - Отказ предторговой проверки. Заявка не отправляется на сервер
+type RiskError struct {
+	// Check - Категория отказа, например ErrMaxOrderValue
+	Check        error
+	AccountId    string
+	InstrumentId string
+	// Message - Подробности отказа
+	Message string
+}
+
+// Error - Текст ошибки с категорией и подробностями
+func (e *RiskError) Error() string {
+	msg := fmt.Sprintf("investgo: risk: %v", e.Check)
+	if e.InstrumentId != "" {
+		msg += fmt.Sprintf(", instrument = %v", e.InstrumentId)
+	}
+	if e.Message != "" {
+		msg += fmt.Sprintf(", %v", e.Message)
+	}
+	return msg
+}
+
+// Unwrap - Категория отказа и ErrRiskRejected для errors.Is
+func (e *RiskError) Unwrap() []error {
+	return []error{e.Check, ErrRiskRejected}
+}
+
+// RiskLimits - Лимиты предторговых проверок. Нулевые значения выключают проверку
+type RiskLimits struct {
+	// MaxOrderValue - Максимальная стоимость заявки в валюте инструмента: цена * количество лотов * лотность.
+	// Для рыночных заявок берется последняя цена
+	MaxOrderValue float64 `yaml:"MaxOrderValue" json:"MaxOrderValue" toml:"MaxOrderValue"`
+	// MaxPosition - Максимальная позиция по инструменту в лотах по модулю после исполнения заявки.
+	// Активные заявки не учитываются, заявки на уменьшение позиции разрешены всегда
+	MaxPosition int64 `yaml:"MaxPosition" json:"MaxPosition" toml:"MaxPosition"`
+	// MaxPositions - Максимальные позиции в лотах по figi, instrument_uid или тикеру, имеют приоритет над MaxPosition
+	MaxPositions map[string]int64 `yaml:"MaxPositions" json:"MaxPositions" toml:"MaxPositions"`
+	// MaxDailyLoss - Максимальный убыток за день по московскому времени в рублях. Убыток считается от цен закрытия
+	// прошлой сессии из GetClosePrices с учетом сделок, комиссий и других операций за день или от стоимости портфеля,
+	// заданной через RiskManager.SetDailyBaseline. Пополнения и выводы средств не считаются, позиции и операции
+	// не в рублях не учитываются. После достижения лимита разрешены только заявки на уменьшение позиции
+	MaxDailyLoss float64 `yaml:"MaxDailyLoss" json:"MaxDailyLoss" toml:"MaxDailyLoss"`
+	// PriceCollar - Максимальное отклонение цены лимитной заявки от последней цены в долях, например 0.05 - ±5%.
+	// Цена активации стоп-заявок не проверяется
+	PriceCollar float64 `yaml:"PriceCollar" json:"PriceCollar" toml:"PriceCollar"`
+	// CheckPriceLimits - Проверять, что цена заявки и стоп-заявки внутри limit_down и limit_up из стакана
+	CheckPriceLimits bool `yaml:"CheckPriceLimits" json:"CheckPriceLimits" toml:"CheckPriceLimits"`
+	// AllowedInstruments - figi, instrument_uid или тикеры разрешенных инструментов, если пустой - все
+	AllowedInstruments []string `yaml:"AllowedInstruments" json:"AllowedInstruments" toml:"AllowedInstruments"`
+	// CheckMargin - Проверять через GetMarginAttributes, что на счете нет недостатка средств, для заявок
+	// на увеличение позиции. Только для маржинальных счетов
+	CheckMargin bool `yaml:"CheckMargin" json:"CheckMargin" toml:"CheckMargin"`
+}
+
+// active - включена ли хотя бы одна проверка, кроме аварийного выключателя
+func (l RiskLimits) active() bool {
+	return l.MaxOrderValue > 0 || l.MaxPosition > 0 || len(l.MaxPositions) > 0 || l.MaxDailyLoss > 0 ||
+		l.PriceCollar > 0 || l.CheckPriceLimits || len(l.AllowedInstruments) > 0 || l.CheckMargin
+}
+
+// validate - проверка значений лимитов
+func (l RiskLimits) validate() []error {
+	var errs []error
+	if l.MaxOrderValue < 0 || l.MaxPosition < 0 || l.MaxDailyLoss < 0 || l.PriceCollar < 0 {
+		errs = append(errs, fmt.Errorf("%w: risk limits must not be negative", ErrInvalidConfig))
+	}
+	for id, limit := range l.MaxPositions {
+		if limit < 0 {
+			errs = append(errs, fmt.Errorf("%w: risk limits: MaxPositions[%q] must not be negative", ErrInvalidConfig, id))
+		}
+	}
+	return errs
+}
+
+// RiskOrder - Заявка для предторговой проверки
+type RiskOrder struct {
+	AccountId    string
+	InstrumentId string
+	Direction    pb.OrderDirection
+	// Quantity - Количество лотов
+	Quantity int64
+	// Price - Цена заявки, nil или 0 - рыночная заявка
+	Price *pb.Quotation
+	// Stop - Стоп-заявка, ее цена проверяется только по limit_down и limit_up
+	Stop bool
+}
+
+// RiskMarketData - Рыночные данные для предторговых проверок. Ее реализует MarketDataServiceClient
+type RiskMarketData interface {
+	GetLastPricesWithContext(ctx context.Context, instrumentIds []string) (*GetLastPricesResponse, error)
+	GetOrderBookWithContext(ctx context.Context, instrumentId string, depth int32) (*GetOrderBookResponse, error)
+	GetClosePricesWithContext(ctx context.Context, instrumentIds []string) (*GetClosePricesResponse, error)
+}
+
+// MarginSource - Маржинальные показатели счета. Ее реализует UsersServiceClient
+type MarginSource interface {
+	GetMarginAttributesWithContext(ctx context.Context, accountId string) (*GetMarginAttributesResponse, error)
+}
+
+// RiskManager - Предторговые проверки заявок по лимитам RiskLimits и аварийный выключатель. Клиент проверяет
+// каждый вызов PostOrder, ReplaceOrder и PostStopOrder, в том числе в песочнице, через Client.Risk.
+// Если данные для проверки получить не удалось, заявка не отправляется и возвращается ошибка запроса данных
+type RiskManager struct {
+	limits      RiskLimits
+	trading     Trading
+	instruments InstrumentSource
+	marketData  RiskMarketData
+	margin      MarginSource
+	logger      Logger
+
+	mu         sync.Mutex
+	killed     bool
+	killReason string
+	// baselines - стоимость портфеля на начало дня по счетам из SetDailyBaseline
+	baselines map[string]dailyBaseline
+}
+
+type dailyBaseline struct {
+	day   string
+	value decimal.Decimal
+}
+
+// moscow - часовой пояс торгового дня для MaxDailyLoss
+var moscow = time.FixedZone("MSK", 3*60*60)
+
+// NewRiskManager - Предторговые проверки с данными из trading, instruments, marketData и margin.
+// margin нужен только при CheckMargin
+func NewRiskManager(limits RiskLimits, trading Trading, instruments InstrumentSource, marketData RiskMarketData, margin MarginSource) *RiskManager {
+	return &RiskManager{
+		limits:      limits,
+		trading:     trading,
+		instruments: instruments,
+		marketData:  marketData,
+		margin:      margin,
+		baselines:   make(map[string]dailyBaseline),
+	}
+}
+
+// KillSwitch - Включение аварийного выключателя: все новые заявки, изменения заявок и стоп-заявки отклоняются
+// с ErrKillSwitch, отмена заявок разрешена
+func (rm *RiskManager) KillSwitch(reason string) {
+	rm.mu.Lock()
+	rm.killed, rm.killReason = true, reason
+	rm.mu.Unlock()
+	if rm.logger != nil {
+		logWarn(rm.logger, "kill switch engaged", FieldError, reason)
+	}
+}
+
+// ResetKillSwitch - Выключение аварийного выключателя
+func (rm *RiskManager) ResetKillSwitch() {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.killed, rm.killReason = false, ""
+}
+
+// Killed - Включен ли аварийный выключатель и причина
+func (rm *RiskManager) Killed() (bool, string) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return rm.killed, rm.killReason
+}
+
+// Limits - Текущие лимиты
+func (rm *RiskManager) Limits() RiskLimits {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return rm.limits
+}
+
+// SetLimits - Замена лимитов без пересоздания клиента
+func (rm *RiskManager) SetLimits(limits RiskLimits) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.limits = limits
+}
+
+// SetDailyBaseline - Стоимость портфеля счета в рублях на начало текущего дня по московскому времени для MaxDailyLoss.
+// Действует до конца дня, затем убыток снова считается от цен закрытия прошлой сессии
+func (rm *RiskManager) SetDailyBaseline(accountId string, value float64) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.baselines[accountId] = dailyBaseline{
+		day:   time.Now().In(moscow).Format(time.DateOnly),
+		value: decimal.NewFromFloat(value),
+	}
+}
+
+// Check - Предторговая проверка заявки. Возвращает *RiskError при отказе
+func (rm *RiskManager) Check(ctx context.Context, o RiskOrder) error {
+	if killed, reason := rm.Killed(); killed {
+		return rm.reject(ErrKillSwitch, o, reason)
+	}
+	limits := rm.Limits()
+	if !limits.active() {
+		return nil
+	}
+
+	inst, err := rm.instruments.InstrumentWithContext(ctx, o.InstrumentId)
+	if err != nil {
+		return fmt.Errorf("risk: instrument %s: %w", o.InstrumentId, err)
+	}
+	if len(limits.AllowedInstruments) > 0 && !matchInstrument(limits.AllowedInstruments, inst) {
+		return rm.reject(ErrInstrumentNotAllowed, o, "")
+	}
+
+	price := o.Price
+	if price.ToDecimal().IsZero() {
+		price = nil
+	}
+	var last *pb.Quotation
+	if price == nil && limits.MaxOrderValue > 0 || price != nil && !o.Stop && limits.PriceCollar > 0 {
+		resp, err := rm.marketData.GetLastPricesWithContext(ctx, []string{inst.GetUid()})
+		if err != nil {
+			return fmt.Errorf("risk: last price %s: %w", o.InstrumentId, err)
+		}
+		for _, lp := range resp.GetLastPrices() {
+			if !lp.GetPrice().ToDecimal().IsZero() {
+				last = lp.GetPrice()
+			}
+		}
+	}
+	if price != nil && !o.Stop && limits.PriceCollar > 0 && last != nil {
+		deviation := price.ToDecimal().Sub(last.ToDecimal()).Abs().Div(last.ToDecimal())
+		if deviation.GreaterThan(decimal.NewFromFloat(limits.PriceCollar)) {
+			return rm.reject(ErrPriceCollar, o, fmt.Sprintf("price %v, last price %v", price.ToDecimal(), last.ToDecimal()))
+		}
+	}
+	if price != nil && limits.CheckPriceLimits {
+		resp, err := rm.marketData.GetOrderBookWithContext(ctx, inst.GetUid(), 1)
+		if err != nil {
+			return fmt.Errorf("risk: order book %s: %w", o.InstrumentId, err)
+		}
+		down, up := resp.GetLimitDown().ToDecimal(), resp.GetLimitUp().ToDecimal()
+		p := price.ToDecimal()
+		if !down.IsZero() && p.LessThan(down) || !up.IsZero() && p.GreaterThan(up) {
+			return rm.reject(ErrPriceCollar, o, fmt.Sprintf("price %v, limits %v - %v", p, down, up))
+		}
+	}
+	if limits.MaxOrderValue > 0 {
+		p := price
+		if p == nil {
+			p = last
+		}
+		if p == nil {
+			return fmt.Errorf("risk: no price to check order value of %s", o.InstrumentId)
+		}
+		value := p.ToDecimal().Mul(decimal.NewFromInt(o.Quantity * int64(inst.GetLot())))
+		if value.GreaterThan(decimal.NewFromFloat(limits.MaxOrderValue)) {
+			return rm.reject(ErrMaxOrderValue, o, fmt.Sprintf("value %v, max %v", value, limits.MaxOrderValue))
+		}
+	}
+
+	maxPosition := positionLimit(limits, inst)
+	if maxPosition == 0 && limits.MaxDailyLoss == 0 && !limits.CheckMargin {
+		return nil
+	}
+	current, err := rm.position(ctx, o.AccountId, inst)
+	if err != nil {
+		return fmt.Errorf("risk: positions: %w", err)
+	}
+	after := current + o.Quantity
+	if o.Direction == pb.OrderDirection_ORDER_DIRECTION_SELL {
+		after = current - o.Quantity
+	}
+	if abs(after) <= abs(current) {
+		// заявка уменьшает позицию
+		return nil
+	}
+	if maxPosition > 0 && abs(after) > maxPosition {
+		return rm.reject(ErrMaxPosition, o, fmt.Sprintf("position %v lots after order, max %v", after, maxPosition))
+	}
+	if limits.MaxDailyLoss > 0 {
+		loss, err := rm.dailyLoss(ctx, o.AccountId)
+		if err != nil {
+			return fmt.Errorf("risk: portfolio: %w", err)
+		}
+		if loss.GreaterThanOrEqual(decimal.NewFromFloat(limits.MaxDailyLoss)) {
+			return rm.reject(ErrMaxDailyLoss, o, fmt.Sprintf("loss %v, max %v", loss, limits.MaxDailyLoss))
+		}
+	}
+	if limits.CheckMargin {
+		resp, err := rm.margin.GetMarginAttributesWithContext(ctx, o.AccountId)
+		if err != nil {
+			return fmt.Errorf("risk: margin attributes: %w", err)
+		}
+		if missing := resp.GetAmountOfMissingFunds().ToDecimal(); missing.IsPositive() {
+			return rm.reject(ErrInsufficientMargin, o, fmt.Sprintf("missing funds %v", missing))
+		}
+	}
+	return nil
+}
+
+// reject - отказ проверки
+func (rm *RiskManager) reject(check error, o RiskOrder, message string) error {
+	err := &RiskError{
+		Check:        check,
+		AccountId:    o.AccountId,
+		InstrumentId: o.InstrumentId,
+		Message:      message,
+	}
+	if rm.logger != nil {
+		logWarn(rm.logger, "order rejected by risk checks", FieldAccountId, o.AccountId, FieldInstrumentId, o.InstrumentId, FieldError, err)
+	}
+	return err
+}
+
+// position - позиция по инструменту в лотах, отрицательная для шорта
+func (rm *RiskManager) position(ctx context.Context, accountId string, inst *pb.Instrument) (int64, error) {
+	resp, err := rm.trading.GetPositions(ctx, accountId)
+	if err != nil {
+		return 0, err
+	}
+	var pieces int64
+	for _, s := range resp.GetSecurities() {
+		if s.GetInstrumentUid() == inst.GetUid() || s.GetFigi() == inst.GetFigi() {
+			pieces += s.GetBalance() + s.GetBlocked()
+		}
+	}
+	for _, f := range resp.GetFutures() {
+		if f.GetInstrumentUid() == inst.GetUid() || f.GetFigi() == inst.GetFigi() {
+			pieces += f.GetBalance() + f.GetBlocked()
+		}
+	}
+	if lot := int64(inst.GetLot()); lot > 1 {
+		return pieces / lot, nil
+	}
+	return pieces, nil
+}
+
+// dailyLoss - убыток за день без учета пополнений и выводов средств
+func (rm *RiskManager) dailyLoss(ctx context.Context, accountId string) (decimal.Decimal, error) {
+	portfolio, err := rm.trading.GetPortfolio(ctx, accountId, pb.PortfolioRequest_RUB)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	now := time.Now().In(moscow)
+	ops, err := rm.trading.GetOperations(ctx, &GetOperationsRequest{
+		AccountId: accountId,
+		State:     pb.OperationState_OPERATION_STATE_EXECUTED,
+		From:      time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, moscow),
+		To:        now,
+	})
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	rm.mu.Lock()
+	baseline, ok := rm.baselines[accountId]
+	rm.mu.Unlock()
+	if ok && baseline.day == now.Format(time.DateOnly) {
+		flows := decimal.Zero
+		for _, op := range ops.GetOperations() {
+			if _, ok := moneyFlows[op.GetOperationType()]; ok && isRub(op.GetCurrency()) {
+				flows = flows.Add(op.GetPayment().ToDecimal())
+			}
+		}
+		return baseline.value.Add(flows).Sub(portfolio.GetTotalAmountPortfolio().ToDecimal()), nil
+	}
+	pnl, err := rm.dailyPnL(ctx, portfolio, ops)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return pnl.Neg(), nil
+}
+
+// dailyPnL - результат за день в рублях: изменение стоимости позиций от цен закрытия прошлой сессии
+// и платежи по операциям за день, кроме пополнений и выводов
+func (rm *RiskManager) dailyPnL(ctx context.Context, portfolio *PortfolioResponse, ops *OperationsResponse) (decimal.Decimal, error) {
+	type dayPosition struct {
+		// quantity - количество сейчас, traded - куплено за день за вычетом проданного
+		quantity, traded decimal.Decimal
+		value            decimal.Decimal
+	}
+	positions := make(map[string]*dayPosition)
+	get := func(uid, figi string) *dayPosition {
+		id := instrumentId(uid, figi)
+		p, ok := positions[id]
+		if !ok {
+			p = &dayPosition{}
+			positions[id] = p
+		}
+		return p
+	}
+	for _, pp := range portfolio.GetPositions() {
+		if !isRub(pp.GetCurrentPrice().GetCurrency()) {
+			continue
+		}
+		p := get(pp.GetInstrumentUid(), pp.GetFigi())
+		p.quantity = pp.GetQuantity().ToDecimal()
+		p.value = p.quantity.Mul(pp.GetCurrentPrice().ToDecimal())
+	}
+
+	pnl := decimal.Zero
+	for _, op := range ops.GetOperations() {
+		if !isRub(op.GetCurrency()) {
+			continue
+		}
+		if _, ok := moneyFlows[op.GetOperationType()]; ok {
+			continue
+		}
+		if sign, ok := tradeOperations[op.GetOperationType()]; ok {
+			p := get(op.GetInstrumentUid(), op.GetFigi())
+			p.traded = p.traded.Add(decimal.NewFromInt(sign * op.GetQuantity()))
+		}
+		pnl = pnl.Add(op.GetPayment().ToDecimal())
+	}
+
+	var ids []string
+	for id, p := range positions {
+		if !p.quantity.Equal(p.traded) {
+			ids = append(ids, id)
+		}
+		pnl = pnl.Add(p.value)
+	}
+	if len(ids) == 0 {
+		return pnl, nil
+	}
+	resp, err := rm.marketData.GetClosePricesWithContext(ctx, ids)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("close prices: %w", err)
+	}
+	closePrices := make(map[string]decimal.Decimal)
+	for _, cp := range resp.GetClosePrices() {
+		closePrices[cp.GetInstrumentUid()] = cp.GetPrice().ToDecimal()
+		closePrices[cp.GetFigi()] = cp.GetPrice().ToDecimal()
+	}
+	for _, id := range ids {
+		price, ok := closePrices[id]
+		if !ok {
+			return decimal.Zero, fmt.Errorf("no close price for %s", id)
+		}
+		p := positions[id]
+		pnl = pnl.Sub(p.quantity.Sub(p.traded).Mul(price))
+	}
+	return pnl, nil
+}
+
+// moneyFlows - пополнения и выводы средств, которые не считаются результатом торговли
+var moneyFlows = map[pb.OperationType]struct{}{
+	pb.OperationType_OPERATION_TYPE_INPUT:            {},
+	pb.OperationType_OPERATION_TYPE_OUTPUT:           {},
+	pb.OperationType_OPERATION_TYPE_INPUT_SWIFT:      {},
+	pb.OperationType_OPERATION_TYPE_OUTPUT_SWIFT:     {},
+	pb.OperationType_OPERATION_TYPE_INPUT_ACQUIRING:  {},
+	pb.OperationType_OPERATION_TYPE_OUTPUT_ACQUIRING: {},
+	pb.OperationType_OPERATION_TYPE_INP_MULTI:        {},
+	pb.OperationType_OPERATION_TYPE_OUT_MULTI:        {},
+}
+
+// tradeOperations - покупки (1) и продажи (-1), которые меняют позицию по инструменту
+var tradeOperations = map[pb.OperationType]int64{
+	pb.OperationType_OPERATION_TYPE_BUY:           1,
+	pb.OperationType_OPERATION_TYPE_BUY_CARD:      1,
+	pb.OperationType_OPERATION_TYPE_BUY_MARGIN:    1,
+	pb.OperationType_OPERATION_TYPE_DELIVERY_BUY:  1,
+	pb.OperationType_OPERATION_TYPE_SELL:          -1,
+	pb.OperationType_OPERATION_TYPE_SELL_CARD:     -1,
+	pb.OperationType_OPERATION_TYPE_SELL_MARGIN:   -1,
+	pb.OperationType_OPERATION_TYPE_DELIVERY_SELL: -1,
+}
+
+// isRub - рублевая ли валюта
+func isRub(currency string) bool {
+	return strings.EqualFold(currency, "rub")
+}
+
+// positionLimit - максимальная позиция по инструменту
+func positionLimit(limits RiskLimits, inst *pb.Instrument) int64 {
+	for _, id := range []string{inst.GetUid(), inst.GetFigi(), inst.GetTicker()} {
+		if limit, ok := limits.MaxPositions[id]; ok && id != "" {
+			return limit
+		}
+	}
+	return limits.MaxPosition
+}
+
+// matchInstrument - есть ли инструмент в списке figi, instrument_uid или тикеров
+func matchInstrument(ids []string, inst *pb.Instrument) bool {
+	for _, id := range ids {
+		if id != "" && (id == inst.GetUid() || id == inst.GetFigi() || id == inst.GetTicker()) {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// riskUnaryInterceptor - предторговые проверки заявок. Стоит после ключей идемпотентности, чтобы повторная заявка
+// с тем же ключом получала сохраненный ответ без повторной проверки
+func riskUnaryInterceptor(rm *RiskManager) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var o RiskOrder
+		switch r := req.(type) {
+		case *pb.PostOrderRequest:
+			o = RiskOrder{
+				AccountId:    r.GetAccountId(),
+				InstrumentId: instrumentId(r.GetInstrumentId(), r.GetFigi()),
+				Direction:    r.GetDirection(),
+				Quantity:     r.GetQuantity(),
+			}
+			if r.GetOrderType() == pb.OrderType_ORDER_TYPE_LIMIT {
+				o.Price = r.GetPrice()
+			}
+		case *pb.ReplaceOrderRequest:
+			if killed, reason := rm.Killed(); killed {
+				return rm.reject(ErrKillSwitch, RiskOrder{AccountId: r.GetAccountId()}, reason)
+			}
+			if !rm.Limits().active() {
+				return invoker(ctx, method, req, reply, cc, opts...)
+			}
+			state, err := rm.trading.GetOrderState(ctx, r.GetAccountId(), r.GetOrderId())
+			if err != nil {
+				return fmt.Errorf("risk: order state: %w", err)
+			}
+			o = RiskOrder{
+				AccountId:    r.GetAccountId(),
+				InstrumentId: state.GetInstrumentUid(),
+				Direction:    state.GetDirection(),
+				Quantity:     r.GetQuantity(),
+				Price:        r.GetPrice(),
+			}
+		case *pb.PostStopOrderRequest:
+			o = RiskOrder{
+				AccountId:    r.GetAccountId(),
+				InstrumentId: instrumentId(r.GetInstrumentId(), r.GetFigi()),
+				Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+				Quantity:     r.GetQuantity(),
+				Price:        r.GetPrice(),
+				Stop:         true,
+			}
+			if r.GetDirection() == pb.StopOrderDirection_STOP_ORDER_DIRECTION_SELL {
+				o.Direction = pb.OrderDirection_ORDER_DIRECTION_SELL
+			}
+			if o.Price.ToDecimal().IsZero() {
+				o.Price = r.GetStopPrice()
+			}
+		default:
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		if err := rm.Check(ctx, o); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// instrumentId - instrument_id запроса или устаревший figi
+func instrumentId(id, figi string) string {
+	if id != "" {
+		return id
+	}
+	return figi
+}
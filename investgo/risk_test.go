@@ -0,0 +1,210 @@
+package investgo_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// newRiskClient - Клиент с лимитами limits
+func newRiskClient(t *testing.T, srv *fake.Server, limits investgo.RiskLimits) *investgo.Client {
+	t.Helper()
+	conf := srv.Config()
+	conf.RiskLimits = limits
+	return newTestClient(t, conf)
+}
+
+func limitBuy(srv *fake.Server, lots, price int64) *investgo.PostOrderRequest {
+	return &investgo.PostOrderRequest{
+		InstrumentId: testFigi,
+		Quantity:     lots,
+		Price:        fake.Quotation(price),
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+		AccountId:    srv.AccountId(),
+		OrderType:    pb.OrderType_ORDER_TYPE_LIMIT,
+	}
+}
+
+func TestRiskLimits(t *testing.T) {
+	srv := newTestServer(t)
+	orders := newRiskClient(t, srv, investgo.RiskLimits{
+		MaxOrderValue: 5000,
+		MaxPosition:   3,
+		PriceCollar:   0.05,
+	}).NewOrdersServiceClient()
+
+	tests := []struct {
+		name  string
+		req   *investgo.PostOrderRequest
+		check error
+	}{
+		{name: "order value", req: limitBuy(srv, 3, 245), check: investgo.ErrMaxOrderValue},
+		{name: "market order value", req: marketBuy(srv, "", 3), check: investgo.ErrMaxOrderValue},
+		{name: "price collar", req: limitBuy(srv, 1, 200), check: investgo.ErrPriceCollar},
+	}
+	for _, tt := range tests {
+		_, err := orders.PostOrder(tt.req)
+		var riskErr *investgo.RiskError
+		if !errors.Is(err, tt.check) || !errors.Is(err, investgo.ErrRiskRejected) || !errors.As(err, &riskErr) {
+			t.Fatalf("%s: err = %v, want %v", tt.name, err, tt.check)
+		}
+	}
+	if calls := srv.Calls(postOrderMethod); calls != 0 {
+		t.Fatalf("PostOrder calls = %d, want rejected orders not sent", calls)
+	}
+
+	// позиция 2 лота, третий разрешен, четвертый - нет
+	if _, err := orders.PostOrder(marketBuy(srv, "", 2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.PostOrder(marketBuy(srv, "", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.PostOrder(marketBuy(srv, "", 1)); !errors.Is(err, investgo.ErrMaxPosition) {
+		t.Fatalf("err = %v, want ErrMaxPosition", err)
+	}
+	// уменьшение позиции разрешено
+	sell := marketBuy(srv, "", 1)
+	sell.Direction = pb.OrderDirection_ORDER_DIRECTION_SELL
+	if _, err := orders.PostOrder(sell); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRiskAllowedInstruments(t *testing.T) {
+	srv := newTestServer(t)
+	orders := newRiskClient(t, srv, investgo.RiskLimits{AllowedInstruments: []string{"GAZP"}}).NewOrdersServiceClient()
+	if _, err := orders.PostOrder(marketBuy(srv, "", 1)); !errors.Is(err, investgo.ErrInstrumentNotAllowed) {
+		t.Fatalf("err = %v, want ErrInstrumentNotAllowed", err)
+	}
+
+	// тикер из списка
+	orders = newRiskClient(t, srv, investgo.RiskLimits{AllowedInstruments: []string{"SBER"}}).NewOrdersServiceClient()
+	if _, err := orders.PostOrder(marketBuy(srv, "", 1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRiskKillSwitch(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	orders := client.NewOrdersServiceClient()
+
+	client.Risk.KillSwitch("test")
+	if _, err := orders.PostOrder(marketBuy(srv, "", 1)); !errors.Is(err, investgo.ErrKillSwitch) {
+		t.Fatalf("err = %v, want ErrKillSwitch", err)
+	}
+	client.Risk.ResetKillSwitch()
+	if _, err := orders.PostOrder(marketBuy(srv, "", 1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRiskReplayNotRechecked(t *testing.T) {
+	srv := newTestServer(t)
+	client := newRiskClient(t, srv, investgo.RiskLimits{MaxPosition: 1})
+	orders := client.NewOrdersServiceClient()
+
+	first, err := orders.PostOrder(marketBuy(srv, "order-1", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// после исполнения позиция на пределе, повторная отправка с тем же ключом получает прежний ответ
+	second, err := orders.PostOrder(marketBuy(srv, "order-1", 1))
+	if err != nil {
+		t.Fatalf("replay was checked again: %v", err)
+	}
+	if !investgo.ReplayedFromHeader(second.Header) || second.GetOrderId() != first.GetOrderId() {
+		t.Fatalf("second order %v, want replay of %v", second.GetOrderId(), first.GetOrderId())
+	}
+	// новая заявка проверяется
+	if _, err := orders.PostOrder(marketBuy(srv, "order-2", 1)); !errors.Is(err, investgo.ErrMaxPosition) {
+		t.Fatalf("err = %v, want ErrMaxPosition", err)
+	}
+	if calls := srv.Calls(postOrderMethod); calls != 1 {
+		t.Fatalf("PostOrder calls = %d, want 1", calls)
+	}
+}
+
+func TestRiskDailyLossFromClose(t *testing.T) {
+	srv := newTestServer(t)
+	client := newRiskClient(t, srv, investgo.RiskLimits{MaxDailyLoss: 150})
+	orders := client.NewOrdersServiceClient()
+
+	// позиция 2 лота куплена вчера
+	yesterday := time.Now().Add(-24 * time.Hour)
+	srv.SetClock(func() time.Time { return yesterday })
+	if _, err := orders.PostOrder(marketBuy(srv, "", 2)); err != nil {
+		t.Fatal(err)
+	}
+	srv.SetClock(time.Now)
+
+	// позиция есть, цены закрытия нет
+	if _, err := orders.PostOrder(marketBuy(srv, "", 1)); err == nil || errors.Is(err, investgo.ErrRiskRejected) {
+		t.Fatalf("err = %v, want error of close prices request", err)
+	}
+
+	// закрытие 260, цена 250: убыток 200 за день уже на первой заявке, пополнение его не уменьшает
+	if err := srv.SetClosePrice(testFigi, fake.Quotation(260)); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.PayIn(srv.AccountId(), fake.Money(1000, "rub")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.PostOrder(marketBuy(srv, "", 1)); !errors.Is(err, investgo.ErrMaxDailyLoss) {
+		t.Fatalf("err = %v, want ErrMaxDailyLoss", err)
+	}
+	// закрытие 255: убыток 100
+	if err := srv.SetClosePrice(testFigi, fake.Quotation(255)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.PostOrder(marketBuy(srv, "", 1)); err != nil {
+		t.Fatal(err)
+	}
+	// цена 245: убыток по вчерашней позиции 20 * 10 и по купленному сегодня лоту 10 * 5
+	if err := srv.SetLastPrice(testFigi, fake.Quotation(245)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.PostOrder(marketBuy(srv, "", 1)); !errors.Is(err, investgo.ErrMaxDailyLoss) {
+		t.Fatalf("err = %v, want ErrMaxDailyLoss", err)
+	}
+}
+
+func TestRiskDailyBaseline(t *testing.T) {
+	srv := newTestServer(t)
+	client := newRiskClient(t, srv, investgo.RiskLimits{MaxDailyLoss: 200})
+	orders := client.NewOrdersServiceClient()
+
+	// счет пополнен вчера
+	yesterday := time.Now().Add(-24 * time.Hour)
+	srv.SetClock(func() time.Time { return yesterday })
+	accountId := srv.OpenAccount("risk", pb.AccountType_ACCOUNT_TYPE_TINKOFF)
+	if err := srv.PayIn(accountId, fake.Money(100000, "rub")); err != nil {
+		t.Fatal(err)
+	}
+	srv.SetClock(time.Now)
+	buy := marketBuy(srv, "", 1)
+	buy.AccountId = accountId
+
+	// на начало дня на счете было на 300 больше текущих 100000
+	client.Risk.SetDailyBaseline(accountId, 100300)
+	if _, err := orders.PostOrder(buy); !errors.Is(err, investgo.ErrMaxDailyLoss) {
+		t.Fatalf("err = %v, want ErrMaxDailyLoss", err)
+	}
+	// пополнение не покрывает убыток
+	if err := srv.PayIn(accountId, fake.Money(1000, "rub")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.PostOrder(buy); !errors.Is(err, investgo.ErrMaxDailyLoss) {
+		t.Fatalf("err = %v, want ErrMaxDailyLoss after pay in", err)
+	}
+
+	client.Risk.SetDailyBaseline(accountId, 100100)
+	if _, err := orders.PostOrder(buy); err != nil {
+		t.Fatal(err)
+	}
+}
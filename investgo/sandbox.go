@@ -18,8 +18,13 @@ type SandboxServiceClient struct {
 
 // OpenSandboxAccount - Метод регистрации счёта в песочнице
 func (s *SandboxServiceClient) OpenSandboxAccount() (*OpenSandboxAccountResponse, error) {
+	return s.OpenSandboxAccountWithContext(s.ctx)
+}
+
+// OpenSandboxAccountWithContext - Метод регистрации счёта в песочнице
+func (s *SandboxServiceClient) OpenSandboxAccountWithContext(ctx context.Context) (*OpenSandboxAccountResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.OpenSandboxAccount(s.ctx, &pb.OpenSandboxAccountRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
+	resp, err := s.pbClient.OpenSandboxAccount(ctx, &pb.OpenSandboxAccountRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
 		header = trailer
 	}
@@ -31,8 +36,13 @@ func (s *SandboxServiceClient) OpenSandboxAccount() (*OpenSandboxAccountResponse
 
 // GetSandboxAccounts - Метод получения счетов в песочнице
 func (s *SandboxServiceClient) GetSandboxAccounts() (*GetAccountsResponse, error) {
+	return s.GetSandboxAccountsWithContext(s.ctx)
+}
+
+// GetSandboxAccountsWithContext - Метод получения счетов в песочнице
+func (s *SandboxServiceClient) GetSandboxAccountsWithContext(ctx context.Context) (*GetAccountsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.GetSandboxAccounts(s.ctx, &pb.GetAccountsRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
+	resp, err := s.pbClient.GetSandboxAccounts(ctx, &pb.GetAccountsRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
 		header = trailer
 	}
@@ -44,8 +54,13 @@ func (s *SandboxServiceClient) GetSandboxAccounts() (*GetAccountsResponse, error
 
 // CloseSandboxAccount - Метод закрытия счёта в песочнице
 func (s *SandboxServiceClient) CloseSandboxAccount(accountId string) (*CloseSandboxAccountResponse, error) {
+	return s.CloseSandboxAccountWithContext(s.ctx, accountId)
+}
+
+// CloseSandboxAccountWithContext - Метод закрытия счёта в песочнице
+func (s *SandboxServiceClient) CloseSandboxAccountWithContext(ctx context.Context, accountId string) (*CloseSandboxAccountResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.CloseSandboxAccount(s.ctx, &pb.CloseSandboxAccountRequest{
+	resp, err := s.pbClient.CloseSandboxAccount(ctx, &pb.CloseSandboxAccountRequest{
 		AccountId: accountId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -59,14 +74,25 @@ func (s *SandboxServiceClient) CloseSandboxAccount(accountId string) (*CloseSand
 
 // PostSandboxOrder - Метод выставления торгового поручения в песочнице
 func (s *SandboxServiceClient) PostSandboxOrder(req *PostOrderRequest) (*PostOrderResponse, error) {
+	return s.PostSandboxOrderWithContext(s.ctx, req)
+}
+
+// PostSandboxOrderWithContext - Метод выставления торгового поручения в песочнице.
+// Если req.OrderId пустой, создается новый ключ идемпотентности, он возвращается в RequestId ответа, и с ним запрос можно
+// отправить повторно. Запрос req не изменяется
+func (s *SandboxServiceClient) PostSandboxOrderWithContext(ctx context.Context, req *PostOrderRequest) (*PostOrderResponse, error) {
+	orderId := req.OrderId
+	if orderId == "" {
+		orderId = CreateUid()
+	}
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.PostSandboxOrder(s.ctx, &pb.PostOrderRequest{
+	resp, err := s.pbClient.PostSandboxOrder(ctx, &pb.PostOrderRequest{
 		Quantity:     req.Quantity,
 		Price:        req.Price,
 		Direction:    req.Direction,
 		AccountId:    req.AccountId,
 		OrderType:    req.OrderType,
-		OrderId:      req.OrderId,
+		OrderId:      orderId,
 		InstrumentId: req.InstrumentId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -75,16 +101,28 @@ func (s *SandboxServiceClient) PostSandboxOrder(req *PostOrderRequest) (*PostOrd
 	return &PostOrderResponse{
 		PostOrderResponse: resp,
 		Header:            header,
+		RequestId:         orderId,
 	}, err
 }
 
 // ReplaceSandboxOrder - Метод изменения выставленной заявки
 func (s *SandboxServiceClient) ReplaceSandboxOrder(req *ReplaceOrderRequest) (*PostOrderResponse, error) {
+	return s.ReplaceSandboxOrderWithContext(s.ctx, req)
+}
+
+// ReplaceSandboxOrderWithContext - Метод изменения выставленной заявки.
+// Если req.NewOrderId пустой, создается новый ключ идемпотентности, он возвращается в RequestId ответа, и с ним запрос можно
+// отправить повторно. Запрос req не изменяется
+func (s *SandboxServiceClient) ReplaceSandboxOrderWithContext(ctx context.Context, req *ReplaceOrderRequest) (*PostOrderResponse, error) {
+	orderId := req.NewOrderId
+	if orderId == "" {
+		orderId = CreateUid()
+	}
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.ReplaceSandboxOrder(s.ctx, &pb.ReplaceOrderRequest{
+	resp, err := s.pbClient.ReplaceSandboxOrder(ctx, &pb.ReplaceOrderRequest{
 		AccountId:      req.AccountId,
 		OrderId:        req.OrderId,
-		IdempotencyKey: req.NewOrderId,
+		IdempotencyKey: orderId,
 		Quantity:       req.Quantity,
 		Price:          req.Price,
 		PriceType:      req.PriceType,
@@ -95,13 +133,19 @@ func (s *SandboxServiceClient) ReplaceSandboxOrder(req *ReplaceOrderRequest) (*P
 	return &PostOrderResponse{
 		PostOrderResponse: resp,
 		Header:            header,
+		RequestId:         orderId,
 	}, err
 }
 
 // GetSandboxOrders - Метод получения списка активных заявок по счёту в песочнице
 func (s *SandboxServiceClient) GetSandboxOrders(accountId string) (*GetOrdersResponse, error) {
+	return s.GetSandboxOrdersWithContext(s.ctx, accountId)
+}
+
+// GetSandboxOrdersWithContext - Метод получения списка активных заявок по счёту в песочнице
+func (s *SandboxServiceClient) GetSandboxOrdersWithContext(ctx context.Context, accountId string) (*GetOrdersResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.GetSandboxOrders(s.ctx, &pb.GetOrdersRequest{
+	resp, err := s.pbClient.GetSandboxOrders(ctx, &pb.GetOrdersRequest{
 		AccountId: accountId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -115,8 +159,13 @@ func (s *SandboxServiceClient) GetSandboxOrders(accountId string) (*GetOrdersRes
 
 // CancelSandboxOrder - Метод отмены торгового поручения в песочнице
 func (s *SandboxServiceClient) CancelSandboxOrder(accountId, orderId string) (*CancelOrderResponse, error) {
+	return s.CancelSandboxOrderWithContext(s.ctx, accountId, orderId)
+}
+
+// CancelSandboxOrderWithContext - Метод отмены торгового поручения в песочнице
+func (s *SandboxServiceClient) CancelSandboxOrderWithContext(ctx context.Context, accountId, orderId string) (*CancelOrderResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.CancelSandboxOrder(s.ctx, &pb.CancelOrderRequest{
+	resp, err := s.pbClient.CancelSandboxOrder(ctx, &pb.CancelOrderRequest{
 		AccountId: accountId,
 		OrderId:   orderId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
@@ -131,8 +180,13 @@ func (s *SandboxServiceClient) CancelSandboxOrder(accountId, orderId string) (*C
 
 // GetSandboxOrderState - Метод получения статуса заявки в песочнице
 func (s *SandboxServiceClient) GetSandboxOrderState(accountId, orderId string) (*GetOrderStateResponse, error) {
+	return s.GetSandboxOrderStateWithContext(s.ctx, accountId, orderId)
+}
+
+// GetSandboxOrderStateWithContext - Метод получения статуса заявки в песочнице
+func (s *SandboxServiceClient) GetSandboxOrderStateWithContext(ctx context.Context, accountId, orderId string) (*GetOrderStateResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.GetSandboxOrderState(s.ctx, &pb.GetOrderStateRequest{
+	resp, err := s.pbClient.GetSandboxOrderState(ctx, &pb.GetOrderStateRequest{
 		AccountId: accountId,
 		OrderId:   orderId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
@@ -147,8 +201,13 @@ func (s *SandboxServiceClient) GetSandboxOrderState(accountId, orderId string) (
 
 // GetSandboxPositions - Метод получения позиций по виртуальному счёту песочницы
 func (s *SandboxServiceClient) GetSandboxPositions(accountId string) (*PositionsResponse, error) {
+	return s.GetSandboxPositionsWithContext(s.ctx, accountId)
+}
+
+// GetSandboxPositionsWithContext - Метод получения позиций по виртуальному счёту песочницы
+func (s *SandboxServiceClient) GetSandboxPositionsWithContext(ctx context.Context, accountId string) (*PositionsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.GetSandboxPositions(s.ctx, &pb.PositionsRequest{
+	resp, err := s.pbClient.GetSandboxPositions(ctx, &pb.PositionsRequest{
 		AccountId: accountId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -162,8 +221,13 @@ func (s *SandboxServiceClient) GetSandboxPositions(accountId string) (*Positions
 
 // GetSandboxOperations - Метод получения операций в песочнице по номеру счёта
 func (s *SandboxServiceClient) GetSandboxOperations(req *GetOperationsRequest) (*OperationsResponse, error) {
+	return s.GetSandboxOperationsWithContext(s.ctx, req)
+}
+
+// GetSandboxOperationsWithContext - Метод получения операций в песочнице по номеру счёта
+func (s *SandboxServiceClient) GetSandboxOperationsWithContext(ctx context.Context, req *GetOperationsRequest) (*OperationsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.GetSandboxOperations(s.ctx, &pb.OperationsRequest{
+	resp, err := s.pbClient.GetSandboxOperations(ctx, &pb.OperationsRequest{
 		AccountId: req.AccountId,
 		From:      TimeToTimestamp(req.From),
 		To:        TimeToTimestamp(req.To),
@@ -181,8 +245,13 @@ func (s *SandboxServiceClient) GetSandboxOperations(req *GetOperationsRequest) (
 
 // GetSandboxOperationsByCursor - Метод получения операций в песочнице по номеру счета с пагинацией
 func (s *SandboxServiceClient) GetSandboxOperationsByCursor(req *GetOperationsByCursorRequest) (*GetOperationsByCursorResponse, error) {
+	return s.GetSandboxOperationsByCursorWithContext(s.ctx, req)
+}
+
+// GetSandboxOperationsByCursorWithContext - Метод получения операций в песочнице по номеру счета с пагинацией
+func (s *SandboxServiceClient) GetSandboxOperationsByCursorWithContext(ctx context.Context, req *GetOperationsByCursorRequest) (*GetOperationsByCursorResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.GetSandboxOperationsByCursor(s.ctx, &pb.GetOperationsByCursorRequest{
+	resp, err := s.pbClient.GetSandboxOperationsByCursor(ctx, &pb.GetOperationsByCursorRequest{
 		AccountId:          req.AccountId,
 		InstrumentId:       req.InstrumentId,
 		From:               TimeToTimestamp(req.From),
@@ -206,8 +275,13 @@ func (s *SandboxServiceClient) GetSandboxOperationsByCursor(req *GetOperationsBy
 
 // GetSandboxPortfolio - Метод получения портфолио в песочнице
 func (s *SandboxServiceClient) GetSandboxPortfolio(accountId string, currency pb.PortfolioRequest_CurrencyRequest) (*PortfolioResponse, error) {
+	return s.GetSandboxPortfolioWithContext(s.ctx, accountId, currency)
+}
+
+// GetSandboxPortfolioWithContext - Метод получения портфолио в песочнице
+func (s *SandboxServiceClient) GetSandboxPortfolioWithContext(ctx context.Context, accountId string, currency pb.PortfolioRequest_CurrencyRequest) (*PortfolioResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.GetSandboxPortfolio(s.ctx, &pb.PortfolioRequest{
+	resp, err := s.pbClient.GetSandboxPortfolio(ctx, &pb.PortfolioRequest{
 		AccountId: accountId,
 		Currency:  currency,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
@@ -222,8 +296,13 @@ func (s *SandboxServiceClient) GetSandboxPortfolio(accountId string, currency pb
 
 // GetSandboxWithdrawLimits - Метод получения доступного остатка для вывода средств в песочнице
 func (s *SandboxServiceClient) GetSandboxWithdrawLimits(accountId string) (*WithdrawLimitsResponse, error) {
+	return s.GetSandboxWithdrawLimitsWithContext(s.ctx, accountId)
+}
+
+// GetSandboxWithdrawLimitsWithContext - Метод получения доступного остатка для вывода средств в песочнице
+func (s *SandboxServiceClient) GetSandboxWithdrawLimitsWithContext(ctx context.Context, accountId string) (*WithdrawLimitsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.GetSandboxWithdrawLimits(s.ctx, &pb.WithdrawLimitsRequest{
+	resp, err := s.pbClient.GetSandboxWithdrawLimits(ctx, &pb.WithdrawLimitsRequest{
 		AccountId: accountId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -237,8 +316,13 @@ func (s *SandboxServiceClient) GetSandboxWithdrawLimits(accountId string) (*With
 
 // SandboxPayIn - Метод пополнения счёта в песочнице
 func (s *SandboxServiceClient) SandboxPayIn(req *SandboxPayInRequest) (*SandboxPayInResponse, error) {
+	return s.SandboxPayInWithContext(s.ctx, req)
+}
+
+// SandboxPayInWithContext - Метод пополнения счёта в песочнице
+func (s *SandboxServiceClient) SandboxPayInWithContext(ctx context.Context, req *SandboxPayInRequest) (*SandboxPayInResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.SandboxPayIn(s.ctx, &pb.SandboxPayInRequest{
+	resp, err := s.pbClient.SandboxPayIn(ctx, &pb.SandboxPayInRequest{
 		AccountId: req.AccountId,
 		Amount: &pb.MoneyValue{
 			Currency: req.Currency,
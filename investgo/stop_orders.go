@@ -18,8 +18,13 @@ type StopOrdersServiceClient struct {
 
 // PostStopOrder - Метод выставления стоп-заявки
 func (s *StopOrdersServiceClient) PostStopOrder(req *PostStopOrderRequest) (*PostStopOrderResponse, error) {
+	return s.PostStopOrderWithContext(s.ctx, req)
+}
+
+// PostStopOrderWithContext - Метод выставления стоп-заявки
+func (s *StopOrdersServiceClient) PostStopOrderWithContext(ctx context.Context, req *PostStopOrderRequest) (*PostStopOrderResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.PostStopOrder(s.ctx, &pb.PostStopOrderRequest{
+	resp, err := s.pbClient.PostStopOrder(ctx, &pb.PostStopOrderRequest{
 		Quantity:       req.Quantity,
 		Price:          req.Price,
 		StopPrice:      req.StopPrice,
@@ -41,8 +46,13 @@ func (s *StopOrdersServiceClient) PostStopOrder(req *PostStopOrderRequest) (*Pos
 
 // GetStopOrders - Метод получения списка активных стоп заявок по счёту
 func (s *StopOrdersServiceClient) GetStopOrders(accountId string) (*GetStopOrdersResponse, error) {
+	return s.GetStopOrdersWithContext(s.ctx, accountId)
+}
+
+// GetStopOrdersWithContext - Метод получения списка активных стоп заявок по счёту
+func (s *StopOrdersServiceClient) GetStopOrdersWithContext(ctx context.Context, accountId string) (*GetStopOrdersResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.GetStopOrders(s.ctx, &pb.GetStopOrdersRequest{
+	resp, err := s.pbClient.GetStopOrders(ctx, &pb.GetStopOrdersRequest{
 		AccountId: accountId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -56,8 +66,13 @@ func (s *StopOrdersServiceClient) GetStopOrders(accountId string) (*GetStopOrder
 
 // CancelStopOrder - Метод отмены стоп-заявки
 func (s *StopOrdersServiceClient) CancelStopOrder(accountId, stopOrderId string) (*CancelStopOrderResponse, error) {
+	return s.CancelStopOrderWithContext(s.ctx, accountId, stopOrderId)
+}
+
+// CancelStopOrderWithContext - Метод отмены стоп-заявки
+func (s *StopOrdersServiceClient) CancelStopOrderWithContext(ctx context.Context, accountId, stopOrderId string) (*CancelStopOrderResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := s.pbClient.CancelStopOrder(s.ctx, &pb.CancelStopOrderRequest{
+	resp, err := s.pbClient.CancelStopOrder(ctx, &pb.CancelStopOrderRequest{
 		AccountId:   accountId,
 		StopOrderId: stopOrderId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
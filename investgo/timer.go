@@ -88,19 +88,19 @@ func (t *Timer) Start(ctx context.Context) error {
 			switch {
 			// если торги еще не начались
 			case time.Now().Before(today.GetStartTime().AsTime()):
-				t.client.Logger.Infof("%v is closed yet, wait for start %v", t.exchange, time.Until(today.GetStartTime().AsTime().Local()))
+				logInfo(t.client.Logger, "exchange is closed yet, wait for start", "exchange", t.exchange, "wait", time.Until(today.GetStartTime().AsTime().Local()))
 				if stop := t.wait(ctxTimer, time.Until(today.GetStartTime().AsTime().Local())); stop {
 					return nil
 				}
 				t.events <- START
-				t.client.Logger.Infof("start trading session, remaining time = %v", time.Until(today.GetEndTime().AsTime().Local()))
+				logInfo(t.client.Logger, "start trading session", "exchange", t.exchange, "remaining", time.Until(today.GetEndTime().AsTime().Local()))
 				if stop := t.wait(ctxTimer, time.Until(today.GetEndTime().AsTime().Local())-t.cancelAhead); stop {
 					return nil
 				}
 				t.events <- STOP
 				// если сегодня торги уже идут
 			case time.Now().After(today.GetStartTime().AsTime()) && time.Now().Before(today.GetEndTime().AsTime().Local().Add(-t.cancelAhead)):
-				t.client.Logger.Infof("start trading session, remaining time = %v", time.Until(today.GetEndTime().AsTime().Local()))
+				logInfo(t.client.Logger, "start trading session", "exchange", t.exchange, "remaining", time.Until(today.GetEndTime().AsTime().Local()))
 				t.events <- START
 				if stop := t.wait(ctxTimer, time.Until(today.GetEndTime().AsTime().Local())-t.cancelAhead); stop {
 					return nil
@@ -109,7 +109,7 @@ func (t *Timer) Start(ctx context.Context) error {
 				// если на сегодня торги уже окончены
 			case time.Now().After(today.GetEndTime().AsTime().Local().Add(-t.cancelAhead)):
 				// спать час, пока не дождемся следующего дня
-				t.client.Logger.Infof("%v is already closed, wait next day for 1 hour", t.exchange)
+				logInfo(t.client.Logger, "exchange is already closed, wait next day for 1 hour", "exchange", t.exchange)
 				if stop := t.wait(ctxTimer, time.Hour); stop {
 					return nil
 				}
@@ -125,7 +125,7 @@ func (t *Timer) Stop() {
 }
 
 func (t *Timer) shutdown() {
-	t.client.Logger.Infof("stop %v timer", t.exchange)
+	logInfo(t.client.Logger, "stop timer", "exchange", t.exchange)
 	close(t.events)
 }
 
@@ -0,0 +1,230 @@
+/*
+Package tracing добавляет трассировку OpenTelemetry в клиента InvestAPI: span на каждый вызов unary-метода с событиями
+по попыткам ретраера и атрибутом x-tracking-id, span на открытие стрима и на каждую подписку и отписку в MarketDataStream.
+
+	t := tracing.New(tracerProvider)
+	client, err := investgo.NewClient(ctx, config, logger, t.ClientOptions()...)
+
+Для тестов подойдет любой TracerProvider, например из go.opentelemetry.io/otel/sdk/trace с tracetest.NewInMemoryExporter.
+*/
+package tracing
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"github.com/tinkoff/invest-api-go-sdk/retry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// InstrumentationName - Имя библиотеки инструментирования для TracerProvider
+const InstrumentationName = "github.com/tinkoff/invest-api-go-sdk/investgo/tracing"
+
+// Атрибуты span
+const (
+	TrackingIdKey    = attribute.Key("investapi.tracking_id")
+	AttemptKey       = attribute.Key("investapi.attempt")
+	SubscriptionKey  = attribute.Key("investapi.subscription")
+	InstrumentIdsKey = attribute.Key("investapi.instrument_ids")
+	rpcSystemKey     = attribute.Key("rpc.system")
+	rpcServiceKey    = attribute.Key("rpc.service")
+	rpcMethodKey     = attribute.Key("rpc.method")
+	rpcStatusCodeKey = attribute.Key("rpc.grpc.status_code")
+)
+
+// Tracing - Трассировка вызовов InvestAPI
+type Tracing struct {
+	tracer trace.Tracer
+}
+
+// New - Создание трассировки, если tp == nil используется глобальный otel.GetTracerProvider()
+func New(tp trace.TracerProvider) *Tracing {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracing{
+		tracer: tp.Tracer(InstrumentationName),
+	}
+}
+
+// ClientOptions - Опции для investgo.NewClient, которые добавляют интерсепторы трассировки
+func (t *Tracing) ClientOptions() []investgo.Option {
+	return []investgo.Option{
+		investgo.WithUnaryInterceptors(t.UnaryInterceptor()),
+		investgo.WithUnaryAttemptInterceptors(t.UnaryAttemptInterceptor()),
+		investgo.WithStreamInterceptors(t.StreamInterceptor()),
+	}
+}
+
+// UnaryInterceptor - Интерсептор вызова: span на весь вызов метода, включая ретраи
+func (t *Tracing) UnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := t.tracer.Start(ctx, strings.TrimPrefix(method, "/"),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(rpcAttributes(method)...))
+		defer span.End()
+
+		var header, trailer metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header), grpc.Trailer(&trailer))...)
+		if trackingId := firstValue(metadata.Join(header, trailer), "x-tracking-id"); trackingId != "" {
+			span.SetAttributes(TrackingIdKey.String(trackingId))
+		}
+		endWithStatus(span, err)
+		return err
+	}
+}
+
+// UnaryAttemptInterceptor - Интерсептор попытки: событие в span вызова на каждую попытку ретраера
+func (t *Tracing) UnaryAttemptInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		span := trace.SpanFromContext(ctx)
+		attempt := 0
+		if md, ok := metadata.FromOutgoingContext(ctx); ok {
+			if n, err := strconv.Atoi(firstValue(md, retry.AttemptMetadataKey)); err == nil {
+				attempt = n
+			}
+		}
+		var header, trailer metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header), grpc.Trailer(&trailer))...)
+		attrs := []attribute.KeyValue{
+			AttemptKey.Int(attempt),
+			rpcStatusCodeKey.Int(int(status.Code(err))),
+		}
+		if trackingId := firstValue(metadata.Join(header, trailer), "x-tracking-id"); trackingId != "" {
+			attrs = append(attrs, TrackingIdKey.String(trackingId))
+		}
+		if err != nil {
+			attrs = append(attrs, attribute.String("error", err.Error()))
+		}
+		span.AddEvent("attempt", trace.WithAttributes(attrs...))
+		return err
+	}
+}
+
+// StreamInterceptor - Интерсептор стримов: span на открытие стрима и на каждый запрос подписки в MarketDataStream
+func (t *Tracing) StreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		spanCtx, span := t.tracer.Start(ctx, strings.TrimPrefix(method, "/"),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(rpcAttributes(method)...))
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		endWithStatus(span, err)
+		span.End()
+		if err != nil {
+			return nil, err
+		}
+		return &tracedStream{ClientStream: stream, tracer: t.tracer, ctx: spanCtx}, nil
+	}
+}
+
+// tracedStream - обертка стрима, которая создает span на каждый запрос подписки
+type tracedStream struct {
+	grpc.ClientStream
+	tracer trace.Tracer
+	// ctx - контекст со span открытия стрима, span подписок становятся его потомками
+	ctx context.Context
+}
+
+func (s *tracedStream) SendMsg(m any) error {
+	req, ok := m.(*pb.MarketDataRequest)
+	if !ok {
+		return s.ClientStream.SendMsg(m)
+	}
+	name, attrs := subscriptionSpan(req)
+	_, span := s.tracer.Start(s.ctx, name, trace.WithAttributes(attrs...))
+	err := s.ClientStream.SendMsg(m)
+	endWithStatus(span, err)
+	span.End()
+	return err
+}
+
+// subscriptionSpan - имя и атрибуты span для запроса в MarketDataStream
+func subscriptionSpan(req *pb.MarketDataRequest) (string, []attribute.KeyValue) {
+	var (
+		subscription string
+		action       pb.SubscriptionAction
+		ids          []string
+	)
+	switch {
+	case req.GetSubscribeCandlesRequest() != nil:
+		r := req.GetSubscribeCandlesRequest()
+		subscription, action = "candles", r.GetSubscriptionAction()
+		for _, i := range r.GetInstruments() {
+			ids = append(ids, i.GetInstrumentId())
+		}
+	case req.GetSubscribeOrderBookRequest() != nil:
+		r := req.GetSubscribeOrderBookRequest()
+		subscription, action = "order_book", r.GetSubscriptionAction()
+		for _, i := range r.GetInstruments() {
+			ids = append(ids, i.GetInstrumentId())
+		}
+	case req.GetSubscribeTradesRequest() != nil:
+		r := req.GetSubscribeTradesRequest()
+		subscription, action = "trades", r.GetSubscriptionAction()
+		for _, i := range r.GetInstruments() {
+			ids = append(ids, i.GetInstrumentId())
+		}
+	case req.GetSubscribeInfoRequest() != nil:
+		r := req.GetSubscribeInfoRequest()
+		subscription, action = "info", r.GetSubscriptionAction()
+		for _, i := range r.GetInstruments() {
+			ids = append(ids, i.GetInstrumentId())
+		}
+	case req.GetSubscribeLastPriceRequest() != nil:
+		r := req.GetSubscribeLastPriceRequest()
+		subscription, action = "last_price", r.GetSubscriptionAction()
+		for _, i := range r.GetInstruments() {
+			ids = append(ids, i.GetInstrumentId())
+		}
+	case req.GetGetMySubscriptions() != nil:
+		return "MarketDataStream.GetMySubscriptions", nil
+	default:
+		return "MarketDataStream.Send", nil
+	}
+
+	name := "MarketDataStream.Subscribe"
+	if action == pb.SubscriptionAction_SUBSCRIPTION_ACTION_UNSUBSCRIBE {
+		name = "MarketDataStream.Unsubscribe"
+	}
+	return name, []attribute.KeyValue{
+		SubscriptionKey.String(subscription),
+		InstrumentIdsKey.StringSlice(ids),
+	}
+}
+
+// rpcAttributes - атрибуты rpc по соглашениям OpenTelemetry
+func rpcAttributes(method string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{rpcSystemKey.String("grpc")}
+	fullMethod := strings.TrimPrefix(method, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		attrs = append(attrs, rpcServiceKey.String(fullMethod[:i]), rpcMethodKey.String(fullMethod[i+1:]))
+	}
+	return attrs
+}
+
+func endWithStatus(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(rpcStatusCodeKey.Int(int(code)))
+	if err != nil && code != codes.Canceled {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+}
+
+func firstValue(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
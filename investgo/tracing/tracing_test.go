@@ -0,0 +1,167 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/tracing"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testFigi = "BBG004730N88"
+
+type testLogger struct{}
+
+func (testLogger) Infof(string, ...any)  {}
+func (testLogger) Errorf(string, ...any) {}
+func (testLogger) Fatalf(string, ...any) {}
+
+func newTracedClient(t *testing.T) (*fake.Server, *investgo.Client, *tracetest.InMemoryExporter) {
+	t.Helper()
+	srv := fake.NewServer()
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+	srv.AddShare(&pb.Share{Figi: testFigi, Ticker: "SBER", ClassCode: "TQBR", Lot: 10, Currency: "rub", ApiTradeAvailableFlag: true})
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client, err := investgo.NewClient(context.Background(), srv.Config(), testLogger{}, tracing.New(tp).ClientOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Stop()
+	})
+	exporter.Reset()
+	return srv, client, exporter
+}
+
+// findSpan - Единственный span с именем name
+func findSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	var found []tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			found = append(found, span)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("spans named %s = %d, want 1", name, len(found))
+	}
+	return found[0]
+}
+
+func attributes(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	res := make(map[attribute.Key]attribute.Value, len(kvs))
+	for _, kv := range kvs {
+		res[kv.Key] = kv.Value
+	}
+	return res
+}
+
+func TestUnarySpan(t *testing.T) {
+	srv, client, exporter := newTracedClient(t)
+	srv.InjectError("UsersService/GetInfo", status.Error(codes.Unavailable, "unavailable"))
+
+	if _, err := client.NewUsersServiceClient().GetInfo(); err != nil {
+		t.Fatal(err)
+	}
+
+	span := findSpan(t, exporter, "tinkoff.public.invest.api.contract.v1.UsersService/GetInfo")
+	if span.SpanKind != trace.SpanKindClient {
+		t.Errorf("span kind = %v, want client", span.SpanKind)
+	}
+	if span.Status.Code != otelcodes.Unset {
+		t.Errorf("status = %v, want unset", span.Status)
+	}
+	attrs := attributes(span.Attributes)
+	want := map[attribute.Key]string{
+		"rpc.system":  "grpc",
+		"rpc.service": "tinkoff.public.invest.api.contract.v1.UsersService",
+		"rpc.method":  "GetInfo",
+	}
+	for key, value := range want {
+		if got := attrs[key].AsString(); got != value {
+			t.Errorf("attribute %s = %q, want %q", key, got, value)
+		}
+	}
+	if got := attrs["rpc.grpc.status_code"].AsInt64(); got != int64(codes.OK) {
+		t.Errorf("status code = %d, want OK", got)
+	}
+	if attrs[tracing.TrackingIdKey].AsString() == "" {
+		t.Error("tracking id attribute is missing")
+	}
+
+	// первая попытка получила Unavailable, вторая прошла
+	if len(span.Events) != 2 {
+		t.Fatalf("attempt events = %d, want 2", len(span.Events))
+	}
+	first, second := attributes(span.Events[0].Attributes), attributes(span.Events[1].Attributes)
+	if first[tracing.AttemptKey].AsInt64() != 0 || first["rpc.grpc.status_code"].AsInt64() != int64(codes.Unavailable) {
+		t.Errorf("first attempt = %v, want attempt 0 with Unavailable", span.Events[0].Attributes)
+	}
+	if second[tracing.AttemptKey].AsInt64() != 1 || second["rpc.grpc.status_code"].AsInt64() != int64(codes.OK) {
+		t.Errorf("second attempt = %v, want attempt 1 with OK", span.Events[1].Attributes)
+	}
+}
+
+func TestUnarySpanError(t *testing.T) {
+	srv, client, exporter := newTracedClient(t)
+
+	_, err := client.NewOrdersServiceClient().GetOrderState(srv.AccountId(), "unknown")
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("err = %v, want NotFound", err)
+	}
+
+	span := findSpan(t, exporter, "tinkoff.public.invest.api.contract.v1.OrdersService/GetOrderState")
+	if span.Status.Code != otelcodes.Error {
+		t.Errorf("status = %v, want error", span.Status)
+	}
+	if got := attributes(span.Attributes)["rpc.grpc.status_code"].AsInt64(); got != int64(codes.NotFound) {
+		t.Errorf("status code = %d, want NotFound", got)
+	}
+}
+
+func TestStreamSpans(t *testing.T) {
+	_, client, exporter := newTracedClient(t)
+
+	stream, err := client.NewMarketDataStreamClient().MarketDataStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Stop()
+	if _, err := stream.SubscribeLastPrice([]string{testFigi}); err != nil {
+		t.Fatal(err)
+	}
+
+	open := findSpan(t, exporter, "tinkoff.public.invest.api.contract.v1.MarketDataStreamService/MarketDataStream")
+	if open.Status.Code != otelcodes.Unset {
+		t.Errorf("stream status = %v, want unset", open.Status)
+	}
+	if got := attributes(open.Attributes)["rpc.method"].AsString(); got != "MarketDataStream" {
+		t.Errorf("rpc.method = %q, want MarketDataStream", got)
+	}
+
+	subscribe := findSpan(t, exporter, "MarketDataStream.Subscribe")
+	if subscribe.Parent.SpanID() != open.SpanContext.SpanID() {
+		t.Error("subscription span is not a child of the stream span")
+	}
+	attrs := attributes(subscribe.Attributes)
+	if got := attrs[tracing.SubscriptionKey].AsString(); got != "last_price" {
+		t.Errorf("subscription = %q, want last_price", got)
+	}
+	if ids := attrs[tracing.InstrumentIdsKey].AsStringSlice(); len(ids) != 1 || ids[0] != testFigi {
+		t.Errorf("instrument ids = %v, want [%s]", ids, testFigi)
+	}
+}
@@ -2,18 +2,24 @@ package investgo
 
 import (
 	"context"
+	"strings"
 
 	pb "github.com/tinkoff/invest-api-go-sdk/proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// tradesStreamMethod - полное имя метода стрима для поля method в логах
+const tradesStreamMethod = "/tinkoff.public.invest.api.contract.v1.OrdersStreamService/TradesStream"
+
 type TradesStream struct {
 	stream       pb.OrdersStreamService_TradesStreamClient
 	ordersClient *OrdersStreamClient
 
 	ctx    context.Context
 	cancel context.CancelFunc
+	// accounts - счета стрима для поля account_id в логах
+	accounts []string
 
 	trades chan *pb.OrderTrades
 }
@@ -35,7 +41,7 @@ func (t *TradesStream) Listen() error {
 			if err != nil {
 				switch {
 				case status.Code(err) == codes.Canceled:
-					t.ordersClient.logger.Infof("stop listening trades stream")
+					logInfo(t.ordersClient.logger, "stop listening stream", t.logFields()...)
 					return nil
 				default:
 					return err
@@ -45,7 +51,7 @@ func (t *TradesStream) Listen() error {
 				case *pb.TradesStreamResponse_OrderTrades:
 					t.trades <- resp.GetOrderTrades()
 				default:
-					t.ordersClient.logger.Infof("info from Trades stream %v", resp.String())
+					logDebug(t.ordersClient.logger, "info from stream", append(t.logFields(), "payload", resp.String())...)
 				}
 			}
 		}
@@ -53,14 +59,19 @@ func (t *TradesStream) Listen() error {
 }
 
 func (t *TradesStream) restart(_ context.Context, attempt uint, err error) {
-	t.ordersClient.logger.Infof("try to restart trades stream err = %v, attempt = %v", err.Error(), attempt)
+	logWarn(t.ordersClient.logger, "try to restart stream", append(t.logFields(), FieldAttempt, attempt, FieldError, err)...)
 }
 
 func (t *TradesStream) shutdown() {
-	t.ordersClient.logger.Infof("close trades stream")
+	logInfo(t.ordersClient.logger, "close stream", t.logFields()...)
 	close(t.trades)
 }
 
+// logFields - поля сообщений логгера для стрима
+func (t *TradesStream) logFields() []any {
+	return []any{FieldMethod, tradesStreamMethod, FieldAccountId, strings.Join(t.accounts, ",")}
+}
+
 // Stop - Завершение работы стрима
 func (t *TradesStream) Stop() {
 	t.cancel()
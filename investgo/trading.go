@@ -0,0 +1,206 @@
+package investgo
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// Trading - Торговые операции по счету, общие для боевого контура и песочницы: заявки, позиции, портфель,
+// операции и доступный остаток для вывода. Стратегия, которая работает через Trading, запускается
+// на любом контуре без изменений, реализация выбирается через Client.NewTrading
+type Trading interface {
+	// PostOrder - Выставление биржевой заявки
+	PostOrder(ctx context.Context, req *PostOrderRequest) (*PostOrderResponse, error)
+	// ReplaceOrder - Изменение выставленной заявки
+	ReplaceOrder(ctx context.Context, req *ReplaceOrderRequest) (*PostOrderResponse, error)
+	// CancelOrder - Отмена биржевой заявки
+	CancelOrder(ctx context.Context, accountId, orderId string) (*CancelOrderResponse, error)
+	// GetOrderState - Статус торгового поручения
+	GetOrderState(ctx context.Context, accountId, orderId string) (*GetOrderStateResponse, error)
+	// GetOrders - Список активных заявок по счету
+	GetOrders(ctx context.Context, accountId string) (*GetOrdersResponse, error)
+	// GetPositions - Список позиций по счету
+	GetPositions(ctx context.Context, accountId string) (*PositionsResponse, error)
+	// GetPortfolio - Портфель по счету
+	GetPortfolio(ctx context.Context, accountId string, currency pb.PortfolioRequest_CurrencyRequest) (*PortfolioResponse, error)
+	// GetOperations - Список операций по счету
+	GetOperations(ctx context.Context, req *GetOperationsRequest) (*OperationsResponse, error)
+	// GetOperationsByCursor - Список операций по счету с пагинацией
+	GetOperationsByCursor(ctx context.Context, req *GetOperationsByCursorRequest) (*GetOperationsByCursorResponse, error)
+	// GetWithdrawLimits - Доступный остаток для вывода средств
+	GetWithdrawLimits(ctx context.Context, accountId string) (*WithdrawLimitsResponse, error)
+}
+
+// StopOrderTrading - Операции со стоп-заявками по счету. Их реализуют ProductionTrading через StopOrdersService
+// и paper.Broker
+type StopOrderTrading interface {
+	// PostStopOrder - Выставление стоп-заявки
+	PostStopOrder(ctx context.Context, req *PostStopOrderRequest) (*PostStopOrderResponse, error)
+	// GetStopOrders - Список активных стоп-заявок по счету
+	GetStopOrders(ctx context.Context, accountId string) (*GetStopOrdersResponse, error)
+	// CancelStopOrder - Отмена стоп-заявки
+	CancelStopOrder(ctx context.Context, accountId, stopOrderId string) (*CancelStopOrderResponse, error)
+}
+
+// InstrumentSource - Параметры инструмента для расчета заявок: лотность и шаг цены. Ее реализуют
+// InstrumentsServiceClient, InstrumentRegistry и paper.Broker
+type InstrumentSource interface {
+	// InstrumentWithContext - Инструмент по figi или instrument_uid
+	InstrumentWithContext(ctx context.Context, instrumentId string) (*pb.Instrument, error)
+}
+
+var (
+	_ Trading          = (*ProductionTrading)(nil)
+	_ Trading          = (*SandboxTrading)(nil)
+	_ StopOrderTrading = (*ProductionTrading)(nil)
+	_ InstrumentSource = (*InstrumentsServiceClient)(nil)
+	_ InstrumentSource = (*InstrumentRegistry)(nil)
+	_ InstrumentLister = (*InstrumentsServiceClient)(nil)
+)
+
+// ErrStopOrdersUnsupported - Стоп-заявки недоступны: в песочнице нет StopOrdersService
+var ErrStopOrdersUnsupported = errors.New("stop orders are not supported")
+
+// NewTrading - Торговые операции для контура из конфигурации клиента
+func (c *Client) NewTrading() Trading {
+	if c.Config.Environment == EnvironmentProduction {
+		return c.NewProductionTrading()
+	}
+	return c.NewSandboxTrading()
+}
+
+// NewStopOrderTrading - Стоп-заявки для контура из конфигурации клиента. В песочнице StopOrdersService
+// недоступен, возвращается nil
+func (c *Client) NewStopOrderTrading() StopOrderTrading {
+	if c.Config.Environment == EnvironmentProduction {
+		return c.NewProductionTrading()
+	}
+	return nil
+}
+
+// NewProductionTrading - Торговые операции боевого контура через OrdersService, StopOrdersService и OperationsService
+func (c *Client) NewProductionTrading() *ProductionTrading {
+	return &ProductionTrading{
+		orders:     c.NewOrdersServiceClient(),
+		stopOrders: c.NewStopOrdersServiceClient(),
+		operations: c.NewOperationsServiceClient(),
+	}
+}
+
+// NewSandboxTrading - Торговые операции песочницы через SandboxService
+func (c *Client) NewSandboxTrading() *SandboxTrading {
+	return &SandboxTrading{
+		sandbox: c.NewSandboxServiceClient(),
+	}
+}
+
+// ProductionTrading - Реализация Trading для боевого контура
+type ProductionTrading struct {
+	orders     *OrdersServiceClient
+	stopOrders *StopOrdersServiceClient
+	operations *OperationsServiceClient
+}
+
+func (t *ProductionTrading) PostOrder(ctx context.Context, req *PostOrderRequest) (*PostOrderResponse, error) {
+	return t.orders.PostOrderWithContext(ctx, req)
+}
+
+func (t *ProductionTrading) ReplaceOrder(ctx context.Context, req *ReplaceOrderRequest) (*PostOrderResponse, error) {
+	return t.orders.ReplaceOrderWithContext(ctx, req)
+}
+
+func (t *ProductionTrading) CancelOrder(ctx context.Context, accountId, orderId string) (*CancelOrderResponse, error) {
+	return t.orders.CancelOrderWithContext(ctx, accountId, orderId)
+}
+
+func (t *ProductionTrading) GetOrderState(ctx context.Context, accountId, orderId string) (*GetOrderStateResponse, error) {
+	return t.orders.GetOrderStateWithContext(ctx, accountId, orderId)
+}
+
+func (t *ProductionTrading) GetOrders(ctx context.Context, accountId string) (*GetOrdersResponse, error) {
+	return t.orders.GetOrdersWithContext(ctx, accountId)
+}
+
+func (t *ProductionTrading) PostStopOrder(ctx context.Context, req *PostStopOrderRequest) (*PostStopOrderResponse, error) {
+	return t.stopOrders.PostStopOrderWithContext(ctx, req)
+}
+
+func (t *ProductionTrading) GetStopOrders(ctx context.Context, accountId string) (*GetStopOrdersResponse, error) {
+	return t.stopOrders.GetStopOrdersWithContext(ctx, accountId)
+}
+
+func (t *ProductionTrading) CancelStopOrder(ctx context.Context, accountId, stopOrderId string) (*CancelStopOrderResponse, error) {
+	return t.stopOrders.CancelStopOrderWithContext(ctx, accountId, stopOrderId)
+}
+
+func (t *ProductionTrading) GetPositions(ctx context.Context, accountId string) (*PositionsResponse, error) {
+	return t.operations.GetPositionsWithContext(ctx, accountId)
+}
+
+func (t *ProductionTrading) GetPortfolio(ctx context.Context, accountId string, currency pb.PortfolioRequest_CurrencyRequest) (*PortfolioResponse, error) {
+	return t.operations.GetPortfolioWithContext(ctx, accountId, currency)
+}
+
+func (t *ProductionTrading) GetOperations(ctx context.Context, req *GetOperationsRequest) (*OperationsResponse, error) {
+	return t.operations.GetOperationsWithContext(ctx, req)
+}
+
+func (t *ProductionTrading) GetOperationsByCursor(ctx context.Context, req *GetOperationsByCursorRequest) (*GetOperationsByCursorResponse, error) {
+	return t.operations.GetOperationsByCursorWithContext(ctx, req)
+}
+
+func (t *ProductionTrading) GetWithdrawLimits(ctx context.Context, accountId string) (*WithdrawLimitsResponse, error) {
+	return t.operations.GetWithdrawLimitsWithContext(ctx, accountId)
+}
+
+// SandboxTrading - Реализация Trading для песочницы
+type SandboxTrading struct {
+	sandbox *SandboxServiceClient
+}
+
+func (t *SandboxTrading) PostOrder(ctx context.Context, req *PostOrderRequest) (*PostOrderResponse, error) {
+	return t.sandbox.PostSandboxOrderWithContext(ctx, req)
+}
+
+func (t *SandboxTrading) ReplaceOrder(ctx context.Context, req *ReplaceOrderRequest) (*PostOrderResponse, error) {
+	return t.sandbox.ReplaceSandboxOrderWithContext(ctx, req)
+}
+
+func (t *SandboxTrading) CancelOrder(ctx context.Context, accountId, orderId string) (*CancelOrderResponse, error) {
+	return t.sandbox.CancelSandboxOrderWithContext(ctx, accountId, orderId)
+}
+
+func (t *SandboxTrading) GetOrderState(ctx context.Context, accountId, orderId string) (*GetOrderStateResponse, error) {
+	return t.sandbox.GetSandboxOrderStateWithContext(ctx, accountId, orderId)
+}
+
+func (t *SandboxTrading) GetOrders(ctx context.Context, accountId string) (*GetOrdersResponse, error) {
+	return t.sandbox.GetSandboxOrdersWithContext(ctx, accountId)
+}
+
+func (t *SandboxTrading) GetPositions(ctx context.Context, accountId string) (*PositionsResponse, error) {
+	return t.sandbox.GetSandboxPositionsWithContext(ctx, accountId)
+}
+
+func (t *SandboxTrading) GetPortfolio(ctx context.Context, accountId string, currency pb.PortfolioRequest_CurrencyRequest) (*PortfolioResponse, error) {
+	return t.sandbox.GetSandboxPortfolioWithContext(ctx, accountId, currency)
+}
+
+func (t *SandboxTrading) GetOperations(ctx context.Context, req *GetOperationsRequest) (*OperationsResponse, error) {
+	return t.sandbox.GetSandboxOperationsWithContext(ctx, req)
+}
+
+func (t *SandboxTrading) GetOperationsByCursor(ctx context.Context, req *GetOperationsByCursorRequest) (*GetOperationsByCursorResponse, error) {
+	return t.sandbox.GetSandboxOperationsByCursorWithContext(ctx, req)
+}
+
+func (t *SandboxTrading) GetWithdrawLimits(ctx context.Context, accountId string) (*WithdrawLimitsResponse, error) {
+	return t.sandbox.GetSandboxWithdrawLimitsWithContext(ctx, accountId)
+}
+
+// PayIn - Пополнение счета в песочнице, на боевом контуре такой операции нет
+func (t *SandboxTrading) PayIn(ctx context.Context, req *SandboxPayInRequest) (*SandboxPayInResponse, error) {
+	return t.sandbox.SandboxPayInWithContext(ctx, req)
+}
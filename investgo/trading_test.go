@@ -0,0 +1,156 @@
+package investgo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// tradingRoute - Метод Trading и методы сервера, в которые он должен попасть на каждом контуре
+type tradingRoute struct {
+	production string
+	sandbox    string
+	call       func() error
+}
+
+// checkTradingRoutes - Каждый метод tr вызывает ровно один метод сервера своего контура и ни одного метода другого
+func checkTradingRoutes(t *testing.T, srv *fake.Server, tr investgo.Trading, accountId string, sandbox bool) {
+	t.Helper()
+	ctx := context.Background()
+	var orderId string
+	routes := []tradingRoute{
+		{"OrdersService/PostOrder", "SandboxService/PostSandboxOrder", func() error {
+			req := limitBuy(srv, 1, 245)
+			req.AccountId = accountId
+			resp, err := tr.PostOrder(ctx, req)
+			orderId = resp.GetOrderId()
+			return err
+		}},
+		{"OrdersService/GetOrderState", "SandboxService/GetSandboxOrderState", func() error {
+			_, err := tr.GetOrderState(ctx, accountId, orderId)
+			return err
+		}},
+		{"OrdersService/GetOrders", "SandboxService/GetSandboxOrders", func() error {
+			_, err := tr.GetOrders(ctx, accountId)
+			return err
+		}},
+		{"OrdersService/ReplaceOrder", "SandboxService/ReplaceSandboxOrder", func() error {
+			resp, err := tr.ReplaceOrder(ctx, &investgo.ReplaceOrderRequest{
+				AccountId: accountId,
+				OrderId:   orderId,
+				Quantity:  2,
+				Price:     fake.Quotation(244),
+			})
+			orderId = resp.GetOrderId()
+			return err
+		}},
+		{"OrdersService/CancelOrder", "SandboxService/CancelSandboxOrder", func() error {
+			_, err := tr.CancelOrder(ctx, accountId, orderId)
+			return err
+		}},
+		{"OperationsService/GetPositions", "SandboxService/GetSandboxPositions", func() error {
+			_, err := tr.GetPositions(ctx, accountId)
+			return err
+		}},
+		{"OperationsService/GetPortfolio", "SandboxService/GetSandboxPortfolio", func() error {
+			_, err := tr.GetPortfolio(ctx, accountId, pb.PortfolioRequest_RUB)
+			return err
+		}},
+		{"OperationsService/GetOperations", "SandboxService/GetSandboxOperations", func() error {
+			_, err := tr.GetOperations(ctx, &investgo.GetOperationsRequest{
+				AccountId: accountId,
+				From:      time.Now().Add(-time.Hour),
+				To:        time.Now().Add(time.Hour),
+			})
+			return err
+		}},
+		{"OperationsService/GetOperationsByCursor", "SandboxService/GetSandboxOperationsByCursor", func() error {
+			_, err := tr.GetOperationsByCursor(ctx, &investgo.GetOperationsByCursorRequest{AccountId: accountId})
+			return err
+		}},
+		{"OperationsService/GetWithdrawLimits", "SandboxService/GetSandboxWithdrawLimits", func() error {
+			_, err := tr.GetWithdrawLimits(ctx, accountId)
+			return err
+		}},
+	}
+	for _, r := range routes {
+		want, other := r.production, r.sandbox
+		if sandbox {
+			want, other = other, want
+		}
+		before := srv.Calls(want)
+		if err := r.call(); err != nil {
+			t.Fatalf("%s: %v", want, err)
+		}
+		if calls := srv.Calls(want) - before; calls != 1 {
+			t.Fatalf("%s calls = %d, want 1", want, calls)
+		}
+		if calls := srv.Calls(other); calls != 0 {
+			t.Fatalf("%s calls = %d, want 0", other, calls)
+		}
+	}
+}
+
+func TestProductionTrading(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	tr := client.NewTrading()
+	if _, ok := tr.(*investgo.ProductionTrading); !ok {
+		t.Fatalf("trading = %T, want *ProductionTrading", tr)
+	}
+	checkTradingRoutes(t, srv, tr, srv.AccountId(), false)
+
+	stops := client.NewStopOrderTrading()
+	if stops == nil {
+		t.Fatal("stop order trading = nil, want StopOrdersService")
+	}
+	ctx := context.Background()
+	resp, err := stops.PostStopOrder(ctx, &investgo.PostStopOrderRequest{
+		InstrumentId:   testFigi,
+		Quantity:       1,
+		StopPrice:      fake.Quotation(240),
+		Direction:      pb.StopOrderDirection_STOP_ORDER_DIRECTION_SELL,
+		AccountId:      srv.AccountId(),
+		ExpirationType: pb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_CANCEL,
+		StopOrderType:  pb.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stops.GetStopOrders(ctx, srv.AccountId()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stops.CancelStopOrder(ctx, srv.AccountId(), resp.GetStopOrderId()); err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{postStopOrderMethod, "StopOrdersService/GetStopOrders", "StopOrdersService/CancelStopOrder"} {
+		if calls := srv.Calls(method); calls != 1 {
+			t.Fatalf("%s calls = %d, want 1", method, calls)
+		}
+	}
+}
+
+func TestSandboxTrading(t *testing.T) {
+	srv := newTestServer(t)
+	accountId := srv.OpenSandboxAccount()
+	if err := srv.PayIn(accountId, fake.Money(100000, "rub")); err != nil {
+		t.Fatal(err)
+	}
+	conf := srv.Config()
+	conf.Environment = investgo.EnvironmentSandbox
+	client := newTestClient(t, conf)
+	tr := client.NewTrading()
+	if _, ok := tr.(*investgo.SandboxTrading); !ok {
+		t.Fatalf("trading = %T, want *SandboxTrading", tr)
+	}
+	checkTradingRoutes(t, srv, tr, accountId, true)
+
+	// в песочнице нет StopOrdersService
+	if stops := client.NewStopOrderTrading(); stops != nil {
+		t.Fatalf("stop order trading = %T, want nil in sandbox", stops)
+	}
+}
@@ -0,0 +1,606 @@
+package investgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+)
+
+// ErrTrailingStopNotFound - Трейлинг-стоп с таким идентификатором не найден
+var ErrTrailingStopNotFound = errors.New("trailing stop not found")
+
+// DefaultTrailingInterval - Период проверки серверных стоп-заявок трейлинг-стопов через GetStopOrders
+const DefaultTrailingInterval = 2 * time.Second
+
+// TrailMode - Способ исполнения трейлинг-стопа
+type TrailMode int
+
+const (
+	// TrailServerStop - Стоп-лосс на сервере, при движении цены переставляется через CancelStopOrder и PostStopOrder.
+	// Позиция защищена, даже если приложение остановлено, но каждое перемещение стоит двух запросов
+	TrailServerStop TrailMode = iota
+	// TrailLocal - Цена стопа хранится в приложении, при ее пересечении выставляется рыночная заявка.
+	// Запросы отправляются только при срабатывании, но без работающего приложения позиция не защищена
+	TrailLocal
+)
+
+// TrailingStopState - Состояние трейлинг-стопа
+type TrailingStopState int
+
+const (
+	// TrailingActive - Трейлинг-стоп следует за ценой
+	TrailingActive TrailingStopState = iota
+	// TrailingTriggered - Стоп сработал: по серверной стоп-заявке есть сделка в OrderTracker или выставлена
+	// рыночная заявка
+	TrailingTriggered
+	// TrailingCancelled - Трейлинг-стоп отменен через Cancel
+	TrailingCancelled
+	// TrailingClosed - Серверная стоп-заявка пропала без сделки в OrderTracker: отменена в обход
+	// TrailingStopManager или истекла. Без WithTrailingTracker так завершается и сработавшая стоп-заявка
+	TrailingClosed
+)
+
+func (s TrailingStopState) String() string {
+	switch s {
+	case TrailingActive:
+		return "active"
+	case TrailingTriggered:
+		return "triggered"
+	case TrailingCancelled:
+		return "cancelled"
+	case TrailingClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("TrailingStopState(%d)", int(s))
+	}
+}
+
+// TrailingStopRequest - Запрос на трейлинг-стоп для позиции
+type TrailingStopRequest struct {
+	// Id - Имя трейлинг-стопа в приложении, если пустой - создается через CreateUid
+	Id           string
+	AccountId    string
+	InstrumentId string
+	// Direction - Направление позиции: BUY - длинная позиция, стоп ниже максимума цены,
+	// SELL - короткая позиция, стоп выше минимума цены
+	Direction pb.OrderDirection
+	// Quantity - Количество лотов, которое закрывается при срабатывании
+	Quantity int64
+	// Distance - Отступ стопа от экстремума цены в единицах цены
+	Distance *pb.Quotation
+	// Percent - Отступ стопа в процентах от экстремума цены, используется, если Distance не задан
+	Percent float64
+	Mode    TrailMode
+	// StartPrice - Начальный экстремум, например цена входа. Если nil - первая последняя цена из MarketDataStream
+	StartPrice *pb.Quotation
+}
+
+// TrailingStop - Состояние трейлинг-стопа
+type TrailingStop struct {
+	Request TrailingStopRequest
+	State   TrailingStopState
+	// HighWaterMark - Экстремум цены: максимум для длинной позиции, минимум для короткой
+	HighWaterMark *pb.Quotation
+	// StopPrice - Текущая цена стопа, округленная к min_price_increment
+	StopPrice *pb.Quotation
+	// StopOrderId - Идентификатор серверной стоп-заявки в режиме TrailServerStop
+	StopOrderId string
+	// OrderId - Идентификатор рыночной заявки, выставленной при срабатывании в режиме TrailLocal
+	OrderId   string
+	UpdatedAt time.Time
+}
+
+// TrailingStopOption - Параметры TrailingStopManager
+type TrailingStopOption func(*TrailingStopManager)
+
+// WithTrailingInterval - Период проверки серверных стоп-заявок
+func WithTrailingInterval(d time.Duration) TrailingStopOption {
+	return func(m *TrailingStopManager) {
+		m.interval = d
+	}
+}
+
+// WithTrailingMinMove - Минимальный сдвиг серверной стоп-заявки в шагах цены, по умолчанию 1. Большее значение
+// уменьшает количество запросов CancelStopOrder и PostStopOrder
+func WithTrailingMinMove(steps int64) TrailingStopOption {
+	return func(m *TrailingStopManager) {
+		if steps > 0 {
+			m.minMove = steps
+		}
+	}
+}
+
+// WithTrailingTracker - OrderTracker с событиями TradesStream, по сделкам которого пропавшая серверная стоп-заявка
+// считается сработавшей. Без него состояние пропавшей стоп-заявки - TrailingClosed
+func WithTrailingTracker(tracker *OrderTracker) TrailingStopOption {
+	return func(m *TrailingStopManager) {
+		m.tracker = tracker
+	}
+}
+
+// WithTrailingCallback - Функция, которая вызывается при каждом изменении трейлинг-стопа
+func WithTrailingCallback(fn func(s TrailingStop)) TrailingStopOption {
+	return func(m *TrailingStopManager) {
+		m.callbacks = append(m.callbacks, fn)
+	}
+}
+
+// WithTrailingLogger - Логгер для ошибок выставления и перемещения стопов
+func WithTrailingLogger(l Logger) TrailingStopOption {
+	return func(m *TrailingStopManager) {
+		m.logger = l
+	}
+}
+
+// TrailingStopManager - Трейлинг-стопы для позиций. Следит за последними ценами из MarketDataStream,
+// запоминает экстремум цены и подтягивает за ним стоп на заданном отступе. Стоп только приближается к цене
+// и никогда не отодвигается
+type TrailingStopManager struct {
+	trading     Trading
+	stops       StopOrderTrading
+	instruments InstrumentSource
+	tracker     *OrderTracker
+	logger      Logger
+	interval    time.Duration
+	minMove     int64
+	callbacks   []func(s TrailingStop)
+
+	// exec - изменения стопов выполняются по одному
+	exec    sync.Mutex
+	mu      sync.Mutex
+	trails  map[string]*trail
+	changed []TrailingStop
+	// missing - время, когда у трейлинг-стопа пропала стоп-заявка без сделки, по Id. Доступ под exec
+	missing map[string]time.Time
+}
+
+// trail - трейлинг-стоп с параметрами инструмента
+type trail struct {
+	TrailingStop
+	figi      string
+	uid       string
+	increment *pb.Quotation
+	// placedAt - время выставления стоп-заявки StopOrderId, сделки по ней ищутся с этого момента
+	placedAt time.Time
+}
+
+// NewTrailingStopManager - Трейлинг-стопы с рыночными заявками через trading, серверными стоп-заявками через stops
+// и шагом цены из instruments. Для режима TrailLocal stops может быть nil
+func NewTrailingStopManager(trading Trading, stops StopOrderTrading, instruments InstrumentSource, opts ...TrailingStopOption) *TrailingStopManager {
+	m := &TrailingStopManager{
+		trading:     trading,
+		stops:       stops,
+		instruments: instruments,
+		interval:    DefaultTrailingInterval,
+		minMove:     1,
+		trails:      make(map[string]*trail),
+		missing:     make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// NewTrailingStopManager - Трейлинг-стопы для контура из конфигурации клиента, серверные стоп-заявки
+// выставляются через StopOrdersService. В песочнице стоп-заявок нет, и доступен только режим TrailLocal
+func (c *Client) NewTrailingStopManager(opts ...TrailingStopOption) *TrailingStopManager {
+	return NewTrailingStopManager(c.NewTrading(), c.NewStopOrderTrading(), c.NewInstrumentsServiceClient(),
+		append([]TrailingStopOption{WithTrailingLogger(c.Logger)}, opts...)...)
+}
+
+// Add - Добавление трейлинг-стопа. Если задан StartPrice, в режиме TrailServerStop стоп-заявка выставляется сразу
+func (m *TrailingStopManager) Add(ctx context.Context, req TrailingStopRequest) (TrailingStop, error) {
+	if err := validateTrailingStop(req, m.stops != nil); err != nil {
+		return TrailingStop{}, err
+	}
+	if req.Id == "" {
+		req.Id = CreateUid()
+	}
+	inst, err := m.instruments.InstrumentWithContext(ctx, req.InstrumentId)
+	if err != nil {
+		return TrailingStop{}, err
+	}
+	if inst.GetMinPriceIncrement().ToDecimal().Sign() <= 0 {
+		return TrailingStop{}, fmt.Errorf("instrument %s: %w", req.InstrumentId, ErrInvalidIncrement)
+	}
+
+	m.exec.Lock()
+	defer m.flush()
+	defer m.exec.Unlock()
+
+	m.mu.Lock()
+	if _, ok := m.trails[req.Id]; ok {
+		m.mu.Unlock()
+		return TrailingStop{}, fmt.Errorf("trailing stop %s already exists", req.Id)
+	}
+	t := &trail{
+		TrailingStop: TrailingStop{Request: req, State: TrailingActive},
+		figi:         inst.GetFigi(),
+		uid:          inst.GetUid(),
+		increment:    inst.GetMinPriceIncrement(),
+	}
+	m.trails[req.Id] = t
+	m.mu.Unlock()
+
+	if req.StartPrice == nil {
+		m.notify(t)
+		return m.snapshot(t), nil
+	}
+	err = m.update(ctx, t, req.StartPrice)
+	return m.snapshot(t), err
+}
+
+// Cancel - Отмена трейлинг-стопа и его серверной стоп-заявки. Позиция не закрывается. Если стоп-заявка уже
+// пропала с сервера, трейлинг-стоп завершается так же, как в Reconcile
+func (m *TrailingStopManager) Cancel(ctx context.Context, id string) error {
+	m.exec.Lock()
+	defer m.flush()
+	defer m.exec.Unlock()
+
+	m.mu.Lock()
+	t, ok := m.trails[id]
+	m.mu.Unlock()
+	if !ok {
+		return ErrTrailingStopNotFound
+	}
+	if t.State != TrailingActive {
+		return nil
+	}
+	if t.StopOrderId != "" {
+		if _, err := m.stops.CancelStopOrder(ctx, t.Request.AccountId, t.StopOrderId); err != nil {
+			if !errors.Is(err, ErrOrderNotFound) {
+				return err
+			}
+			// стоп-заявка пропала раньше отмены
+			_, err := m.vanished(t)
+			return err
+		}
+	}
+	m.mu.Lock()
+	t.StopOrderId = ""
+	m.mu.Unlock()
+	m.setState(t, TrailingCancelled)
+	return nil
+}
+
+// TrailingStop - Состояние трейлинг-стопа по Id
+func (m *TrailingStopManager) TrailingStop(id string) (TrailingStop, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.trails[id]
+	if !ok {
+		return TrailingStop{}, false
+	}
+	return t.TrailingStop, true
+}
+
+// TrailingStops - Состояния всех трейлинг-стопов
+func (m *TrailingStopManager) TrailingStops() []TrailingStop {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stops := make([]TrailingStop, 0, len(m.trails))
+	for _, t := range m.trails {
+		stops = append(stops, t.TrailingStop)
+	}
+	return stops
+}
+
+// Run - Обработка последних цен из updates до отмены ctx или закрытия updates и периодическая проверка серверных
+// стоп-заявок. updates - канал подписчика MarketDataStream, например
+// mdStream.AddSubscriber(investgo.SubscriberOptions{Kinds: []investgo.MarketDataKind{investgo.MarketDataLastPrice}}).Updates()
+func (m *TrailingStopManager) Run(ctx context.Context, updates <-chan *pb.MarketDataResponse) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case resp, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if lp := resp.GetLastPrice(); lp != nil {
+				m.OnLastPrice(ctx, lp)
+			}
+		case <-ticker.C:
+			_ = m.Reconcile(ctx)
+		}
+	}
+}
+
+// OnLastPrice - Обработка последней цены: сдвиг экстремума и стопа, срабатывание стопа в режиме TrailLocal
+func (m *TrailingStopManager) OnLastPrice(ctx context.Context, lp *pb.LastPrice) {
+	m.exec.Lock()
+	defer m.flush()
+	defer m.exec.Unlock()
+
+	m.mu.Lock()
+	var matched []*trail
+	for _, t := range m.trails {
+		if t.State == TrailingActive && (lp.GetFigi() != "" && lp.GetFigi() == t.figi || lp.GetInstrumentUid() != "" && lp.GetInstrumentUid() == t.uid) {
+			matched = append(matched, t)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, t := range matched {
+		if err := m.update(ctx, t, lp.GetPrice()); err != nil {
+			m.error("trailing stop", FieldInstrumentId, t.Request.InstrumentId, FieldAccountId, t.Request.AccountId, FieldError, err)
+		}
+	}
+}
+
+// Reconcile - Проверка серверных стоп-заявок через GetStopOrders: исчезнувшая стоп-заявка считается исполненной,
+// если по ней есть сделка в OrderTracker, невыставленная из-за ошибки - выставляется повторно
+func (m *TrailingStopManager) Reconcile(ctx context.Context) error {
+	m.exec.Lock()
+	defer m.flush()
+	defer m.exec.Unlock()
+
+	m.mu.Lock()
+	accounts := make(map[string][]*trail)
+	for _, t := range m.trails {
+		if t.State == TrailingActive && t.Request.Mode == TrailServerStop && t.StopPrice != nil {
+			accounts[t.Request.AccountId] = append(accounts[t.Request.AccountId], t)
+		}
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for accountId, trails := range accounts {
+		resp, err := m.stops.GetStopOrders(ctx, accountId)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		alive := make(map[string]struct{}, len(resp.GetStopOrders()))
+		for _, so := range resp.GetStopOrders() {
+			alive[so.GetStopOrderId()] = struct{}{}
+		}
+		for _, t := range trails {
+			if t.StopOrderId == "" {
+				if err := m.place(ctx, t, t.StopPrice); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			if _, ok := alive[t.StopOrderId]; !ok {
+				if _, err := m.vanished(t); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// update - новая цена для трейлинг-стопа, вызывается под exec
+func (m *TrailingStopManager) update(ctx context.Context, t *trail, price *pb.Quotation) error {
+	long := t.Request.Direction == pb.OrderDirection_ORDER_DIRECTION_BUY
+	if t.HighWaterMark == nil || long && CompareQuotation(price, t.HighWaterMark) > 0 || !long && CompareQuotation(price, t.HighWaterMark) < 0 {
+		m.mu.Lock()
+		t.HighWaterMark = price
+		m.mu.Unlock()
+	}
+
+	if t.Request.Mode == TrailLocal && t.StopPrice != nil {
+		if long && CompareQuotation(price, t.StopPrice) <= 0 || !long && CompareQuotation(price, t.StopPrice) >= 0 {
+			return m.fire(ctx, t)
+		}
+	}
+
+	stop, err := m.stopPrice(t)
+	if err != nil {
+		return err
+	}
+	// невыставленная из-за ошибки серверная стоп-заявка выставляется по следующей цене
+	retry := t.Request.Mode == TrailServerStop && t.StopOrderId == ""
+	if t.StopPrice != nil && !retry {
+		// стоп двигается только в сторону цены и не меньше чем на minMove шагов
+		move := stop.ToDecimal().Sub(t.StopPrice.ToDecimal())
+		if !long {
+			move = move.Neg()
+		}
+		if move.LessThan(t.increment.ToDecimal().Mul(decimal.NewFromInt(m.minMove))) {
+			return nil
+		}
+	}
+	if t.Request.Mode == TrailServerStop {
+		return m.place(ctx, t, stop)
+	}
+	m.mu.Lock()
+	t.StopPrice = stop
+	m.mu.Unlock()
+	m.notify(t)
+	return nil
+}
+
+// stopPrice - цена стопа на отступе от экстремума, округленная к шагу цены в сторону от цены
+func (m *TrailingStopManager) stopPrice(t *trail) (*pb.Quotation, error) {
+	hwm := t.HighWaterMark.ToDecimal()
+	var distance decimal.Decimal
+	if t.Request.Distance != nil {
+		distance = t.Request.Distance.ToDecimal()
+	} else {
+		distance = hwm.Mul(decimal.NewFromFloat(t.Request.Percent)).Div(decimal.NewFromInt(100))
+	}
+	if t.Request.Direction == pb.OrderDirection_ORDER_DIRECTION_BUY {
+		return RoundQuotation(DecimalToQuotation(hwm.Sub(distance)), t.increment, RoundDown)
+	}
+	return RoundQuotation(DecimalToQuotation(hwm.Add(distance)), t.increment, RoundUp)
+}
+
+// place - перестановка серверной стоп-заявки на цену stop, вызывается под exec. Старая стоп-заявка отменяется
+// до выставления новой, чтобы на сервере не было двух стоп-заявок на весь объем. Если новая стоп-заявка
+// не выставилась, ее выставит следующая цена или Reconcile
+func (m *TrailingStopManager) place(ctx context.Context, t *trail, stop *pb.Quotation) error {
+	req := t.Request
+	if t.StopOrderId != "" {
+		if _, err := m.stops.CancelStopOrder(ctx, req.AccountId, t.StopOrderId); err != nil {
+			if errors.Is(err, ErrOrderNotFound) {
+				// старая стоп-заявка пропала до перестановки
+				_, err = m.vanished(t)
+			}
+			return err
+		}
+	}
+	// до выставления запоминаем цену, чтобы при ошибке стоп-заявка выставилась повторно
+	m.mu.Lock()
+	t.StopOrderId = ""
+	t.StopPrice = stop
+	m.mu.Unlock()
+	placedAt := time.Now()
+	resp, err := m.stops.PostStopOrder(ctx, &PostStopOrderRequest{
+		InstrumentId:   req.InstrumentId,
+		Quantity:       req.Quantity,
+		StopPrice:      stop,
+		Direction:      exitDirection(req.Direction),
+		AccountId:      req.AccountId,
+		ExpirationType: pb.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_CANCEL,
+		StopOrderType:  pb.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS,
+	})
+	if err != nil {
+		m.notify(t)
+		return err
+	}
+	m.mu.Lock()
+	t.StopOrderId = resp.GetStopOrderId()
+	t.placedAt = placedAt
+	m.mu.Unlock()
+	m.notify(t)
+	return nil
+}
+
+// vanished - серверная стоп-заявка пропала. Если по ней есть сделка в OrderTracker, трейлинг-стоп сработал.
+// Если сделки нет, возвращается ошибка, а когда сделка не находится дольше WithTrailingInterval, трейлинг-стоп
+// завершается как TrailingClosed. Без WithTrailingTracker сделку не проверить, и трейлинг-стоп сразу завершается
+// как TrailingClosed. Возвращает true, если трейлинг-стоп завершился, вызывается под exec
+func (m *TrailingStopManager) vanished(t *trail) (bool, error) {
+	req := t.Request
+	if m.tracker == nil {
+		m.setState(t, TrailingClosed)
+		return true, nil
+	}
+	direction := pb.OrderDirection_ORDER_DIRECTION_SELL
+	if req.Direction == pb.OrderDirection_ORDER_DIRECTION_SELL {
+		direction = pb.OrderDirection_ORDER_DIRECTION_BUY
+	}
+	if len(m.tracker.UntrackedTrades(req.AccountId, req.InstrumentId, direction, t.placedAt)) > 0 {
+		m.setState(t, TrailingTriggered)
+		return true, nil
+	}
+	// событие TradesStream может прийти позже, чем стоп-заявка пропадет из GetStopOrders
+	since, ok := m.missing[req.Id]
+	if !ok {
+		since = time.Now()
+		m.missing[req.Id] = since
+	}
+	if time.Since(since) < m.interval {
+		return false, fmt.Errorf("trailing stop %s: stop order %s is gone without a trade", req.Id, t.StopOrderId)
+	}
+	m.error("trailing stop order is gone without a trade", FieldOrderId, t.StopOrderId, FieldInstrumentId, req.InstrumentId)
+	m.setState(t, TrailingClosed)
+	return true, nil
+}
+
+// fire - срабатывание локального стопа: рыночная заявка на закрытие позиции, вызывается под exec
+func (m *TrailingStopManager) fire(ctx context.Context, t *trail) error {
+	req := t.Request
+	direction := pb.OrderDirection_ORDER_DIRECTION_SELL
+	if req.Direction == pb.OrderDirection_ORDER_DIRECTION_SELL {
+		direction = pb.OrderDirection_ORDER_DIRECTION_BUY
+	}
+	resp, err := m.trading.PostOrder(ctx, &PostOrderRequest{
+		InstrumentId: req.InstrumentId,
+		Quantity:     req.Quantity,
+		Direction:    direction,
+		AccountId:    req.AccountId,
+		OrderType:    pb.OrderType_ORDER_TYPE_MARKET,
+		// один ключ на трейлинг-стоп, чтобы повторное срабатывание не выставило вторую заявку
+		OrderId: OrderKey("trailing/" + req.Id),
+	})
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	t.OrderId = resp.GetOrderId()
+	m.mu.Unlock()
+	m.setState(t, TrailingTriggered)
+	return nil
+}
+
+// setState - смена состояния трейлинг-стопа и уведомление
+func (m *TrailingStopManager) setState(t *trail, state TrailingStopState) {
+	if state != TrailingActive {
+		delete(m.missing, t.Request.Id)
+	}
+	m.mu.Lock()
+	t.State = state
+	m.mu.Unlock()
+	m.notify(t)
+}
+
+// notify - постановка изменения в очередь функций WithTrailingCallback, вызывается под exec
+func (m *TrailingStopManager) notify(t *trail) {
+	m.mu.Lock()
+	t.UpdatedAt = time.Now()
+	m.changed = append(m.changed, t.TrailingStop)
+	m.mu.Unlock()
+}
+
+// flush - вызов функций WithTrailingCallback после освобождения exec
+func (m *TrailingStopManager) flush() {
+	m.mu.Lock()
+	changed := m.changed
+	m.changed = nil
+	m.mu.Unlock()
+	for _, s := range changed {
+		for _, fn := range m.callbacks {
+			fn(s)
+		}
+	}
+}
+
+func (m *TrailingStopManager) snapshot(t *trail) TrailingStop {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return t.TrailingStop
+}
+
+// error - ошибка в логгер, если он задан
+func (m *TrailingStopManager) error(msg string, keysAndValues ...any) {
+	if m.logger != nil {
+		logError(m.logger, msg, keysAndValues...)
+	}
+}
+
+// exitDirection - направление стоп-заявки, закрывающей позицию, открытую заявкой в направлении direction
+func exitDirection(direction pb.OrderDirection) pb.StopOrderDirection {
+	if direction == pb.OrderDirection_ORDER_DIRECTION_SELL {
+		return pb.StopOrderDirection_STOP_ORDER_DIRECTION_BUY
+	}
+	return pb.StopOrderDirection_STOP_ORDER_DIRECTION_SELL
+}
+
+// validateTrailingStop - проверка запроса на трейлинг-стоп
+func validateTrailingStop(req TrailingStopRequest, serverStops bool) error {
+	switch {
+	case req.Quantity <= 0:
+		return errors.New("trailing stop quantity must be positive")
+	case req.Direction != pb.OrderDirection_ORDER_DIRECTION_BUY && req.Direction != pb.OrderDirection_ORDER_DIRECTION_SELL:
+		return errors.New("trailing stop direction must be buy or sell")
+	case req.Distance == nil && req.Percent <= 0:
+		return errors.New("trailing stop needs distance or percent")
+	case req.Distance != nil && req.Distance.ToDecimal().Sign() <= 0:
+		return errors.New("trailing stop distance must be positive")
+	case req.Mode == TrailServerStop && !serverStops:
+		return fmt.Errorf("server trailing stop: %w", ErrStopOrdersUnsupported)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package investgo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tinkoff/invest-api-go-sdk/investgo"
+	"github.com/tinkoff/invest-api-go-sdk/investgo/fake"
+	pb "github.com/tinkoff/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func trailingRequest(srv *fake.Server, mode investgo.TrailMode) investgo.TrailingStopRequest {
+	return investgo.TrailingStopRequest{
+		Id:           "trailing-1",
+		AccountId:    srv.AccountId(),
+		InstrumentId: testFigi,
+		Direction:    pb.OrderDirection_ORDER_DIRECTION_BUY,
+		Quantity:     1,
+		Distance:     fake.Quotation(5),
+		Mode:         mode,
+		StartPrice:   fake.Quotation(250),
+	}
+}
+
+// stopPrices - Цены активации активных стоп-заявок счета по идентификаторам
+func stopPrices(t *testing.T, client *investgo.Client, srv *fake.Server) map[string]int64 {
+	t.Helper()
+	resp, err := client.NewStopOrderTrading().GetStopOrders(context.Background(), srv.AccountId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make(map[string]int64)
+	for _, so := range resp.GetStopOrders() {
+		res[so.GetStopOrderId()] = so.GetStopPrice().GetUnits()
+	}
+	return res
+}
+
+// moveTo - Последняя цена price на сервере и в трейлинг-стопах
+func moveTo(t *testing.T, srv *fake.Server, m *investgo.TrailingStopManager, price int64) {
+	t.Helper()
+	if err := srv.SetLastPrice(testFigi, fake.Quotation(price)); err != nil {
+		t.Fatal(err)
+	}
+	m.OnLastPrice(context.Background(), &pb.LastPrice{Figi: testFigi, Price: fake.Quotation(price)})
+}
+
+func TestTrailingServerStopFollowsPrice(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	if _, err := client.NewOrdersServiceClient().PostOrder(marketBuy(srv, "", 1)); err != nil {
+		t.Fatal(err)
+	}
+	m := client.NewTrailingStopManager(investgo.WithTrailingInterval(time.Hour),
+		investgo.WithTrailingTracker(runStreamTracker(t, client, srv)))
+
+	first, err := m.Add(context.Background(), trailingRequest(srv, investgo.TrailServerStop))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stopPrices(t, client, srv); len(got) != 1 || got[first.StopOrderId] != 245 {
+		t.Fatalf("stop orders = %v, want %s at 245", got, first.StopOrderId)
+	}
+
+	// цена выросла: старая стоп-заявка отменена, новая выставлена
+	moveTo(t, srv, m, 260)
+	moved, _ := m.TrailingStop("trailing-1")
+	if moved.StopOrderId == first.StopOrderId || moved.StopPrice.GetUnits() != 255 {
+		t.Fatalf("trailing stop = %+v, want a new stop order at 255", moved)
+	}
+	if got := stopPrices(t, client, srv); len(got) != 1 || got[moved.StopOrderId] != 255 {
+		t.Fatalf("stop orders = %v, want %s at 255", got, moved.StopOrderId)
+	}
+
+	// стоп не отодвигается от цены
+	moveTo(t, srv, m, 252)
+	if got, _ := m.TrailingStop("trailing-1"); got.StopOrderId != moved.StopOrderId {
+		t.Fatalf("stop order %s moved back", got.StopOrderId)
+	}
+
+	// стоп-заявка сработала на сервере, ее сделка приходит в TradesStream
+	if err := srv.SetLastPrice(testFigi, fake.Quotation(254)); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "trailing stop was not triggered", func() bool {
+		_ = m.Reconcile(context.Background())
+		got, _ := m.TrailingStop("trailing-1")
+		return got.State == investgo.TrailingTriggered
+	})
+	if pos := srv.PositionBalance(srv.AccountId(), testFigi); pos != 0 {
+		t.Fatalf("position = %d, want closed", pos)
+	}
+}
+
+func TestTrailingRetriesStopOnPostError(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	m := client.NewTrailingStopManager(investgo.WithTrailingInterval(time.Hour))
+	if _, err := m.Add(context.Background(), trailingRequest(srv, investgo.TrailServerStop)); err != nil {
+		t.Fatal(err)
+	}
+
+	// старая стоп-заявка отменена до выставления новой, новая не выставилась
+	srv.InjectError(postStopOrderMethod, status.Error(codes.InvalidArgument, "invalid"))
+	moveTo(t, srv, m, 260)
+	got, _ := m.TrailingStop("trailing-1")
+	if got.StopOrderId != "" || got.StopPrice.GetUnits() != 255 {
+		t.Fatalf("trailing stop = %+v, want no stop order at 255", got)
+	}
+	if stops := stopPrices(t, client, srv); len(stops) != 0 {
+		t.Fatalf("stop orders = %v, want none", stops)
+	}
+
+	// сверка выставляет стоп-заявку повторно
+	if err := m.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = m.TrailingStop("trailing-1")
+	if stops := stopPrices(t, client, srv); len(stops) != 1 || stops[got.StopOrderId] != 255 {
+		t.Fatalf("stop orders = %v, want %s at 255", stops, got.StopOrderId)
+	}
+
+	// следующая цена тоже выставляет стоп-заявку повторно, без сдвига экстремума
+	srv.InjectError(postStopOrderMethod, status.Error(codes.InvalidArgument, "invalid"))
+	moveTo(t, srv, m, 261)
+	moveTo(t, srv, m, 261)
+	got, _ = m.TrailingStop("trailing-1")
+	if stops := stopPrices(t, client, srv); len(stops) != 1 || stops[got.StopOrderId] != 256 {
+		t.Fatalf("stop orders = %v, want %s at 256", stops, got.StopOrderId)
+	}
+}
+
+func TestTrailingStopGoneWithoutTrade(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	stops := client.NewStopOrderTrading()
+
+	// без OrderTracker сделку не проверить: трейлинг-стоп закрыт
+	m := client.NewTrailingStopManager(investgo.WithTrailingInterval(time.Hour))
+	s, err := m.Add(context.Background(), trailingRequest(srv, investgo.TrailServerStop))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stops.CancelStopOrder(context.Background(), srv.AccountId(), s.StopOrderId); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.TrailingStop("trailing-1"); got.State != investgo.TrailingClosed {
+		t.Fatalf("state = %v, want closed", got.State)
+	}
+
+	// с OrderTracker сделка ожидается WithTrailingInterval, затем трейлинг-стоп закрыт
+	m = client.NewTrailingStopManager(investgo.WithTrailingInterval(50*time.Millisecond),
+		investgo.WithTrailingTracker(runStreamTracker(t, client, srv)))
+	if s, err = m.Add(context.Background(), trailingRequest(srv, investgo.TrailServerStop)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stops.CancelStopOrder(context.Background(), srv.AccountId(), s.StopOrderId); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reconcile(context.Background()); err == nil {
+		t.Fatal("want error for the stop order gone without a trade")
+	}
+	if got, _ := m.TrailingStop("trailing-1"); got.State != investgo.TrailingActive {
+		t.Fatalf("state = %v, want active until the trade is awaited", got.State)
+	}
+	eventually(t, "trailing stop was not closed", func() bool {
+		_ = m.Reconcile(context.Background())
+		got, _ := m.TrailingStop("trailing-1")
+		return got.State == investgo.TrailingClosed
+	})
+}
+
+func TestTrailingLocal(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t, srv.Config())
+	if _, err := client.NewOrdersServiceClient().PostOrder(marketBuy(srv, "", 1)); err != nil {
+		t.Fatal(err)
+	}
+	m := client.NewTrailingStopManager()
+	if _, err := m.Add(context.Background(), trailingRequest(srv, investgo.TrailLocal)); err != nil {
+		t.Fatal(err)
+	}
+	moveTo(t, srv, m, 260)
+	if got, _ := m.TrailingStop("trailing-1"); got.State != investgo.TrailingActive || got.StopPrice.GetUnits() != 255 {
+		t.Fatalf("trailing stop = %+v, want active at 255", got)
+	}
+
+	moveTo(t, srv, m, 255)
+	got, _ := m.TrailingStop("trailing-1")
+	if got.State != investgo.TrailingTriggered || got.OrderId == "" {
+		t.Fatalf("trailing stop = %+v, want triggered with a market order", got)
+	}
+	if pos := srv.PositionBalance(srv.AccountId(), testFigi); pos != 0 {
+		t.Fatalf("position = %d, want closed", pos)
+	}
+	if stops := stopPrices(t, client, srv); len(stops) != 0 {
+		t.Fatalf("stop orders = %v, want none in local mode", stops)
+	}
+}
+
+func TestTrailingSandboxLocalOnly(t *testing.T) {
+	srv := newTestServer(t)
+	conf := srv.Config()
+	conf.Environment = investgo.EnvironmentSandbox
+	m := newTestClient(t, conf).NewTrailingStopManager()
+
+	_, err := m.Add(context.Background(), trailingRequest(srv, investgo.TrailServerStop))
+	if !errors.Is(err, investgo.ErrStopOrdersUnsupported) {
+		t.Fatalf("err = %v, want ErrStopOrdersUnsupported", err)
+	}
+}
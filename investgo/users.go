@@ -18,8 +18,13 @@ type UsersServiceClient struct {
 
 // GetAccounts - Метод получения счетов пользователя
 func (us *UsersServiceClient) GetAccounts() (*GetAccountsResponse, error) {
+	return us.GetAccountsWithContext(us.ctx)
+}
+
+// GetAccountsWithContext - Метод получения счетов пользователя
+func (us *UsersServiceClient) GetAccountsWithContext(ctx context.Context) (*GetAccountsResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := us.pbClient.GetAccounts(us.ctx, &pb.GetAccountsRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
+	resp, err := us.pbClient.GetAccounts(ctx, &pb.GetAccountsRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
 		header = trailer
 	}
@@ -31,8 +36,13 @@ func (us *UsersServiceClient) GetAccounts() (*GetAccountsResponse, error) {
 
 // GetMarginAttributes - Расчёт маржинальных показателей по счёту
 func (us *UsersServiceClient) GetMarginAttributes(accountId string) (*GetMarginAttributesResponse, error) {
+	return us.GetMarginAttributesWithContext(us.ctx, accountId)
+}
+
+// GetMarginAttributesWithContext - Расчёт маржинальных показателей по счёту
+func (us *UsersServiceClient) GetMarginAttributesWithContext(ctx context.Context, accountId string) (*GetMarginAttributesResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := us.pbClient.GetMarginAttributes(us.ctx, &pb.GetMarginAttributesRequest{
+	resp, err := us.pbClient.GetMarginAttributes(ctx, &pb.GetMarginAttributesRequest{
 		AccountId: accountId,
 	}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
@@ -46,8 +56,13 @@ func (us *UsersServiceClient) GetMarginAttributes(accountId string) (*GetMarginA
 
 // GetUserTariff - Запрос тарифа пользователя
 func (us *UsersServiceClient) GetUserTariff() (*GetUserTariffResponse, error) {
+	return us.GetUserTariffWithContext(us.ctx)
+}
+
+// GetUserTariffWithContext - Запрос тарифа пользователя
+func (us *UsersServiceClient) GetUserTariffWithContext(ctx context.Context) (*GetUserTariffResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := us.pbClient.GetUserTariff(us.ctx, &pb.GetUserTariffRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
+	resp, err := us.pbClient.GetUserTariff(ctx, &pb.GetUserTariffRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
 		header = trailer
 	}
@@ -59,8 +74,13 @@ func (us *UsersServiceClient) GetUserTariff() (*GetUserTariffResponse, error) {
 
 // GetInfo - Метод получения информации о пользователе
 func (us *UsersServiceClient) GetInfo() (*GetInfoResponse, error) {
+	return us.GetInfoWithContext(us.ctx)
+}
+
+// GetInfoWithContext - Метод получения информации о пользователе
+func (us *UsersServiceClient) GetInfoWithContext(ctx context.Context) (*GetInfoResponse, error) {
 	var header, trailer metadata.MD
-	resp, err := us.pbClient.GetInfo(us.ctx, &pb.GetInfoRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
+	resp, err := us.pbClient.GetInfo(ctx, &pb.GetInfoRequest{}, grpc.Header(&header), grpc.Trailer(&trailer))
 	if err != nil {
 		header = trailer
 	}
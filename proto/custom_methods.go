@@ -3,6 +3,8 @@ package investapi
 import (
 	"fmt"
 	"math"
+
+	"github.com/shopspring/decimal"
 )
 
 // ToFloat - get value as float64 number
@@ -29,6 +31,22 @@ func (mv *MoneyValue) ToFloat() float64 {
 	return float64(0)
 }
 
+// ToDecimal - get value as decimal.Decimal without loss of precision
+func (q *Quotation) ToDecimal() decimal.Decimal {
+	if q != nil {
+		return decimal.New(q.Units, 0).Add(decimal.New(int64(q.Nano), -9))
+	}
+	return decimal.Zero
+}
+
+// ToDecimal - get value as decimal.Decimal without loss of precision
+func (mv *MoneyValue) ToDecimal() decimal.Decimal {
+	if mv != nil {
+		return decimal.New(mv.Units, 0).Add(decimal.New(int64(mv.Nano), -9))
+	}
+	return decimal.Zero
+}
+
 // ToCSV - return historic candle in csv format (time in unix): time;open;close;high;low;volume
 func (hc *HistoricCandle) ToCSV() string {
 	return fmt.Sprintf("%v;%.9f;%.9f;%.9f;%.9f;%v", hc.GetTime().AsTime().Unix(), hc.GetOpen().ToFloat(),
@@ -48,8 +48,21 @@ func BackoffExponential(scalar time.Duration) BackoffFunc {
 
 // BackoffExponentialWithJitter creates an exponential backoff like
 // BackoffExponential does, but adds jitter.
-//func BackoffExponentialWithJitter(scalar time.Duration, jitterFraction float64) BackoffFunc {
-//	return func(ctx context.Context, attempt uint) time.Duration {
-//		return jitterUp(scalar*time.Duration(exponentBase2(attempt)), jitterFraction)
-//	}
-//}
+func BackoffExponentialWithJitter(scalar time.Duration, jitterFraction float64) BackoffFunc {
+	return func(ctx context.Context, attempt uint) time.Duration {
+		return jitterUp(scalar*time.Duration(exponentBase2(attempt)), jitterFraction)
+	}
+}
+
+// BackoffExponentialWithJitterBounded creates an exponential backoff like
+// BackoffExponentialWithJitter does, but the wait before adding jitter never exceeds maxBackoff.
+// A maxBackoff of 0 disables the bound.
+func BackoffExponentialWithJitterBounded(scalar time.Duration, jitterFraction float64, maxBackoff time.Duration) BackoffFunc {
+	return func(ctx context.Context, attempt uint) time.Duration {
+		backoff := scalar * time.Duration(exponentBase2(attempt))
+		if maxBackoff > 0 && (backoff > maxBackoff || backoff < 0) {
+			backoff = maxBackoff
+		}
+		return jitterUp(backoff, jitterFraction)
+	}
+}
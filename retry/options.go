@@ -44,9 +44,9 @@ type OnRetryCallback func(ctx context.Context, attempt uint, err error)
 // Disable disables the retry behaviour on this call, or this interceptor.
 //
 // Its semantically the same to `WithMax`
-//func Disable() CallOption {
-//	return WithMax(0)
-//}
+func Disable() CallOption {
+	return WithMax(0)
+}
 
 // WithMax sets the maximum number of retries on this call, or this interceptor.
 func WithMax(maxRetries uint) CallOption {
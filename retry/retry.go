@@ -145,8 +145,8 @@ func UnaryClientInterceptorRE(optFuncs ...CallOption) grpc.UnaryClientIntercepto
 // changed through options (e.g. WithMax) on creation of the interceptor or on call (through grpc.CallOptions).
 //
 // Retry logic is available *only for ServerStreams*, i.e. 1:n streams, as the internal logic needs
-// to buffer the messages sent by the client. If retry is enabled on any other streams (ClientStreams,
-// BidiStreams), the retry interceptor will fail the call.
+// to buffer the messages sent by the client. Other streams (ClientStreams, BidiStreams) are passed
+// through to the streamer without retries.
 func StreamClientInterceptor(optFuncs ...CallOption) grpc.StreamClientInterceptor {
 	intOpts := reuseOrNewWithCallOptions(defaultOptions, optFuncs)
 	return func(parentCtx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
@@ -156,9 +156,9 @@ func StreamClientInterceptor(optFuncs ...CallOption) grpc.StreamClientIntercepto
 		if callOpts.max == 0 {
 			return streamer(parentCtx, desc, cc, method, grpcOpts...)
 		}
-		//if desc.ClientStreams {
-		//	return nil, status.Errorf(codes.Unimplemented, "grpc_retry: cannot retry on ClientStreams, set grpc_retry.Disable()")
-		//}
+		if desc.ClientStreams {
+			return streamer(parentCtx, desc, cc, method, grpcOpts...)
+		}
 
 		var lastErr error
 		for attempt := uint(0); attempt < callOpts.max; attempt++ {
@@ -328,6 +328,10 @@ func (s *retryingStream) reestablishStreamAndResendBuffer(callCtx context.Contex
 func waitRetryBackoff(attempt uint, parentCtx context.Context, callOpts *options) error {
 	var waitTime time.Duration = 0
 	if attempt > 0 {
+		// the parent context may be cancelled between attempts, don't make another call in that case.
+		if err := parentCtx.Err(); err != nil {
+			return contextErrToGrpcErr(err)
+		}
 		waitTime = callOpts.backoffFunc(parentCtx, attempt)
 	}
 	if waitTime > 0 {
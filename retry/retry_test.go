@@ -0,0 +1,40 @@
+package retry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tinkoff/invest-api-go-sdk/retry"
+	"google.golang.org/grpc"
+)
+
+type stubStream struct {
+	grpc.ClientStream
+}
+
+func TestStreamClientInterceptorPassesClientStreams(t *testing.T) {
+	interceptor := retry.StreamClientInterceptor(retry.WithMax(3))
+	want := &stubStream{}
+	calls := 0
+	streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		calls++
+		return want, nil
+	}
+
+	for _, desc := range []*grpc.StreamDesc{
+		{ClientStreams: true},
+		{ClientStreams: true, ServerStreams: true},
+	} {
+		calls = 0
+		got, err := interceptor(context.Background(), desc, nil, "/test.Service/Stream", streamer)
+		if err != nil {
+			t.Fatalf("desc %+v: %v", desc, err)
+		}
+		if got != want {
+			t.Fatalf("desc %+v: stream is wrapped, want the stream from streamer", desc)
+		}
+		if calls != 1 {
+			t.Fatalf("desc %+v: streamer calls = %d, want 1", desc, calls)
+		}
+	}
+}